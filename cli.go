@@ -0,0 +1,268 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"fraudbase/internal/database"
+	"fraudbase/internal/importacao"
+	"fraudbase/internal/jobs"
+	"fraudbase/internal/repository"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const usoCLI = `Uso:
+  fraudbase                           inicia a API (aplica migrações pendentes)
+  fraudbase migrate up                aplica todas as migrações pendentes
+  fraudbase migrate down [N]          reverte as últimas N migrações (padrão 1)
+  fraudbase migrate status            lista as migrações e se já foram aplicadas
+  fraudbase backfill identificadores  preenche CPF, telefone, PIX e conta normalizados dos registros antigos
+  fraudbase audit verify              verifica a cadeia de hashes e os checkpoints da auditoria
+  fraudbase audit checkpoint          assina agora um checkpoint da auditoria (requer AUDIT_SIGNING_KEY)
+  fraudbase ingest --watch PASTA      importa os relatórios deixados em PASTA (fraudbase ingest -h para as opções)`
+
+// executarComando trata os subcomandos de linha de comando e retorna o código de saída
+func executarComando(db *sql.DB, args []string) int {
+	switch args[0] {
+	case "migrate":
+		return comandoMigrate(db, args[1:])
+	case "audit":
+		return comandoAudit(db, args[1:])
+	case "backfill":
+		return comandoBackfill(db, args[1:])
+	case "ingest":
+		return comandoIngest(db, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Comando desconhecido: %s\n\n%s\n", args[0], usoCLI)
+		return 2
+	}
+}
+
+func comandoMigrate(db *sql.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usoCLI)
+		return 2
+	}
+
+	switch args[0] {
+	case "up":
+		if err := database.MigrateUp(db); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao aplicar migrações: %v\n", err)
+			return 1
+		}
+		if err := database.MigrarCamposTipados(db); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao converter campos tipados: %v\n", err)
+			return 1
+		}
+		if err := database.NormalizarIdentificadores(db); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao normalizar CPFs e telefones: %v\n", err)
+			return 1
+		}
+
+	case "down":
+		passos := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Número de migrações inválido: %s\n", args[1])
+				return 2
+			}
+			passos = n
+		}
+		if err := database.MigrateDown(db, passos); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao reverter migrações: %v\n", err)
+			return 1
+		}
+
+	case "status":
+		status, err := database.MigrationStatus(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao consultar migrações: %v\n", err)
+			return 1
+		}
+		for _, s := range status {
+			aplicada := "pendente"
+			if s.Aplicada {
+				aplicada = "aplicada em " + s.AplicadaEm.Format("02/01/2006 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s  %s\n", s.Versao, s.Nome, aplicada)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Subcomando desconhecido: migrate %s\n\n%s\n", args[0], usoCLI)
+		return 2
+	}
+
+	return 0
+}
+
+func comandoAudit(db *sql.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usoCLI)
+		return 2
+	}
+
+	repo := repository.NewAuditoriaRepository(db)
+
+	switch args[0] {
+	case "verify":
+		resultado, err := repo.VerificarCadeia()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao verificar cadeia de auditoria: %v\n", err)
+			return 1
+		}
+
+		fmt.Printf("Registros verificados:   %d\n", resultado.RegistrosVerificados)
+		fmt.Printf("Checkpoints verificados: %d\n", resultado.CheckpointsVerificados)
+		if !resultado.AssinaturasVerificadas {
+			fmt.Println("Aviso: sem AUDIT_PUBLIC_KEY/AUDIT_SIGNING_KEY, as assinaturas dos checkpoints não foram conferidas")
+		}
+		if !resultado.Valida {
+			falha := resultado.PrimeiraFalha
+			fmt.Printf("CADEIA QUEBRADA: registro %d, checkpoint %d: %s\n", falha.RegistroID, falha.CheckpointID, falha.Motivo)
+			return 1
+		}
+		fmt.Println("Cadeia de auditoria íntegra")
+
+	case "checkpoint":
+		criado, err := repo.CriarCheckpoint()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao criar checkpoint: %v\n", err)
+			return 1
+		}
+		if !criado {
+			fmt.Println("Nenhum registro novo desde o último checkpoint")
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Subcomando desconhecido: audit %s\n\n%s\n", args[0], usoCLI)
+		return 2
+	}
+
+	return 0
+}
+
+func comandoBackfill(db *sql.DB, args []string) int {
+	if len(args) == 0 || args[0] != "identificadores" {
+		fmt.Fprintln(os.Stderr, usoCLI)
+		return 2
+	}
+
+	if err := database.NormalizarIdentificadores(db); err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao normalizar CPFs e telefones: %v\n", err)
+		return 1
+	}
+	fmt.Println("CPFs e telefones normalizados")
+	return 0
+}
+
+// comandoIngest observa uma pasta e importa os relatórios deixados nela em nome
+// de um usuário de serviço, até receber SIGINT ou SIGTERM
+func comandoIngest(db *sql.DB, args []string) int {
+	opcoes := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	pasta := opcoes.String("watch", "", "pasta observada; os arquivos processados vão para done/ ou failed/ dentro dela")
+	usuario := opcoes.String("usuario", os.Getenv("INGEST_USER"), "login do usuário de serviço em nome do qual os relatórios são importados (INGEST_USER)")
+	estado := opcoes.String("estado", os.Getenv("INGEST_ESTADO"), "UF usada como sufixo do número do BO (INGEST_ESTADO; padrão: a do usuário)")
+	perfil := opcoes.String("perfil", "", "nome do perfil de importação (padrão: o perfil padrão)")
+	padrao := opcoes.String("padrao", jobs.PadraoIngestaoPasta, "padrão do nome dos arquivos importados")
+	modo := opcoes.String("modo", repository.ModoInclusao, "modo de importação: inclusao ou atualizacao")
+	parcial := opcoes.Bool("parcial", false, "gravação parcial: descarta só os lotes com erro")
+	intervalo := opcoes.Duration("intervalo", time.Minute, "intervalo entre as varreduras da pasta")
+	if err := opcoes.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *pasta == "" || *usuario == "" {
+		fmt.Fprintln(os.Stderr, "Informe a pasta (--watch) e o usuário de serviço (--usuario ou INGEST_USER)")
+		opcoes.Usage()
+		return 2
+	}
+	if *modo != repository.ModoInclusao && *modo != repository.ModoAtualizacao {
+		fmt.Fprintf(os.Stderr, "Modo de importação inválido: %s (use inclusao ou atualizacao)\n", *modo)
+		return 2
+	}
+
+	// A ingestão não aplica migrações: o banco precisa já estar na versão deste binário
+	status, err := database.MigrationStatus(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao consultar migrações: %v\n", err)
+		return 1
+	}
+	pendentes := 0
+	for _, s := range status {
+		if !s.Aplicada {
+			pendentes++
+		}
+	}
+	if pendentes > 0 {
+		fmt.Fprintf(os.Stderr, "Há %d migrações pendentes: execute 'fraudbase migrate up' (ou inicie a API) antes da ingestão\n", pendentes)
+		return 1
+	}
+
+	servico, err := repository.NewUserRepository(db).GetUserByLogin(*usuario)
+	if err == sql.ErrNoRows {
+		fmt.Fprintf(os.Stderr, "Usuário de serviço não encontrado: %s\n", *usuario)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao consultar usuário de serviço: %v\n", err)
+		return 1
+	}
+	if *estado == "" {
+		*estado = servico.Estado
+	}
+
+	perfilImportacao, err := importacao.BuscarPerfil(repository.NewPerfilImportacaoRepository(db), *perfil)
+	if errors.Is(err, repository.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "Perfil de importação não encontrado: %s\n", *perfil)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao obter perfil de importação: %v\n", err)
+		return 1
+	}
+
+	pastaAbsoluta, err := filepath.Abs(*pasta)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Pasta inválida %s: %v\n", *pasta, err)
+		return 1
+	}
+	importacoes := repository.NewImportacaoRepository(db, jobs.NomeProcesso("ingest:"+pastaAbsoluta))
+	config := jobs.ConfigIngestaoPasta{
+		Pasta:     *pasta,
+		Padrao:    *padrao,
+		Autor:     repository.Autor{UsuarioID: servico.ID, Login: servico.Login, Admin: servico.IsAdmin},
+		Estado:    *estado,
+		Perfil:    perfilImportacao,
+		Opcoes:    repository.OpcoesImportacao{Modo: *modo, GravacaoParcial: *parcial},
+		Intervalo: *intervalo,
+	}
+	importacaoJob := jobs.NewImportacaoJob(importacoes, repository.NewRelatorioRepository(db))
+	defer importacaoJob.Encerrar()
+	ingestao, err := jobs.NewIngestaoPasta(config, importacoes, importacaoJob)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao preparar a pasta %s: %v\n", *pasta, err)
+		return 1
+	}
+	importacaoJob.InterromperPendentes()
+
+	parar := make(chan struct{})
+	sinais := make(chan os.Signal, 1)
+	signal.Notify(sinais, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sinais
+		fmt.Println("Encerrando após a importação em andamento...")
+		close(parar)
+	}()
+
+	ingestao.Executar(parar)
+	return 0
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+
+	"fraudbase/internal/normalize"
+)
+
+// tamanhoLoteCamposTipados define quantos registros são convertidos por transação
+const tamanhoLoteCamposTipados = 1000
+
+// MigrarCamposTipados preenche as colunas tipadas dos registros ainda não convertidos,
+// interpretando os formatos brasileiros. Valores não reconhecidos ficam NULL e são
+// registrados em tabela_estelionato_conversao_erros para revisão.
+func MigrarCamposTipados(db *sql.DB) error {
+	totalConvertidos := 0
+	totalErros := 0
+
+	for {
+		convertidos, erros, err := migrarLoteCamposTipados(db)
+		if err != nil {
+			log.Printf("Erro ao converter campos tipados: %v", err)
+			return err
+		}
+		if convertidos == 0 {
+			break
+		}
+
+		totalConvertidos += convertidos
+		totalErros += erros
+		log.Printf("Campos tipados: %d registros convertidos até agora...", totalConvertidos)
+	}
+
+	if totalConvertidos > 0 {
+		log.Printf("Conversão de campos tipados concluída: %d registros, %d valores não reconhecidos (ver tabela_estelionato_conversao_erros)",
+			totalConvertidos, totalErros)
+	}
+
+	return nil
+}
+
+func migrarLoteCamposTipados(db *sql.DB) (int, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT pa.id, pa.ocorrencia_id, pa.pessoa_id,
+			COALESCE(o.data_fato, ''), COALESCE(pe.nascimento, ''), COALESCE(pa.valor, ''),
+			COALESCE(o.latitude_fato, ''), COALESCE(o.longitude_fato, '')
+		FROM participacoes pa
+		JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+		JOIN pessoas pe ON pe.id = pa.pessoa_id
+		WHERE NOT pa.campos_tipados
+		ORDER BY pa.id
+		LIMIT $1
+		FOR UPDATE OF pa SKIP LOCKED`, tamanhoLoteCamposTipados)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	type registroPendente struct {
+		id           int
+		ocorrenciaID int
+		pessoaID     int
+		valores      [5]string
+	}
+	var pendentes []registroPendente
+	for rows.Next() {
+		var p registroPendente
+		if err := rows.Scan(&p.id, &p.ocorrenciaID, &p.pessoaID, &p.valores[0], &p.valores[1], &p.valores[2], &p.valores[3], &p.valores[4]); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		pendentes = append(pendentes, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	if len(pendentes) == 0 {
+		return 0, 0, nil
+	}
+
+	stmtOcorrencia, err := tx.Prepare(`
+		UPDATE ocorrencias SET data_fato_ts = $1, latitude_fato_num = $2, longitude_fato_num = $3
+		WHERE id = $4`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmtOcorrencia.Close()
+
+	stmtPessoa, err := tx.Prepare(`UPDATE pessoas SET nascimento_data = $1 WHERE id = $2`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmtPessoa.Close()
+
+	stmtParticipacao, err := tx.Prepare(`UPDATE participacoes SET valor_numerico = $1, campos_tipados = TRUE WHERE id = $2`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmtParticipacao.Close()
+
+	stmtErro, err := tx.Prepare(`INSERT INTO tabela_estelionato_conversao_erros (registro_id, campo, valor) VALUES ($1, $2, $3)`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmtErro.Close()
+
+	erros := 0
+	for _, p := range pendentes {
+		campos := ConverterCamposTipados(p.valores[0], p.valores[1], p.valores[2], p.valores[3], p.valores[4])
+
+		if _, err := stmtOcorrencia.Exec(campos.DataFato, campos.Latitude, campos.Longitude, p.ocorrenciaID); err != nil {
+			return 0, 0, err
+		}
+		if _, err := stmtPessoa.Exec(campos.Nascimento, p.pessoaID); err != nil {
+			return 0, 0, err
+		}
+		if _, err := stmtParticipacao.Exec(campos.Valor, p.id); err != nil {
+			return 0, 0, err
+		}
+
+		for _, campo := range campos.Invalidos {
+			if _, err := stmtErro.Exec(p.id, campo.Campo, campo.Valor); err != nil {
+				return 0, 0, err
+			}
+			erros++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return len(pendentes), erros, nil
+}
+
+// CampoInvalido descreve um valor textual que não pôde ser convertido
+type CampoInvalido struct {
+	Campo string
+	Valor string
+}
+
+// CamposTipados contém os valores convertidos prontos para gravação (NULL quando ausentes)
+type CamposTipados struct {
+	DataFato   sql.NullTime
+	Nascimento sql.NullString // DATE no formato AAAA-MM-DD
+	Valor      sql.NullFloat64
+	Latitude   sql.NullFloat64
+	Longitude  sql.NullFloat64
+	Invalidos  []CampoInvalido
+}
+
+// ConverterCamposTipados converte os campos textuais de um registro para as colunas
+// tipadas. É usada tanto pela migração quanto pelos repositórios na inserção.
+func ConverterCamposTipados(dataFato, nascimento, valor, latitude, longitude string) CamposTipados {
+	var c CamposTipados
+
+	if t, ok := normalize.Data(dataFato); ok {
+		c.DataFato = sql.NullTime{Time: t, Valid: true}
+	} else if dataFato != "" {
+		c.Invalidos = append(c.Invalidos, CampoInvalido{Campo: "data_fato", Valor: dataFato})
+	}
+
+	if t, ok := normalize.Data(nascimento); ok {
+		c.Nascimento = sql.NullString{String: t.Format("2006-01-02"), Valid: true}
+	} else if nascimento != "" {
+		c.Invalidos = append(c.Invalidos, CampoInvalido{Campo: "nascimento", Valor: nascimento})
+	}
+
+	if v, ok := normalize.Valor(valor); ok && v < 1e12 {
+		c.Valor = sql.NullFloat64{Float64: v, Valid: true}
+	} else if valor != "" {
+		c.Invalidos = append(c.Invalidos, CampoInvalido{Campo: "valor", Valor: valor})
+	}
+
+	if v, ok := normalize.Coordenada(latitude); ok && v >= -90 && v <= 90 {
+		c.Latitude = sql.NullFloat64{Float64: v, Valid: true}
+	} else if latitude != "" {
+		c.Invalidos = append(c.Invalidos, CampoInvalido{Campo: "latitude_fato", Valor: latitude})
+	}
+
+	if v, ok := normalize.Coordenada(longitude); ok {
+		c.Longitude = sql.NullFloat64{Float64: v, Valid: true}
+	} else if longitude != "" {
+		c.Invalidos = append(c.Invalidos, CampoInvalido{Campo: "longitude_fato", Valor: longitude})
+	}
+
+	return c
+}
@@ -10,6 +10,8 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// ConnectDB abre o pool de conexões com o PostgreSQL. O schema é gerenciado
+// separadamente por MigrateUp (ver migrate.go).
 func ConnectDB() (*sql.DB, error) {
 	log.Println("Iniciando conexão com o banco...")
 	
@@ -59,37 +61,5 @@ func ConnectDB() (*sql.DB, error) {
 	}
 
 	log.Println("Conexão estabelecida com sucesso!")
-
-	// Executar migrações automaticamente
-	log.Println("Executando migrações do banco de dados...")
-	if err := RunMigrations(db); err != nil {
-		log.Printf("Erro ao executar migrações: %v", err)
-		return nil, err
-	}
-
-	// Atualizar estrutura da tabela usuarios
-	if err := UpdateUsersTableStructure(db); err != nil {
-		log.Printf("Aviso: Erro ao atualizar estrutura da tabela usuarios: %v", err)
-	}
-
-	// Atualizar estrutura da tabela se necessário (para corrigir tamanhos de campos)
-	if err := UpdateTableStructure(db); err != nil {
-		log.Printf("Aviso: Erro ao atualizar estrutura da tabela: %v", err)
-		// Não retornar erro, continuar execução
-	}
-
-	// Adicionar índices otimizados para performance
-	if err := AddIndexes(db); err != nil {
-		log.Printf("Aviso: Erro ao criar índices: %v", err)
-		// Não retornar erro, continuar execução
-	}
-
-	// Criar views materializadas para dashboard
-	if err := CreateMaterializedViews(db); err != nil {
-		log.Printf("Aviso: Erro ao criar views materializadas: %v", err)
-		// Não retornar erro, continuar execução
-	}
-
-	log.Println("Banco de dados configurado e otimizado com sucesso!")
 	return db, nil
 }
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"fraudbase/internal/normalize"
+
+	"github.com/lib/pq"
+)
+
+// tamanhoLoteIdentificadores define quantas linhas são normalizadas por transação
+const tamanhoLoteIdentificadores = 5000
+
+// identificadorPendente descreve uma tabela cuja coluna normalizada é preenchida
+// a partir da coluna original (ou de uma expressão sobre várias colunas)
+type identificadorPendente struct {
+	tabela      string
+	original    string
+	normalizada string
+	normalizar  func(string) string
+}
+
+// originalConta junta banco, agência e conta (separados por chr(31), o
+// separadorConta) no valor original da conta bancária
+const (
+	originalConta  = `COALESCE(instituicao_bancaria, '') || chr(31) || COALESCE(numero_agencia_bancaria, '') || chr(31) || numero_conta_bancaria`
+	separadorConta = "\x1f"
+)
+
+var identificadoresPendentes = []identificadorPendente{
+	{"pessoas", "cpf", "cpf_normalizado", normalize.CPF},
+	{"participacoes", "telefone_envolvido", "telefone_normalizado", normalize.Telefone},
+	{"participacoes", "pix_utilizado", "pix_normalizado", chavePix},
+	{"participacoes", originalConta, "conta_normalizada", contaBancaria},
+}
+
+// chavePix é a forma canônica da chave PIX, sem o tipo
+func chavePix(valor string) string {
+	_, chave := normalize.ChavePix(valor)
+	return chave
+}
+
+// contaBancaria é a chave canônica da conta a partir do valor de originalConta
+func contaBancaria(valor string) string {
+	partes := strings.SplitN(valor, separadorConta, 3)
+	if len(partes) != 3 {
+		return ""
+	}
+	return normalize.ContaBancaria(partes[0], partes[1], partes[2])
+}
+
+// NormalizarIdentificadores preenche as colunas normalizadas (CPF, telefone,
+// chave PIX e conta bancária) das linhas gravadas antes dessas colunas existirem
+// (valor NULL). As gravações novas já calculam as colunas; o backfill pode ser
+// interrompido e retomado.
+func NormalizarIdentificadores(db *sql.DB) error {
+	for _, p := range identificadoresPendentes {
+		total := 0
+		for {
+			n, err := normalizarLoteIdentificadores(db, p)
+			if err != nil {
+				log.Printf("Erro ao normalizar %s.%s: %v", p.tabela, p.normalizada, err)
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			total += n
+			log.Printf("Identificadores: %d linhas de %s normalizadas até agora...", total, p.tabela)
+		}
+
+		if total > 0 {
+			log.Printf("Normalização de %s.%s concluída: %d linhas", p.tabela, p.normalizada, total)
+		}
+	}
+
+	return nil
+}
+
+func normalizarLoteIdentificadores(db *sql.DB, p identificadorPendente) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(`+p.original+`, '') FROM `+p.tabela+`
+		WHERE `+p.normalizada+` IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, tamanhoLoteIdentificadores)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int64
+	var valores []string
+	for rows.Next() {
+		var id int64
+		var original string
+		if err := rows.Scan(&id, &original); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		valores = append(valores, p.normalizar(original))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE `+p.tabela+` t SET `+p.normalizada+` = n.valor
+		FROM unnest($1::bigint[], $2::text[]) AS n(id, valor)
+		WHERE t.id = n.id`, pq.Array(ids), pq.Array(valores))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), tx.Commit()
+}
@@ -0,0 +1,251 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var arquivosMigracoes embed.FS
+
+// lockMigracoes é a chave do advisory lock que impede duas réplicas da API
+// de aplicarem migrações ao mesmo tempo
+const lockMigracoes = 7301000
+
+var nomeArquivoMigracao = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migracao representa uma versão do schema com seus scripts de subida e descida
+type Migracao struct {
+	Versao int64
+	Nome   string
+	Up     string
+	Down   string
+}
+
+// StatusMigracao descreve se uma migração já foi aplicada ao banco
+type StatusMigracao struct {
+	Versao     int64
+	Nome       string
+	Aplicada   bool
+	AplicadaEm *time.Time
+}
+
+// carregarMigracoes lê os arquivos embutidos em migrations/ e os ordena por versão
+func carregarMigracoes() ([]Migracao, error) {
+	arquivos, err := fs.ReadDir(arquivosMigracoes, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	porVersao := make(map[int64]*Migracao)
+	for _, arquivo := range arquivos {
+		m := nomeArquivoMigracao.FindStringSubmatch(arquivo.Name())
+		if m == nil {
+			return nil, fmt.Errorf("nome de arquivo de migração inválido: %s", arquivo.Name())
+		}
+
+		versao, _ := strconv.ParseInt(m[1], 10, 64)
+		conteudo, err := arquivosMigracoes.ReadFile("migrations/" + arquivo.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migracao, exists := porVersao[versao]
+		if !exists {
+			migracao = &Migracao{Versao: versao, Nome: m[2]}
+			porVersao[versao] = migracao
+		} else if migracao.Nome != m[2] {
+			return nil, fmt.Errorf("versão %d usada por duas migrações: %s e %s", versao, migracao.Nome, m[2])
+		}
+
+		if m[3] == "up" {
+			migracao.Up = string(conteudo)
+		} else {
+			migracao.Down = string(conteudo)
+		}
+	}
+
+	var migracoes []Migracao
+	for _, migracao := range porVersao {
+		if migracao.Up == "" {
+			return nil, fmt.Errorf("migração %d_%s sem arquivo .up.sql", migracao.Versao, migracao.Nome)
+		}
+		migracoes = append(migracoes, *migracao)
+	}
+	sort.Slice(migracoes, func(i, j int) bool { return migracoes[i].Versao < migracoes[j].Versao })
+
+	return migracoes, nil
+}
+
+// comLockMigracoes executa fn em uma conexão dedicada que mantém o advisory lock de migrações
+func comLockMigracoes(db *sql.DB, fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	log.Println("Aguardando lock de migrações...")
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockMigracoes); err != nil {
+		return fmt.Errorf("erro ao obter lock de migrações: %v", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockMigracoes)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(200) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("erro ao criar tabela schema_migrations: %v", err)
+	}
+
+	return fn(ctx, conn)
+}
+
+// versoesAplicadas retorna as versões registradas em schema_migrations
+func versoesAplicadas(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aplicadas := make(map[int64]time.Time)
+	for rows.Next() {
+		var versao int64
+		var aplicadaEm time.Time
+		if err := rows.Scan(&versao, &aplicadaEm); err != nil {
+			return nil, err
+		}
+		aplicadas[versao] = aplicadaEm
+	}
+
+	return aplicadas, rows.Err()
+}
+
+// executarMigracao roda um script e atualiza schema_migrations na mesma transação
+func executarMigracao(ctx context.Context, conn *sql.Conn, script string, registro string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, registro, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MigrateUp aplica, em ordem, todas as migrações pendentes
+func MigrateUp(db *sql.DB) error {
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return err
+	}
+
+	return comLockMigracoes(db, func(ctx context.Context, conn *sql.Conn) error {
+		aplicadas, err := versoesAplicadas(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		pendentes := 0
+		for _, m := range migracoes {
+			if _, ok := aplicadas[m.Versao]; ok {
+				continue
+			}
+
+			log.Printf("Aplicando migração %04d_%s...", m.Versao, m.Nome)
+			if err := executarMigracao(ctx, conn, m.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Versao, m.Nome); err != nil {
+				return fmt.Errorf("erro na migração %04d_%s: %v", m.Versao, m.Nome, err)
+			}
+			pendentes++
+		}
+
+		if pendentes == 0 {
+			log.Println("Banco de dados já está na versão mais recente")
+		} else {
+			log.Printf("%d migrações aplicadas com sucesso", pendentes)
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverte as últimas "passos" migrações aplicadas
+func MigrateDown(db *sql.DB, passos int) error {
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return err
+	}
+
+	return comLockMigracoes(db, func(ctx context.Context, conn *sql.Conn) error {
+		aplicadas, err := versoesAplicadas(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migracoes) - 1; i >= 0 && passos > 0; i-- {
+			m := migracoes[i]
+			if _, ok := aplicadas[m.Versao]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migração %04d_%s não possui arquivo .down.sql", m.Versao, m.Nome)
+			}
+
+			log.Printf("Revertendo migração %04d_%s...", m.Versao, m.Nome)
+			if err := executarMigracao(ctx, conn, m.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", m.Versao); err != nil {
+				return fmt.Errorf("erro ao reverter migração %04d_%s: %v", m.Versao, m.Nome, err)
+			}
+			passos--
+		}
+
+		return nil
+	})
+}
+
+// MigrationStatus lista todas as migrações conhecidas e se já foram aplicadas
+func MigrationStatus(db *sql.DB) ([]StatusMigracao, error) {
+	migracoes, err := carregarMigracoes()
+	if err != nil {
+		return nil, err
+	}
+
+	var status []StatusMigracao
+	err = comLockMigracoes(db, func(ctx context.Context, conn *sql.Conn) error {
+		aplicadas, err := versoesAplicadas(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migracoes {
+			s := StatusMigracao{Versao: m.Versao, Nome: m.Nome}
+			if aplicadaEm, ok := aplicadas[m.Versao]; ok {
+				s.Aplicada = true
+				s.AplicadaEm = &aplicadaEm
+			}
+			status = append(status, s)
+		}
+		return nil
+	})
+
+	return status, err
+}
@@ -0,0 +1,10 @@
+-- ATENÇÃO: remove todas as tabelas e dados do FraudBase
+DROP MATERIALIZED VIEW IF EXISTS mv_contagens_gerais;
+DROP MATERIALIZED VIEW IF EXISTS mv_infratores_por_delegacia;
+DROP MATERIALIZED VIEW IF EXISTS mv_vitimas_por_sexo;
+DROP TABLE IF EXISTS paises;
+DROP TABLE IF EXISTS municipios_e_estados;
+DROP TABLE IF EXISTS delegacias;
+DROP TABLE IF EXISTS bancos;
+DROP TABLE IF EXISTS tabela_estelionato;
+DROP TABLE IF EXISTS usuarios;
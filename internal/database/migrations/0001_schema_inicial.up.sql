@@ -0,0 +1,175 @@
+-- Schema inicial do FraudBase (equivalente ao antigo RunMigrations).
+-- Todos os comandos são idempotentes para que bancos já existentes possam
+-- ser adotados pelo controle de versões sem erro.
+
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS usuarios (
+	id SERIAL PRIMARY KEY,
+	login VARCHAR(100) UNIQUE NOT NULL,
+	nome VARCHAR(200) NOT NULL,
+	cpf VARCHAR(14) NOT NULL,
+	matricula VARCHAR(50),
+	telefone VARCHAR(20),
+	cidade VARCHAR(100),
+	estado VARCHAR(50),
+	unidade_policial VARCHAR(200),
+	email VARCHAR(200) UNIQUE NOT NULL,
+	senha TEXT NOT NULL,
+	is_admin BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE usuarios ADD COLUMN IF NOT EXISTS cidade VARCHAR(100);
+ALTER TABLE usuarios ADD COLUMN IF NOT EXISTS estado VARCHAR(50);
+
+CREATE TABLE IF NOT EXISTS tabela_estelionato (
+	id SERIAL PRIMARY KEY,
+	numero_do_bo VARCHAR(50),
+	tipo_envolvido VARCHAR(100),
+	nomecompleto VARCHAR(200),
+	cpf VARCHAR(14),
+	nomedamae VARCHAR(200),
+	nascimento VARCHAR(50),
+	nacionalidade VARCHAR(100),
+	naturalidade VARCHAR(100),
+	uf_envolvido VARCHAR(100),
+	sexo_envolvido VARCHAR(50),
+	telefone_envolvido VARCHAR(50),
+	data_fato VARCHAR(50),
+	cep_fato VARCHAR(10),
+	latitude_fato VARCHAR(50),
+	longitude_fato VARCHAR(50),
+	logradouro_fato VARCHAR(1000),
+	numerocasa_fato VARCHAR(50),
+	bairro_fato VARCHAR(150),
+	municipio_fato VARCHAR(100),
+	pais_fato VARCHAR(100),
+	delegacia_responsavel VARCHAR(300),
+	situacao VARCHAR(50),
+	natureza TEXT,
+	relato_historico TEXT,
+	instituicao_bancaria VARCHAR(200),
+	endereco_ip VARCHAR(50),
+	valor VARCHAR(100),
+	pix_utilizado VARCHAR(200),
+	numero_conta_bancaria VARCHAR(50),
+	numero_boleto VARCHAR(100),
+	processo_banco VARCHAR(100),
+	numero_agencia_bancaria VARCHAR(100),
+	cartao VARCHAR(50),
+	terminal VARCHAR(100),
+	tipo_pagamento VARCHAR(100),
+	orgao_concessionaria VARCHAR(200),
+	veiculo VARCHAR(200),
+	terminal_conexao VARCHAR(100),
+	erb VARCHAR(1000),
+	operacao_policial VARCHAR(200),
+	numero_laudo_pericial VARCHAR(100),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Correção de tamanhos de campos em bancos criados por versões antigas
+ALTER TABLE tabela_estelionato ALTER COLUMN telefone_envolvido TYPE VARCHAR(50);
+ALTER TABLE tabela_estelionato ALTER COLUMN logradouro_fato TYPE VARCHAR(1000);
+ALTER TABLE tabela_estelionato ALTER COLUMN delegacia_responsavel TYPE VARCHAR(300);
+ALTER TABLE tabela_estelionato ALTER COLUMN natureza TYPE TEXT;
+
+-- Tabelas auxiliares (vazias, para compatibilidade)
+CREATE TABLE IF NOT EXISTS bancos (
+	id SERIAL PRIMARY KEY,
+	nome_completo VARCHAR(200),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS delegacias (
+	id SERIAL PRIMARY KEY,
+	nome VARCHAR(300),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS municipios_e_estados (
+	id SERIAL PRIMARY KEY,
+	municipio VARCHAR(100),
+	uf VARCHAR(10),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS paises (
+	id SERIAL PRIMARY KEY,
+	nome_pais VARCHAR(100),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Usuário administrador padrão (senha: admin123 - ALTERAR APÓS O PRIMEIRO LOGIN)
+INSERT INTO usuarios (login, nome, cpf, matricula, telefone, unidade_policial, email, senha, is_admin)
+VALUES ('admin', 'Administrador', '12345678900', '001', '', 'FraudBase', 'admin@admin.com',
+	'$2a$10$6L536qPoCxiYcGrRAG11POh38GIa.cYH98hxtvevp.mU6zYGfSda2', TRUE)
+ON CONFLICT (login) DO NOTHING;
+
+-- Índices básicos
+CREATE INDEX IF NOT EXISTS idx_tabela_estelionato_cpf ON tabela_estelionato(cpf);
+CREATE INDEX IF NOT EXISTS idx_tabela_estelionato_telefone ON tabela_estelionato(telefone_envolvido);
+CREATE INDEX IF NOT EXISTS idx_tabela_estelionato_numero_bo ON tabela_estelionato(numero_do_bo);
+CREATE INDEX IF NOT EXISTS idx_tabela_estelionato_tipo_envolvido ON tabela_estelionato(tipo_envolvido);
+
+-- Índices de reincidência
+CREATE INDEX IF NOT EXISTS idx_reincidencia_cpf ON tabela_estelionato(cpf, tipo_envolvido) WHERE tipo_envolvido = 'Suposto Autor/infrator' AND cpf IS NOT NULL AND cpf != '';
+CREATE INDEX IF NOT EXISTS idx_reincidencia_telefone ON tabela_estelionato(telefone_envolvido, tipo_envolvido) WHERE tipo_envolvido = 'Suposto Autor/infrator' AND telefone_envolvido IS NOT NULL AND telefone_envolvido != '';
+CREATE INDEX IF NOT EXISTS idx_reincidencia_pix ON tabela_estelionato(pix_utilizado) WHERE pix_utilizado IS NOT NULL AND pix_utilizado != '';
+CREATE INDEX IF NOT EXISTS idx_reincidencia_conta ON tabela_estelionato(numero_conta_bancaria) WHERE numero_conta_bancaria IS NOT NULL AND numero_conta_bancaria != '';
+
+-- Índices de consulta
+CREATE INDEX IF NOT EXISTS idx_nomecompleto_trgm ON tabela_estelionato USING gin(nomecompleto gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_consulta_envolvidos ON tabela_estelionato(nomecompleto, cpf, numero_do_bo, telefone_envolvido);
+
+-- Índices para dashboard
+CREATE INDEX IF NOT EXISTS idx_dashboard_vitimas ON tabela_estelionato(tipo_envolvido, sexo_envolvido) WHERE tipo_envolvido IN ('Comunicante, Vítima', 'Vítima');
+CREATE INDEX IF NOT EXISTS idx_dashboard_infratores ON tabela_estelionato(tipo_envolvido, delegacia_responsavel) WHERE tipo_envolvido = 'Suposto Autor/infrator';
+
+-- Índices para ordenação
+CREATE INDEX IF NOT EXISTS idx_data_fato ON tabela_estelionato(data_fato);
+CREATE INDEX IF NOT EXISTS idx_created_at ON tabela_estelionato(created_at);
+
+-- Índices de usuários
+CREATE INDEX IF NOT EXISTS idx_usuarios_login ON usuarios(login);
+CREATE INDEX IF NOT EXISTS idx_usuarios_email ON usuarios(email);
+
+-- Índices para verificação de duplicatas na importação
+CREATE INDEX IF NOT EXISTS idx_verificacao_duplicatas ON tabela_estelionato(numero_do_bo, tipo_envolvido, nomecompleto, cpf, telefone_envolvido, data_fato);
+CREATE INDEX IF NOT EXISTS idx_duplicatas_hash ON tabela_estelionato(numero_do_bo, delegacia_responsavel, situacao) WHERE numero_do_bo IS NOT NULL;
+
+-- Views materializadas do dashboard
+CREATE MATERIALIZED VIEW IF NOT EXISTS mv_vitimas_por_sexo AS
+SELECT
+	sexo_envolvido,
+	COUNT(*) as quantidade
+FROM tabela_estelionato
+WHERE tipo_envolvido IN ('Comunicante, Vítima', 'Vítima')
+	AND sexo_envolvido IS NOT NULL
+GROUP BY sexo_envolvido;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS mv_infratores_por_delegacia AS
+SELECT
+	delegacia_responsavel,
+	COUNT(*) as quantidade
+FROM tabela_estelionato
+WHERE tipo_envolvido = 'Suposto Autor/infrator'
+	AND delegacia_responsavel IS NOT NULL
+	AND delegacia_responsavel != ''
+GROUP BY delegacia_responsavel
+ORDER BY quantidade DESC
+LIMIT 10;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS mv_contagens_gerais AS
+SELECT
+	COUNT(DISTINCT numero_do_bo) as total_bos,
+	COUNT(CASE WHEN tipo_envolvido = 'Suposto Autor/infrator' THEN 1 END) as total_infratores,
+	COUNT(CASE WHEN tipo_envolvido IN ('Comunicante, Vítima', 'Vítima') THEN 1 END) as total_vitimas
+FROM tabela_estelionato;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mv_vitimas_sexo ON mv_vitimas_por_sexo(sexo_envolvido);
+CREATE INDEX IF NOT EXISTS idx_mv_delegacias_qtd ON mv_infratores_por_delegacia(quantidade DESC);
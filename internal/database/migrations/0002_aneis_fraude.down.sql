@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS aneis_fraude_registros;
+DROP TABLE IF EXISTS aneis_fraude;
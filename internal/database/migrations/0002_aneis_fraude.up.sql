@@ -0,0 +1,20 @@
+-- Tabelas preenchidas pelo job de detecção de anéis de fraude (componentes conexos de suspeitos)
+CREATE TABLE IF NOT EXISTS aneis_fraude (
+	ring_id INTEGER PRIMARY KEY,
+	quantidade_registros INTEGER NOT NULL,
+	quantidade_pessoas INTEGER NOT NULL,
+	quantidade_bos INTEGER NOT NULL,
+	valor_total NUMERIC(14,2) NOT NULL DEFAULT 0,
+	delegacias TEXT[] NOT NULL DEFAULT '{}',
+	data_inicio TIMESTAMPTZ,
+	data_fim TIMESTAMPTZ,
+	calculado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS aneis_fraude_registros (
+	registro_id INTEGER PRIMARY KEY REFERENCES tabela_estelionato(id) ON DELETE CASCADE,
+	ring_id INTEGER NOT NULL REFERENCES aneis_fraude(ring_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_aneis_fraude_registros_ring ON aneis_fraude_registros(ring_id);
+CREATE INDEX IF NOT EXISTS idx_aneis_fraude_ranking ON aneis_fraude(quantidade_pessoas DESC, quantidade_bos DESC, valor_total DESC);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS tabela_estelionato_conversao_erros;
+DROP INDEX IF EXISTS idx_data_fato_ts;
+DROP INDEX IF EXISTS idx_campos_tipados_pendentes;
+ALTER TABLE tabela_estelionato DROP COLUMN IF EXISTS campos_tipados;
+ALTER TABLE tabela_estelionato DROP COLUMN IF EXISTS longitude_fato_num;
+ALTER TABLE tabela_estelionato DROP COLUMN IF EXISTS latitude_fato_num;
+ALTER TABLE tabela_estelionato DROP COLUMN IF EXISTS valor_numerico;
+ALTER TABLE tabela_estelionato DROP COLUMN IF EXISTS nascimento_data;
+ALTER TABLE tabela_estelionato DROP COLUMN IF EXISTS data_fato_ts;
@@ -0,0 +1,21 @@
+-- Colunas tipadas que espelham data_fato, nascimento, valor, latitude_fato e longitude_fato.
+-- O preenchimento é feito em Go por database.MigrarCamposTipados (formatos brasileiros).
+ALTER TABLE tabela_estelionato ADD COLUMN IF NOT EXISTS data_fato_ts TIMESTAMPTZ;
+ALTER TABLE tabela_estelionato ADD COLUMN IF NOT EXISTS nascimento_data DATE;
+ALTER TABLE tabela_estelionato ADD COLUMN IF NOT EXISTS valor_numerico NUMERIC(14,2);
+ALTER TABLE tabela_estelionato ADD COLUMN IF NOT EXISTS latitude_fato_num DOUBLE PRECISION;
+ALTER TABLE tabela_estelionato ADD COLUMN IF NOT EXISTS longitude_fato_num DOUBLE PRECISION;
+
+-- Marca os registros já convertidos; registros gravados por versões antigas ficam FALSE
+ALTER TABLE tabela_estelionato ADD COLUMN IF NOT EXISTS campos_tipados BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_campos_tipados_pendentes ON tabela_estelionato(id) WHERE NOT campos_tipados;
+CREATE INDEX IF NOT EXISTS idx_data_fato_ts ON tabela_estelionato(data_fato_ts);
+
+CREATE TABLE IF NOT EXISTS tabela_estelionato_conversao_erros (
+	id SERIAL PRIMARY KEY,
+	registro_id INTEGER NOT NULL REFERENCES tabela_estelionato(id) ON DELETE CASCADE,
+	campo VARCHAR(50) NOT NULL,
+	valor TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
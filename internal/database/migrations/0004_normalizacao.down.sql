@@ -0,0 +1,144 @@
+-- Reconstrói tabela_estelionato (com as colunas tipadas) a partir do modelo normalizado
+CREATE TABLE tabela_estelionato (
+	id SERIAL PRIMARY KEY,
+	numero_do_bo VARCHAR(50),
+	tipo_envolvido VARCHAR(100),
+	nomecompleto VARCHAR(200),
+	cpf VARCHAR(14),
+	nomedamae VARCHAR(200),
+	nascimento VARCHAR(50),
+	nacionalidade VARCHAR(100),
+	naturalidade VARCHAR(100),
+	uf_envolvido VARCHAR(100),
+	sexo_envolvido VARCHAR(50),
+	telefone_envolvido VARCHAR(50),
+	data_fato VARCHAR(50),
+	cep_fato VARCHAR(10),
+	latitude_fato VARCHAR(50),
+	longitude_fato VARCHAR(50),
+	logradouro_fato VARCHAR(1000),
+	numerocasa_fato VARCHAR(50),
+	bairro_fato VARCHAR(150),
+	municipio_fato VARCHAR(100),
+	pais_fato VARCHAR(100),
+	delegacia_responsavel VARCHAR(300),
+	situacao VARCHAR(50),
+	natureza TEXT,
+	relato_historico TEXT,
+	instituicao_bancaria VARCHAR(200),
+	endereco_ip VARCHAR(50),
+	valor VARCHAR(100),
+	pix_utilizado VARCHAR(200),
+	numero_conta_bancaria VARCHAR(50),
+	numero_boleto VARCHAR(100),
+	processo_banco VARCHAR(100),
+	numero_agencia_bancaria VARCHAR(100),
+	cartao VARCHAR(50),
+	terminal VARCHAR(100),
+	tipo_pagamento VARCHAR(100),
+	orgao_concessionaria VARCHAR(200),
+	veiculo VARCHAR(200),
+	terminal_conexao VARCHAR(100),
+	erb VARCHAR(1000),
+	operacao_policial VARCHAR(200),
+	numero_laudo_pericial VARCHAR(100),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	data_fato_ts TIMESTAMPTZ,
+	nascimento_data DATE,
+	valor_numerico NUMERIC(14,2),
+	latitude_fato_num DOUBLE PRECISION,
+	longitude_fato_num DOUBLE PRECISION,
+	campos_tipados BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO tabela_estelionato (
+	id, numero_do_bo, tipo_envolvido, nomecompleto, cpf, nomedamae, nascimento, nacionalidade,
+	naturalidade, uf_envolvido, sexo_envolvido, telefone_envolvido, data_fato, cep_fato,
+	latitude_fato, longitude_fato, logradouro_fato, numerocasa_fato, bairro_fato, municipio_fato,
+	pais_fato, delegacia_responsavel, situacao, natureza, relato_historico, instituicao_bancaria,
+	endereco_ip, valor, pix_utilizado, numero_conta_bancaria, numero_boleto, processo_banco,
+	numero_agencia_bancaria, cartao, terminal, tipo_pagamento, orgao_concessionaria, veiculo,
+	terminal_conexao, erb, operacao_policial, numero_laudo_pericial, created_at, updated_at,
+	data_fato_ts, nascimento_data, valor_numerico, latitude_fato_num, longitude_fato_num, campos_tipados
+)
+SELECT
+	id, numero_do_bo, tipo_envolvido, nomecompleto, cpf, nomedamae, nascimento, nacionalidade,
+	naturalidade, uf_envolvido, sexo_envolvido, telefone_envolvido, data_fato, cep_fato,
+	latitude_fato, longitude_fato, logradouro_fato, numerocasa_fato, bairro_fato, municipio_fato,
+	pais_fato, delegacia_responsavel, situacao, natureza, relato_historico, instituicao_bancaria,
+	endereco_ip, valor, pix_utilizado, numero_conta_bancaria, numero_boleto, processo_banco,
+	numero_agencia_bancaria, cartao, terminal, tipo_pagamento, orgao_concessionaria, veiculo,
+	terminal_conexao, erb, operacao_policial, numero_laudo_pericial, created_at, updated_at,
+	data_fato_ts, nascimento_data, valor_numerico, latitude_fato_num, longitude_fato_num, campos_tipados
+FROM vw_envolvidos;
+
+SELECT setval(pg_get_serial_sequence('tabela_estelionato', 'id'), COALESCE((SELECT MAX(id) FROM participacoes), 0) + 1, false);
+
+ALTER TABLE aneis_fraude_registros DROP CONSTRAINT IF EXISTS aneis_fraude_registros_registro_id_fkey;
+ALTER TABLE aneis_fraude_registros ADD CONSTRAINT aneis_fraude_registros_registro_id_fkey
+	FOREIGN KEY (registro_id) REFERENCES tabela_estelionato(id) ON DELETE CASCADE;
+ALTER TABLE tabela_estelionato_conversao_erros DROP CONSTRAINT IF EXISTS tabela_estelionato_conversao_erros_registro_id_fkey;
+ALTER TABLE tabela_estelionato_conversao_erros ADD CONSTRAINT tabela_estelionato_conversao_erros_registro_id_fkey
+	FOREIGN KEY (registro_id) REFERENCES tabela_estelionato(id) ON DELETE CASCADE;
+
+DROP MATERIALIZED VIEW IF EXISTS mv_contagens_gerais;
+DROP MATERIALIZED VIEW IF EXISTS mv_infratores_por_delegacia;
+DROP MATERIALIZED VIEW IF EXISTS mv_vitimas_por_sexo;
+DROP VIEW IF EXISTS vw_envolvidos;
+DROP TABLE IF EXISTS participacoes;
+DROP TABLE IF EXISTS pessoas;
+DROP TABLE IF EXISTS ocorrencias;
+DROP FUNCTION IF EXISTS chave_pessoa(TEXT, TEXT, TEXT, TEXT);
+DROP FUNCTION IF EXISTS cpf_valido(TEXT);
+
+-- Índices e views materializadas das migrações 0001 e 0003
+CREATE INDEX idx_tabela_estelionato_cpf ON tabela_estelionato(cpf);
+CREATE INDEX idx_tabela_estelionato_telefone ON tabela_estelionato(telefone_envolvido);
+CREATE INDEX idx_tabela_estelionato_numero_bo ON tabela_estelionato(numero_do_bo);
+CREATE INDEX idx_tabela_estelionato_tipo_envolvido ON tabela_estelionato(tipo_envolvido);
+CREATE INDEX idx_reincidencia_cpf ON tabela_estelionato(cpf, tipo_envolvido) WHERE tipo_envolvido = 'Suposto Autor/infrator' AND cpf IS NOT NULL AND cpf != '';
+CREATE INDEX idx_reincidencia_telefone ON tabela_estelionato(telefone_envolvido, tipo_envolvido) WHERE tipo_envolvido = 'Suposto Autor/infrator' AND telefone_envolvido IS NOT NULL AND telefone_envolvido != '';
+CREATE INDEX idx_reincidencia_pix ON tabela_estelionato(pix_utilizado) WHERE pix_utilizado IS NOT NULL AND pix_utilizado != '';
+CREATE INDEX idx_reincidencia_conta ON tabela_estelionato(numero_conta_bancaria) WHERE numero_conta_bancaria IS NOT NULL AND numero_conta_bancaria != '';
+CREATE INDEX idx_nomecompleto_trgm ON tabela_estelionato USING gin(nomecompleto gin_trgm_ops);
+CREATE INDEX idx_consulta_envolvidos ON tabela_estelionato(nomecompleto, cpf, numero_do_bo, telefone_envolvido);
+CREATE INDEX idx_dashboard_vitimas ON tabela_estelionato(tipo_envolvido, sexo_envolvido) WHERE tipo_envolvido IN ('Comunicante, Vítima', 'Vítima');
+CREATE INDEX idx_dashboard_infratores ON tabela_estelionato(tipo_envolvido, delegacia_responsavel) WHERE tipo_envolvido = 'Suposto Autor/infrator';
+CREATE INDEX idx_data_fato ON tabela_estelionato(data_fato);
+CREATE INDEX idx_created_at ON tabela_estelionato(created_at);
+CREATE INDEX idx_verificacao_duplicatas ON tabela_estelionato(numero_do_bo, tipo_envolvido, nomecompleto, cpf, telefone_envolvido, data_fato);
+CREATE INDEX idx_duplicatas_hash ON tabela_estelionato(numero_do_bo, delegacia_responsavel, situacao) WHERE numero_do_bo IS NOT NULL;
+CREATE INDEX idx_campos_tipados_pendentes ON tabela_estelionato(id) WHERE NOT campos_tipados;
+CREATE INDEX idx_data_fato_ts ON tabela_estelionato(data_fato_ts);
+
+CREATE MATERIALIZED VIEW mv_vitimas_por_sexo AS
+SELECT
+	sexo_envolvido,
+	COUNT(*) as quantidade
+FROM tabela_estelionato
+WHERE tipo_envolvido IN ('Comunicante, Vítima', 'Vítima')
+	AND sexo_envolvido IS NOT NULL
+GROUP BY sexo_envolvido;
+
+CREATE MATERIALIZED VIEW mv_infratores_por_delegacia AS
+SELECT
+	delegacia_responsavel,
+	COUNT(*) as quantidade
+FROM tabela_estelionato
+WHERE tipo_envolvido = 'Suposto Autor/infrator'
+	AND delegacia_responsavel IS NOT NULL
+	AND delegacia_responsavel != ''
+GROUP BY delegacia_responsavel
+ORDER BY quantidade DESC
+LIMIT 10;
+
+CREATE MATERIALIZED VIEW mv_contagens_gerais AS
+SELECT
+	COUNT(DISTINCT numero_do_bo) as total_bos,
+	COUNT(CASE WHEN tipo_envolvido = 'Suposto Autor/infrator' THEN 1 END) as total_infratores,
+	COUNT(CASE WHEN tipo_envolvido IN ('Comunicante, Vítima', 'Vítima') THEN 1 END) as total_vitimas
+FROM tabela_estelionato;
+
+CREATE UNIQUE INDEX idx_mv_vitimas_sexo ON mv_vitimas_por_sexo(sexo_envolvido);
+CREATE INDEX idx_mv_delegacias_qtd ON mv_infratores_por_delegacia(quantidade DESC);
@@ -0,0 +1,297 @@
+-- Normaliza tabela_estelionato em ocorrencias (uma linha por BO), pessoas
+-- (deduplicadas por CPF ou nome + mãe + nascimento) e participacoes (vínculo
+-- pessoa x BO com o papel e os dados da transação). Os ids de participacoes
+-- preservam os ids de tabela_estelionato; a view vw_envolvidos reconstrói a
+-- linha plana usada pelas consultas e pela API.
+
+-- Validação de CPF (dígitos verificadores), igual a normalize.CPFValido
+CREATE OR REPLACE FUNCTION cpf_valido(digitos TEXT) RETURNS BOOLEAN AS $$
+DECLARE
+	soma INTEGER;
+	resto INTEGER;
+BEGIN
+	IF digitos IS NULL OR digitos !~ '^[0-9]{11}$' OR digitos ~ '^(.)\1{10}$' THEN
+		RETURN FALSE;
+	END IF;
+
+	soma := 0;
+	FOR i IN 1..9 LOOP
+		soma := soma + substr(digitos, i, 1)::INTEGER * (11 - i);
+	END LOOP;
+	resto := (soma * 10) % 11;
+	IF resto = 10 THEN resto := 0; END IF;
+	IF resto <> substr(digitos, 10, 1)::INTEGER THEN
+		RETURN FALSE;
+	END IF;
+
+	soma := 0;
+	FOR i IN 1..10 LOOP
+		soma := soma + substr(digitos, i, 1)::INTEGER * (12 - i);
+	END LOOP;
+	resto := (soma * 10) % 11;
+	IF resto = 10 THEN resto := 0; END IF;
+
+	RETURN resto = substr(digitos, 11, 1)::INTEGER;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Chave de deduplicação de pessoas: CPF válido ou, na falta dele, nome + nome da
+-- mãe + nascimento. Retorna NULL quando não há dados suficientes para identificar
+-- a pessoa (nesse caso cada participação gera uma pessoa própria).
+CREATE OR REPLACE FUNCTION chave_pessoa(cpf TEXT, nome TEXT, nomedamae TEXT, nascimento TEXT) RETURNS TEXT AS $$
+DECLARE
+	digitos TEXT := regexp_replace(COALESCE(cpf, ''), '[^0-9]', '', 'g');
+	nome_norm TEXT := upper(unaccent(regexp_replace(trim(COALESCE(nome, '')), '\s+', ' ', 'g')));
+	mae_norm TEXT := upper(unaccent(regexp_replace(trim(COALESCE(nomedamae, '')), '\s+', ' ', 'g')));
+	nascimento_norm TEXT := regexp_replace(COALESCE(nascimento, ''), '\s+', '', 'g');
+BEGIN
+	IF cpf_valido(digitos) THEN
+		RETURN 'cpf:' || digitos;
+	END IF;
+	IF nome_norm = '' OR (mae_norm = '' AND nascimento_norm = '') THEN
+		RETURN NULL;
+	END IF;
+	RETURN 'nome:' || nome_norm || '|' || mae_norm || '|' || nascimento_norm;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+CREATE TABLE ocorrencias (
+	id SERIAL PRIMARY KEY,
+	numero_do_bo VARCHAR(50) UNIQUE,
+	data_fato VARCHAR(50),
+	cep_fato VARCHAR(10),
+	latitude_fato VARCHAR(50),
+	longitude_fato VARCHAR(50),
+	logradouro_fato VARCHAR(1000),
+	numerocasa_fato VARCHAR(50),
+	bairro_fato VARCHAR(150),
+	municipio_fato VARCHAR(100),
+	pais_fato VARCHAR(100),
+	delegacia_responsavel VARCHAR(300),
+	situacao VARCHAR(50),
+	natureza TEXT,
+	relato_historico TEXT,
+	data_fato_ts TIMESTAMPTZ,
+	latitude_fato_num DOUBLE PRECISION,
+	longitude_fato_num DOUBLE PRECISION,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE pessoas (
+	id SERIAL PRIMARY KEY,
+	chave_identidade TEXT UNIQUE,
+	nomecompleto VARCHAR(200),
+	cpf VARCHAR(14),
+	nomedamae VARCHAR(200),
+	nascimento VARCHAR(50),
+	nacionalidade VARCHAR(100),
+	naturalidade VARCHAR(100),
+	uf_envolvido VARCHAR(100),
+	sexo_envolvido VARCHAR(50),
+	nascimento_data DATE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE participacoes (
+	id SERIAL PRIMARY KEY,
+	ocorrencia_id INTEGER NOT NULL REFERENCES ocorrencias(id) ON DELETE CASCADE,
+	pessoa_id INTEGER NOT NULL REFERENCES pessoas(id),
+	tipo_envolvido VARCHAR(100),
+	telefone_envolvido VARCHAR(50),
+	instituicao_bancaria VARCHAR(200),
+	endereco_ip VARCHAR(50),
+	valor VARCHAR(100),
+	pix_utilizado VARCHAR(200),
+	numero_conta_bancaria VARCHAR(50),
+	numero_boleto VARCHAR(100),
+	processo_banco VARCHAR(100),
+	numero_agencia_bancaria VARCHAR(100),
+	cartao VARCHAR(50),
+	terminal VARCHAR(100),
+	tipo_pagamento VARCHAR(100),
+	orgao_concessionaria VARCHAR(200),
+	veiculo VARCHAR(200),
+	terminal_conexao VARCHAR(100),
+	erb VARCHAR(1000),
+	operacao_policial VARCHAR(200),
+	numero_laudo_pericial VARCHAR(100),
+	valor_numerico NUMERIC(14,2),
+	campos_tipados BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Migração dos dados: cada grupo (BO ou pessoa) herda o id e os dados da
+-- primeira linha de tabela_estelionato que o compõe
+CREATE TEMP TABLE migracao_grupos ON COMMIT DROP AS
+SELECT
+	t.id AS registro_id,
+	FIRST_VALUE(t.id) OVER (
+		PARTITION BY COALESCE(NULLIF(t.numero_do_bo, ''), 'registro:' || t.id) ORDER BY t.id
+	) AS ocorrencia_id,
+	FIRST_VALUE(t.id) OVER (
+		PARTITION BY COALESCE(chave_pessoa(t.cpf, t.nomecompleto, t.nomedamae, t.nascimento), 'registro:' || t.id) ORDER BY t.id
+	) AS pessoa_id
+FROM tabela_estelionato t;
+
+INSERT INTO ocorrencias (
+	id, numero_do_bo, data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato,
+	numerocasa_fato, bairro_fato, municipio_fato, pais_fato, delegacia_responsavel, situacao,
+	natureza, relato_historico, data_fato_ts, latitude_fato_num, longitude_fato_num, created_at, updated_at
+)
+SELECT
+	t.id, NULLIF(t.numero_do_bo, ''), t.data_fato, t.cep_fato, t.latitude_fato, t.longitude_fato, t.logradouro_fato,
+	t.numerocasa_fato, t.bairro_fato, t.municipio_fato, t.pais_fato, t.delegacia_responsavel, t.situacao,
+	t.natureza, t.relato_historico, t.data_fato_ts, t.latitude_fato_num, t.longitude_fato_num, t.created_at, t.updated_at
+FROM tabela_estelionato t
+WHERE t.id IN (SELECT ocorrencia_id FROM migracao_grupos);
+
+INSERT INTO pessoas (
+	id, chave_identidade, nomecompleto, cpf, nomedamae, nascimento, nacionalidade, naturalidade,
+	uf_envolvido, sexo_envolvido, nascimento_data, created_at, updated_at
+)
+SELECT
+	t.id, chave_pessoa(t.cpf, t.nomecompleto, t.nomedamae, t.nascimento), t.nomecompleto, t.cpf, t.nomedamae,
+	t.nascimento, t.nacionalidade, t.naturalidade, t.uf_envolvido, t.sexo_envolvido, t.nascimento_data,
+	t.created_at, t.updated_at
+FROM tabela_estelionato t
+WHERE t.id IN (SELECT pessoa_id FROM migracao_grupos);
+
+INSERT INTO participacoes (
+	id, ocorrencia_id, pessoa_id, tipo_envolvido, telefone_envolvido, instituicao_bancaria, endereco_ip,
+	valor, pix_utilizado, numero_conta_bancaria, numero_boleto, processo_banco, numero_agencia_bancaria,
+	cartao, terminal, tipo_pagamento, orgao_concessionaria, veiculo, terminal_conexao, erb,
+	operacao_policial, numero_laudo_pericial, valor_numerico, campos_tipados, created_at, updated_at
+)
+SELECT
+	t.id, g.ocorrencia_id, g.pessoa_id, t.tipo_envolvido, t.telefone_envolvido, t.instituicao_bancaria, t.endereco_ip,
+	t.valor, t.pix_utilizado, t.numero_conta_bancaria, t.numero_boleto, t.processo_banco, t.numero_agencia_bancaria,
+	t.cartao, t.terminal, t.tipo_pagamento, t.orgao_concessionaria, t.veiculo, t.terminal_conexao, t.erb,
+	t.operacao_policial, t.numero_laudo_pericial, t.valor_numerico, t.campos_tipados, t.created_at, t.updated_at
+FROM tabela_estelionato t
+JOIN migracao_grupos g ON g.registro_id = t.id;
+
+SELECT setval(pg_get_serial_sequence('ocorrencias', 'id'), COALESCE((SELECT MAX(id) FROM ocorrencias), 0) + 1, false);
+SELECT setval(pg_get_serial_sequence('pessoas', 'id'), COALESCE((SELECT MAX(id) FROM pessoas), 0) + 1, false);
+SELECT setval(pg_get_serial_sequence('participacoes', 'id'), COALESCE((SELECT MAX(id) FROM tabela_estelionato), 0) + 1, false);
+
+-- Tabelas que apontavam para tabela_estelionato passam a apontar para participacoes
+ALTER TABLE aneis_fraude_registros DROP CONSTRAINT IF EXISTS aneis_fraude_registros_registro_id_fkey;
+ALTER TABLE aneis_fraude_registros ADD CONSTRAINT aneis_fraude_registros_registro_id_fkey
+	FOREIGN KEY (registro_id) REFERENCES participacoes(id) ON DELETE CASCADE;
+ALTER TABLE tabela_estelionato_conversao_erros DROP CONSTRAINT IF EXISTS tabela_estelionato_conversao_erros_registro_id_fkey;
+ALTER TABLE tabela_estelionato_conversao_erros ADD CONSTRAINT tabela_estelionato_conversao_erros_registro_id_fkey
+	FOREIGN KEY (registro_id) REFERENCES participacoes(id) ON DELETE CASCADE;
+
+DROP MATERIALIZED VIEW IF EXISTS mv_contagens_gerais;
+DROP MATERIALIZED VIEW IF EXISTS mv_infratores_por_delegacia;
+DROP MATERIALIZED VIEW IF EXISTS mv_vitimas_por_sexo;
+DROP TABLE tabela_estelionato;
+
+-- Linha plana equivalente à antiga tabela_estelionato
+CREATE VIEW vw_envolvidos AS
+SELECT
+	pa.id,
+	pa.ocorrencia_id,
+	pa.pessoa_id,
+	o.numero_do_bo,
+	pa.tipo_envolvido,
+	pe.nomecompleto,
+	pe.cpf,
+	pe.nomedamae,
+	pe.nascimento,
+	pe.nacionalidade,
+	pe.naturalidade,
+	pe.uf_envolvido,
+	pe.sexo_envolvido,
+	pa.telefone_envolvido,
+	o.data_fato,
+	o.cep_fato,
+	o.latitude_fato,
+	o.longitude_fato,
+	o.logradouro_fato,
+	o.numerocasa_fato,
+	o.bairro_fato,
+	o.municipio_fato,
+	o.pais_fato,
+	o.delegacia_responsavel,
+	o.situacao,
+	o.natureza,
+	o.relato_historico,
+	pa.instituicao_bancaria,
+	pa.endereco_ip,
+	pa.valor,
+	pa.pix_utilizado,
+	pa.numero_conta_bancaria,
+	pa.numero_boleto,
+	pa.processo_banco,
+	pa.numero_agencia_bancaria,
+	pa.cartao,
+	pa.terminal,
+	pa.tipo_pagamento,
+	pa.orgao_concessionaria,
+	pa.veiculo,
+	pa.terminal_conexao,
+	pa.erb,
+	pa.operacao_policial,
+	pa.numero_laudo_pericial,
+	pa.created_at,
+	pa.updated_at,
+	o.data_fato_ts,
+	pe.nascimento_data,
+	pa.valor_numerico,
+	o.latitude_fato_num,
+	o.longitude_fato_num,
+	pa.campos_tipados
+FROM participacoes pa
+JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+JOIN pessoas pe ON pe.id = pa.pessoa_id;
+
+-- Índices
+CREATE INDEX idx_ocorrencias_data_fato_ts ON ocorrencias(data_fato_ts);
+CREATE INDEX idx_ocorrencias_delegacia ON ocorrencias(delegacia_responsavel);
+CREATE INDEX idx_pessoas_cpf ON pessoas(cpf);
+CREATE INDEX idx_pessoas_nomecompleto_trgm ON pessoas USING gin(nomecompleto gin_trgm_ops);
+CREATE INDEX idx_participacoes_ocorrencia ON participacoes(ocorrencia_id);
+CREATE INDEX idx_participacoes_pessoa ON participacoes(pessoa_id);
+CREATE INDEX idx_participacoes_tipo_envolvido ON participacoes(tipo_envolvido);
+CREATE INDEX idx_participacoes_telefone ON participacoes(telefone_envolvido);
+CREATE INDEX idx_participacoes_pix ON participacoes(pix_utilizado) WHERE pix_utilizado IS NOT NULL AND pix_utilizado != '';
+CREATE INDEX idx_participacoes_conta ON participacoes(numero_conta_bancaria) WHERE numero_conta_bancaria IS NOT NULL AND numero_conta_bancaria != '';
+CREATE INDEX idx_participacoes_campos_tipados_pendentes ON participacoes(id) WHERE NOT campos_tipados;
+
+-- Views materializadas do dashboard recriadas sobre o modelo normalizado
+CREATE MATERIALIZED VIEW mv_vitimas_por_sexo AS
+SELECT
+	pe.sexo_envolvido,
+	COUNT(*) as quantidade
+FROM participacoes pa
+JOIN pessoas pe ON pe.id = pa.pessoa_id
+WHERE pa.tipo_envolvido IN ('Comunicante, Vítima', 'Vítima')
+	AND pe.sexo_envolvido IS NOT NULL
+GROUP BY pe.sexo_envolvido;
+
+CREATE MATERIALIZED VIEW mv_infratores_por_delegacia AS
+SELECT
+	o.delegacia_responsavel,
+	COUNT(*) as quantidade
+FROM participacoes pa
+JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+WHERE pa.tipo_envolvido = 'Suposto Autor/infrator'
+	AND o.delegacia_responsavel IS NOT NULL
+	AND o.delegacia_responsavel != ''
+GROUP BY o.delegacia_responsavel
+ORDER BY quantidade DESC
+LIMIT 10;
+
+CREATE MATERIALIZED VIEW mv_contagens_gerais AS
+SELECT
+	(SELECT COUNT(*) FROM ocorrencias WHERE numero_do_bo IS NOT NULL) as total_bos,
+	COUNT(CASE WHEN tipo_envolvido = 'Suposto Autor/infrator' THEN 1 END) as total_infratores,
+	COUNT(CASE WHEN tipo_envolvido IN ('Comunicante, Vítima', 'Vítima') THEN 1 END) as total_vitimas
+FROM participacoes;
+
+CREATE UNIQUE INDEX idx_mv_vitimas_sexo ON mv_vitimas_por_sexo(sexo_envolvido);
+CREATE INDEX idx_mv_delegacias_qtd ON mv_infratores_por_delegacia(quantidade DESC);
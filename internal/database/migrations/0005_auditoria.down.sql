@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS auditoria_consultas;
+DROP FUNCTION IF EXISTS impedir_alteracao_auditoria();
@@ -0,0 +1,30 @@
+-- Trilha de auditoria das consultas a dados de pessoas. A tabela é somente
+-- de inserção: UPDATE, DELETE e TRUNCATE são bloqueados por trigger.
+CREATE TABLE auditoria_consultas (
+	id BIGSERIAL PRIMARY KEY,
+	usuario_id INTEGER NOT NULL,
+	usuario_login VARCHAR(100) NOT NULL,
+	endpoint VARCHAR(200) NOT NULL,
+	parametros JSONB NOT NULL DEFAULT '{}',
+	quantidade_resultados INTEGER NOT NULL,
+	ip VARCHAR(64) NOT NULL,
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_auditoria_usuario ON auditoria_consultas(usuario_id, criado_em);
+CREATE INDEX idx_auditoria_criado_em ON auditoria_consultas(criado_em);
+CREATE INDEX idx_auditoria_parametros ON auditoria_consultas USING gin(parametros jsonb_path_ops);
+
+CREATE OR REPLACE FUNCTION impedir_alteracao_auditoria() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'auditoria_consultas é somente de inserção (% bloqueado)', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_auditoria_somente_insercao
+	BEFORE UPDATE OR DELETE ON auditoria_consultas
+	FOR EACH ROW EXECUTE PROCEDURE impedir_alteracao_auditoria();
+
+CREATE TRIGGER trg_auditoria_sem_truncate
+	BEFORE TRUNCATE ON auditoria_consultas
+	FOR EACH STATEMENT EXECUTE PROCEDURE impedir_alteracao_auditoria();
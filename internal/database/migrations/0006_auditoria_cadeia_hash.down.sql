@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS auditoria_checkpoints;
+
+DROP TRIGGER IF EXISTS trg_auditoria_somente_insercao ON auditoria_consultas;
+CREATE TRIGGER trg_auditoria_somente_insercao
+	BEFORE UPDATE OR DELETE ON auditoria_consultas
+	FOR EACH ROW EXECUTE PROCEDURE impedir_alteracao_auditoria();
+DROP FUNCTION IF EXISTS proteger_auditoria_consultas();
+
+DROP INDEX IF EXISTS idx_auditoria_sem_hash;
+ALTER TABLE auditoria_consultas DROP COLUMN IF EXISTS hash;
+ALTER TABLE auditoria_consultas DROP COLUMN IF EXISTS hash_anterior;
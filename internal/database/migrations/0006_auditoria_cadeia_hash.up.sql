@@ -0,0 +1,44 @@
+-- Cadeia de hashes da trilha de auditoria: cada registro guarda o SHA-256 do seu
+-- conteúdo e o hash do registro anterior (calculados pela aplicação).
+ALTER TABLE auditoria_consultas ADD COLUMN hash_anterior CHAR(64);
+ALTER TABLE auditoria_consultas ADD COLUMN hash CHAR(64);
+
+CREATE INDEX idx_auditoria_sem_hash ON auditoria_consultas(id) WHERE hash IS NULL;
+
+-- A única atualização permitida é selar (encadear) registros gravados antes da
+-- cadeia existir, sem alterar nenhum outro campo
+CREATE OR REPLACE FUNCTION proteger_auditoria_consultas() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP = 'UPDATE' AND OLD.hash IS NULL AND NEW.hash IS NOT NULL AND NEW.hash_anterior IS NOT NULL
+		AND (NEW.id, NEW.usuario_id, NEW.usuario_login, NEW.endpoint, NEW.parametros,
+			NEW.quantidade_resultados, NEW.ip, NEW.criado_em)
+		IS NOT DISTINCT FROM (OLD.id, OLD.usuario_id, OLD.usuario_login, OLD.endpoint, OLD.parametros,
+			OLD.quantidade_resultados, OLD.ip, OLD.criado_em) THEN
+		RETURN NEW;
+	END IF;
+	RAISE EXCEPTION 'auditoria_consultas é somente de inserção (% bloqueado)', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER trg_auditoria_somente_insercao ON auditoria_consultas;
+CREATE TRIGGER trg_auditoria_somente_insercao
+	BEFORE UPDATE OR DELETE ON auditoria_consultas
+	FOR EACH ROW EXECUTE PROCEDURE proteger_auditoria_consultas();
+
+-- Checkpoints periódicos: assinatura Ed25519 do último registro da cadeia
+CREATE TABLE auditoria_checkpoints (
+	id SERIAL PRIMARY KEY,
+	ultimo_registro_id BIGINT NOT NULL,
+	ultimo_hash CHAR(64) NOT NULL,
+	quantidade_registros BIGINT NOT NULL,
+	criado_em TIMESTAMPTZ NOT NULL,
+	assinatura TEXT NOT NULL
+);
+
+CREATE TRIGGER trg_auditoria_checkpoints_somente_insercao
+	BEFORE UPDATE OR DELETE ON auditoria_checkpoints
+	FOR EACH ROW EXECUTE PROCEDURE impedir_alteracao_auditoria();
+
+CREATE TRIGGER trg_auditoria_checkpoints_sem_truncate
+	BEFORE TRUNCATE ON auditoria_checkpoints
+	FOR EACH STATEMENT EXECUTE PROCEDURE impedir_alteracao_auditoria();
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS tabela_estelionato_history;
+DROP FUNCTION IF EXISTS diff_jsonb(JSONB, JSONB);
+DROP FUNCTION IF EXISTS snapshot_envolvido(INTEGER);
@@ -0,0 +1,42 @@
+-- Histórico de alterações dos envolvidos. Cada linha guarda o estado do registro
+-- (linha de vw_envolvidos) antes e depois da alteração, a diferença campo a campo
+-- e o usuário responsável. O registro_id não tem chave estrangeira para que o
+-- histórico sobreviva à exclusão da participação.
+CREATE TABLE tabela_estelionato_history (
+	id BIGSERIAL PRIMARY KEY,
+	registro_id INTEGER NOT NULL,
+	acao VARCHAR(20) NOT NULL,
+	usuario_id INTEGER,
+	usuario_login VARCHAR(100) NOT NULL DEFAULT '',
+	motivo TEXT,
+	antes JSONB,
+	depois JSONB,
+	alteracoes JSONB NOT NULL DEFAULT '{}',
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_historico_registro ON tabela_estelionato_history(registro_id, id);
+CREATE INDEX idx_historico_usuario ON tabela_estelionato_history(usuario_id, criado_em);
+
+-- Estado de um envolvido como exposto pela API (sem colunas técnicas nem campos
+-- nulos). Retorna NULL se o registro não existe.
+CREATE OR REPLACE FUNCTION snapshot_envolvido(registro INTEGER) RETURNS JSONB AS $$
+	SELECT jsonb_strip_nulls(to_jsonb(v)) - ARRAY[
+		'id', 'ocorrencia_id', 'pessoa_id', 'created_at', 'updated_at', 'data_fato_ts',
+		'nascimento_data', 'valor_numerico', 'latitude_fato_num', 'longitude_fato_num', 'campos_tipados'
+	]
+	FROM vw_envolvidos v
+	WHERE v.id = registro
+$$ LANGUAGE sql STABLE;
+
+-- Diferença entre dois objetos: {"campo": {"antes": ..., "depois": ...}} apenas
+-- para os campos cujo valor mudou
+CREATE OR REPLACE FUNCTION diff_jsonb(antes JSONB, depois JSONB) RETURNS JSONB AS $$
+	SELECT COALESCE(
+		jsonb_object_agg(COALESCE(a.key, d.key), jsonb_build_object('antes', a.value, 'depois', d.value)),
+		'{}'::jsonb
+	)
+	FROM jsonb_each(COALESCE(antes, '{}'::jsonb)) a
+	FULL JOIN jsonb_each(COALESCE(depois, '{}'::jsonb)) d ON d.key = a.key
+	WHERE a.value IS DISTINCT FROM d.value
+$$ LANGUAGE sql IMMUTABLE;
@@ -0,0 +1,118 @@
+-- Exclusão lógica de envolvidos e unidade responsável pelo cadastro. Registros
+-- excluídos continuam em participacoes (com data, autor e motivo), mas deixam de
+-- aparecer em vw_envolvidos e, portanto, nas consultas, no dashboard e nas
+-- reincidências. vw_envolvidos_todos inclui os excluídos (usada na verificação
+-- de duplicatas da importação, para que um registro excluído não volte).
+ALTER TABLE participacoes
+	ADD COLUMN cadastrado_por INTEGER REFERENCES usuarios(id) ON DELETE SET NULL,
+	ADD COLUMN unidade_cadastro VARCHAR(200),
+	ADD COLUMN excluido_em TIMESTAMPTZ,
+	ADD COLUMN excluido_por INTEGER REFERENCES usuarios(id) ON DELETE SET NULL,
+	ADD COLUMN motivo_exclusao TEXT;
+
+CREATE INDEX idx_participacoes_excluidas ON participacoes(excluido_em) WHERE excluido_em IS NOT NULL;
+
+CREATE VIEW vw_envolvidos_todos AS
+SELECT
+	pa.id,
+	pa.ocorrencia_id,
+	pa.pessoa_id,
+	o.numero_do_bo,
+	pa.tipo_envolvido,
+	pe.nomecompleto,
+	pe.cpf,
+	pe.nomedamae,
+	pe.nascimento,
+	pe.nacionalidade,
+	pe.naturalidade,
+	pe.uf_envolvido,
+	pe.sexo_envolvido,
+	pa.telefone_envolvido,
+	o.data_fato,
+	o.cep_fato,
+	o.latitude_fato,
+	o.longitude_fato,
+	o.logradouro_fato,
+	o.numerocasa_fato,
+	o.bairro_fato,
+	o.municipio_fato,
+	o.pais_fato,
+	o.delegacia_responsavel,
+	o.situacao,
+	o.natureza,
+	o.relato_historico,
+	pa.instituicao_bancaria,
+	pa.endereco_ip,
+	pa.valor,
+	pa.pix_utilizado,
+	pa.numero_conta_bancaria,
+	pa.numero_boleto,
+	pa.processo_banco,
+	pa.numero_agencia_bancaria,
+	pa.cartao,
+	pa.terminal,
+	pa.tipo_pagamento,
+	pa.orgao_concessionaria,
+	pa.veiculo,
+	pa.terminal_conexao,
+	pa.erb,
+	pa.operacao_policial,
+	pa.numero_laudo_pericial,
+	pa.created_at,
+	pa.updated_at,
+	o.data_fato_ts,
+	pe.nascimento_data,
+	pa.valor_numerico,
+	o.latitude_fato_num,
+	o.longitude_fato_num,
+	pa.campos_tipados,
+	pa.excluido_em
+FROM participacoes pa
+JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+JOIN pessoas pe ON pe.id = pa.pessoa_id;
+
+-- SELECT * é expandido na criação da view: as migrations que acrescentam colunas
+-- a vw_envolvidos_todos recriam vw_envolvidos com esta mesma linha
+CREATE OR REPLACE VIEW vw_envolvidos AS SELECT * FROM vw_envolvidos_todos WHERE excluido_em IS NULL;
+
+-- Views materializadas do dashboard sem os registros excluídos
+DROP MATERIALIZED VIEW IF EXISTS mv_contagens_gerais;
+DROP MATERIALIZED VIEW IF EXISTS mv_infratores_por_delegacia;
+DROP MATERIALIZED VIEW IF EXISTS mv_vitimas_por_sexo;
+
+CREATE MATERIALIZED VIEW mv_vitimas_por_sexo AS
+SELECT
+	pe.sexo_envolvido,
+	COUNT(*) as quantidade
+FROM participacoes pa
+JOIN pessoas pe ON pe.id = pa.pessoa_id
+WHERE pa.tipo_envolvido IN ('Comunicante, Vítima', 'Vítima')
+	AND pa.excluido_em IS NULL
+	AND pe.sexo_envolvido IS NOT NULL
+GROUP BY pe.sexo_envolvido;
+
+CREATE MATERIALIZED VIEW mv_infratores_por_delegacia AS
+SELECT
+	o.delegacia_responsavel,
+	COUNT(*) as quantidade
+FROM participacoes pa
+JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+WHERE pa.tipo_envolvido = 'Suposto Autor/infrator'
+	AND pa.excluido_em IS NULL
+	AND o.delegacia_responsavel IS NOT NULL
+	AND o.delegacia_responsavel != ''
+GROUP BY o.delegacia_responsavel
+ORDER BY quantidade DESC
+LIMIT 10;
+
+CREATE MATERIALIZED VIEW mv_contagens_gerais AS
+SELECT
+	(SELECT COUNT(*) FROM ocorrencias o WHERE o.numero_do_bo IS NOT NULL
+		AND EXISTS (SELECT 1 FROM participacoes ativa WHERE ativa.ocorrencia_id = o.id AND ativa.excluido_em IS NULL)) as total_bos,
+	COUNT(CASE WHEN tipo_envolvido = 'Suposto Autor/infrator' THEN 1 END) as total_infratores,
+	COUNT(CASE WHEN tipo_envolvido IN ('Comunicante, Vítima', 'Vítima') THEN 1 END) as total_vitimas
+FROM participacoes
+WHERE excluido_em IS NULL;
+
+CREATE UNIQUE INDEX idx_mv_vitimas_sexo ON mv_vitimas_por_sexo(sexo_envolvido);
+CREATE INDEX idx_mv_delegacias_qtd ON mv_infratores_por_delegacia(quantidade DESC);
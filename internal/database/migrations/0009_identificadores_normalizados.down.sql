@@ -0,0 +1,83 @@
+-- Estado de um envolvido como exposto pela API (sem colunas técnicas nem campos
+-- nulos). Retorna NULL se o registro não existe.
+CREATE OR REPLACE FUNCTION snapshot_envolvido(registro INTEGER) RETURNS JSONB AS $$
+	SELECT jsonb_strip_nulls(to_jsonb(v)) - ARRAY[
+		'id', 'ocorrencia_id', 'pessoa_id', 'created_at', 'updated_at', 'data_fato_ts',
+		'nascimento_data', 'valor_numerico', 'latitude_fato_num', 'longitude_fato_num', 'campos_tipados'
+	]
+	FROM vw_envolvidos v
+	WHERE v.id = registro
+$$ LANGUAGE sql STABLE;
+
+-- Colunas adicionadas ao final das views não podem ser removidas com CREATE OR REPLACE
+DROP VIEW vw_envolvidos;
+DROP VIEW vw_envolvidos_todos;
+
+CREATE VIEW vw_envolvidos_todos AS
+SELECT
+	pa.id,
+	pa.ocorrencia_id,
+	pa.pessoa_id,
+	o.numero_do_bo,
+	pa.tipo_envolvido,
+	pe.nomecompleto,
+	pe.cpf,
+	pe.nomedamae,
+	pe.nascimento,
+	pe.nacionalidade,
+	pe.naturalidade,
+	pe.uf_envolvido,
+	pe.sexo_envolvido,
+	pa.telefone_envolvido,
+	o.data_fato,
+	o.cep_fato,
+	o.latitude_fato,
+	o.longitude_fato,
+	o.logradouro_fato,
+	o.numerocasa_fato,
+	o.bairro_fato,
+	o.municipio_fato,
+	o.pais_fato,
+	o.delegacia_responsavel,
+	o.situacao,
+	o.natureza,
+	o.relato_historico,
+	pa.instituicao_bancaria,
+	pa.endereco_ip,
+	pa.valor,
+	pa.pix_utilizado,
+	pa.numero_conta_bancaria,
+	pa.numero_boleto,
+	pa.processo_banco,
+	pa.numero_agencia_bancaria,
+	pa.cartao,
+	pa.terminal,
+	pa.tipo_pagamento,
+	pa.orgao_concessionaria,
+	pa.veiculo,
+	pa.terminal_conexao,
+	pa.erb,
+	pa.operacao_policial,
+	pa.numero_laudo_pericial,
+	pa.created_at,
+	pa.updated_at,
+	o.data_fato_ts,
+	pe.nascimento_data,
+	pa.valor_numerico,
+	o.latitude_fato_num,
+	o.longitude_fato_num,
+	pa.campos_tipados,
+	pa.excluido_em
+FROM participacoes pa
+JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+JOIN pessoas pe ON pe.id = pa.pessoa_id;
+
+CREATE VIEW vw_envolvidos AS SELECT * FROM vw_envolvidos_todos WHERE excluido_em IS NULL;
+
+DROP INDEX IF EXISTS idx_participacoes_telefone_pendente;
+DROP INDEX IF EXISTS idx_pessoas_cpf_pendente;
+DROP INDEX IF EXISTS idx_participacoes_telefone_normalizado;
+DROP INDEX IF EXISTS idx_pessoas_cpf_normalizado;
+
+ALTER TABLE participacoes DROP COLUMN IF EXISTS telefone_normalizado;
+ALTER TABLE pessoas DROP COLUMN IF EXISTS cpf_normalizado;
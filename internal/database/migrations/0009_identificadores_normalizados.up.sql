@@ -0,0 +1,88 @@
+-- Identificadores canônicos: CPF com 11 dígitos (pessoas.cpf_normalizado) e
+-- telefone em E.164 (participacoes.telefone_normalizado), calculados em Go
+-- (pacote normalize) em todas as gravações. NULL indica registro ainda não
+-- processado pelo backfill (fraudbase backfill identificadores); string vazia
+-- indica que o valor original não é um CPF/telefone reconhecível.
+ALTER TABLE pessoas ADD COLUMN cpf_normalizado VARCHAR(11);
+ALTER TABLE participacoes ADD COLUMN telefone_normalizado VARCHAR(16);
+
+CREATE INDEX idx_pessoas_cpf_normalizado ON pessoas(cpf_normalizado);
+CREATE INDEX idx_participacoes_telefone_normalizado ON participacoes(telefone_normalizado);
+CREATE INDEX idx_pessoas_cpf_pendente ON pessoas(id) WHERE cpf_normalizado IS NULL;
+CREATE INDEX idx_participacoes_telefone_pendente ON participacoes(id) WHERE telefone_normalizado IS NULL;
+
+-- Colunas novas de participacoes/pessoas entram no fim de vw_envolvidos_todos;
+-- vw_envolvidos é recriada para expandir o SELECT * (ver 0008)
+CREATE OR REPLACE VIEW vw_envolvidos_todos AS
+SELECT
+	pa.id,
+	pa.ocorrencia_id,
+	pa.pessoa_id,
+	o.numero_do_bo,
+	pa.tipo_envolvido,
+	pe.nomecompleto,
+	pe.cpf,
+	pe.nomedamae,
+	pe.nascimento,
+	pe.nacionalidade,
+	pe.naturalidade,
+	pe.uf_envolvido,
+	pe.sexo_envolvido,
+	pa.telefone_envolvido,
+	o.data_fato,
+	o.cep_fato,
+	o.latitude_fato,
+	o.longitude_fato,
+	o.logradouro_fato,
+	o.numerocasa_fato,
+	o.bairro_fato,
+	o.municipio_fato,
+	o.pais_fato,
+	o.delegacia_responsavel,
+	o.situacao,
+	o.natureza,
+	o.relato_historico,
+	pa.instituicao_bancaria,
+	pa.endereco_ip,
+	pa.valor,
+	pa.pix_utilizado,
+	pa.numero_conta_bancaria,
+	pa.numero_boleto,
+	pa.processo_banco,
+	pa.numero_agencia_bancaria,
+	pa.cartao,
+	pa.terminal,
+	pa.tipo_pagamento,
+	pa.orgao_concessionaria,
+	pa.veiculo,
+	pa.terminal_conexao,
+	pa.erb,
+	pa.operacao_policial,
+	pa.numero_laudo_pericial,
+	pa.created_at,
+	pa.updated_at,
+	o.data_fato_ts,
+	pe.nascimento_data,
+	pa.valor_numerico,
+	o.latitude_fato_num,
+	o.longitude_fato_num,
+	pa.campos_tipados,
+	pa.excluido_em,
+	pe.cpf_normalizado,
+	pa.telefone_normalizado
+FROM participacoes pa
+JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+JOIN pessoas pe ON pe.id = pa.pessoa_id;
+
+CREATE OR REPLACE VIEW vw_envolvidos AS SELECT * FROM vw_envolvidos_todos WHERE excluido_em IS NULL;
+
+-- snapshot_envolvido (ver 0007) passa a ignorar também as colunas normalizadas
+CREATE OR REPLACE FUNCTION snapshot_envolvido(registro INTEGER) RETURNS JSONB AS $$
+	SELECT jsonb_strip_nulls(to_jsonb(v)) - ARRAY[
+		'id', 'ocorrencia_id', 'pessoa_id', 'created_at', 'updated_at', 'data_fato_ts',
+		'nascimento_data', 'valor_numerico', 'latitude_fato_num', 'longitude_fato_num', 'campos_tipados',
+		'cpf_normalizado', 'telefone_normalizado'
+	]
+	FROM vw_envolvidos v
+	WHERE v.id = registro
+$$ LANGUAGE sql STABLE;
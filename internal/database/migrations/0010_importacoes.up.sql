@@ -0,0 +1,21 @@
+-- Importações de relatórios processadas em background. O upload apenas valida o
+-- arquivo e cria a importação; os workers atualizam o progresso a cada lote.
+CREATE TABLE import_jobs (
+	id BIGSERIAL PRIMARY KEY,
+	usuario_id INTEGER REFERENCES usuarios(id) ON DELETE SET NULL,
+	usuario_login VARCHAR(100) NOT NULL,
+	nome_arquivo VARCHAR(255) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pendente'
+		CHECK (status IN ('pendente', 'processando', 'concluido', 'erro')),
+	total_registros INTEGER NOT NULL DEFAULT 0,
+	processados INTEGER NOT NULL DEFAULT 0,
+	inseridos INTEGER NOT NULL DEFAULT 0,
+	duplicados INTEGER NOT NULL DEFAULT 0,
+	erros JSONB NOT NULL DEFAULT '[]',
+	criado_em TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	iniciado_em TIMESTAMPTZ,
+	concluido_em TIMESTAMPTZ
+);
+
+CREATE INDEX idx_import_jobs_usuario ON import_jobs(usuario_id, id DESC);
+CREATE INDEX idx_import_jobs_em_andamento ON import_jobs(status) WHERE status IN ('pendente', 'processando');
@@ -0,0 +1,8 @@
+DELETE FROM import_jobs WHERE status IN ('previa', 'expirada');
+
+ALTER TABLE import_jobs DROP CONSTRAINT import_jobs_status_check;
+ALTER TABLE import_jobs ADD CONSTRAINT import_jobs_status_check
+	CHECK (status IN ('pendente', 'processando', 'concluido', 'erro'));
+
+DROP INDEX IF EXISTS idx_import_jobs_em_andamento;
+CREATE INDEX idx_import_jobs_em_andamento ON import_jobs(status) WHERE status IN ('pendente', 'processando');
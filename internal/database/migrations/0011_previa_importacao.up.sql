@@ -0,0 +1,8 @@
+-- Prévias de importação (dryRun): a importação fica em 'previa' até ser confirmada
+-- e passa a 'expirada' se não for confirmada a tempo.
+ALTER TABLE import_jobs DROP CONSTRAINT import_jobs_status_check;
+ALTER TABLE import_jobs ADD CONSTRAINT import_jobs_status_check
+	CHECK (status IN ('previa', 'expirada', 'pendente', 'processando', 'concluido', 'erro'));
+
+DROP INDEX IF EXISTS idx_import_jobs_em_andamento;
+CREATE INDEX idx_import_jobs_em_andamento ON import_jobs(status) WHERE status IN ('previa', 'pendente', 'processando');
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_participacoes_import;
+ALTER TABLE participacoes DROP COLUMN IF EXISTS import_id;
+
+DROP INDEX IF EXISTS idx_import_jobs_sha256;
+
+UPDATE import_jobs SET status = 'erro' WHERE status = 'revertida';
+ALTER TABLE import_jobs DROP CONSTRAINT import_jobs_status_check;
+ALTER TABLE import_jobs ADD CONSTRAINT import_jobs_status_check
+	CHECK (status IN ('previa', 'expirada', 'pendente', 'processando', 'concluido', 'erro'));
+
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS revertido_por;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS revertido_em;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS estado;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS sha256;
@@ -0,0 +1,16 @@
+-- Procedência das importações: cada participação importada aponta para a
+-- importação que a criou, que guarda o arquivo (nome e SHA-256), o usuário e a
+-- UF usada como sufixo do número do BO. Uma importação pode ser revertida.
+ALTER TABLE import_jobs ADD COLUMN sha256 CHAR(64);
+ALTER TABLE import_jobs ADD COLUMN estado VARCHAR(50);
+ALTER TABLE import_jobs ADD COLUMN revertido_em TIMESTAMPTZ;
+ALTER TABLE import_jobs ADD COLUMN revertido_por INTEGER REFERENCES usuarios(id) ON DELETE SET NULL;
+
+ALTER TABLE import_jobs DROP CONSTRAINT import_jobs_status_check;
+ALTER TABLE import_jobs ADD CONSTRAINT import_jobs_status_check
+	CHECK (status IN ('previa', 'expirada', 'pendente', 'processando', 'concluido', 'erro', 'revertida'));
+
+CREATE INDEX idx_import_jobs_sha256 ON import_jobs(sha256);
+
+ALTER TABLE participacoes ADD COLUMN import_id BIGINT REFERENCES import_jobs(id);
+CREATE INDEX idx_participacoes_import ON participacoes(import_id) WHERE import_id IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS transacao;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS gravacao_parcial;
@@ -0,0 +1,9 @@
+-- Resultado da transação de cada importação. Por padrão a importação é tudo ou
+-- nada: 'confirmada' ou 'desfeita' (nenhum registro gravado). Com gravação parcial
+-- cada lote tem o seu savepoint e os lotes com erro são descartados ('parcial').
+ALTER TABLE import_jobs ADD COLUMN gravacao_parcial BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE import_jobs ADD COLUMN transacao VARCHAR(20)
+	CHECK (transacao IN ('confirmada', 'parcial', 'desfeita'));
+
+-- Das importações anteriores só se sabe que as concluídas foram gravadas por inteiro
+UPDATE import_jobs SET transacao = 'confirmada' WHERE status IN ('concluido', 'revertida');
@@ -0,0 +1,3 @@
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS alteracoes;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS atualizados;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS modo;
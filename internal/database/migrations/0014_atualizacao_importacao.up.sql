@@ -0,0 +1,8 @@
+-- Modo de atualização das importações: um BO exportado de novo atualiza os
+-- participantes já cadastrados (mesmo BO, mesma pessoa e mesmo papel) em vez de
+-- gerar registros duplicados. A importação guarda quantos foram atualizados e o
+-- relatório por BO do que mudou.
+ALTER TABLE import_jobs ADD COLUMN modo VARCHAR(20) NOT NULL DEFAULT 'inclusao'
+	CHECK (modo IN ('inclusao', 'atualizacao'));
+ALTER TABLE import_jobs ADD COLUMN atualizados INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN alteracoes JSONB NOT NULL DEFAULT '[]';
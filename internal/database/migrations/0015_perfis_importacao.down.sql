@@ -0,0 +1,2 @@
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS perfil;
+DROP TABLE IF EXISTS perfis_importacao;
@@ -0,0 +1,52 @@
+-- Perfis de importação: cada sistema estadual exporta o relatório com abas e
+-- cabeçalhos próprios. O perfil diz o nome de cada aba, os cabeçalhos aceitos
+-- para cada campo (apelidos), as colunas obrigatórias, como completar o número
+-- do BO e o trecho exigido no nome do arquivo. É escolhido no upload; sem
+-- escolha vale o perfil marcado como padrão.
+CREATE TABLE perfis_importacao (
+	id SERIAL PRIMARY KEY,
+	nome VARCHAR(100) NOT NULL UNIQUE,
+	descricao TEXT NOT NULL DEFAULT '',
+	padrao_arquivo VARCHAR(200) NOT NULL DEFAULT '',
+	planilhas JSONB NOT NULL,
+	regra_sufixo VARCHAR(20) NOT NULL DEFAULT 'uf_usuario'
+		CHECK (regra_sufixo IN ('uf_usuario', 'fixo', 'nenhum')),
+	sufixo VARCHAR(10) NOT NULL DEFAULT '',
+	padrao BOOLEAN NOT NULL DEFAULT FALSE,
+	criado_em TIMESTAMP NOT NULL DEFAULT NOW(),
+	atualizado_em TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- No máximo um perfil padrão
+CREATE UNIQUE INDEX idx_perfis_importacao_padrao ON perfis_importacao (padrao) WHERE padrao;
+
+-- Leiaute que o parser esperava até aqui
+INSERT INTO perfis_importacao (nome, descricao, padrao_arquivo, planilhas, regra_sufixo, padrao)
+VALUES ('padrao', 'Relatório "Resultado da pesquisa de BO"', 'resultado_da_pesquisa_bo_', '{
+	"registro": {
+		"nome": "Dados do Registro",
+		"colunas": {"id": ["Id"], "numero": ["Número"], "unidade": ["Unidade de Apuração"], "situacao": ["Situação"], "naturezas": ["Naturezas"]},
+		"obrigatorias": ["id", "numero", "unidade", "situacao", "naturezas"]
+	},
+	"fato": {
+		"nome": "Dados do Fato",
+		"colunas": {"id": ["Id"], "data_hora": ["Data/Hora Início"], "cep": ["CEP"], "latitude": ["Latitude"], "longitude": ["Longitude"],
+			"logradouro": ["Logradouro"], "numero": ["Número"], "bairro": ["Bairro"], "municipio": ["Município"], "pais": ["Pais"]},
+		"obrigatorias": ["id"]
+	},
+	"envolvidos": {
+		"nome": "Envolvidos",
+		"colunas": {"id": ["Id"], "participacao": ["Participações"], "nome": ["Nome Completo"], "cpf": ["CPF"], "filiacao": ["Filiação 1"],
+			"nascimento": ["Data de Nascimento"], "nacionalidade": ["Nacionalidade"], "naturalidade": ["Naturalidade"], "uf": ["UF"],
+			"sexo": ["Sexo"], "telefone": ["Telefone"]},
+		"obrigatorias": ["id"]
+	},
+	"relato": {
+		"nome": "Relato Histórico",
+		"colunas": {"id": ["Id"], "relato": ["Relato / Histórico"]},
+		"obrigatorias": ["id", "relato"]
+	}
+}', 'uf_usuario', TRUE);
+
+-- Perfil usado em cada importação (o nome, que continua legível se o perfil for apagado)
+ALTER TABLE import_jobs ADD COLUMN perfil VARCHAR(100);
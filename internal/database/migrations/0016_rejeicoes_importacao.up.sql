@@ -0,0 +1,15 @@
+-- Linhas do relatório que uma importação deixou de gravar (sem Id, sem
+-- correspondente na aba de registro, duplicatas ou lotes descartados), com a aba
+-- e a linha de origem e o motivo, para o relatório de rejeições da importação.
+CREATE TABLE import_rejeicoes (
+	id BIGSERIAL PRIMARY KEY,
+	import_id BIGINT NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+	planilha TEXT NOT NULL,
+	linha INTEGER NOT NULL,
+	motivo TEXT NOT NULL,
+	numero_do_bo TEXT NOT NULL DEFAULT '',
+	nomecompleto TEXT NOT NULL DEFAULT '',
+	cpf TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_import_rejeicoes_import ON import_rejeicoes (import_id, id);
@@ -0,0 +1,15 @@
+-- Volta proteger_auditoria_consultas à definição de 0006
+CREATE OR REPLACE FUNCTION proteger_auditoria_consultas() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP = 'UPDATE' AND OLD.hash IS NULL AND NEW.hash IS NOT NULL AND NEW.hash_anterior IS NOT NULL
+		AND (NEW.id, NEW.usuario_id, NEW.usuario_login, NEW.endpoint, NEW.parametros,
+			NEW.quantidade_resultados, NEW.ip, NEW.criado_em)
+		IS NOT DISTINCT FROM (OLD.id, OLD.usuario_id, OLD.usuario_login, OLD.endpoint, OLD.parametros,
+			OLD.quantidade_resultados, OLD.ip, OLD.criado_em) THEN
+		RETURN NEW;
+	END IF;
+	RAISE EXCEPTION 'auditoria_consultas é somente de inserção (% bloqueado)', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS auditoria_legado;
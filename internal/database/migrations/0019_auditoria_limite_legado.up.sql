@@ -0,0 +1,32 @@
+-- Só podem ser selados os registros gravados antes da cadeia de hashes (0006).
+-- O limite é o maior id anterior à aplicação de 0006; qualquer registro posterior
+-- sem hash foi adulterado e não pode ser selado de novo.
+CREATE TABLE auditoria_legado (
+	ultimo_registro_id BIGINT NOT NULL
+);
+
+INSERT INTO auditoria_legado (ultimo_registro_id)
+SELECT COALESCE(MAX(id), 0) FROM auditoria_consultas
+WHERE criado_em < (SELECT applied_at FROM schema_migrations WHERE version = 6);
+
+CREATE TRIGGER trg_auditoria_legado_somente_insercao
+	BEFORE UPDATE OR DELETE ON auditoria_legado
+	FOR EACH ROW EXECUTE PROCEDURE impedir_alteracao_auditoria();
+
+CREATE TRIGGER trg_auditoria_legado_sem_truncate
+	BEFORE TRUNCATE ON auditoria_legado
+	FOR EACH STATEMENT EXECUTE PROCEDURE impedir_alteracao_auditoria();
+
+CREATE OR REPLACE FUNCTION proteger_auditoria_consultas() RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP = 'UPDATE' AND OLD.hash IS NULL AND NEW.hash IS NOT NULL AND NEW.hash_anterior IS NOT NULL
+		AND OLD.id <= (SELECT ultimo_registro_id FROM auditoria_legado)
+		AND (NEW.id, NEW.usuario_id, NEW.usuario_login, NEW.endpoint, NEW.parametros,
+			NEW.quantidade_resultados, NEW.ip, NEW.criado_em)
+		IS NOT DISTINCT FROM (OLD.id, OLD.usuario_id, OLD.usuario_login, OLD.endpoint, OLD.parametros,
+			OLD.quantidade_resultados, OLD.ip, OLD.criado_em) THEN
+		RETURN NEW;
+	END IF;
+	RAISE EXCEPTION 'auditoria_consultas é somente de inserção (% bloqueado)', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
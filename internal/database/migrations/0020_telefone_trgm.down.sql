@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_participacoes_telefone_digitos_trgm;
+DROP INDEX IF EXISTS idx_participacoes_telefone_normalizado_trgm;
@@ -0,0 +1,9 @@
+-- Busca parcial por telefone (LIKE '%dígitos%'): índices trigram nos dígitos do
+-- telefone normalizado e, para os telefones que não puderam ser normalizados, nos
+-- dígitos do telefone original
+CREATE INDEX idx_participacoes_telefone_normalizado_trgm ON participacoes
+	USING gin(telefone_normalizado gin_trgm_ops);
+
+CREATE INDEX idx_participacoes_telefone_digitos_trgm ON participacoes
+	USING gin(regexp_replace(telefone_envolvido, '\D', '', 'g') gin_trgm_ops)
+	WHERE COALESCE(telefone_normalizado, '') = '';
@@ -0,0 +1,4 @@
+-- Processo (instância da API ou ingestão por pasta) que processa cada importação.
+-- Ao reiniciar, cada processo encerra apenas as importações que eram suas; as
+-- anteriores a esta coluna ficam sem dono e são encerradas pelo primeiro que subir.
+ALTER TABLE import_jobs ADD COLUMN processo VARCHAR(255);
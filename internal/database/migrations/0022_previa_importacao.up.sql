@@ -0,0 +1,4 @@
+-- Resultado da prévia (dryRun), calculada em background: contagens, amostras dos
+-- registros e linhas ignoradas. Fica vazio enquanto a prévia é calculada e é
+-- apagado quando ela é confirmada ou expira.
+ALTER TABLE import_jobs ADD COLUMN previa JSONB;
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+)
+
+// RefreshMaterializedViews atualiza as views materializadas do dashboard.
+// Todas as views são atualizadas mesmo que uma falhe; o primeiro erro é retornado.
+func RefreshMaterializedViews(db *sql.DB) error {
+	views := []string{
+		"REFRESH MATERIALIZED VIEW mv_vitimas_por_sexo;",
+		"REFRESH MATERIALIZED VIEW mv_infratores_por_delegacia;",
+		"REFRESH MATERIALIZED VIEW mv_contagens_gerais;",
+	}
+
+	var primeiroErro error
+	for _, refreshQuery := range views {
+		if _, err := db.Exec(refreshQuery); err != nil {
+			log.Printf("Erro ao atualizar view materializada: %v", err)
+			if primeiroErro == nil {
+				primeiroErro = err
+			}
+		}
+	}
+
+	return primeiroErro
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fraudbase/internal/jobs"
+	"fraudbase/internal/models"
+	"fraudbase/internal/repository"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AneisHandler manipula requisições relacionadas aos anéis de fraude
+type AneisHandler struct {
+	aneisRepo     *repository.AneisRepository
+	aneisJob      *jobs.AneisJob
+	auditoriaRepo *repository.AuditoriaRepository
+}
+
+// NewAneisHandler cria um novo handler para anéis de fraude
+func NewAneisHandler(aneisRepo *repository.AneisRepository, aneisJob *jobs.AneisJob, auditoriaRepo *repository.AuditoriaRepository) *AneisHandler {
+	return &AneisHandler{aneisRepo: aneisRepo, aneisJob: aneisJob, auditoriaRepo: auditoriaRepo}
+}
+
+// GetAneis retorna o ranking paginado dos maiores anéis de fraude
+func (h *AneisHandler) GetAneis(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para ranking de anéis de fraude")
+
+	// Obter parâmetros de paginação
+	page := 1
+	limit := 10
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if pageNum, err := strconv.Atoi(pageStr); err == nil && pageNum > 0 {
+			page = pageNum
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limitNum, err := strconv.Atoi(limitStr); err == nil && limitNum > 0 {
+			limit = limitNum
+		}
+	}
+
+	aneis, total, err := h.aneisRepo.GetAneis(page, limit)
+	if err != nil {
+		log.Printf("Erro ao buscar anéis de fraude: %v", err)
+		http.Error(w, "Erro ao buscar anéis de fraude", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Data       []repository.AnelFraude `json:"data"`
+		TotalCount int                     `json:"totalCount"`
+		Page       int                     `json:"page"`
+		Limit      int                     `json:"limit"`
+		TotalPages int                     `json:"totalPages"`
+	}{
+		Data:       aneis,
+		TotalCount: total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: (total + limit - 1) / limit,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAnelByID retorna um anel de fraude com os registros que o compõem
+func (h *AneisHandler) GetAnelByID(w http.ResponseWriter, r *http.Request) {
+	ringID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	anel, registros, err := h.aneisRepo.GetAnelByID(ringID)
+	if err != nil {
+		log.Printf("Erro ao buscar anel de fraude: %v", err)
+		if err == repository.ErrNotFound {
+			http.Error(w, "Anel de fraude não encontrado", http.StatusNotFound)
+		} else {
+			http.Error(w, "Erro ao buscar anel de fraude", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Os registros do anel trazem os dados das pessoas envolvidas
+	if err := registrarConsulta(h.auditoriaRepo, r, len(registros)); err != nil {
+		http.Error(w, "Erro ao registrar auditoria da consulta", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		repository.AnelFraude
+		Registros []models.Envolvido `json:"registros"`
+	}{
+		AnelFraude: anel,
+		Registros:  registros,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+		http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		return
+	}
+}
+
+// RecalcularAneis dispara o recálculo dos anéis de fraude em background
+func (h *AneisHandler) RecalcularAneis(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para recalcular anéis de fraude")
+
+	go h.aneisJob.Executar()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Recálculo de anéis de fraude iniciado",
+	})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fraudbase/internal/auth"
+	"fraudbase/internal/middleware"
+	"fraudbase/internal/normalize"
+	"fraudbase/internal/repository"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// registrarConsulta grava na trilha de auditoria a consulta feita na requisição:
+// usuário do token, rota, parâmetros (query string e variáveis da rota), quantidade
+// de resultados devolvidos e IP de origem. Se a auditoria falhar, o handler não
+// deve devolver os dados.
+func registrarConsulta(repo *repository.AuditoriaRepository, r *http.Request, quantidade int) error {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		return errors.New("usuário não autenticado")
+	}
+
+	endpoint := r.URL.Path
+	if rota := mux.CurrentRoute(r); rota != nil {
+		if template, err := rota.GetPathTemplate(); err == nil {
+			endpoint = template
+		}
+	}
+
+	parametros := make(map[string]string)
+	for chave, valores := range r.URL.Query() {
+		if valor := strings.Join(valores, ","); valor != "" {
+			parametros[chave] = valor
+		}
+	}
+	for chave, valor := range mux.Vars(r) {
+		parametros[chave] = valor
+	}
+
+	return repo.Registrar(repository.RegistroAuditoria{
+		UsuarioID:            claims.UserID,
+		UsuarioLogin:         claims.Username,
+		Endpoint:             endpoint,
+		Parametros:           parametros,
+		QuantidadeResultados: quantidade,
+		IP:                   ipOrigem(r),
+	})
+}
+
+// ipOrigem retorna o IP do cliente. O cabeçalho X-Real-IP só é aceito quando a
+// conexão vem de um endereço privado (o proxy reverso do frontend).
+func ipOrigem(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip != nil && (ip.IsLoopback() || ip.IsPrivate()) {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+	}
+
+	return host
+}
+
+// AuditoriaHandler expõe a trilha de auditoria para a corregedoria (somente admin)
+type AuditoriaHandler struct {
+	auditoriaRepo *repository.AuditoriaRepository
+}
+
+// NewAuditoriaHandler cria um novo handler de auditoria
+func NewAuditoriaHandler(auditoriaRepo *repository.AuditoriaRepository) *AuditoriaHandler {
+	return &AuditoriaHandler{auditoriaRepo: auditoriaRepo}
+}
+
+// filtroAuditoria lê os filtros da query string. Datas no formato AAAA-MM-DD;
+// "ate" é inclusivo (considera o dia inteiro).
+func filtroAuditoria(r *http.Request) (repository.FiltroAuditoria, error) {
+	q := r.URL.Query()
+	f := repository.FiltroAuditoria{
+		Login:    q.Get("login"),
+		Endpoint: q.Get("endpoint"),
+		Termo:    q.Get("termo"),
+		IP:       q.Get("ip"),
+	}
+
+	if usuario := q.Get("usuario_id"); usuario != "" {
+		id, err := strconv.Atoi(usuario)
+		if err != nil {
+			return f, errors.New("usuario_id inválido")
+		}
+		f.UsuarioID = id
+	}
+
+	if de := q.Get("de"); de != "" {
+		t, err := time.ParseInLocation("2006-01-02", de, normalize.FusoBrasilia)
+		if err != nil {
+			return f, errors.New("data inicial inválida (use AAAA-MM-DD)")
+		}
+		f.De = &t
+	}
+	if ate := q.Get("ate"); ate != "" {
+		t, err := time.ParseInLocation("2006-01-02", ate, normalize.FusoBrasilia)
+		if err != nil {
+			return f, errors.New("data final inválida (use AAAA-MM-DD)")
+		}
+		t = t.AddDate(0, 0, 1)
+		f.Ate = &t
+	}
+
+	return f, nil
+}
+
+// GetAuditoria lista a trilha de auditoria com filtros e paginação, ou exporta
+// todos os registros filtrados em CSV quando formato=csv
+func (h *AuditoriaHandler) GetAuditoria(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para consultar auditoria")
+
+	filtro, err := filtroAuditoria(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if r.URL.Query().Get("formato") == "csv" {
+		h.exportarCSV(w, filtro)
+		return
+	}
+
+	page := 1
+	limit := 50
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if pageNum, err := strconv.Atoi(pageStr); err == nil && pageNum > 0 {
+			page = pageNum
+		}
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limitNum, err := strconv.Atoi(limitStr); err == nil && limitNum > 0 && limitNum <= 500 {
+			limit = limitNum
+		}
+	}
+
+	registros, totalCount, err := h.auditoriaRepo.Buscar(filtro, page, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao consultar auditoria")
+		return
+	}
+
+	response := struct {
+		Data       []repository.RegistroAuditoria `json:"data"`
+		TotalCount int                            `json:"totalCount"`
+		Page       int                            `json:"page"`
+		Limit      int                            `json:"limit"`
+		TotalPages int                            `json:"totalPages"`
+	}{
+		Data:       registros,
+		TotalCount: totalCount,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: (totalCount + limit - 1) / limit,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
+
+// VerificarAuditoria percorre a cadeia de hashes e os checkpoints assinados e
+// informa o primeiro elo quebrado, se houver
+func (h *AuditoriaHandler) VerificarAuditoria(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para verificar a cadeia de auditoria")
+
+	resultado, err := h.auditoriaRepo.VerificarCadeia()
+	if err != nil {
+		log.Printf("Erro ao verificar cadeia de auditoria: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao verificar cadeia de auditoria")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resultado); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
+
+func (h *AuditoriaHandler) exportarCSV(w http.ResponseWriter, filtro repository.FiltroAuditoria) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=auditoria_"+time.Now().Format("20060102_150405")+".csv")
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "data_hora", "usuario_id", "usuario_login", "endpoint", "parametros", "quantidade_resultados", "ip"})
+
+	err := h.auditoriaRepo.Exportar(filtro, func(reg repository.RegistroAuditoria) error {
+		return writer.Write([]string{
+			strconv.FormatInt(reg.ID, 10),
+			reg.CriadoEm.In(normalize.FusoBrasilia).Format("02/01/2006 15:04:05"),
+			strconv.Itoa(reg.UsuarioID),
+			celulaCSV(reg.UsuarioLogin),
+			celulaCSV(reg.Endpoint),
+			celulaCSV(formatarParametros(reg.Parametros)),
+			strconv.Itoa(reg.QuantidadeResultados),
+			reg.IP,
+		})
+	})
+	if err != nil {
+		// O cabeçalho já foi enviado; o erro fica registrado no log
+		log.Printf("Erro ao exportar auditoria em CSV: %v", err)
+	}
+
+	writer.Flush()
+}
+
+// formatarParametros gera "chave=valor; ..." em ordem alfabética de chave
+func formatarParametros(parametros map[string]string) string {
+	chaves := make([]string, 0, len(parametros))
+	for chave := range parametros {
+		chaves = append(chaves, chave)
+	}
+	sort.Strings(chaves)
+
+	pares := make([]string, len(chaves))
+	for i, chave := range chaves {
+		pares[i] = chave + "=" + parametros[chave]
+	}
+	return strings.Join(pares, "; ")
+}
+
+// celulaCSV neutraliza valores que planilhas interpretariam como fórmula
+func celulaCSV(valor string) string {
+	if valor != "" && strings.ContainsRune("=+-@\t\r", rune(valor[0])) {
+		return "'" + valor
+	}
+	return valor
+}
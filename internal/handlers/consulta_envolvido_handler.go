@@ -1,23 +1,30 @@
 package handlers
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 	"fraudbase/internal/models"
+	"fraudbase/internal/normalize"
 	"fraudbase/internal/repository"
 	"github.com/gorilla/mux"
+	"github.com/xuri/excelize/v2"
 )
 
 // ConsultaEnvolvidoHandler manipula requisições para consulta de envolvidos
 type ConsultaEnvolvidoHandler struct {
-	consultaRepo *repository.ConsultaRepository
+	consultaRepo  *repository.ConsultaRepository
+	historicoRepo *repository.HistoricoRepository
+	auditoriaRepo *repository.AuditoriaRepository
 }
 
 // NewConsultaEnvolvidoHandler cria um novo handler para consulta de envolvidos
-func NewConsultaEnvolvidoHandler(consultaRepo *repository.ConsultaRepository) *ConsultaEnvolvidoHandler {
-	return &ConsultaEnvolvidoHandler{consultaRepo: consultaRepo}
+func NewConsultaEnvolvidoHandler(consultaRepo *repository.ConsultaRepository, historicoRepo *repository.HistoricoRepository, auditoriaRepo *repository.AuditoriaRepository) *ConsultaEnvolvidoHandler {
+	return &ConsultaEnvolvidoHandler{consultaRepo: consultaRepo, historicoRepo: historicoRepo, auditoriaRepo: auditoriaRepo}
 }
 
 // GetEnvolvidos busca envolvidos com filtros opcionais e paginação
@@ -55,6 +62,12 @@ func (h *ConsultaEnvolvidoHandler) GetEnvolvidos(w http.ResponseWriter, r *http.
 		return
 	}
 	
+	// Registrar a consulta na trilha de auditoria antes de devolver os dados
+	if err := registrarConsulta(h.auditoriaRepo, r, len(envolvidos)); err != nil {
+		http.Error(w, "Erro ao registrar auditoria da consulta", http.StatusInternalServerError)
+		return
+	}
+	
 	// Calcular total de páginas
 	totalPages := (totalCount + limit - 1) / limit
 	
@@ -103,6 +116,11 @@ func (h *ConsultaEnvolvidoHandler) GetEnvolvidoById(w http.ResponseWriter, r *ht
 	if err != nil {
 		log.Printf("Erro ao buscar envolvido: %v", err)
 		if err == repository.ErrNotFound {
+			// A tentativa também fica na trilha: revela se o id existe
+			if err := registrarConsulta(h.auditoriaRepo, r, 0); err != nil {
+				http.Error(w, "Erro ao registrar auditoria da consulta", http.StatusInternalServerError)
+				return
+			}
 			http.Error(w, "Envolvido não encontrado", http.StatusNotFound)
 		} else {
 			http.Error(w, "Erro ao buscar envolvido", http.StatusInternalServerError)
@@ -110,6 +128,12 @@ func (h *ConsultaEnvolvidoHandler) GetEnvolvidoById(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// Registrar a consulta na trilha de auditoria antes de devolver os dados
+	if err := registrarConsulta(h.auditoriaRepo, r, 1); err != nil {
+		http.Error(w, "Erro ao registrar auditoria da consulta", http.StatusInternalServerError)
+		return
+	}
+
 	// Retornar o envolvido como JSON
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(envolvido); err != nil {
@@ -118,3 +142,314 @@ func (h *ConsultaEnvolvidoHandler) GetEnvolvidoById(w http.ResponseWriter, r *ht
 		return
 	}
 }
+
+// GetHistoricoEnvolvido retorna as alterações de um envolvido (importação, cadastro,
+// edições e exclusão) em ordem cronológica. O histórico de registros já excluídos
+// continua disponível.
+func (h *ConsultaEnvolvidoHandler) GetHistoricoEnvolvido(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para consultar histórico de envolvido")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("ID inválido: %v", err)
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	historico, err := h.historicoRepo.GetHistorico(id)
+	if err != nil {
+		http.Error(w, "Erro ao buscar histórico do envolvido", http.StatusInternalServerError)
+		return
+	}
+
+	// Registros anteriores ao histórico não têm entradas; só é 404 se o registro não existe
+	if len(historico) == 0 {
+		if _, err := h.consultaRepo.FindEnvolvidoById(id); err != nil {
+			if err == repository.ErrNotFound {
+				http.Error(w, "Envolvido não encontrado", http.StatusNotFound)
+			} else {
+				http.Error(w, "Erro ao buscar envolvido", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	// Registrar a consulta na trilha de auditoria antes de devolver os dados
+	if err := registrarConsulta(h.auditoriaRepo, r, len(historico)); err != nil {
+		http.Error(w, "Erro ao registrar auditoria da consulta", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		RegistroID int                           `json:"registro_id"`
+		Data       []repository.EntradaHistorico `json:"data"`
+	}{
+		RegistroID: id,
+		Data:       historico,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
+
+// limiteLinhasXLSX é o máximo de registros em uma planilha: o Excel aceita
+// 1.048.576 linhas, uma delas o cabeçalho
+const limiteLinhasXLSX = 1048575
+
+// colunasExportacaoEnvolvidos são o título e a largura de cada coluna da
+// exportação, na ordem de linhaExportacaoEnvolvido
+var colunasExportacaoEnvolvidos = []struct {
+	titulo  string
+	largura float64
+}{
+	{"ID", 10}, {"Número do BO", 22}, {"Tipo de envolvimento", 20}, {"Nome completo", 36}, {"CPF", 16},
+	{"Nome da mãe", 36}, {"Nascimento", 14}, {"Nacionalidade", 16}, {"Naturalidade", 20}, {"UF", 6},
+	{"Sexo", 12}, {"Telefone", 18}, {"Data do fato", 20}, {"CEP do fato", 12}, {"Latitude", 14},
+	{"Longitude", 14}, {"Logradouro", 32}, {"Número", 10}, {"Bairro", 22}, {"Município", 22},
+	{"País", 14}, {"Delegacia responsável", 32}, {"Situação", 18}, {"Natureza", 32}, {"Relato histórico", 60},
+	{"Instituição bancária", 24}, {"Endereço IP", 18}, {"Valor", 14}, {"PIX utilizado", 24},
+	{"Conta bancária", 18}, {"Boleto", 24}, {"Processo do banco", 20}, {"Agência bancária", 16},
+	{"Cartão", 20}, {"Terminal", 16}, {"Tipo de pagamento", 18}, {"Órgão/concessionária", 24},
+	{"Veículo", 18}, {"Terminal de conexão", 20}, {"ERB", 16}, {"Operação policial", 20},
+	{"Laudo pericial", 18},
+}
+
+func linhaExportacaoEnvolvido(e models.Envolvido) []interface{} {
+	return []interface{}{
+		e.ID, e.NumeroBO, e.TipoEnvolvido, e.NomeCompleto, e.CPF,
+		e.NomeMae, e.Nascimento, e.Nacionalidade, e.Naturalidade, e.UFEnvolvido,
+		e.SexoEnvolvido, e.TelefoneEnvolvido, e.DataFato, e.CEPFato, e.LatitudeFato,
+		e.LongitudeFato, e.LogradouroFato, e.NumeroCasaFato, e.BairroFato, e.MunicipioFato,
+		e.PaisFato, e.DelegaciaResponsavel, e.Situacao, e.Natureza, e.RelatoHistorico,
+		e.InstituicaoBancaria, e.EnderecoIP, e.Valor, e.PixUtilizado,
+		e.NumeroContaBancaria, e.NumeroBoleto, e.ProcessoBanco, e.NumeroAgenciaBancaria,
+		e.Cartao, e.Terminal, e.TipoPagamento, e.OrgaoConcessionaria,
+		e.Veiculo, e.TerminalConexao, e.ERB, e.OperacaoPolicial,
+		e.NumeroLaudoPericial,
+	}
+}
+
+// ExportarEnvolvidos baixa todos os envolvidos que atendem aos filtros da
+// consulta (nome, cpf, bo, telefone), com todas as colunas, em XLSX (padrão) ou
+// CSV (format=csv). A exportação é registrada na auditoria com a quantidade de
+// registros antes do envio.
+func (h *ConsultaEnvolvidoHandler) ExportarEnvolvidos(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para exportar envolvidos")
+
+	queryParams := r.URL.Query()
+	nome := queryParams.Get("nome")
+	cpf := queryParams.Get("cpf")
+	bo := queryParams.Get("bo")
+	telefone := queryParams.Get("telefone")
+
+	formato := queryParams.Get("format")
+	if formato == "" {
+		formato = "xlsx"
+	}
+	if formato != "xlsx" && formato != "csv" {
+		respondWithError(w, http.StatusBadRequest, "Formato inválido (use xlsx ou csv)")
+		return
+	}
+
+	total, err := h.consultaRepo.ContarEnvolvidos(nome, cpf, bo, telefone)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar envolvidos")
+		return
+	}
+	if formato == "xlsx" && total > limiteLinhasXLSX {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("O resultado tem %d registros, mais do que cabe em uma planilha; refine os filtros ou exporte em CSV", total))
+		return
+	}
+
+	// Registrar a exportação na trilha de auditoria antes de devolver os dados
+	if err := registrarConsulta(h.auditoriaRepo, r, total); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao registrar auditoria da consulta")
+		return
+	}
+
+	nomeArquivo := "envolvidos_" + time.Now().Format("20060102_150405")
+	if formato == "csv" {
+		h.exportarEnvolvidosCSV(w, nomeArquivo, nome, cpf, bo, telefone)
+	} else {
+		h.exportarEnvolvidosXLSX(w, nomeArquivo, nome, cpf, bo, telefone)
+	}
+}
+
+func (h *ConsultaEnvolvidoHandler) exportarEnvolvidosCSV(w http.ResponseWriter, nomeArquivo, nome, cpf, bo, telefone string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename="+nomeArquivo+".csv")
+
+	writer := csv.NewWriter(w)
+	titulos := make([]string, len(colunasExportacaoEnvolvidos))
+	for i, coluna := range colunasExportacaoEnvolvidos {
+		titulos[i] = coluna.titulo
+	}
+	writer.Write(titulos)
+
+	err := h.consultaRepo.ExportarEnvolvidos(nome, cpf, bo, telefone, func(e models.Envolvido) error {
+		valores := linhaExportacaoEnvolvido(e)
+		campos := make([]string, len(valores))
+		for i, valor := range valores {
+			if texto, ok := valor.(string); ok {
+				campos[i] = celulaCSV(texto)
+			} else {
+				campos[i] = fmt.Sprint(valor)
+			}
+		}
+		return writer.Write(campos)
+	})
+	if err != nil {
+		// O cabeçalho já foi enviado; o erro fica registrado no log
+		log.Printf("Erro ao exportar envolvidos em CSV: %v", err)
+	}
+
+	writer.Flush()
+}
+
+// formatoCelulaXLSX indica como o texto de uma coluna vira célula na planilha
+type formatoCelulaXLSX int
+
+const (
+	formatoTexto formatoCelulaXLSX = iota
+	formatoData
+	formatoDataHora
+	formatoValor
+	formatoCoordenada
+)
+
+// formatosColunasXLSX são as colunas exportadas como data ou número na
+// planilha; as demais vão como texto, como no CSV
+var formatosColunasXLSX = map[string]formatoCelulaXLSX{
+	"Nascimento":   formatoData,
+	"Data do fato": formatoDataHora,
+	"Latitude":     formatoCoordenada,
+	"Longitude":    formatoCoordenada,
+	"Valor":        formatoValor,
+}
+
+// estilosFormatosXLSX cria na planilha o estilo de cada formato de célula
+func estilosFormatosXLSX(planilha *excelize.File) (map[formatoCelulaXLSX]int, error) {
+	dataDMA, dataHora, coordenada := "dd/mm/yyyy", "dd/mm/yyyy hh:mm", "0.000000"
+	definicoes := map[formatoCelulaXLSX]*excelize.Style{
+		formatoData:       {CustomNumFmt: &dataDMA},
+		formatoDataHora:   {CustomNumFmt: &dataHora},
+		formatoValor:      {NumFmt: 4}, // #,##0.00
+		formatoCoordenada: {CustomNumFmt: &coordenada},
+	}
+	estilos := make(map[formatoCelulaXLSX]int, len(definicoes))
+	for formato, definicao := range definicoes {
+		estilo, err := planilha.NewStyle(definicao)
+		if err != nil {
+			return nil, err
+		}
+		estilos[formato] = estilo
+	}
+	return estilos, nil
+}
+
+// celulaExportacaoXLSX converte o valor de uma coluna no formato dela. Textos
+// que não são reconhecidos como data ou número ficam como estão; textos vazios
+// não geram célula.
+func celulaExportacaoXLSX(valor interface{}, formato formatoCelulaXLSX, estilos map[formatoCelulaXLSX]int) interface{} {
+	texto, ok := valor.(string)
+	if !ok {
+		return valor
+	}
+	if texto == "" {
+		return nil
+	}
+
+	switch formato {
+	case formatoData, formatoDataHora:
+		if data, ok := normalize.Data(texto); ok {
+			// O excelize conta os dias a partir da época em UTC: mantém o horário de Brasília
+			data = time.Date(data.Year(), data.Month(), data.Day(), data.Hour(), data.Minute(), data.Second(), 0, time.UTC)
+			return excelize.Cell{StyleID: estilos[formato], Value: data}
+		}
+	case formatoValor:
+		if numero, ok := normalize.Valor(texto); ok {
+			return excelize.Cell{StyleID: estilos[formato], Value: numero}
+		}
+	case formatoCoordenada:
+		if numero, ok := normalize.Coordenada(texto); ok {
+			return excelize.Cell{StyleID: estilos[formato], Value: numero}
+		}
+	}
+	return texto
+}
+
+func (h *ConsultaEnvolvidoHandler) exportarEnvolvidosXLSX(w http.ResponseWriter, nomeArquivo, nome, cpf, bo, telefone string) {
+	planilha := excelize.NewFile()
+	defer planilha.Close()
+
+	const aba = "Envolvidos"
+	planilha.SetSheetName("Sheet1", aba)
+	sw, err := planilha.NewStreamWriter(aba)
+	if err != nil {
+		log.Printf("Erro ao criar planilha de envolvidos: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao gerar planilha de envolvidos")
+		return
+	}
+	estilos, err := estilosFormatosXLSX(planilha)
+	if err != nil {
+		log.Printf("Erro ao criar estilos da planilha de envolvidos: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao gerar planilha de envolvidos")
+		return
+	}
+	estiloTitulo, err := planilha.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"1F4E78"}},
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	})
+	if err != nil {
+		log.Printf("Erro ao criar estilos da planilha de envolvidos: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao gerar planilha de envolvidos")
+		return
+	}
+
+	// Títulos fixos ao rolar; painéis e larguras precisam vir antes das linhas
+	sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	titulos := make([]interface{}, len(colunasExportacaoEnvolvidos))
+	formatos := make([]formatoCelulaXLSX, len(colunasExportacaoEnvolvidos))
+	for i, coluna := range colunasExportacaoEnvolvidos {
+		sw.SetColWidth(i+1, i+1, coluna.largura)
+		titulos[i] = excelize.Cell{StyleID: estiloTitulo, Value: coluna.titulo}
+		formatos[i] = formatosColunasXLSX[coluna.titulo]
+	}
+	err = sw.SetRow("A1", titulos)
+
+	linha := 1
+	if err == nil {
+		err = h.consultaRepo.ExportarEnvolvidos(nome, cpf, bo, telefone, func(e models.Envolvido) error {
+			valores := linhaExportacaoEnvolvido(e)
+			for i, valor := range valores {
+				valores[i] = celulaExportacaoXLSX(valor, formatos[i], estilos)
+			}
+			linha++
+			celula, _ := excelize.CoordinatesToCellName(1, linha)
+			return sw.SetRow(celula, valores)
+		})
+	}
+	if err == nil {
+		// A aba inteira vira uma tabela (filtro e zebrado)
+		ultima, _ := excelize.CoordinatesToCellName(len(colunasExportacaoEnvolvidos), linha)
+		err = sw.AddTable(&excelize.Table{Range: "A1:" + ultima, Name: "TabelaEnvolvidos", StyleName: "TableStyleMedium2"})
+	}
+	if err == nil {
+		err = sw.Flush()
+	}
+	if err != nil {
+		log.Printf("Erro ao gerar planilha de envolvidos: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao gerar planilha de envolvidos")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", "attachment; filename="+nomeArquivo+".xlsx")
+	if err := planilha.Write(w); err != nil {
+		// O cabeçalho já foi enviado; o erro fica registrado no log
+		log.Printf("Erro ao enviar planilha de envolvidos: %v", err)
+	}
+}
@@ -2,9 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"fraudbase/internal/auth"
+	"fraudbase/internal/middleware"
 	"fraudbase/internal/repository"
+	"fraudbase/internal/validacao"
+	"github.com/gorilla/mux"
 )
 
 type EnvolvidoHandler struct {
@@ -18,6 +25,12 @@ func NewEnvolvidoHandler(envolvidoRepo *repository.EnvolvidoRepository) *Envolvi
 func (h *EnvolvidoHandler) CreateEnvolvido(w http.ResponseWriter, r *http.Request) {
 	log.Println("Recebida requisição para cadastrar envolvido")
 	
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Usuário não autenticado")
+		return
+	}
+	
 	var envolvido repository.Envolvido
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&envolvido); err != nil {
@@ -30,7 +43,13 @@ func (h *EnvolvidoHandler) CreateEnvolvido(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
-	id, err := h.envolvidoRepo.CreateEnvolvido(envolvido)
+	id, avisos, err := h.envolvidoRepo.CreateEnvolvido(envolvido, autorRequisicao(claims))
+	var erros validacao.Erros
+	if errors.As(err, &erros) {
+		log.Printf("Cadastro de envolvido rejeitado: %v", erros)
+		respondWithErrosValidacao(w, erros)
+		return
+	}
 	if err != nil {
 		log.Printf("Erro ao cadastrar envolvido: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -42,11 +61,137 @@ func (h *EnvolvidoHandler) CreateEnvolvido(w http.ResponseWriter, r *http.Reques
 	}
 	
 	log.Printf("Envolvido cadastrado com sucesso. ID: %s", id)
+	resposta := map[string]interface{}{
+		"success": "true",
+		"message": "Envolvido cadastrado com sucesso",
+		"id":      id,
+	}
+	// Valores gravados só como texto, sem a forma tipada (data, valor, coordenada)
+	if len(avisos.NaoConvertidos) > 0 {
+		campos := make(map[string]string, len(avisos.NaoConvertidos))
+		for _, c := range avisos.NaoConvertidos {
+			campos[c.Campo] = c.Valor
+		}
+		resposta["campos_nao_convertidos"] = campos
+	}
+	// Campos do BO ou da pessoa já cadastrados, que valem pela primeira gravação
+	if len(avisos.Mantidos) > 0 {
+		resposta["campos_mantidos"] = avisos.Mantidos
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resposta)
+}
+
+// autorRequisicao identifica o usuário do token como autor das alterações
+func autorRequisicao(claims *auth.Claims) repository.Autor {
+	return repository.Autor{UsuarioID: claims.UserID, Login: claims.Username, Admin: claims.IsAdmin}
+}
+
+// respondWithErrosValidacao responde 422 com a mensagem de cada campo inválido
+func respondWithErrosValidacao(w http.ResponseWriter, erros validacao.Erros) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": "false",
+		"message": "Dados inválidos: verifique os campos destacados",
+		"errors":  erros,
+	})
+}
+
+// respondWithErroAlteracao traduz os erros de alteração/exclusão do repositório
+func respondWithErroAlteracao(w http.ResponseWriter, err error) {
+	var erros validacao.Erros
+	switch {
+	case errors.As(err, &erros):
+		respondWithErrosValidacao(w, erros)
+	case errors.Is(err, repository.ErrNotFound):
+		respondWithError(w, http.StatusNotFound, "Envolvido não encontrado")
+	case errors.Is(err, repository.ErrSemPermissao):
+		respondWithError(w, http.StatusForbidden, "Apenas administradores ou a unidade que cadastrou o registro podem alterá-lo")
+	case errors.Is(err, repository.ErrDadosCompartilhados):
+		respondWithError(w, http.StatusForbidden, "Os dados do BO ou da pessoa também constam em registros de outra unidade; apenas administradores podem alterá-los")
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Erro ao gravar envolvido no banco de dados")
+	}
+}
+
+// UpdateEnvolvido substitui os dados de um envolvido (admin ou unidade que o cadastrou)
+func (h *EnvolvidoHandler) UpdateEnvolvido(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para alterar envolvido")
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Usuário não autenticado")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID inválido")
+		return
+	}
+
+	var envolvido repository.Envolvido
+	if err := json.NewDecoder(r.Body).Decode(&envolvido); err != nil {
+		log.Printf("Erro ao decodificar JSON: %v", err)
+		respondWithError(w, http.StatusBadRequest, "Erro ao processar dados do formulário")
+		return
+	}
+
+	if err := h.envolvidoRepo.UpdateEnvolvido(id, envolvido, autorRequisicao(claims)); err != nil {
+		log.Printf("Erro ao alterar envolvido %d: %v", id, err)
+		respondWithErroAlteracao(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"success": "true",
-		"message": "Envolvido cadastrado com sucesso",
-		"id": id,
+		"message": "Envolvido alterado com sucesso",
+		"id":      strconv.Itoa(id),
+	})
+}
+
+// DeleteEnvolvido exclui logicamente um envolvido; o motivo é obrigatório
+func (h *EnvolvidoHandler) DeleteEnvolvido(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para excluir envolvido")
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Usuário não autenticado")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID inválido")
+		return
+	}
+
+	var requisicao struct {
+		Motivo string `json:"motivo"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requisicao); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Erro ao processar dados da requisição")
+		return
+	}
+	motivo := strings.TrimSpace(requisicao.Motivo)
+	if motivo == "" {
+		respondWithError(w, http.StatusBadRequest, "Informe o motivo da exclusão")
+		return
+	}
+
+	if err := h.envolvidoRepo.DeleteEnvolvido(id, motivo, autorRequisicao(claims)); err != nil {
+		log.Printf("Erro ao excluir envolvido %d: %v", id, err)
+		respondWithErroAlteracao(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"success": "true",
+		"message": "Envolvido excluído com sucesso",
+		"id":      strconv.Itoa(id),
 	})
 }
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fraudbase/internal/repository"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// GrafoHandler manipula requisições de análise de vínculos
+type GrafoHandler struct {
+	grafoRepo     *repository.GrafoRepository
+	auditoriaRepo *repository.AuditoriaRepository
+}
+
+// NewGrafoHandler cria um novo handler para análise de vínculos
+func NewGrafoHandler(grafoRepo *repository.GrafoRepository, auditoriaRepo *repository.AuditoriaRepository) *GrafoHandler {
+	return &GrafoHandler{grafoRepo: grafoRepo, auditoriaRepo: auditoriaRepo}
+}
+
+const (
+	saltosPadrao = 2
+	saltosMaximo = 4
+	maxNosGrafo  = 1000
+)
+
+// GetGrafoVinculos retorna o grafo de vínculos a partir de um identificador.
+// Parâmetros: tipo (cpf, telefone, pix ou bo), valor, saltos (1 a 4) e
+// formato (json, graphml ou gexf).
+func (h *GrafoHandler) GetGrafoVinculos(w http.ResponseWriter, r *http.Request) {
+	log.Println("Recebida requisição para grafo de vínculos")
+
+	queryParams := r.URL.Query()
+	tipo := queryParams.Get("tipo")
+	valor := queryParams.Get("valor")
+
+	saltos := saltosPadrao
+	if saltosStr := queryParams.Get("saltos"); saltosStr != "" {
+		if saltosNum, err := strconv.Atoi(saltosStr); err == nil && saltosNum > 0 {
+			saltos = saltosNum
+		}
+	}
+	if saltos > saltosMaximo {
+		saltos = saltosMaximo
+	}
+
+	grafo, err := h.grafoRepo.GetGrafoVinculos(tipo, valor, saltos, maxNosGrafo)
+	if err != nil {
+		if err == repository.ErrIdentificadorInvalido {
+			respondWithError(w, http.StatusBadRequest, "Informe um tipo (cpf, telefone, pix ou bo) e um valor válido")
+			return
+		}
+		log.Printf("Erro ao montar grafo de vínculos: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao montar grafo de vínculos")
+		return
+	}
+
+	if err := registrarConsulta(h.auditoriaRepo, r, len(grafo.Nodes)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao registrar auditoria da consulta")
+		return
+	}
+
+	switch queryParams.Get("formato") {
+	case "graphml":
+		escreverXMLGrafo(w, "application/graphml+xml", "grafo_vinculos.graphml", grafoParaGraphML(grafo))
+	case "gexf":
+		escreverXMLGrafo(w, "application/gexf+xml", "grafo_vinculos.gexf", grafoParaGEXF(grafo))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(grafo); err != nil {
+			log.Printf("Erro ao codificar resposta JSON: %v", err)
+			http.Error(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}
+	}
+}
+
+func escreverXMLGrafo(w http.ResponseWriter, contentType, nomeArquivo string, documento interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+nomeArquivo)
+	w.Write([]byte(xml.Header))
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(documento); err != nil {
+		log.Printf("Erro ao codificar grafo em XML: %v", err)
+	}
+}
+
+// chavesAtributos lista, em ordem estável, todos os atributos usados pelos nós
+func chavesAtributos(grafo *repository.Grafo) []string {
+	vistos := map[string]bool{}
+	var chaves []string
+	for _, no := range grafo.Nodes {
+		for chave := range no.Atributos {
+			if !vistos[chave] {
+				vistos[chave] = true
+				chaves = append(chaves, chave)
+			}
+		}
+	}
+	sort.Strings(chaves)
+	return chaves
+}
+
+// Estruturas do formato GraphML (http://graphml.graphdrawing.org/)
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGrafo `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGrafo struct {
+	ID          string          `xml:"id,attr"`
+	EdgeDefault string          `xml:"edgedefault,attr"`
+	Nodes       []graphMLNo     `xml:"node"`
+	Edges       []graphMLAresta `xml:"edge"`
+}
+
+type graphMLNo struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLDado `xml:"data"`
+}
+
+type graphMLAresta struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLDado `xml:"data"`
+}
+
+type graphMLDado struct {
+	Key   string `xml:"key,attr"`
+	Valor string `xml:",chardata"`
+}
+
+func grafoParaGraphML(grafo *repository.Grafo) graphML {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "tipo", For: "node", AttrName: "tipo", AttrType: "string"},
+			{ID: "rotulo", For: "node", AttrName: "rotulo", AttrType: "string"},
+			{ID: "aresta_tipo", For: "edge", AttrName: "tipo", AttrType: "string"},
+			{ID: "aresta_rotulo", For: "edge", AttrName: "rotulo", AttrType: "string"},
+		},
+		Graph: graphMLGrafo{ID: "vinculos", EdgeDefault: "undirected"},
+	}
+
+	atributos := chavesAtributos(grafo)
+	for _, chave := range atributos {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: chave, For: "node", AttrName: chave, AttrType: "string"})
+	}
+
+	for _, no := range grafo.Nodes {
+		n := graphMLNo{ID: no.ID, Data: []graphMLDado{{Key: "tipo", Valor: no.Tipo}, {Key: "rotulo", Valor: no.Rotulo}}}
+		for _, chave := range atributos {
+			if valor, ok := no.Atributos[chave]; ok && valor != "" {
+				n.Data = append(n.Data, graphMLDado{Key: chave, Valor: valor})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+
+	for _, aresta := range grafo.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLAresta{
+			ID:     aresta.ID,
+			Source: aresta.Source,
+			Target: aresta.Target,
+			Data:   []graphMLDado{{Key: "aresta_tipo", Valor: aresta.Tipo}, {Key: "aresta_rotulo", Valor: aresta.Rotulo}},
+		})
+	}
+
+	return doc
+}
+
+// Estruturas do formato GEXF 1.3 (https://gexf.net/)
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGrafo `xml:"graph"`
+}
+
+type gexfGrafo struct {
+	DefaultEdgeType string        `xml:"defaultedgetype,attr"`
+	Atributos       gexfAtributos `xml:"attributes"`
+	Nodes           []gexfNo      `xml:"nodes>node"`
+	Edges           []gexfAresta  `xml:"edges>edge"`
+}
+
+type gexfAtributos struct {
+	Class     string         `xml:"class,attr"`
+	Atributos []gexfAtributo `xml:"attribute"`
+}
+
+type gexfAtributo struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNo struct {
+	ID      string          `xml:"id,attr"`
+	Label   string          `xml:"label,attr"`
+	Valores []gexfValorAttr `xml:"attvalues>attvalue"`
+}
+
+type gexfAresta struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Kind   string `xml:"kind,attr,omitempty"`
+	Label  string `xml:"label,attr,omitempty"`
+}
+
+type gexfValorAttr struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func grafoParaGEXF(grafo *repository.Grafo) gexf {
+	doc := gexf{
+		Xmlns:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph: gexfGrafo{
+			DefaultEdgeType: "undirected",
+			Atributos: gexfAtributos{
+				Class:     "node",
+				Atributos: []gexfAtributo{{ID: "tipo", Title: "tipo", Type: "string"}},
+			},
+		},
+	}
+
+	atributos := chavesAtributos(grafo)
+	for _, chave := range atributos {
+		doc.Graph.Atributos.Atributos = append(doc.Graph.Atributos.Atributos, gexfAtributo{ID: chave, Title: chave, Type: "string"})
+	}
+
+	for _, no := range grafo.Nodes {
+		n := gexfNo{ID: no.ID, Label: no.Rotulo, Valores: []gexfValorAttr{{For: "tipo", Value: no.Tipo}}}
+		for _, chave := range atributos {
+			if valor, ok := no.Atributos[chave]; ok && valor != "" {
+				n.Valores = append(n.Valores, gexfValorAttr{For: chave, Value: valor})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+
+	for _, aresta := range grafo.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfAresta{
+			ID:     aresta.ID,
+			Source: aresta.Source,
+			Target: aresta.Target,
+			Kind:   aresta.Tipo,
+			Label:  aresta.Rotulo,
+		})
+	}
+
+	return doc
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"fraudbase/internal/auth"
+	"fraudbase/internal/jobs"
+	"fraudbase/internal/middleware"
+	"fraudbase/internal/repository"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/xuri/excelize/v2"
+)
+
+// ImportacaoHandler expõe o andamento das importações de relatórios
+type ImportacaoHandler struct {
+	importacoes   *repository.ImportacaoRepository
+	importacaoJob *jobs.ImportacaoJob
+	auditoriaRepo *repository.AuditoriaRepository
+}
+
+// NewImportacaoHandler cria um novo handler de importações
+func NewImportacaoHandler(importacoes *repository.ImportacaoRepository, importacaoJob *jobs.ImportacaoJob, auditoriaRepo *repository.AuditoriaRepository) *ImportacaoHandler {
+	return &ImportacaoHandler{importacoes: importacoes, importacaoJob: importacaoJob, auditoriaRepo: auditoriaRepo}
+}
+
+// importacaoDoUsuario carrega a importação da rota e confere se pertence ao
+// usuário (administradores acessam todas). Responde com erro e retorna nil caso contrário.
+func (h *ImportacaoHandler) importacaoDoUsuario(w http.ResponseWriter, r *http.Request) *repository.Importacao {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Usuário não autenticado")
+		return nil
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID inválido")
+		return nil
+	}
+
+	imp, err := h.importacoes.GetImportacao(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Importação não encontrada")
+		return nil
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao consultar importação")
+		return nil
+	}
+
+	// Importação de outro usuário responde como inexistente
+	if !claims.IsAdmin && (imp.UsuarioID == nil || *imp.UsuarioID != claims.UserID) {
+		respondWithError(w, http.StatusNotFound, "Importação não encontrada")
+		return nil
+	}
+
+	return imp
+}
+
+// GetImportacao retorna a situação, as contagens e os erros de uma importação;
+// em uma prévia já calculada, também o resultado dela em previa. Cada usuário só
+// vê as próprias importações; administradores veem todas.
+func (h *ImportacaoHandler) GetImportacao(w http.ResponseWriter, r *http.Request) {
+	imp := h.importacaoDoUsuario(w, r)
+	if imp == nil {
+		return
+	}
+
+	if imp.Status == repository.ImportacaoPrevia {
+		previa, err := h.importacoes.GetPrevia(imp.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erro ao consultar prévia da importação")
+			return
+		}
+		imp.Previa = previa
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(imp); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
+
+// ConfirmarImportacao coloca na fila uma prévia (upload com dryRun=true) ainda válida
+func (h *ImportacaoHandler) ConfirmarImportacao(w http.ResponseWriter, r *http.Request) {
+	imp := h.importacaoDoUsuario(w, r)
+	if imp == nil {
+		return
+	}
+
+	if imp.Status != repository.ImportacaoPrevia {
+		respondWithError(w, http.StatusConflict, "A importação não é uma prévia aguardando confirmação (situação: "+imp.Status+")")
+		return
+	}
+
+	// O arquivo da prévia está só na instância que recebeu o upload
+	if !h.importacoes.DesteProcesso(imp) {
+		respondWithError(w, http.StatusConflict, "A prévia foi calculada em outra instância da API. Confirme na mesma instância em que o arquivo foi enviado ou envie o arquivo novamente.")
+		return
+	}
+
+	err := h.importacaoJob.ConfirmarPrevia(imp.ID)
+	if errors.Is(err, jobs.ErrPreviaExpirada) {
+		respondWithError(w, http.StatusGone, "A prévia expirou. Envie o arquivo novamente.")
+		return
+	}
+	if errors.Is(err, jobs.ErrPreviaEmCalculo) {
+		respondWithError(w, http.StatusConflict, "A prévia ainda está sendo calculada. Aguarde o resultado para confirmar.")
+		return
+	}
+	if errors.Is(err, jobs.ErrFilaCheia) {
+		respondWithError(w, http.StatusServiceUnavailable, "Muitas importações em andamento. Tente novamente em alguns minutos.")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao confirmar importação")
+		return
+	}
+
+	log.Printf("Prévia %d confirmada", imp.ID)
+
+	response := map[string]interface{}{
+		"success":  true,
+		"message":  "Importação confirmada e enviada para processamento",
+		"importId": imp.ID,
+		"status":   repository.ImportacaoPendente,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ReverterImportacao apaga todos os registros criados por uma importação concluída
+// (ou interrompida por erro) e a marca como revertida. Importações que alteraram
+// registros existentes respondem 409.
+func (h *ImportacaoHandler) ReverterImportacao(w http.ResponseWriter, r *http.Request) {
+	imp := h.importacaoDoUsuario(w, r)
+	if imp == nil {
+		return
+	}
+	claims := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+
+	apagados, err := h.importacoes.ReverterImportacao(imp.ID, autorRequisicao(claims))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Importação não encontrada")
+		return
+	}
+	if errors.Is(err, repository.ErrImportacaoNaoReversivel) {
+		respondWithError(w, http.StatusConflict, "A importação não pode ser revertida (situação: "+imp.Status+")")
+		return
+	}
+	if errors.Is(err, repository.ErrImportacaoComAtualizacoes) {
+		respondWithError(w, http.StatusConflict, "A importação alterou registros existentes (modo de atualização) e não pode ser revertida; as alterações constam no histórico de cada registro")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao reverter importação")
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":           true,
+		"message":           "Importação revertida com sucesso",
+		"importId":          imp.ID,
+		"registrosApagados": apagados,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAlteracoes retorna o relatório por BO do que uma importação em modo de
+// atualização alterou ou incluiu
+func (h *ImportacaoHandler) GetAlteracoes(w http.ResponseWriter, r *http.Request) {
+	imp := h.importacaoDoUsuario(w, r)
+	if imp == nil {
+		return
+	}
+
+	alteracoes, err := h.importacoes.GetAlteracoes(imp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao consultar alterações da importação")
+		return
+	}
+
+	if err := registrarConsulta(h.auditoriaRepo, r, len(alteracoes)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao registrar auditoria da consulta")
+		return
+	}
+
+	response := map[string]interface{}{
+		"importId":    imp.ID,
+		"modo":        imp.Modo,
+		"atualizados": imp.Atualizados,
+		"inseridos":   imp.Inseridos,
+		"alteracoes":  alteracoes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
+
+// GetRejeicoes baixa em XLSX as linhas do relatório que a importação não gravou
+// (sem Id, sem correspondente na aba de registro, duplicatas ou lotes
+// descartados), com a aba e a linha de origem e o motivo
+func (h *ImportacaoHandler) GetRejeicoes(w http.ResponseWriter, r *http.Request) {
+	imp := h.importacaoDoUsuario(w, r)
+	if imp == nil {
+		return
+	}
+
+	switch imp.Status {
+	case repository.ImportacaoPrevia, repository.ImportacaoExpirada,
+		repository.ImportacaoPendente, repository.ImportacaoProcessando:
+		respondWithError(w, http.StatusConflict, "As rejeições ficam disponíveis após o processamento da importação")
+		return
+	}
+
+	// A importação que falhou na leitura do arquivo não chega a guardar rejeições:
+	// em vez de uma planilha vazia, o motivo da falha é devolvido
+	if imp.Status == repository.ImportacaoErro {
+		total, err := h.importacoes.ContarRejeicoes(imp.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erro ao consultar rejeições da importação")
+			return
+		}
+		if total == 0 {
+			respondWithError(w, http.StatusConflict, "A importação falhou sem rejeições de linhas: "+strings.Join(imp.Erros, "; "))
+			return
+		}
+	}
+
+	planilha := excelize.NewFile()
+	defer planilha.Close()
+
+	const aba = "Rejeições"
+	planilha.SetSheetName("Sheet1", aba)
+	sw, err := planilha.NewStreamWriter(aba)
+	if err != nil {
+		log.Printf("Erro ao criar planilha de rejeições da importação %d: %v", imp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao gerar relatório de rejeições")
+		return
+	}
+	sw.SetColWidth(1, 1, 24)
+	sw.SetColWidth(3, 3, 60)
+	sw.SetColWidth(4, 5, 30)
+	sw.SetColWidth(6, 6, 16)
+	sw.SetRow("A1", []interface{}{"Planilha", "Linha", "Motivo", "Número do BO", "Nome", "CPF"})
+
+	linha := 2
+	err = h.importacoes.ExportarRejeicoes(imp.ID, func(rej repository.RejeicaoImportacao) error {
+		celula, _ := excelize.CoordinatesToCellName(1, linha)
+		linha++
+		return sw.SetRow(celula, []interface{}{rej.Planilha, rej.Linha, rej.Motivo, rej.NumeroBo, rej.NomeCompleto, rej.Cpf})
+	})
+	if err == nil {
+		err = sw.Flush()
+	}
+	if err != nil {
+		log.Printf("Erro ao gerar planilha de rejeições da importação %d: %v", imp.ID, err)
+		respondWithError(w, http.StatusInternalServerError, "Erro ao gerar relatório de rejeições")
+		return
+	}
+
+	// As rejeições trazem nome e CPF das linhas do relatório
+	if err := registrarConsulta(h.auditoriaRepo, r, linha-2); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao registrar auditoria da consulta")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=rejeicoes_importacao_%d.xlsx", imp.ID))
+	if err := planilha.Write(w); err != nil {
+		// O cabeçalho já foi enviado; o erro fica registrado no log
+		log.Printf("Erro ao enviar planilha de rejeições da importação %d: %v", imp.ID, err)
+	}
+}
+
+// ListarImportacoes lista, paginadas, as importações do usuário autenticado.
+// Administradores podem consultar as de outro usuário com usuario_id.
+func (h *ImportacaoHandler) ListarImportacoes(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Usuário não autenticado")
+		return
+	}
+
+	usuarioID := claims.UserID
+	if usuario := r.URL.Query().Get("usuario_id"); usuario != "" {
+		id, err := strconv.Atoi(usuario)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "usuario_id inválido")
+			return
+		}
+		if id != claims.UserID && !claims.IsAdmin {
+			respondWithError(w, http.StatusForbidden, "Acesso negado")
+			return
+		}
+		usuarioID = id
+	}
+
+	page := 1
+	limit := 20
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if pageNum, err := strconv.Atoi(pageStr); err == nil && pageNum > 0 {
+			page = pageNum
+		}
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limitNum, err := strconv.Atoi(limitStr); err == nil && limitNum > 0 && limitNum <= 100 {
+			limit = limitNum
+		}
+	}
+
+	importacoes, totalCount, err := h.importacoes.ListarImportacoes(usuarioID, page, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao listar importações")
+		return
+	}
+
+	response := struct {
+		Data       []repository.Importacao `json:"data"`
+		TotalCount int                     `json:"totalCount"`
+		Page       int                     `json:"page"`
+		Limit      int                     `json:"limit"`
+		TotalPages int                     `json:"totalPages"`
+	}{
+		Data:       importacoes,
+		TotalCount: totalCount,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: (totalCount + limit - 1) / limit,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
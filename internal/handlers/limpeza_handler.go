@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"fraudbase/internal/auth"
+	"fraudbase/internal/middleware"
 	"fraudbase/internal/repository"
 	"log"
 )
@@ -27,8 +29,15 @@ func (h *LimpezaHandler) LimparDuplicatasHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Usuário não autenticado")
+		return
+	}
+
 	// Chamar o repository para executar a limpeza
-	totalAntes, totalDepois, err := h.limpezaRepo.LimparRegistrosDuplicados()
+	autor := repository.Autor{UsuarioID: claims.UserID, Login: claims.Username}
+	totalAntes, totalDepois, err := h.limpezaRepo.LimparRegistrosDuplicados(autor)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao remover duplicatas: "+err.Error())
 		return
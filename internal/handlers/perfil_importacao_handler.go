@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fraudbase/internal/importacao"
+	"fraudbase/internal/repository"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// PerfilImportacaoHandler gerencia os perfis de importação (leiautes dos relatórios
+// exportados por cada sistema estadual)
+type PerfilImportacaoHandler struct {
+	perfis *repository.PerfilImportacaoRepository
+}
+
+// NewPerfilImportacaoHandler cria um novo handler de perfis de importação
+func NewPerfilImportacaoHandler(perfis *repository.PerfilImportacaoRepository) *PerfilImportacaoHandler {
+	return &PerfilImportacaoHandler{perfis: perfis}
+}
+
+// ListarPerfis lista os perfis que podem ser escolhidos no upload
+func (h *PerfilImportacaoHandler) ListarPerfis(w http.ResponseWriter, r *http.Request) {
+	perfis, err := h.perfis.ListarPerfis()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao listar perfis de importação")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(perfis); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
+
+// GetPerfil retorna um perfil de importação
+func (h *PerfilImportacaoHandler) GetPerfil(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID inválido")
+		return
+	}
+
+	perfil, err := h.perfis.GetPerfil(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Perfil de importação não encontrado")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao consultar perfil de importação")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(perfil); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
+
+// decodificarPerfil lê e valida o perfil do corpo da requisição. Responde com erro
+// e retorna false se ele for inválido.
+func decodificarPerfil(w http.ResponseWriter, r *http.Request, perfil *repository.PerfilImportacao) bool {
+	if err := json.NewDecoder(r.Body).Decode(perfil); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Erro ao processar dados do perfil: "+err.Error())
+		return false
+	}
+	if erros := importacao.ValidarPerfil(perfil); erros != nil {
+		log.Printf("Perfil de importação rejeitado: %v", erros)
+		respondWithErrosValidacao(w, erros)
+		return false
+	}
+	return true
+}
+
+// CriarPerfil cadastra um perfil de importação (admin)
+func (h *PerfilImportacaoHandler) CriarPerfil(w http.ResponseWriter, r *http.Request) {
+	var perfil repository.PerfilImportacao
+	if !decodificarPerfil(w, r, &perfil) {
+		return
+	}
+
+	id, err := h.perfis.CriarPerfil(perfil)
+	if errors.Is(err, repository.ErrPerfilDuplicado) {
+		respondWithError(w, http.StatusConflict, "Já existe um perfil de importação com o nome "+perfil.Nome)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao cadastrar perfil de importação")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Perfil de importação cadastrado com sucesso",
+		"id":      id,
+	})
+}
+
+// AtualizarPerfil substitui os dados de um perfil de importação (admin)
+func (h *PerfilImportacaoHandler) AtualizarPerfil(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID inválido")
+		return
+	}
+
+	var perfil repository.PerfilImportacao
+	if !decodificarPerfil(w, r, &perfil) {
+		return
+	}
+
+	err = h.perfis.AtualizarPerfil(id, perfil)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Perfil de importação não encontrado")
+		return
+	}
+	if errors.Is(err, repository.ErrPerfilDuplicado) {
+		respondWithError(w, http.StatusConflict, "Já existe um perfil de importação com o nome "+perfil.Nome)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao alterar perfil de importação")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Perfil de importação alterado com sucesso",
+		"id":      id,
+	})
+}
+
+// ExcluirPerfil apaga um perfil de importação (admin). Sem perfil padrão, os uploads
+// sem perfil usam o leiaute original do relatório.
+func (h *PerfilImportacaoHandler) ExcluirPerfil(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID inválido")
+		return
+	}
+
+	err = h.perfis.ExcluirPerfil(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Perfil de importação não encontrado")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao excluir perfil de importação")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Perfil de importação excluído com sucesso",
+	})
+}
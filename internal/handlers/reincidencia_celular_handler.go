@@ -11,11 +11,12 @@ import (
 // ReincidenciaCelularHandler manipula requisições para estatísticas de reincidência por celular
 type ReincidenciaCelularHandler struct {
     reincidenciaCelularRepo *repository.ReincidenciaCelularRepository
+    auditoriaRepo           *repository.AuditoriaRepository
 }
 
 // NewReincidenciaCelularHandler cria um novo handler para estatísticas de reincidência por celular
-func NewReincidenciaCelularHandler(reincidenciaCelularRepo *repository.ReincidenciaCelularRepository) *ReincidenciaCelularHandler {
-    return &ReincidenciaCelularHandler{reincidenciaCelularRepo: reincidenciaCelularRepo}
+func NewReincidenciaCelularHandler(reincidenciaCelularRepo *repository.ReincidenciaCelularRepository, auditoriaRepo *repository.AuditoriaRepository) *ReincidenciaCelularHandler {
+    return &ReincidenciaCelularHandler{reincidenciaCelularRepo: reincidenciaCelularRepo, auditoriaRepo: auditoriaRepo}
 }
 
 // GetReincidenciaPorCelular retorna estatísticas de reincidência por celular
@@ -47,6 +48,12 @@ func (h *ReincidenciaCelularHandler) GetReincidenciaPorCelular(w http.ResponseWr
         http.Error(w, "Erro ao buscar estatísticas", http.StatusInternalServerError)
         return
     }
+
+    // Registrar a consulta na trilha de auditoria antes de devolver os dados
+    if err := registrarConsulta(h.auditoriaRepo, r, len(stats)); err != nil {
+        http.Error(w, "Erro ao registrar auditoria da consulta", http.StatusInternalServerError)
+        return
+    }
     
     // Estrutura para retornar os dados e informações de paginação
     response := struct {
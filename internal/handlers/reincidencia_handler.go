@@ -11,11 +11,12 @@ import (
 // ReincidenciaHandler manipula requisições para estatísticas de reincidência
 type ReincidenciaHandler struct {
 	reincidenciaRepo *repository.ReincidenciaRepository
+	auditoriaRepo    *repository.AuditoriaRepository
 }
 
 // NewReincidenciaHandler cria um novo handler para estatísticas de reincidência
-func NewReincidenciaHandler(reincidenciaRepo *repository.ReincidenciaRepository) *ReincidenciaHandler {
-	return &ReincidenciaHandler{reincidenciaRepo: reincidenciaRepo}
+func NewReincidenciaHandler(reincidenciaRepo *repository.ReincidenciaRepository, auditoriaRepo *repository.AuditoriaRepository) *ReincidenciaHandler {
+	return &ReincidenciaHandler{reincidenciaRepo: reincidenciaRepo, auditoriaRepo: auditoriaRepo}
 }
 
 // GetReincidenciaPorCPF retorna estatísticas de reincidência por CPF
@@ -49,6 +50,12 @@ func (h *ReincidenciaHandler) GetReincidenciaPorCPF(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// Registrar a consulta na trilha de auditoria antes de devolver os dados
+	if err := registrarConsulta(h.auditoriaRepo, r, len(stats)); err != nil {
+		http.Error(w, "Erro ao registrar auditoria da consulta", http.StatusInternalServerError)
+		return
+	}
+
 	// Estrutura para retornar os dados e informações de paginação
 	response := struct {
 		Data       []repository.ReincidenciaCPFStats `json:"data"`
@@ -1,30 +1,133 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"fraudbase/internal/repository"
 	"fraudbase/internal/database"
 	"fraudbase/internal/auth"
+	"fraudbase/internal/importacao"
+	"fraudbase/internal/jobs"
 	"fraudbase/internal/middleware"
+	"io"
 	"net/http"
-	"path/filepath"
+	"os"
+	"strconv"
 	"strings"
-	"time"
 	"log"
-
-	"github.com/xuri/excelize/v2"
 )
 
+// limitePadraoUploadMB é usado quando IMPORT_MAX_UPLOAD_MB não está definido
+const limitePadraoUploadMB = 500
+
 type RelatorioHandler struct {
-	repo *repository.RelatorioRepository
+	repo          *repository.RelatorioRepository
+	importacoes   *repository.ImportacaoRepository
+	perfis        *repository.PerfilImportacaoRepository
+	importacaoJob *jobs.ImportacaoJob
+	limiteUpload  int64 // em bytes
+}
+
+// NewRelatorioHandler cria o handler de relatórios. O tamanho máximo do upload, em
+// MB, pode ser configurado pela variável de ambiente IMPORT_MAX_UPLOAD_MB.
+func NewRelatorioHandler(repo *repository.RelatorioRepository, importacoes *repository.ImportacaoRepository, perfis *repository.PerfilImportacaoRepository, importacaoJob *jobs.ImportacaoJob) *RelatorioHandler {
+	limiteMB := limitePadraoUploadMB
+	if valor := os.Getenv("IMPORT_MAX_UPLOAD_MB"); valor != "" {
+		if n, err := strconv.Atoi(valor); err == nil && n > 0 {
+			limiteMB = n
+		} else {
+			log.Printf("Aviso: IMPORT_MAX_UPLOAD_MB inválido (%q), usando %d", valor, limitePadraoUploadMB)
+		}
+	}
+	return &RelatorioHandler{repo: repo, importacoes: importacoes, perfis: perfis, importacaoJob: importacaoJob, limiteUpload: int64(limiteMB) << 20}
 }
 
-func NewRelatorioHandler(repo *repository.RelatorioRepository) *RelatorioHandler {
-	return &RelatorioHandler{repo: repo}
+// arquivoRecebido é o relatório enviado, gravado em um arquivo temporário
+type arquivoRecebido struct {
+	nome    string // nome original do arquivo
+	caminho string
+	sha256  string
 }
 
-// UploadRelatorio processa o upload e importação do relatório
+// receberArquivo grava em disco (no diretório temporário do sistema, TMPDIR) o
+// arquivo do campo "relatorio" do formulário, sem carregá-lo na memória, e
+// calcula o SHA-256 durante a cópia. Em erro responde e retorna nil.
+func (h *RelatorioHandler) receberArquivo(w http.ResponseWriter, r *http.Request) *arquivoRecebido {
+	r.Body = http.MaxBytesReader(w, r.Body, h.limiteUpload)
+	partes, err := r.MultipartReader()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Erro ao obter arquivo: "+err.Error())
+		return nil
+	}
+
+	for {
+		parte, err := partes.NextPart()
+		if err == io.EOF {
+			respondWithError(w, http.StatusBadRequest, "Erro ao obter arquivo: campo 'relatorio' não enviado")
+			return nil
+		}
+		if err != nil {
+			respondErroUpload(w, err, h.limiteUpload)
+			return nil
+		}
+		if parte.FormName() != "relatorio" {
+			parte.Close()
+			continue
+		}
+		defer parte.Close()
+
+		// Verificar extensão do arquivo (.xlsx, .xls, .ods, .zip com CSVs ou .json)
+		nome := parte.FileName()
+		if err := importacao.VerificarFormato(nome); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return nil
+		}
+
+		temporario, err := h.importacaoJob.ArquivoTemporario()
+		if err != nil {
+			log.Printf("Erro ao criar arquivo temporário para o upload: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Erro ao receber arquivo")
+			return nil
+		}
+		hash := sha256.New()
+		_, err = io.Copy(io.MultiWriter(temporario, hash), parte)
+		if errClose := temporario.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			os.Remove(temporario.Name())
+			respondErroUpload(w, err, h.limiteUpload)
+			return nil
+		}
+
+		return &arquivoRecebido{nome: nome, caminho: temporario.Name(), sha256: hex.EncodeToString(hash.Sum(nil))}
+	}
+}
+
+// respondErroUpload responde a um erro na leitura do upload (413 se passou do limite)
+func respondErroUpload(w http.ResponseWriter, err error, limite int64) {
+	var muitoGrande *http.MaxBytesError
+	if errors.As(err, &muitoGrande) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Arquivo maior que o limite de %d MB", limite>>20))
+		return
+	}
+	log.Printf("Erro ao receber arquivo: %v", err)
+	respondWithError(w, http.StatusBadRequest, "Erro ao obter arquivo: "+err.Error())
+}
+
+// UploadRelatorio valida o arquivo enviado e cria uma importação processada em
+// background. A resposta (202) traz o id para acompanhar em GET /api/imports/{id}.
+// Com dryRun=true nada é gravado: a prévia da importação é calculada em background
+// e, quando pronta, vem em previa no GET /api/imports/{id}; ela pode ser
+// confirmada em POST /api/imports/{id}/confirm. A importação é tudo ou nada;
+// com gravacaoParcial=true os lotes com erro são descartados e os demais gravados.
+// Com modo=atualizacao os participantes já cadastrados de cada BO são atualizados.
+// O leiaute do arquivo é o do perfil de importação escolhido em perfil (nome) ou o
+// do perfil padrão. O arquivo é gravado em disco e lido linha a linha, até o
+// limite de IMPORT_MAX_UPLOAD_MB.
 func (h *RelatorioHandler) UploadRelatorio(w http.ResponseWriter, r *http.Request) {
 	// OBTER INFORMAÇÕES DO USUÁRIO AUTENTICADO ATRAVÉS DO JWT
 	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
@@ -43,81 +146,153 @@ func (h *RelatorioHandler) UploadRelatorio(w http.ResponseWriter, r *http.Reques
 
 	log.Printf("Usuario ID: %d, Estado: %s", claims.UserID, estadoUsuario)
 
-	// Limitar tamanho do upload para 10MB
-	r.ParseMultipartForm(10 << 20)
-
-	file, handler, err := r.FormFile("relatorio")
+	perfil, err := importacao.BuscarPerfil(h.perfis, r.URL.Query().Get("perfil"))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusBadRequest, "Perfil de importação não encontrado: "+r.URL.Query().Get("perfil"))
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Erro ao obter arquivo: "+err.Error())
+		respondWithError(w, http.StatusInternalServerError, "Erro ao obter perfil de importação")
 		return
 	}
-	defer file.Close()
 
-	// Verificar extensão do arquivo
-	if filepath.Ext(handler.Filename) != ".xlsx" {
-		respondWithError(w, http.StatusBadRequest, "Formato de arquivo inválido. Apenas arquivos .xlsx são permitidos.")
+	// Prévias guardam o arquivo até a confirmação: recusar antes de receber outro
+	if r.URL.Query().Get("dryRun") == "true" {
+		if err := h.importacaoJob.VerificarLimitePrevias(claims.UserID); err != nil {
+			respondWithErroPrevia(w, err)
+			return
+		}
+	}
+
+	arquivo := h.receberArquivo(w, r)
+	if arquivo == nil {
 		return
 	}
+	descartarArquivo := func() { os.Remove(arquivo.caminho) }
 
-	// Verificar padrão do nome
-	if !strings.Contains(handler.Filename, "resultado_da_pesquisa_bo_") {
-		respondWithError(w, http.StatusBadRequest, "Nome do arquivo não segue o padrão esperado.")
+	// Verificar padrão do nome exigido pelo perfil
+	if !strings.Contains(arquivo.nome, perfil.PadraoArquivo) {
+		descartarArquivo()
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Nome do arquivo não segue o padrão esperado pelo perfil '%s' (deve conter '%s').", perfil.Nome, perfil.PadraoArquivo))
 		return
 	}
 
-	// Processar o arquivo Excel
-	xlsx, err := excelize.OpenReader(file)
+	// Abrir o relatório e verificar se todas as planilhas do perfil existem
+	rel, err := importacao.AbrirRelatorio(arquivo.caminho, arquivo.nome, perfil)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Erro ao ler arquivo Excel: "+err.Error())
+		descartarArquivo()
+		if errors.Is(err, importacao.ErrArquivoInvalido) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Erro ao ler arquivo: "+err.Error())
+		}
 		return
 	}
 
-	// Verificar se todas as planilhas necessárias existem
-	requiredSheets := []string{"Dados do Registro", "Dados do Fato", "Envolvidos", "Relato Histórico"}
-	for _, sheet := range requiredSheets {
-		idx, err := xlsx.GetSheetIndex(sheet)
-		if err != nil || idx == -1 {
-			respondWithError(w, http.StatusBadRequest, "Planilha '"+sheet+"' não encontrada no arquivo.")
-			return
-		}
+	autor := autorRequisicao(claims)
+	origem := repository.OrigemImportacao{
+		NomeArquivo: arquivo.nome,
+		SHA256:      arquivo.sha256,
+		Estado:      estadoUsuario,
+		Perfil:      perfil.Nome,
+	}
+	opcoes := repository.OpcoesImportacao{
+		Modo:            r.URL.Query().Get("modo"),
+		GravacaoParcial: r.URL.Query().Get("gravacaoParcial") == "true",
+	}
+	tarefa := jobs.TarefaImportacao{Autor: autor, Estado: estadoUsuario, Perfil: perfil, Arquivo: rel, Caminho: arquivo.caminho}
+	if opcoes.Modo != "" && opcoes.Modo != repository.ModoInclusao && opcoes.Modo != repository.ModoAtualizacao {
+		tarefa.Liberar()
+		respondWithError(w, http.StatusBadRequest, "Modo de importação inválido (use inclusao ou atualizacao)")
+		return
+	}
+	if opcoes.Modo == "" {
+		opcoes.Modo = repository.ModoInclusao
+	}
+	tarefa.Opcoes = opcoes
+	if r.URL.Query().Get("dryRun") == "true" {
+		h.previaRelatorio(w, tarefa, origem)
+		return
 	}
 
-	// PROCESSAR OS DADOS PASSANDO O ESTADO DO USUÁRIO OBTIDO DO BANCO
-	dadosProcessados, err := processarDadosRelatorio(xlsx, estadoUsuario)
+	id, err := h.importacoes.CriarImportacao(autor, origem, opcoes)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Erro ao processar dados: "+err.Error())
+		tarefa.Liberar()
+		respondWithError(w, http.StatusInternalServerError, "Erro ao registrar importação")
+		return
+	}
+
+	tarefa.ID = id
+	if !h.importacaoJob.Enfileirar(tarefa) {
+		tarefa.Liberar()
+		h.importacoes.FinalizarImportacao(id, repository.ImportacaoErro, repository.TransacaoDesfeita, []string{"fila de importação cheia"})
+		respondWithError(w, http.StatusServiceUnavailable, "Muitas importações em andamento. Tente novamente em alguns minutos.")
 		return
 	}
 
-	// Log para debug
-	if len(dadosProcessados) > 0 {
-		log.Printf("Primeiro registro processado - BO: %s", dadosProcessados[0].NumeroBo)
+	log.Printf("Importação %d criada para o arquivo %s", id, arquivo.nome)
+
+	response := map[string]interface{}{
+		"success":         true,
+		"message":         fmt.Sprintf("Arquivo recebido e enviado para processamento (Estado: %s)", estadoUsuario),
+		"importId":        id,
+		"status":          repository.ImportacaoPendente,
+		"modo":            opcoes.Modo,
+		"gravacaoParcial": opcoes.GravacaoParcial,
+		"perfil":          perfil.Nome,
 	}
 
-	// Inserir dados no banco de dados
-	registrosInseridos, duplicatasEvitadas, err := h.repo.InserirDadosRelatorio(dadosProcessados)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// respondWithErroPrevia responde à recusa de uma prévia por limite
+func respondWithErroPrevia(w http.ResponseWriter, err error) {
+	log.Printf("Prévia recusada: %v", err)
+	respondWithError(w, http.StatusTooManyRequests, "Há prévias demais aguardando confirmação. Confirme ou aguarde a expiração das prévias anteriores.")
+}
+
+// previaRelatorio registra a prévia (dryRun) e a coloca na fila para ser
+// calculada em background, sem gravar nada. A resposta (202) traz o id; o
+// resultado vem em previa no GET /api/imports/{id} quando fica pronto. O arquivo
+// fica guardado para que a prévia possa ser confirmada sem novo upload.
+func (h *RelatorioHandler) previaRelatorio(w http.ResponseWriter, tarefa jobs.TarefaImportacao, origem repository.OrigemImportacao) {
+	id, err := h.importacoes.CriarPrevia(tarefa.Autor, origem, tarefa.Opcoes)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Erro ao inserir dados no banco: "+err.Error())
+		tarefa.Liberar()
+		respondWithError(w, http.StatusInternalServerError, "Erro ao registrar prévia da importação")
 		return
 	}
 
-	// Atualizar views materializadas em background
-	go func() {
-		time.Sleep(2 * time.Second)
-		database.RefreshMaterializedViews(h.repo.DB)
-		log.Println("Views materializadas atualizadas após upload")
-	}()
+	tarefa.ID = id
+	if err := h.importacaoJob.EnfileirarPrevia(tarefa); err != nil {
+		tarefa.Liberar()
+		if errors.Is(err, jobs.ErrLimitePrevias) {
+			h.importacoes.ExpirarPrevia(id)
+			respondWithErroPrevia(w, err)
+			return
+		}
+		h.importacoes.FinalizarImportacao(id, repository.ImportacaoErro, repository.TransacaoDesfeita, []string{"fila de importação cheia"})
+		respondWithError(w, http.StatusServiceUnavailable, "Muitas importações em andamento. Tente novamente em alguns minutos.")
+		return
+	}
+
+	log.Printf("Prévia %d criada para o arquivo %s", id, origem.NomeArquivo)
 
-	// Responder com sucesso
 	response := map[string]interface{}{
-		"success":             true,
-		"message":             fmt.Sprintf("Arquivo processado com sucesso (Estado: %s)", estadoUsuario),
-		"registrosInseridos":  registrosInseridos,
-		"duplicatasEvitadas":  duplicatasEvitadas,
-		"totalProcessados":    len(dadosProcessados),
+		"success":         true,
+		"dryRun":          true,
+		"message":         "Arquivo recebido; a prévia está sendo calculada",
+		"importId":        id,
+		"status":          repository.ImportacaoPrevia,
+		"modo":            tarefa.Opcoes.Modo,
+		"gravacaoParcial": tarefa.Opcoes.GravacaoParcial,
+		"perfil":          tarefa.Perfil.Nome,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -145,372 +320,6 @@ func (h *RelatorioHandler) RefreshViews(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
-// Função completa para processar todas as tabelas do relatório - VERSÃO UNIVERSAL
-func processarDadosRelatorio(xlsx *excelize.File, estadoUsuario string) ([]repository.DadosRelatorio, error) {
-	var resultado []repository.DadosRelatorio
-
-	log.Printf("Processando dados com estado do usuário: '%s'", estadoUsuario)
-
-	// Mapas para armazenar dados temporariamente por ID
-	dadosRegistro := make(map[string]map[string]string)
-	dadosFato := make(map[string]map[string]string)
-	dadosEnvolvidos := make(map[string][]map[string]string)
-	dadosRelato := make(map[string]string)
-
-	// 1. Processar "Dados do Registro"
-	rows, err := xlsx.GetRows("Dados do Registro")
-	if err != nil {
-		return nil, fmt.Errorf("erro ao ler planilha 'Dados do Registro': %v", err)
-	}
-
-	// Identificar índices das colunas
-	var idxId, idxNumero, idxUnidade, idxSituacao, idxNaturezas int = -1, -1, -1, -1, -1
-	for i, cell := range rows[0] {
-		switch cell {
-		case "Id":
-			idxId = i
-		case "Número":
-			idxNumero = i
-		case "Unidade de Apuração":
-			idxUnidade = i
-		case "Situação":
-			idxSituacao = i
-		case "Naturezas":
-			idxNaturezas = i
-		}
-	}
-
-	// Verificar se todas as colunas foram encontradas
-	if idxId == -1 || idxNumero == -1 || idxUnidade == -1 || idxSituacao == -1 || idxNaturezas == -1 {
-		return nil, fmt.Errorf("colunas obrigatórias não encontradas na planilha 'Dados do Registro'")
-	}
-
-	// Processar linhas
-	for i, row := range rows {
-		if i == 0 { // Pular cabeçalho
-			continue
-		}
-		if len(row) <= idxId || len(row) <= idxNumero {
-			continue // Linha vazia ou inválida
-		}
-
-		id := row[idxId]
-		if id == "" {
-			continue
-		}
-
-		// APLICAR SUFIXO DO ESTADO PARA QUALQUER ESTADO BRASILEIRO
-		numeroBO := getValueSafely(row, idxNumero)
-		log.Printf("BO original: '%s', Estado usuário: '%s'", numeroBO, estadoUsuario)
-
-		if estadoUsuario != "" && numeroBO != "" {
-			// Lista de todos os estados brasileiros
-			estadosValidos := []string{
-				"AC", "AL", "AP", "AM", "BA", "CE", "DF", "ES", "GO", 
-				"MA", "MT", "MS", "MG", "PA", "PB", "PR", "PE", "PI", 
-				"RJ", "RN", "RS", "RO", "RR", "SC", "SP", "SE", "TO",
-			}
-			
-			// Verificar se o estado do usuário é válido
-			estadoValido := false
-			for _, estado := range estadosValidos {
-				if estado == estadoUsuario {
-					estadoValido = true
-					break
-				}
-			}
-			
-			log.Printf("Estado válido: %v", estadoValido)
-			
-			// Se o estado é válido e o BO ainda não tem sufixo de estado
-			if estadoValido {
-				// Verificar se já não termina com algum sufixo de estado
-				jaTemSufixo := false
-				for _, estado := range estadosValidos {
-					if strings.HasSuffix(numeroBO, "/"+estado) {
-						jaTemSufixo = true
-						log.Printf("BO já tem sufixo: %s", estado)
-						break
-					}
-				}
-				
-				// Adicionar sufixo apenas se não tiver nenhum
-				if !jaTemSufixo {
-					numeroBO = numeroBO + "/" + estadoUsuario
-					log.Printf("BO modificado: '%s'", numeroBO)
-				}
-			}
-		}
-
-		dadosRegistro[id] = map[string]string{
-			"Número":              numeroBO, // NÚMERO JÁ COM SUFIXO SE NECESSÁRIO
-			"Unidade de Apuração": getValueSafely(row, idxUnidade),
-			"Situação":            getValueSafely(row, idxSituacao),
-			"Naturezas":           getValueSafely(row, idxNaturezas),
-		}
-	}
-
-	// 2. Processar "Dados do Fato"
-	rows, err = xlsx.GetRows("Dados do Fato")
-	if err != nil {
-		return nil, fmt.Errorf("erro ao ler planilha 'Dados do Fato': %v", err)
-	}
-
-	// Identificar índices das colunas para "Dados do Fato"
-	var idxIdFato, idxDataHora, idxCep, idxLatitude, idxLongitude,
-		idxLogradouro, idxNumeroFato, idxBairro, idxMunicipio, idxPais int = -1, -1, -1, -1, -1, -1, -1, -1, -1, -1
-
-	for i, cell := range rows[0] {
-		switch cell {
-		case "Id":
-			idxIdFato = i
-		case "Data/Hora Início":
-			idxDataHora = i
-		case "CEP":
-			idxCep = i
-		case "Latitude":
-			idxLatitude = i
-		case "Longitude":
-			idxLongitude = i
-		case "Logradouro":
-			idxLogradouro = i
-		case "Número":
-			idxNumeroFato = i
-		case "Bairro":
-			idxBairro = i
-		case "Município":
-			idxMunicipio = i
-		case "Pais":
-			idxPais = i
-		}
-	}
-
-	// Verificar se todas as colunas foram encontradas
-	if idxIdFato == -1 {
-		return nil, fmt.Errorf("coluna Id não encontrada na planilha 'Dados do Fato'")
-	}
-
-	// Processar linhas
-	for i, row := range rows {
-		if i == 0 { // Pular cabeçalho
-			continue
-		}
-		if len(row) <= idxIdFato {
-			continue // Linha vazia ou inválida
-		}
-
-		id := row[idxIdFato]
-		if id == "" {
-			continue
-		}
-
-		dadosFato[id] = map[string]string{
-			"Data/Hora Início": getValueSafely(row, idxDataHora),
-			"CEP":              getValueSafely(row, idxCep),
-			"Latitude":         getValueSafely(row, idxLatitude),
-			"Longitude":        getValueSafely(row, idxLongitude),
-			"Logradouro":       getValueSafely(row, idxLogradouro),
-			"Número":           getValueSafely(row, idxNumeroFato),
-			"Bairro":           getValueSafely(row, idxBairro),
-			"Município":        getValueSafely(row, idxMunicipio),
-			"Pais":             getValueSafely(row, idxPais),
-		}
-	}
-
-	// 3. Processar "Envolvidos" (pode ter múltiplos por ID)
-	rows, err = xlsx.GetRows("Envolvidos")
-	if err != nil {
-		return nil, fmt.Errorf("erro ao ler planilha 'Envolvidos': %v", err)
-	}
-
-	// Identificar índices das colunas para "Envolvidos"
-	var idxIdEnvolvido, idxParticipacoes, idxNome, idxCpf, idxFiliacao1,
-		idxDataNasc, idxNacionalidade, idxNaturalidade, idxUf, idxSexo, idxTelefone int = -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1
-
-	for i, cell := range rows[0] {
-		switch cell {
-		case "Id":
-			idxIdEnvolvido = i
-		case "Participações":
-			idxParticipacoes = i
-		case "Nome Completo":
-			idxNome = i
-		case "CPF":
-			idxCpf = i
-		case "Filiação 1":
-			idxFiliacao1 = i
-		case "Data de Nascimento":
-			idxDataNasc = i
-		case "Nacionalidade":
-			idxNacionalidade = i
-		case "Naturalidade":
-			idxNaturalidade = i
-		case "UF":
-			idxUf = i
-		case "Sexo":
-			idxSexo = i
-		case "Telefone":
-			idxTelefone = i
-		}
-	}
-
-	// Verificar se todas as colunas foram encontradas
-	if idxIdEnvolvido == -1 {
-		return nil, fmt.Errorf("coluna Id não encontrada na planilha 'Envolvidos'")
-	}
-
-	// Processar linhas
-	for i, row := range rows {
-		if i == 0 { // Pular cabeçalho
-			continue
-		}
-		if len(row) <= idxIdEnvolvido {
-			continue // Linha vazia ou inválida
-		}
-
-		id := row[idxIdEnvolvido]
-		if id == "" {
-			continue
-		}
-
-		envolvido := map[string]string{
-			"Participações":       getValueSafely(row, idxParticipacoes),
-			"Nome Completo":       getValueSafely(row, idxNome),
-			"CPF":                 getValueSafely(row, idxCpf),
-			"Filiação 1":          getValueSafely(row, idxFiliacao1),
-			"Data de Nascimento":  getValueSafely(row, idxDataNasc),
-			"Nacionalidade":       getValueSafely(row, idxNacionalidade),
-			"Naturalidade":        getValueSafely(row, idxNaturalidade),
-			"UF":                  getValueSafely(row, idxUf),
-			"Sexo":                getValueSafely(row, idxSexo),
-			"Telefone":            getValueSafely(row, idxTelefone),
-		}
-
-		// Adicionar envolvido ao mapa por ID
-		if _, exists := dadosEnvolvidos[id]; !exists {
-			dadosEnvolvidos[id] = []map[string]string{}
-		}
-		dadosEnvolvidos[id] = append(dadosEnvolvidos[id], envolvido)
-	}
-
-	// 4. Processar "Relato Histórico"
-	rows, err = xlsx.GetRows("Relato Histórico")
-	if err != nil {
-		return nil, fmt.Errorf("erro ao ler planilha 'Relato Histórico': %v", err)
-	}
-
-	// Identificar índices das colunas para "Relato Histórico"
-	var idxIdRelato, idxRelato int = -1, -1
-	for i, cell := range rows[0] {
-		switch cell {
-		case "Id":
-			idxIdRelato = i
-		case "Relato / Histórico":
-			idxRelato = i
-		}
-	}
-
-	// Verificar se todas as colunas foram encontradas
-	if idxIdRelato == -1 || idxRelato == -1 {
-		return nil, fmt.Errorf("colunas obrigatórias não encontradas na planilha 'Relato Histórico'")
-	}
-
-	// Processar linhas
-	for i, row := range rows {
-		if i == 0 { // Pular cabeçalho
-			continue
-		}
-		if len(row) <= idxIdRelato || len(row) <= idxRelato {
-			continue // Linha vazia ou inválida
-		}
-
-		id := row[idxIdRelato]
-		if id == "" {
-			continue
-		}
-
-		dadosRelato[id] = getValueSafely(row, idxRelato)
-	}
-
-	// 5. Relacionar os dados para gerar a lista final
-	for id, registro := range dadosRegistro {
-		fato, hasFato := dadosFato[id]
-		if !hasFato {
-			fato = make(map[string]string) // Criar mapa vazio se não existir
-		}
-
-		relato := dadosRelato[id]
-		envolvidos, hasEnvolvidos := dadosEnvolvidos[id]
-
-		if !hasEnvolvidos || len(envolvidos) == 0 {
-			// Criar pelo menos um registro mesmo sem envolvidos
-			dados := repository.DadosRelatorio{
-				NumeroBo:             registro["Número"],
-				DelegaciaResponsavel: registro["Unidade de Apuração"],
-				Situacao:             registro["Situação"],
-				Natureza:             registro["Naturezas"],
-				DataFato:             fato["Data/Hora Início"],
-				CepFato:              fato["CEP"],
-				LatitudeFato:         fato["Latitude"],
-				LongitudeFato:        fato["Longitude"],
-				LogradouroFato:       fato["Logradouro"],
-				NumeroCasaFato:       fato["Número"],
-				BairroFato:           fato["Bairro"],
-				MunicipioFato:        fato["Município"],
-				PaisFato:             fato["Pais"],
-				RelatoHistorico:      relato,
-			}
-			resultado = append(resultado, dados)
-		} else {
-			// Criar um registro para cada envolvido
-			for _, envolvido := range envolvidos {
-				dados := repository.DadosRelatorio{
-					NumeroBo:             registro["Número"],
-					DelegaciaResponsavel: registro["Unidade de Apuração"],
-					Situacao:             registro["Situação"],
-					Natureza:             registro["Naturezas"],
-					DataFato:             fato["Data/Hora Início"],
-					CepFato:              fato["CEP"],
-					LatitudeFato:         fato["Latitude"],
-					LongitudeFato:        fato["Longitude"],
-					LogradouroFato:       fato["Logradouro"],
-					NumeroCasaFato:       fato["Número"],
-					BairroFato:           fato["Bairro"],
-					MunicipioFato:        fato["Município"],
-					PaisFato:             fato["Pais"],
-					TipoEnvolvido:        envolvido["Participações"],
-					NomeCompleto:         envolvido["Nome Completo"],
-					Cpf:                  envolvido["CPF"],
-					NomeDaMae:            envolvido["Filiação 1"],
-					Nascimento:           envolvido["Data de Nascimento"],
-					Nacionalidade:        envolvido["Nacionalidade"],
-					Naturalidade:         envolvido["Naturalidade"],
-					UfEnvolvido:          envolvido["UF"],
-					SexoEnvolvido:        envolvido["Sexo"],
-					TelefoneEnvolvido:    envolvido["Telefone"],
-					RelatoHistorico:      relato,
-				}
-				resultado = append(resultado, dados)
-			}
-		}
-	}
-
-	log.Printf("Processamento concluído. Total de registros: %d", len(resultado))
-	if len(resultado) > 0 {
-		log.Printf("Primeiro BO processado: %s", resultado[0].NumeroBo)
-	}
-
-	return resultado, nil
-}
-
-// Função auxiliar para obter valor seguro de uma linha
-func getValueSafely(row []string, index int) string {
-	if index >= 0 && index < len(row) {
-		return row[index]
-	}
-	return ""
-}
-
 // Função auxiliar para responder com erro
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	response := map[string]string{"error": message}
@@ -0,0 +1,100 @@
+package importacao
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LinhaIgnorada é uma linha da planilha que não gerou registro
+type LinhaIgnorada struct {
+	Planilha string `json:"planilha"`
+	Linha    int    `json:"linha"` // numeração do Excel (o cabeçalho é a linha 1)
+	Motivo   string `json:"motivo"`
+}
+
+// maxLinhasIgnoradas limita as linhas listadas no diagnóstico; as demais só são contadas
+const maxLinhasIgnoradas = 1000
+
+// Diagnostico reúne o que a leitura do relatório ignorou ou não conseguiu mapear
+type Diagnostico struct {
+	LinhasIgnoradas []LinhaIgnorada `json:"linhas_ignoradas"`
+	TotalIgnoradas  int             `json:"total_linhas_ignoradas"`
+	Avisos          []string        `json:"avisos"`
+}
+
+// conferirColunas registra os campos do perfil sem coluna no cabeçalho (o campo
+// fica vazio) e as colunas do arquivo que não são importadas
+func (d *Diagnostico) conferirColunas(planilha string, cabecalho []string, colunas map[string][]string, campos []string) {
+	var naoImportadas []string
+	for _, coluna := range cabecalho {
+		if strings.TrimSpace(coluna) == "" {
+			continue
+		}
+		importada := false
+		for _, apelidos := range colunas {
+			if correspondeColuna(coluna, apelidos) {
+				importada = true
+				break
+			}
+		}
+		if !importada {
+			naoImportadas = append(naoImportadas, coluna)
+		}
+	}
+
+	for _, campo := range campos {
+		apelidos, ok := colunas[campo]
+		if ok && indiceColuna(cabecalho, apelidos) == -1 {
+			d.Avisos = append(d.Avisos, fmt.Sprintf("coluna '%s' não encontrada na planilha '%s'; o campo ficará vazio", strings.Join(apelidos, "' / '"), planilha))
+		}
+	}
+	if len(naoImportadas) > 0 {
+		d.Avisos = append(d.Avisos, fmt.Sprintf("colunas da planilha '%s' que não são importadas: %s", planilha, strings.Join(naoImportadas, ", ")))
+	}
+}
+
+// ignorar registra uma linha descartada e retorna se ela foi listada; linhas
+// totalmente em branco não são listadas
+func (d *Diagnostico) ignorar(planilha string, linha int, row []string, motivo string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			d.ignorarLinha(planilha, linha, motivo)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Diagnostico) ignorarLinha(planilha string, linha int, motivo string) {
+	d.TotalIgnoradas++
+	if len(d.LinhasIgnoradas) >= maxLinhasIgnoradas {
+		return
+	}
+	d.LinhasIgnoradas = append(d.LinhasIgnoradas, LinhaIgnorada{Planilha: planilha, Linha: linha, Motivo: motivo})
+}
+
+// ordenar deixa as linhas ignoradas na ordem das planilhas (nomes das abas no
+// arquivo) e das linhas
+func (d *Diagnostico) ordenar(planilhas []string) {
+	ordem := map[string]int{}
+	for i, planilha := range planilhas {
+		ordem[planilha] = i
+	}
+	sort.SliceStable(d.LinhasIgnoradas, func(i, j int) bool {
+		a, b := d.LinhasIgnoradas[i], d.LinhasIgnoradas[j]
+		if a.Planilha != b.Planilha {
+			return ordem[a.Planilha] < ordem[b.Planilha]
+		}
+		return a.Linha < b.Linha
+	})
+}
+
+func contem(lista []string, valor string) bool {
+	for _, item := range lista {
+		if item == valor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package importacao
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fraudbase/internal/normalize"
+)
+
+// Relatorio é um arquivo de relatório aberto, em qualquer formato suportado. Cada
+// aba é uma tabela de células em texto, com o cabeçalho na primeira linha; as
+// linhas podem ter menos células que o cabeçalho. As abas são lidas linha a
+// linha do arquivo em disco, sem carregá-las inteiras na memória.
+type Relatorio interface {
+	// Planilhas lista os nomes das abas do arquivo
+	Planilhas() []string
+	// Linhas percorre as linhas da aba; erro se ela não existir
+	Linhas(planilha string) (LinhasPlanilha, error)
+	Close() error
+}
+
+// LinhasPlanilha percorre as linhas de uma aba, como sql.Rows
+type LinhasPlanilha interface {
+	// Proxima avança para a próxima linha; false no fim da aba ou em erro
+	Proxima() bool
+	// Colunas retorna as células da linha atual
+	Colunas() []string
+	Err() error
+	Close() error
+}
+
+// leitorRelatorio abre um arquivo de um formato
+type leitorRelatorio func(caminho string) (Relatorio, error)
+
+// leitores associa a extensão do arquivo ao leitor do formato
+var leitores = map[string]leitorRelatorio{
+	".xlsx": abrirXLSX,
+	".xls":  abrirXLS, // Excel 97-2003
+	".ods":  abrirODS,
+	".zip":  abrirCSVZip, // um arquivo .csv por aba
+	".json": abrirJSON,
+}
+
+// VerificarFormato confere pela extensão se o arquivo está em um formato de
+// relatório que pode ser importado. O erro embrulha ErrArquivoInvalido.
+func VerificarFormato(nomeArquivo string) error {
+	_, err := leitorFormato(nomeArquivo)
+	return err
+}
+
+func leitorFormato(nomeArquivo string) (leitorRelatorio, error) {
+	extensao := strings.ToLower(filepath.Ext(nomeArquivo))
+	leitor, ok := leitores[extensao]
+	if ok {
+		return leitor, nil
+	}
+
+	extensoes := make([]string, 0, len(leitores))
+	for e := range leitores {
+		extensoes = append(extensoes, e)
+	}
+	sort.Strings(extensoes)
+	return nil, fmt.Errorf("%w: formato de arquivo %q não suportado (use %s)", ErrArquivoInvalido, extensao, strings.Join(extensoes, ", "))
+}
+
+// abrirFormato abre o arquivo em caminho com o leitor escolhido pela extensão do
+// nome original
+func abrirFormato(caminho, nomeArquivo string) (Relatorio, error) {
+	leitor, err := leitorFormato(nomeArquivo)
+	if err != nil {
+		return nil, err
+	}
+	return leitor(caminho)
+}
+
+// numeroExcel formata o número de uma célula do Excel como o texto exibido: data
+// se o formato da célula for de data (layout de layoutDataExcel), senão o número
+// com até 15 algarismos significativos, como o Excel o mostra
+func numeroExcel(v float64, layout string, data1904 bool) string {
+	if layout != "" && v >= 0 && v < 2958466 {
+		base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		if data1904 {
+			base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		return base.Add(time.Duration(math.Round(v*86400)) * time.Second).Format(layout)
+	}
+	v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// layoutDataExcel retorna o layout de data do Go para o formato de número do
+// Excel (XLS e XLSX), ou "" se não for formato de data. Os formatos internos de
+// data são os da especificação; os personalizados são de data se usam d, m, y, h
+// ou s fora de aspas e colchetes.
+func layoutDataExcel(id uint16, codigos map[uint16]string) string {
+	codigo, ok := codigos[id]
+	if !ok {
+		switch {
+		case id >= 14 && id <= 17, id >= 27 && id <= 36, id >= 50 && id <= 58:
+			return "02/01/2006"
+		case id == 18 || id == 20:
+			return "15:04"
+		case id == 19 || id == 21 || (id >= 45 && id <= 47):
+			return "15:04:05"
+		case id == 22:
+			return "02/01/2006 15:04"
+		}
+		return ""
+	}
+
+	var data, mes, hora, segundos bool
+	var aspas, colchetes, escapar bool
+percorrer:
+	for _, c := range strings.ToLower(codigo) {
+		switch {
+		case escapar:
+			escapar = false
+		case aspas:
+			aspas = c != '"'
+		case colchetes:
+			colchetes = c != ']'
+		case c == '\\' || c == '_' || c == '*':
+			escapar = true
+		case c == '"':
+			aspas = true
+		case c == '[':
+			colchetes = true
+		case c == ';':
+			break percorrer // só a seção dos números positivos
+		case c == 'd' || c == 'y':
+			data = true
+		case c == 'm':
+			mes = true
+		case c == 'h':
+			hora = true
+		case c == 's':
+			segundos = true
+		}
+	}
+	// m é mês, a não ser em formatos só de hora (h:mm, mm:ss)
+	data = data || (mes && !hora && !segundos)
+	hora = hora || segundos
+
+	switch {
+	case data && hora:
+		return "02/01/2006 15:04"
+	case data:
+		return "02/01/2006"
+	case hora && segundos:
+		return "15:04:05"
+	case hora:
+		return "15:04"
+	}
+	return ""
+}
+
+// localizarPlanilha retorna o nome da aba do relatório que corresponde ao nome do
+// perfil. Além do nome exato, aceita diferenças de maiúsculas, acentos e "_" no
+// lugar de espaço, comuns em nomes de arquivos CSV.
+func localizarPlanilha(rel Relatorio, planilha string) (string, bool) {
+	nomes := rel.Planilhas()
+	if contem(nomes, planilha) {
+		return planilha, true
+	}
+	procurado := nomeComparavel(planilha)
+	for _, nome := range nomes {
+		if nomeComparavel(nome) == procurado {
+			return nome, true
+		}
+	}
+	return "", false
+}
+
+func nomeComparavel(nome string) string {
+	return normalize.TextoSemAcentos(strings.ReplaceAll(nome, "_", " "))
+}
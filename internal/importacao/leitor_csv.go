@@ -0,0 +1,206 @@
+package importacao
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// tamanhoBufferCSV é o trecho do início do arquivo usado para detectar o separador
+const tamanhoBufferCSV = 64 << 10
+
+// relatorioCSVZip lê um .zip com um arquivo .csv por aba; o nome do arquivo (sem
+// a extensão e sem as pastas) é o nome da aba. O separador (";", "," ou tab) é
+// detectado pelo cabeçalho e arquivos que não são UTF-8 são lidos como Latin-1,
+// a codificação que o Excel usa ao salvar CSV no Windows.
+type relatorioCSVZip struct {
+	pacote   *zip.ReadCloser
+	nomes    []string
+	arquivos map[string]*zip.File
+}
+
+func abrirCSVZip(caminho string) (Relatorio, error) {
+	pacote, err := zip.OpenReader(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("%w: erro ao ler arquivo .zip: %v", ErrArquivoInvalido, err)
+	}
+
+	rel := &relatorioCSVZip{pacote: pacote, arquivos: map[string]*zip.File{}}
+	for _, f := range pacote.File {
+		nome := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(nome, ".") ||
+			!strings.EqualFold(path.Ext(nome), ".csv") {
+			continue
+		}
+
+		planilha := strings.TrimSuffix(nome, path.Ext(nome))
+		if _, ok := rel.arquivos[planilha]; !ok {
+			rel.nomes = append(rel.nomes, planilha)
+		}
+		rel.arquivos[planilha] = f
+	}
+
+	if len(rel.nomes) == 0 {
+		pacote.Close()
+		return nil, fmt.Errorf("%w: o arquivo .zip não contém arquivos .csv", ErrArquivoInvalido)
+	}
+	return rel, nil
+}
+
+func (r *relatorioCSVZip) Planilhas() []string {
+	return r.nomes
+}
+
+// Linhas lê o CSV da aba. O arquivo é percorrido uma vez antes para saber se é
+// UTF-8, já que um caractere Latin-1 pode aparecer só no fim.
+func (r *relatorioCSVZip) Linhas(planilha string) (LinhasPlanilha, error) {
+	f, ok := r.arquivos[planilha]
+	if !ok {
+		return nil, fmt.Errorf("planilha '%s' não existe", planilha)
+	}
+
+	utf8Valido, err := csvUTF8(f)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler '%s': %v", f.Name, err)
+	}
+
+	arquivo, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler '%s': %v", f.Name, err)
+	}
+
+	bruto := bufio.NewReader(arquivo)
+	if bom, _ := bruto.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		bruto.Discard(3)
+	}
+	var conteudo io.Reader = bruto
+	if !utf8Valido {
+		conteudo = &leitorLatin1{r: bruto}
+	}
+	buffer := bufio.NewReaderSize(conteudo, tamanhoBufferCSV)
+	inicio, _ := buffer.Peek(tamanhoBufferCSV)
+
+	leitor := csv.NewReader(buffer)
+	leitor.Comma = separadorCSV(inicio)
+	leitor.FieldsPerRecord = -1
+	leitor.LazyQuotes = true
+
+	return &linhasCSV{arquivo: arquivo, leitor: leitor}, nil
+}
+
+func (r *relatorioCSVZip) Close() error {
+	return r.pacote.Close()
+}
+
+// linhasCSV entrega os registros do CSV sem as células vazias do fim de cada
+// linha, como o XLSX
+type linhasCSV struct {
+	arquivo io.ReadCloser
+	leitor  *csv.Reader
+	atual   []string
+	err     error
+}
+
+func (l *linhasCSV) Proxima() bool {
+	linha, err := l.leitor.Read()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		return false
+	}
+
+	fim := len(linha)
+	for fim > 0 && linha[fim-1] == "" {
+		fim--
+	}
+	l.atual = linha[:fim]
+	return true
+}
+
+func (l *linhasCSV) Colunas() []string {
+	return l.atual
+}
+
+func (l *linhasCSV) Err() error {
+	return l.err
+}
+
+func (l *linhasCSV) Close() error {
+	return l.arquivo.Close()
+}
+
+// csvUTF8 percorre o arquivo verificando se todo ele é UTF-8 válido
+func csvUTF8(f *zip.File) (bool, error) {
+	arquivo, err := f.Open()
+	if err != nil {
+		return false, err
+	}
+	defer arquivo.Close()
+
+	leitor := bufio.NewReaderSize(arquivo, tamanhoBufferCSV)
+	for {
+		r, tamanho, err := leitor.ReadRune()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if r == utf8.RuneError && tamanho == 1 {
+			return false, nil
+		}
+	}
+}
+
+// separadorCSV escolhe o separador mais frequente na primeira linha
+func separadorCSV(conteudo []byte) rune {
+	primeira := conteudo
+	if fim := bytes.IndexByte(conteudo, '\n'); fim >= 0 {
+		primeira = conteudo[:fim]
+	}
+
+	separador, maior := ';', 0
+	for _, candidato := range []rune{';', ',', '\t'} {
+		if n := bytes.Count(primeira, []byte(string(candidato))); n > maior {
+			separador, maior = candidato, n
+		}
+	}
+	return separador
+}
+
+// leitorLatin1 converte para UTF-8 um texto ISO-8859-1, em que cada byte é um
+// caractere
+type leitorLatin1 struct {
+	r          io.Reader
+	bruto      []byte
+	convertido []byte
+	pendente   []byte // parte de convertido ainda não entregue
+}
+
+func (l *leitorLatin1) Read(p []byte) (int, error) {
+	if len(l.pendente) == 0 {
+		if cap(l.bruto) < len(p) {
+			l.bruto = make([]byte, len(p))
+		}
+		n, err := l.r.Read(l.bruto[:len(p)])
+		if n == 0 {
+			return 0, err
+		}
+		l.convertido = l.convertido[:0]
+		for _, c := range l.bruto[:n] {
+			l.convertido = utf8.AppendRune(l.convertido, rune(c))
+		}
+		l.pendente = l.convertido
+	}
+
+	n := copy(p, l.pendente)
+	l.pendente = l.pendente[n:]
+	return n, nil
+}
@@ -0,0 +1,72 @@
+package importacao
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeparadorCSV(t *testing.T) {
+	casos := []struct {
+		conteudo string
+		esperado rune
+	}{
+		{"BO;Nome;CPF\n1;a,b;c", ';'},
+		{"BO,Nome,CPF\n1;2;3;4;5", ','},
+		{"BO\tNome\tCPF", '\t'},
+		{"Nome, Sobrenome;CPF;Tipo", ';'},
+		{"BO", ';'},
+		{"", ';'},
+	}
+	for _, c := range casos {
+		if obtido := separadorCSV([]byte(c.conteudo)); obtido != c.esperado {
+			t.Errorf("separadorCSV(%q) = %q; esperado %q", c.conteudo, obtido, c.esperado)
+		}
+	}
+}
+
+func TestLinhasCSV(t *testing.T) {
+	casos := []struct {
+		nome     string
+		conteudo string
+		esperado [][]string
+	}{
+		{
+			nome:     "UTF-8 com BOM e ponto e vírgula",
+			conteudo: "\xef\xbb\xbfNúmero do BO;Relato\r\n1;\"a; b\"\r\n",
+			esperado: [][]string{{"Número do BO", "Relato"}, {"1", "a; b"}},
+		},
+		{
+			nome:     "Latin-1 com vírgula",
+			conteudo: "N\xfamero do BO,Tipo\n1,V\xedtima\n",
+			esperado: [][]string{{"Número do BO", "Tipo"}, {"1", "Vítima"}},
+		},
+		{
+			nome:     "tabulação e células vazias no fim",
+			conteudo: "BO\tNome\tCPF\n1\t\t\n2\tAna\t\n",
+			esperado: [][]string{{"BO", "Nome", "CPF"}, {"1"}, {"2", "Ana"}},
+		},
+		{
+			nome:     "aspas soltas",
+			conteudo: "BO;Relato\n1;disse \"não\" ao golpe\n",
+			esperado: [][]string{{"BO", "Relato"}, {"1", "disse \"não\" ao golpe"}},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			rel, err := abrirCSVZip(arquivoTemporario(t, "relatorio.zip", "",
+				[2]string{"__MACOSX/._Aba.csv", "lixo"}, [2]string{"pasta/Aba.csv", c.conteudo}))
+			if err != nil {
+				t.Fatalf("abrirCSVZip: %v", err)
+			}
+			defer rel.Close()
+
+			if obtido := rel.Planilhas(); !reflect.DeepEqual(obtido, []string{"Aba"}) {
+				t.Errorf("Planilhas() = %q; esperado [Aba]", obtido)
+			}
+			if obtido := lerAbas(t, rel)["Aba"]; !reflect.DeepEqual(obtido, c.esperado) {
+				t.Errorf("linhas = %q; esperado %q", obtido, c.esperado)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package importacao
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// relatorioJSON lê um objeto em que cada chave é o nome de uma aba e o valor é a
+// lista das linhas, em um de dois formatos:
+//
+//	{"Envolvidos": [{"Id": 1, "Nome Completo": "..."}, ...]}  objetos por cabeçalho
+//	{"Envolvidos": [["Id", "Nome Completo"], [1, "..."]]}     listas, cabeçalho na primeira
+//
+// Nos objetos as colunas seguem a ordem em que os cabeçalhos aparecem. Números são
+// lidos como escritos e null como célula vazia. O arquivo é lido por tokens e
+// percorrido de novo a cada aba, sem ser carregado na memória.
+type relatorioJSON struct {
+	caminho string
+	nomes   []string
+}
+
+func abrirJSON(caminho string) (Relatorio, error) {
+	arquivo, err := os.Open(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo: %v", err)
+	}
+	defer arquivo.Close()
+
+	decoder := novoDecoderJSON(arquivo)
+	if err := esperarDelimitador(decoder, '{'); err != nil {
+		return nil, fmt.Errorf("%w: o JSON deve ser um objeto com uma lista de linhas por aba: %v", ErrArquivoInvalido, err)
+	}
+
+	rel := &relatorioJSON{caminho: caminho}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: erro ao ler JSON: %v", ErrArquivoInvalido, err)
+		}
+		planilha, _ := token.(string)
+
+		if err := esperarDelimitador(decoder, '['); err != nil {
+			return nil, fmt.Errorf("%w: a aba '%s' do JSON deve ser uma lista de linhas: %v", ErrArquivoInvalido, planilha, err)
+		}
+		if err := pularValorJSON(decoder, 1); err != nil {
+			return nil, fmt.Errorf("%w: erro ao ler a aba '%s' do JSON: %v", ErrArquivoInvalido, planilha, err)
+		}
+		if !contem(rel.nomes, planilha) {
+			rel.nomes = append(rel.nomes, planilha)
+		}
+	}
+
+	return rel, nil
+}
+
+func (r *relatorioJSON) Planilhas() []string {
+	return r.nomes
+}
+
+// Linhas lê a aba no formato da primeira linha. No formato de objetos a aba é
+// percorrida uma vez antes para montar o cabeçalho com todas as chaves.
+func (r *relatorioJSON) Linhas(planilha string) (LinhasPlanilha, error) {
+	cabecalho, objetos, err := r.cabecalho(planilha)
+	if err != nil {
+		return nil, err
+	}
+
+	arquivo, decoder, err := r.posicionar(planilha)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linhasJSON{arquivo: arquivo, decoder: decoder, objetos: objetos}
+	if objetos {
+		l.cabecalho = cabecalho
+		l.colunas = make(map[string]int, len(cabecalho))
+		for i, coluna := range cabecalho {
+			l.colunas[coluna] = i
+		}
+	}
+	return l, nil
+}
+
+func (r *relatorioJSON) Close() error {
+	return nil
+}
+
+// posicionar abre o arquivo e avança até a primeira linha da aba. Se a chave se
+// repetir vale a última, como em encoding/json.
+func (r *relatorioJSON) posicionar(planilha string) (*os.File, *json.Decoder, error) {
+	if !contem(r.nomes, planilha) {
+		return nil, nil, fmt.Errorf("planilha '%s' não existe", planilha)
+	}
+	ocorrencias := 0
+	arquivo, err := os.Open(r.caminho)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A primeira passada conta as ocorrências da chave para parar na última
+	decoder := novoDecoderJSON(arquivo)
+	if err := percorrerAbasJSON(decoder, func(nome string) (bool, error) {
+		if nome == planilha {
+			ocorrencias++
+		}
+		return false, pularValorJSON(decoder, 0)
+	}); err != nil {
+		arquivo.Close()
+		return nil, nil, err
+	}
+
+	if _, err := arquivo.Seek(0, io.SeekStart); err != nil {
+		arquivo.Close()
+		return nil, nil, err
+	}
+	decoder = novoDecoderJSON(arquivo)
+	vistas := 0
+	err = percorrerAbasJSON(decoder, func(nome string) (bool, error) {
+		if nome == planilha {
+			if vistas++; vistas == ocorrencias {
+				return true, esperarDelimitador(decoder, '[')
+			}
+		}
+		return false, pularValorJSON(decoder, 0)
+	})
+	if err != nil {
+		arquivo.Close()
+		return nil, nil, err
+	}
+	return arquivo, decoder, nil
+}
+
+// cabecalho percorre a aba e, se as linhas forem objetos, retorna as chaves na
+// ordem em que aparecem
+func (r *relatorioJSON) cabecalho(planilha string) ([]string, bool, error) {
+	arquivo, decoder, err := r.posicionar(planilha)
+	if err != nil {
+		return nil, false, err
+	}
+	defer arquivo.Close()
+
+	cabecalho := []string{}
+	vistas := map[string]bool{}
+	for i := 1; decoder.More(); i++ {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false, fmt.Errorf("linha %d: %v", i, err)
+		}
+		if d, ok := token.(json.Delim); ok && d == '[' && i == 1 {
+			return nil, false, nil
+		}
+		if d, ok := token.(json.Delim); !ok || d != '{' {
+			return nil, false, fmt.Errorf("linha %d: esperado um objeto, encontrado %v", i, token)
+		}
+		for decoder.More() {
+			chave, err := decoder.Token()
+			if err != nil {
+				return nil, false, fmt.Errorf("linha %d: %v", i, err)
+			}
+			nome, _ := chave.(string)
+			if !vistas[nome] {
+				vistas[nome] = true
+				cabecalho = append(cabecalho, nome)
+			}
+			if err := pularValorJSON(decoder, 0); err != nil {
+				return nil, false, fmt.Errorf("linha %d: %v", i, err)
+			}
+		}
+		if err := esperarDelimitador(decoder, '}'); err != nil {
+			return nil, false, fmt.Errorf("linha %d: %v", i, err)
+		}
+	}
+	return cabecalho, true, nil
+}
+
+// linhasJSON entrega as linhas de uma aba; no formato de objetos a primeira é o
+// cabeçalho montado por relatorioJSON.cabecalho
+type linhasJSON struct {
+	arquivo   *os.File
+	decoder   *json.Decoder
+	objetos   bool
+	cabecalho []string
+	colunas   map[string]int
+	lidas     int
+	atual     []string
+	err       error
+}
+
+func (l *linhasJSON) Proxima() bool {
+	if l.objetos && l.cabecalho != nil {
+		l.atual, l.cabecalho = l.cabecalho, nil
+		return true
+	}
+	if l.err != nil || !l.decoder.More() {
+		return false
+	}
+	l.lidas++
+
+	var err error
+	if l.objetos {
+		l.atual, err = l.lerObjeto()
+	} else {
+		l.atual, err = l.lerLista()
+	}
+	if err != nil {
+		l.err = fmt.Errorf("linha %d: %v", l.lidas, err)
+		return false
+	}
+	return true
+}
+
+func (l *linhasJSON) lerLista() ([]string, error) {
+	if err := esperarDelimitador(l.decoder, '['); err != nil {
+		return nil, err
+	}
+	linha := []string{}
+	for l.decoder.More() {
+		var valor interface{}
+		if err := l.decoder.Decode(&valor); err != nil {
+			return nil, err
+		}
+		linha = append(linha, celulaJSON(valor))
+	}
+	return linha, esperarDelimitador(l.decoder, ']')
+}
+
+func (l *linhasJSON) lerObjeto() ([]string, error) {
+	if err := esperarDelimitador(l.decoder, '{'); err != nil {
+		return nil, fmt.Errorf("esperado um objeto ou uma lista: %v", err)
+	}
+	linha := []string{}
+	for l.decoder.More() {
+		chave, err := l.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var valor interface{}
+		if err := l.decoder.Decode(&valor); err != nil {
+			return nil, err
+		}
+		nome, _ := chave.(string)
+		j := l.colunas[nome]
+		for len(linha) <= j {
+			linha = append(linha, "")
+		}
+		linha[j] = celulaJSON(valor)
+	}
+	return linha, esperarDelimitador(l.decoder, '}')
+}
+
+func (l *linhasJSON) Colunas() []string {
+	return l.atual
+}
+
+func (l *linhasJSON) Err() error {
+	return l.err
+}
+
+func (l *linhasJSON) Close() error {
+	return l.arquivo.Close()
+}
+
+func novoDecoderJSON(arquivo io.Reader) *json.Decoder {
+	decoder := json.NewDecoder(arquivo)
+	decoder.UseNumber()
+	return decoder
+}
+
+// percorrerAbasJSON chama aba para cada chave do objeto principal, com o decoder
+// posicionado antes do valor, até aba retornar true
+func percorrerAbasJSON(decoder *json.Decoder, aba func(nome string) (bool, error)) error {
+	if err := esperarDelimitador(decoder, '{'); err != nil {
+		return err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		nome, _ := token.(string)
+		parar, err := aba(nome)
+		if err != nil || parar {
+			return err
+		}
+	}
+	return nil
+}
+
+// pularValorJSON descarta um valor sem decodificá-lo. profundidade é quantos
+// delimitadores do valor já foram lidos (0 se nenhum).
+func pularValorJSON(decoder *json.Decoder, profundidade int) error {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := token.(json.Delim); ok {
+			if d == '[' || d == '{' {
+				profundidade++
+			} else {
+				profundidade--
+			}
+		}
+		if profundidade == 0 {
+			return nil
+		}
+	}
+}
+
+func esperarDelimitador(decoder *json.Decoder, delimitador json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delimitador {
+		return fmt.Errorf("esperado '%s', encontrado %v", delimitador, token)
+	}
+	return nil
+}
+
+// celulaJSON converte um valor JSON no texto da célula
+func celulaJSON(valor interface{}) string {
+	switch v := valor.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		texto, _ := json.Marshal(v)
+		return string(texto)
+	}
+}
@@ -0,0 +1,95 @@
+package importacao
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinhasJSON(t *testing.T) {
+	casos := []struct {
+		nome     string
+		conteudo string
+		esperado [][]string
+	}{
+		{
+			nome:     "objetos com chaves novas e null",
+			conteudo: `{"Aba": [{"BO": 1, "Valor": 10.50}, {"Tipo": true, "BO": "2", "Valor": null}]}`,
+			esperado: [][]string{{"BO", "Valor", "Tipo"}, {"1", "10.50"}, {"2", "", "true"}},
+		},
+		{
+			nome:     "listas",
+			conteudo: `{"Aba": [["BO", "Nome"], [1, {"a": 1}], []]}`,
+			esperado: [][]string{{"BO", "Nome"}, {"1", `{"a":1}`}, {}},
+		},
+		{
+			nome:     "aba repetida vale a última",
+			conteudo: `{"Aba": [["antiga"]], "Outra": [], "Aba": [["nova"]]}`,
+			esperado: [][]string{{"nova"}},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			rel, err := abrirJSON(arquivoTemporario(t, "relatorio.json", c.conteudo))
+			if err != nil {
+				t.Fatalf("abrirJSON: %v", err)
+			}
+			defer rel.Close()
+
+			if obtido := lerAbas(t, rel)["Aba"]; !reflect.DeepEqual(obtido, c.esperado) {
+				t.Errorf("linhas = %q; esperado %q", obtido, c.esperado)
+			}
+		})
+	}
+}
+
+func TestFormatoInvalidoJSON(t *testing.T) {
+	casos := []struct {
+		nome     string
+		conteudo string
+		erro     string // erro ao abrir (embrulha ErrArquivoInvalido)
+		erroAba  string // erro ao ler as linhas da aba
+	}{
+		{nome: "lista na raiz", conteudo: `[{"BO": 1}]`, erro: "deve ser um objeto"},
+		{nome: "aba que não é lista", conteudo: `{"Aba": {"BO": 1}}`, erro: "deve ser uma lista de linhas"},
+		{nome: "arquivo truncado", conteudo: `{"Aba": [["BO"], [1`, erro: "erro ao ler a aba 'Aba'"},
+		{nome: "linha que não é objeto nem lista", conteudo: `{"Aba": [1, 2]}`, erroAba: "linha 1: esperado um objeto"},
+		{nome: "lista depois de objeto", conteudo: `{"Aba": [{"BO": 1}, ["2"]]}`, erroAba: "linha 2: esperado um objeto"},
+		{nome: "objeto depois de lista", conteudo: `{"Aba": [["BO"], {"BO": 2}]}`, erroAba: "linha 2:"},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			rel, err := abrirJSON(arquivoTemporario(t, "relatorio.json", c.conteudo))
+			if c.erro != "" {
+				if !errors.Is(err, ErrArquivoInvalido) || !strings.Contains(err.Error(), c.erro) {
+					t.Errorf("abrirJSON = %v; esperado erro com %q", err, c.erro)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("abrirJSON: %v", err)
+			}
+			defer rel.Close()
+
+			err = lerAbaJSON(rel, "Aba")
+			if err == nil || !strings.Contains(err.Error(), c.erroAba) {
+				t.Errorf("erro da aba = %v; esperado %q", err, c.erroAba)
+			}
+		})
+	}
+}
+
+// lerAbaJSON percorre a aba e retorna o erro de Linhas ou da leitura
+func lerAbaJSON(rel Relatorio, planilha string) error {
+	linhas, err := rel.Linhas(planilha)
+	if err != nil {
+		return err
+	}
+	defer linhas.Close()
+	for linhas.Proxima() {
+	}
+	return linhas.Err()
+}
@@ -0,0 +1,279 @@
+package importacao
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxRepeticoesODS limita as repetições de células e linhas preenchidas; as vazias
+// no fim da aba (o LibreOffice grava milhares) são descartadas
+const maxRepeticoesODS = 100000
+
+// relatorioODS lê as abas de uma planilha OpenDocument (.ods): um zip cujo
+// content.xml traz as tabelas. O content.xml é percorrido de novo a cada aba
+// lida, sem ser carregado na memória. As células são lidas como o texto
+// exibido, como no XLSX.
+type relatorioODS struct {
+	caminho string
+	nomes   []string
+}
+
+func abrirODS(caminho string) (Relatorio, error) {
+	conteudo, err := abrirConteudoODS(caminho)
+	if err != nil {
+		return nil, err
+	}
+	defer conteudo.Close()
+
+	rel := &relatorioODS{caminho: caminho}
+	decoder := xml.NewDecoder(conteudo)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: erro ao ler arquivo ODS: %v", ErrArquivoInvalido, err)
+		}
+		if t, ok := token.(xml.StartElement); ok && t.Name.Local == "table" {
+			rel.nomes = append(rel.nomes, atributoXML(t, "name"))
+			if err := decoder.Skip(); err != nil {
+				return nil, fmt.Errorf("%w: erro ao ler arquivo ODS: %v", ErrArquivoInvalido, err)
+			}
+		}
+	}
+	return rel, nil
+}
+
+func (r *relatorioODS) Planilhas() []string {
+	return r.nomes
+}
+
+func (r *relatorioODS) Linhas(planilha string) (LinhasPlanilha, error) {
+	conteudo, err := abrirConteudoODS(r.caminho)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(conteudo)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			conteudo.Close()
+			if err == io.EOF {
+				return nil, fmt.Errorf("planilha '%s' não existe", planilha)
+			}
+			return nil, err
+		}
+		t, ok := token.(xml.StartElement)
+		if !ok || t.Name.Local != "table" {
+			continue
+		}
+		if atributoXML(t, "name") == planilha {
+			return &linhasODS{conteudo: conteudo, decoder: decoder}, nil
+		}
+		if err := decoder.Skip(); err != nil {
+			conteudo.Close()
+			return nil, err
+		}
+	}
+}
+
+func (r *relatorioODS) Close() error {
+	return nil
+}
+
+// conteudoODS é o content.xml aberto dentro do zip
+type conteudoODS struct {
+	io.ReadCloser
+	pacote *zip.ReadCloser
+}
+
+func (c *conteudoODS) Close() error {
+	c.ReadCloser.Close()
+	return c.pacote.Close()
+}
+
+func abrirConteudoODS(caminho string) (*conteudoODS, error) {
+	pacote, err := zip.OpenReader(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("%w: erro ao ler arquivo ODS: %v", ErrArquivoInvalido, err)
+	}
+	for _, f := range pacote.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+		xmlConteudo, err := f.Open()
+		if err != nil {
+			pacote.Close()
+			return nil, fmt.Errorf("%w: erro ao ler arquivo ODS: %v", ErrArquivoInvalido, err)
+		}
+		return &conteudoODS{ReadCloser: xmlConteudo, pacote: pacote}, nil
+	}
+	pacote.Close()
+	return nil, fmt.Errorf("%w: arquivo ODS sem content.xml", ErrArquivoInvalido)
+}
+
+// linhasODS percorre as table:table-row de uma table:table. Linhas repetidas são
+// entregues uma a uma; as vazias só entram se vier outra preenchida depois.
+type linhasODS struct {
+	conteudo *conteudoODS
+	decoder  *xml.Decoder
+
+	vaziasLidas int // linhas vazias ainda não entregues
+	vazias      int // linhas vazias a entregar antes de linha
+	linha       []string
+	repeticoes  int // vezes que linha ainda será entregue
+	atual       []string
+	fim         bool
+	err         error
+}
+
+func (l *linhasODS) Proxima() bool {
+	for l.vazias == 0 && l.repeticoes == 0 {
+		if l.fim {
+			return false
+		}
+		linha, repeticao, ok := l.lerLinha()
+		if !ok {
+			l.fim = true
+			return false
+		}
+		if len(linha) == 0 {
+			l.vaziasLidas += repeticao
+			continue
+		}
+		l.vazias, l.vaziasLidas = min(l.vaziasLidas, maxRepeticoesODS), 0
+		l.linha, l.repeticoes = linha, min(repeticao, maxRepeticoesODS)
+	}
+
+	if l.vazias > 0 {
+		l.vazias--
+		l.atual = []string{}
+		return true
+	}
+	l.repeticoes--
+	l.atual = l.linha
+	return true
+}
+
+// lerLinha lê a próxima table-row da tabela; false no fim da tabela ou em erro
+func (l *linhasODS) lerLinha() ([]string, int, bool) {
+	var (
+		linha          []string
+		celulasVazias  int // células vazias ainda não gravadas (só entram se vier outra preenchida)
+		repeticaoLinha = 1
+
+		celula          strings.Builder
+		repeticaoCelula int
+		dentroCelula    bool
+		paragrafos      int  // parágrafos da célula, separados por quebra de linha
+		noParagrafo     bool // dentro de text:p
+		anotacao        int  // profundidade dentro de office:annotation, cujo texto é ignorado
+	)
+
+	for {
+		token, err := l.decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("content.xml terminou antes do fim da tabela")
+			}
+			l.err = err
+			return nil, 0, false
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case anotacao > 0:
+				anotacao++
+			case t.Name.Local == "annotation":
+				anotacao = 1
+			case t.Name.Local == "table-row":
+				linha, celulasVazias = nil, 0
+				repeticaoLinha = repeticoesODS(t, "number-rows-repeated")
+			case t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell":
+				celula.Reset()
+				dentroCelula, paragrafos = true, 0
+				repeticaoCelula = repeticoesODS(t, "number-columns-repeated")
+			case dentroCelula && t.Name.Local == "p":
+				if paragrafos > 0 {
+					celula.WriteString("\n")
+				}
+				paragrafos++
+				noParagrafo = true
+			case dentroCelula && t.Name.Local == "s":
+				celula.WriteString(strings.Repeat(" ", repeticoesODS(t, "c")))
+			case dentroCelula && t.Name.Local == "tab":
+				celula.WriteString("\t")
+			case dentroCelula && t.Name.Local == "line-break":
+				celula.WriteString("\n")
+			}
+
+		case xml.CharData:
+			if dentroCelula && anotacao == 0 && noParagrafo {
+				celula.Write(t)
+			}
+
+		case xml.EndElement:
+			switch {
+			case anotacao > 0:
+				anotacao--
+			case t.Name.Local == "p":
+				noParagrafo = false
+			case t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell":
+				dentroCelula = false
+				valor := celula.String()
+				if valor == "" {
+					celulasVazias += repeticaoCelula
+					continue
+				}
+				for i := 0; i < celulasVazias && i < maxRepeticoesODS; i++ {
+					linha = append(linha, "")
+				}
+				celulasVazias = 0
+				for i := 0; i < repeticaoCelula && i < maxRepeticoesODS; i++ {
+					linha = append(linha, valor)
+				}
+			case t.Name.Local == "table-row":
+				return linha, repeticaoLinha, true
+			case t.Name.Local == "table":
+				return nil, 0, false
+			}
+		}
+	}
+}
+
+func (l *linhasODS) Colunas() []string {
+	return l.atual
+}
+
+func (l *linhasODS) Err() error {
+	return l.err
+}
+
+func (l *linhasODS) Close() error {
+	return l.conteudo.Close()
+}
+
+func atributoXML(elemento xml.StartElement, nome string) string {
+	for _, atributo := range elemento.Attr {
+		if atributo.Name.Local == nome {
+			return atributo.Value
+		}
+	}
+	return ""
+}
+
+// repeticoesODS lê um atributo de repetição (1 se ausente ou inválido)
+func repeticoesODS(elemento xml.StartElement, nome string) int {
+	n, err := strconv.Atoi(atributoXML(elemento, nome))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,64 @@
+package importacao
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLinhasODS(t *testing.T) {
+	casos := []struct {
+		nome     string
+		linhas   string // table:table-row da aba
+		esperado [][]string
+	}{
+		{
+			nome: "colunas repetidas",
+			linhas: `<table:table-row><table:table-cell table:number-columns-repeated="3"><text:p>x</text:p></table:table-cell>` +
+				`<table:table-cell><text:p>y</text:p></table:table-cell></table:table-row>`,
+			esperado: [][]string{{"x", "x", "x", "y"}},
+		},
+		{
+			nome: "células vazias repetidas no meio e no fim",
+			linhas: `<table:table-row><table:table-cell><text:p>a</text:p></table:table-cell>` +
+				`<table:table-cell table:number-columns-repeated="2"/><table:table-cell><text:p>b</text:p></table:table-cell>` +
+				`<table:table-cell table:number-columns-repeated="16380"/></table:table-row>`,
+			esperado: [][]string{{"a", "", "", "b"}},
+		},
+		{
+			nome: "linhas repetidas e vazias",
+			linhas: `<table:table-row table:number-rows-repeated="2"><table:table-cell><text:p>r</text:p></table:table-cell></table:table-row>` +
+				`<table:table-row table:number-rows-repeated="2"><table:table-cell/></table:table-row>` +
+				`<table:table-row><table:table-cell><text:p>s</text:p></table:table-cell></table:table-row>` +
+				`<table:table-row table:number-rows-repeated="1048570"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>`,
+			esperado: [][]string{{"r"}, {"r"}, {}, {}, {"s"}},
+		},
+		{
+			nome: "parágrafos, espaços e anotações",
+			linhas: `<table:table-row><table:table-cell><office:annotation><text:p>nota</text:p></office:annotation>` +
+				`<text:p>Rua<text:s text:c="2"/>A</text:p><text:p>Casa 2</text:p></table:table-cell>` +
+				`<table:covered-table-cell/><table:table-cell><text:p>fim</text:p></table:table-cell></table:table-row>`,
+			esperado: [][]string{{"Rua  A\nCasa 2", "", "fim"}},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			conteudo := `<?xml version="1.0" encoding="UTF-8"?>` +
+				`<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+				`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" ` +
+				`xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"><office:body><office:spreadsheet>` +
+				`<table:table table:name="Outra"><table:table-row><table:table-cell><text:p>-</text:p></table:table-cell></table:table-row></table:table>` +
+				`<table:table table:name="Aba">` + c.linhas + `</table:table>` +
+				`</office:spreadsheet></office:body></office:document-content>`
+			rel, err := abrirODS(arquivoTemporario(t, "relatorio.ods", "", [2]string{"content.xml", conteudo}))
+			if err != nil {
+				t.Fatalf("abrirODS: %v", err)
+			}
+			defer rel.Close()
+
+			if obtido := lerAbas(t, rel)["Aba"]; !reflect.DeepEqual(obtido, c.esperado) {
+				t.Errorf("linhas = %q; esperado %q", obtido, c.esperado)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package importacao
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// arquivoTemporario grava um zip com as partes (nome e conteúdo), na ordem, ou
+// só o conteúdo se não houver partes, e retorna o caminho
+func arquivoTemporario(t *testing.T, nome string, conteudo string, partes ...[2]string) string {
+	t.Helper()
+	caminho := filepath.Join(t.TempDir(), nome)
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arquivo.Close()
+
+	if len(partes) == 0 {
+		if _, err := arquivo.WriteString(conteudo); err != nil {
+			t.Fatal(err)
+		}
+		return caminho
+	}
+	pacote := zip.NewWriter(arquivo)
+	for _, parte := range partes {
+		w, err := pacote.Create(parte[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(parte[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pacote.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return caminho
+}
+
+// lerAbas lê todas as linhas de cada aba do relatório
+func lerAbas(t *testing.T, rel Relatorio) map[string][][]string {
+	t.Helper()
+	abas := map[string][][]string{}
+	for _, planilha := range rel.Planilhas() {
+		linhas, err := rel.Linhas(planilha)
+		if err != nil {
+			t.Fatalf("Linhas(%q): %v", planilha, err)
+		}
+		celulas := [][]string{}
+		for linhas.Proxima() {
+			celulas = append(celulas, append([]string{}, linhas.Colunas()...))
+		}
+		if err := linhas.Err(); err != nil {
+			t.Fatalf("aba %q: %v", planilha, err)
+		}
+		linhas.Close()
+		abas[planilha] = celulas
+	}
+	return abas
+}
+
+// TestLeitoresMesmoConteudo confere que o mesmo relatório, salvo em cada formato
+// (testdata/relatorio.*), é lido com as mesmas abas, cabeçalhos e células. No
+// .xls um texto da SST continua em um CONTINUE; no .zip um CSV é UTF-8 com BOM e
+// ";" e o outro Latin-1 com ","; no .json uma aba é de objetos e a outra de listas.
+func TestLeitoresMesmoConteudo(t *testing.T) {
+	planilhas := []string{"Ocorrências", "Envolvidos"}
+	esperado := map[string][][]string{
+		"Ocorrências": {
+			{"Número do BO", "Data do Fato", "Valor", "Relato"},
+			{"123/2024", "15/03/2024", "1500.5", "Golpe do PIX, vítima transferiu"},
+			{"124/2024", "16/03/2024", "", "Relato\ncom duas linhas"},
+		},
+		"Envolvidos": {
+			{"Número do BO", "Nome Completo", "CPF", "Tipo"},
+			{"123/2024", "José da Silva", "529.982.247-25", "Vítima"},
+			{"124/2024", "Maria Souza", "", "Suposto Autor/infrator"},
+		},
+	}
+
+	for _, arquivo := range []string{"relatorio.xlsx", "relatorio.xls", "relatorio.ods", "relatorio.zip", "relatorio.json"} {
+		t.Run(arquivo, func(t *testing.T) {
+			rel, err := abrirFormato(filepath.Join("testdata", arquivo), arquivo)
+			if err != nil {
+				t.Fatalf("abrirFormato: %v", err)
+			}
+			defer rel.Close()
+
+			if obtido := rel.Planilhas(); !reflect.DeepEqual(obtido, planilhas) {
+				t.Errorf("Planilhas() = %q; esperado %q", obtido, planilhas)
+			}
+			if obtido := lerAbas(t, rel); !reflect.DeepEqual(obtido, esperado) {
+				t.Errorf("abas = %q; esperado %q", obtido, esperado)
+			}
+		})
+	}
+}
+
+func TestLocalizarPlanilha(t *testing.T) {
+	rel, err := abrirFormato(filepath.Join("testdata", "relatorio.zip"), "relatorio.zip")
+	if err != nil {
+		t.Fatalf("abrirFormato: %v", err)
+	}
+	defer rel.Close()
+
+	casos := []struct {
+		procurada string
+		esperada  string
+		ok        bool
+	}{
+		{"Ocorrências", "Ocorrências", true},
+		{"ocorrencias", "Ocorrências", true},
+		{"ENVOLVIDOS", "Envolvidos", true},
+		{"Dados Fato", "", false},
+	}
+	for _, c := range casos {
+		obtida, ok := localizarPlanilha(rel, c.procurada)
+		if obtida != c.esperada || ok != c.ok {
+			t.Errorf("localizarPlanilha(%q) = %q, %v; esperado %q, %v", c.procurada, obtida, ok, c.esperada, c.ok)
+		}
+	}
+}
+
+func TestNumeroExcel(t *testing.T) {
+	casos := []struct {
+		valor    float64
+		layout   string
+		data1904 bool
+		esperado string
+	}{
+		{45366, "02/01/2006", false, "15/03/2024"},
+		{43904, "02/01/2006", true, "15/03/2024"},
+		{45366.75, "02/01/2006 15:04", false, "15/03/2024 18:00"},
+		{0.5, "15:04", false, "12:00"},
+		{45366, "", false, "45366"},
+		{1500.5, "", false, "1500.5"},
+		{0.1 + 0.2, "", false, "0.3"},
+		{52998224725, "", false, "52998224725"},
+		{-1, "02/01/2006", false, "-1"},
+	}
+	for _, c := range casos {
+		if obtido := numeroExcel(c.valor, c.layout, c.data1904); obtido != c.esperado {
+			t.Errorf("numeroExcel(%v, %q, %v) = %q; esperado %q", c.valor, c.layout, c.data1904, obtido, c.esperado)
+		}
+	}
+}
+
+func TestLayoutDataExcel(t *testing.T) {
+	codigos := map[uint16]string{
+		164: "dd/mm/yyyy",
+		165: "h:mm",
+		166: "mm:ss",
+		167: `"dia" d "de" mmmm`,
+		168: `0.00" m"`,
+		169: "[Red]0.00;[h]",
+		170: "yyyy-mm-dd hh:mm:ss",
+		171: `#,##0.00\ "d"`,
+	}
+	casos := []struct {
+		id       uint16
+		esperado string
+	}{
+		{0, ""},
+		{2, ""},
+		{14, "02/01/2006"},
+		{20, "15:04"},
+		{21, "15:04:05"},
+		{22, "02/01/2006 15:04"},
+		{164, "02/01/2006"},
+		{165, "15:04"},
+		{166, "15:04:05"},
+		{167, "02/01/2006"},
+		{168, ""},
+		{169, ""},
+		{170, "02/01/2006 15:04"},
+		{171, ""},
+	}
+	for _, c := range casos {
+		if obtido := layoutDataExcel(c.id, codigos); obtido != c.esperado {
+			t.Errorf("layoutDataExcel(%d) = %q; esperado %q", c.id, obtido, c.esperado)
+		}
+	}
+}
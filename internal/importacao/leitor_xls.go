@@ -0,0 +1,653 @@
+package importacao
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// Registros do formato BIFF8 (Excel 97-2003) lidos pelo leitor de .xls
+const (
+	xlsBOF        = 0x0809
+	xlsEOF        = 0x000A
+	xlsFilePass   = 0x002F
+	xlsDateMode   = 0x0022
+	xlsFormat     = 0x041E
+	xlsXF         = 0x00E0
+	xlsSST        = 0x00FC
+	xlsContinue   = 0x003C
+	xlsBoundSheet = 0x0085
+	xlsLabelSST   = 0x00FD
+	xlsLabel      = 0x0204
+	xlsNumber     = 0x0203
+	xlsRK         = 0x027E
+	xlsMulRK      = 0x00BD
+	xlsFormula    = 0x0006
+	xlsString     = 0x0207
+	xlsBoolErr    = 0x0205
+
+	xlsVersaoBIFF8 = 0x0600
+)
+
+// errosXLS são os textos exibidos pelo Excel para os códigos de erro das células
+var errosXLS = map[byte]string{
+	0x00: "#NULL!",
+	0x07: "#DIV/0!",
+	0x0F: "#VALUE!",
+	0x17: "#REF!",
+	0x1D: "#NAME?",
+	0x24: "#NUM!",
+	0x2A: "#N/A",
+}
+
+// relatorioXLS lê as abas de uma pasta de trabalho do Excel 97-2003 (.xls): um
+// arquivo OLE2 cujo stream Workbook traz os registros BIFF8. Ao abrir são lidos
+// só os registros globais (nomes e posições das abas, formatos e a tabela de
+// textos compartilhados, que fica na memória); as células de cada aba são lidas
+// registro a registro do arquivo, que é aberto de novo a cada aba lida. Os
+// números são lidos como o texto exibido e as datas como DD/MM/AAAA, como no XLSX.
+type relatorioXLS struct {
+	caminho  string
+	abas     []abaXLS
+	textos   []string // SST
+	formatos []string // layout de data de cada XF ("" se a célula não é data)
+	data1904 bool
+}
+
+// abaXLS é uma aba da pasta de trabalho e a posição do seu BOF no stream Workbook
+type abaXLS struct {
+	nome    string
+	posicao int64
+}
+
+func abrirXLS(caminho string) (Relatorio, error) {
+	arquivo, workbook, err := abrirWorkbookXLS(caminho)
+	if err != nil {
+		return nil, err
+	}
+	defer arquivo.Close()
+
+	rel := &relatorioXLS{caminho: caminho}
+	if err := rel.lerGlobais(novoRegistrosXLS(workbook)); err != nil {
+		return nil, fmt.Errorf("%w: erro ao ler arquivo Excel 97-2003: %v", ErrArquivoInvalido, err)
+	}
+	return rel, nil
+}
+
+// abrirWorkbookXLS abre o arquivo OLE2 e localiza o stream Workbook na raiz
+func abrirWorkbookXLS(caminho string) (*os.File, *mscfb.File, error) {
+	arquivo, err := os.Open(caminho)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := mscfb.New(arquivo)
+	if err != nil {
+		arquivo.Close()
+		return nil, nil, fmt.Errorf("%w: erro ao ler arquivo Excel 97-2003: %v", ErrArquivoInvalido, err)
+	}
+	for entrada, err := doc.Next(); err == nil; entrada, err = doc.Next() {
+		if len(entrada.Path) > 0 {
+			continue
+		}
+		switch entrada.Name {
+		case "Workbook":
+			return arquivo, entrada, nil
+		case "Book":
+			arquivo.Close()
+			return nil, nil, fmt.Errorf("%w: arquivos do Excel 5.0/95 não são suportados; salve o relatório como .xlsx", ErrArquivoInvalido)
+		}
+	}
+	arquivo.Close()
+	return nil, nil, fmt.Errorf("%w: arquivo .xls sem pasta de trabalho do Excel", ErrArquivoInvalido)
+}
+
+// lerGlobais lê os registros do início do stream até o EOF da pasta de trabalho
+func (r *relatorioXLS) lerGlobais(regs *registrosXLS) error {
+	if !regs.proximo() || regs.tipo != xlsBOF || len(regs.dados) < 2 ||
+		binary.LittleEndian.Uint16(regs.dados) != xlsVersaoBIFF8 {
+		if err := regs.erro(); err != nil {
+			return err
+		}
+		return errors.New("o arquivo não é uma pasta de trabalho do Excel 97-2003")
+	}
+
+	codigosFormato := map[uint16]string{}
+	var formatosXF []uint16
+	for regs.proximo() {
+		dados := regs.dados
+		switch regs.tipo {
+		case xlsEOF:
+			r.formatos = make([]string, len(formatosXF))
+			for i, id := range formatosXF {
+				r.formatos[i] = layoutDataExcel(id, codigosFormato)
+			}
+			return nil
+		case xlsFilePass:
+			return errors.New("o arquivo está protegido por senha")
+		case xlsDateMode:
+			r.data1904 = len(dados) >= 2 && binary.LittleEndian.Uint16(dados) == 1
+		case xlsFormat:
+			if len(dados) < 2 {
+				return errors.New("registro FORMAT inválido")
+			}
+			codigo, err := novoTextoXLS(dados[2:]).lerTexto(2)
+			if err != nil {
+				return err
+			}
+			codigosFormato[binary.LittleEndian.Uint16(dados)] = codigo
+		case xlsXF:
+			if len(dados) < 4 {
+				return errors.New("registro XF inválido")
+			}
+			formatosXF = append(formatosXF, binary.LittleEndian.Uint16(dados[2:]))
+		case xlsSST:
+			if err := r.lerTextos(regs); err != nil {
+				return err
+			}
+		case xlsBoundSheet:
+			if len(dados) < 8 {
+				return errors.New("registro BOUNDSHEET inválido")
+			}
+			nome, err := novoTextoXLS(dados[6:]).lerTexto(1)
+			if err != nil {
+				return err
+			}
+			// Só as planilhas de dados; gráficos e macros ficam de fora
+			if dados[5] == 0 {
+				r.abas = append(r.abas, abaXLS{nome: nome, posicao: int64(binary.LittleEndian.Uint32(dados))})
+			}
+		}
+	}
+	if err := regs.erro(); err != nil {
+		return err
+	}
+	return errors.New("pasta de trabalho sem registro EOF")
+}
+
+// lerTextos lê a SST, cujos textos continuam nos registros CONTINUE seguintes
+func (r *relatorioXLS) lerTextos(regs *registrosXLS) error {
+	if len(regs.dados) < 8 {
+		return errors.New("registro SST inválido")
+	}
+	total := int(binary.LittleEndian.Uint32(regs.dados[4:]))
+	texto, err := regs.comContinuacoes(8)
+	if err != nil {
+		return err
+	}
+
+	r.textos = make([]string, 0, min(total, 1<<16))
+	for i := 0; i < total; i++ {
+		s, err := texto.lerTexto(2)
+		if err != nil {
+			return fmt.Errorf("SST: %v", err)
+		}
+		r.textos = append(r.textos, s)
+	}
+	return nil
+}
+
+func (r *relatorioXLS) Planilhas() []string {
+	nomes := make([]string, len(r.abas))
+	for i, aba := range r.abas {
+		nomes[i] = aba.nome
+	}
+	return nomes
+}
+
+func (r *relatorioXLS) Linhas(planilha string) (LinhasPlanilha, error) {
+	for _, aba := range r.abas {
+		if aba.nome != planilha {
+			continue
+		}
+		arquivo, workbook, err := abrirWorkbookXLS(r.caminho)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := workbook.Seek(aba.posicao, io.SeekStart); err != nil {
+			arquivo.Close()
+			return nil, fmt.Errorf("posição da planilha '%s' inválida: %v", planilha, err)
+		}
+		return &linhasXLS{rel: r, arquivo: arquivo, regs: novoRegistrosXLS(workbook)}, nil
+	}
+	return nil, fmt.Errorf("planilha '%s' não existe", planilha)
+}
+
+func (r *relatorioXLS) Close() error {
+	return nil
+}
+
+// numero formata o valor de uma célula numérica com o formato do XF dela
+func (r *relatorioXLS) numero(xf uint16, v float64) string {
+	var layout string
+	if int(xf) < len(r.formatos) {
+		layout = r.formatos[xf]
+	}
+	return numeroExcel(v, layout, r.data1904)
+}
+
+// linhasXLS percorre os registros de células de uma aba, que o Excel grava por
+// linha, em ordem. Uma linha só é entregue quando aparece uma célula de outra
+// linha ou o fim da aba; linhas vazias no meio da aba vêm vazias e as do fim são
+// descartadas, como no XLSX.
+type linhasXLS struct {
+	rel     *relatorioXLS
+	arquivo *os.File
+	regs    *registrosXLS
+
+	profundidade int      // BOFs abertos (gráficos embutidos têm BOF e EOF próprios)
+	celulas      []string // células da linha em leitura
+	linhaCelulas int
+	formula      []int    // linha e coluna da fórmula cujo texto vem no registro STRING
+	pronta       []string // próxima linha preenchida
+	linhaPronta  int
+	proximaLinha int // número (base 0) da próxima linha a entregar
+	atual        []string
+	fim          bool
+	err          error
+}
+
+func (l *linhasXLS) Proxima() bool {
+	for l.pronta == nil {
+		if l.fim {
+			return false
+		}
+		l.lerRegistro()
+	}
+
+	if l.proximaLinha < l.linhaPronta {
+		l.proximaLinha++
+		l.atual = []string{}
+		return true
+	}
+	l.proximaLinha++
+	l.atual, l.pronta = l.pronta, nil
+	return true
+}
+
+// lerRegistro lê o próximo registro da aba e guarda a célula que ele trouxer
+func (l *linhasXLS) lerRegistro() {
+	if !l.regs.proximo() {
+		l.falhar(l.regs.erro())
+		if l.err == nil {
+			l.falhar(errors.New("aba terminou sem registro EOF"))
+		}
+		return
+	}
+
+	dados := l.regs.dados
+	switch l.regs.tipo {
+	case xlsBOF:
+		l.profundidade++
+		return
+	case xlsEOF:
+		l.profundidade--
+		if l.profundidade <= 0 {
+			l.fim = true
+			if l.celulas != nil {
+				l.pronta, l.linhaPronta = l.celulas, l.linhaCelulas
+			}
+		}
+		return
+	}
+	if l.profundidade != 1 {
+		return
+	}
+	if l.regs.tipo == xlsString {
+		l.textoFormula()
+		return
+	}
+
+	linha, coluna, xf, ok := posicaoCelulaXLS(l.regs.tipo, dados)
+	if !ok {
+		return
+	}
+	switch l.regs.tipo {
+	case xlsLabelSST:
+		if len(dados) < 10 {
+			l.falhar(errors.New("registro LABELSST inválido"))
+			return
+		}
+		indice := int(binary.LittleEndian.Uint32(dados[6:]))
+		if indice >= len(l.rel.textos) {
+			l.falhar(fmt.Errorf("texto %d fora da SST", indice))
+			return
+		}
+		l.celula(linha, coluna, l.rel.textos[indice])
+	case xlsLabel:
+		valor, err := novoTextoXLS(dados[6:]).lerTexto(2)
+		if err != nil {
+			l.falhar(err)
+			return
+		}
+		l.celula(linha, coluna, valor)
+	case xlsNumber:
+		if len(dados) < 14 {
+			l.falhar(errors.New("registro NUMBER inválido"))
+			return
+		}
+		l.celula(linha, coluna, l.rel.numero(xf, math.Float64frombits(binary.LittleEndian.Uint64(dados[6:]))))
+	case xlsRK:
+		if len(dados) < 10 {
+			l.falhar(errors.New("registro RK inválido"))
+			return
+		}
+		l.celula(linha, coluna, l.rel.numero(xf, numeroRK(binary.LittleEndian.Uint32(dados[6:]))))
+	case xlsMulRK:
+		// Várias células RK seguidas na mesma linha: xf e valor de cada uma
+		for i := 4; i+6 <= len(dados)-2; i += 6 {
+			xf := binary.LittleEndian.Uint16(dados[i:])
+			l.celula(linha, coluna, l.rel.numero(xf, numeroRK(binary.LittleEndian.Uint32(dados[i+2:]))))
+			coluna++
+		}
+	case xlsBoolErr:
+		if len(dados) < 8 {
+			l.falhar(errors.New("registro BOOLERR inválido"))
+			return
+		}
+		l.celula(linha, coluna, valorBoolErrXLS(dados[6], dados[7] != 0))
+	case xlsFormula:
+		if len(dados) < 14 {
+			l.falhar(errors.New("registro FORMULA inválido"))
+			return
+		}
+		resultado := dados[6:14]
+		if resultado[6] != 0xFF || resultado[7] != 0xFF {
+			l.celula(linha, coluna, l.rel.numero(xf, math.Float64frombits(binary.LittleEndian.Uint64(resultado))))
+			return
+		}
+		switch resultado[0] {
+		case 0: // texto, no registro STRING a seguir
+			l.formula = []int{linha, coluna}
+		case 1:
+			l.celula(linha, coluna, valorBoolErrXLS(resultado[2], false))
+		case 2:
+			l.celula(linha, coluna, valorBoolErrXLS(resultado[2], true))
+		}
+	}
+}
+
+// textoFormula guarda o resultado em texto da fórmula anterior, que vem no
+// registro STRING
+func (l *linhasXLS) textoFormula() {
+	if l.formula == nil {
+		return
+	}
+	texto, err := l.regs.comContinuacoes(0)
+	if err != nil {
+		l.falhar(err)
+		return
+	}
+	valor, err := texto.lerTexto(2)
+	if err != nil {
+		l.falhar(err)
+		return
+	}
+	l.celula(l.formula[0], l.formula[1], valor)
+	l.formula = nil
+}
+
+// celula guarda o valor na linha em leitura; uma célula de outra linha completa
+// a linha anterior
+func (l *linhasXLS) celula(linha, coluna int, valor string) {
+	if valor == "" {
+		return
+	}
+	if l.celulas != nil && linha != l.linhaCelulas {
+		if linha < l.linhaCelulas {
+			l.falhar(fmt.Errorf("células da linha %d fora de ordem", linha+1))
+			return
+		}
+		l.pronta, l.linhaPronta = l.celulas, l.linhaCelulas
+		l.celulas = nil
+	}
+	if l.celulas == nil && linha < l.proximaLinha {
+		l.falhar(fmt.Errorf("células da linha %d fora de ordem", linha+1))
+		return
+	}
+
+	l.linhaCelulas = linha
+	for len(l.celulas) <= coluna {
+		l.celulas = append(l.celulas, "")
+	}
+	l.celulas[coluna] = valor
+}
+
+func (l *linhasXLS) falhar(err error) {
+	if err == nil {
+		return
+	}
+	l.err, l.fim = err, true
+	l.pronta, l.celulas = nil, nil
+}
+
+func (l *linhasXLS) Colunas() []string {
+	return l.atual
+}
+
+func (l *linhasXLS) Err() error {
+	return l.err
+}
+
+func (l *linhasXLS) Close() error {
+	return l.arquivo.Close()
+}
+
+// posicaoCelulaXLS lê linha, coluna e XF do início de um registro de célula
+func posicaoCelulaXLS(tipo uint16, dados []byte) (linha, coluna int, xf uint16, ok bool) {
+	switch tipo {
+	case xlsLabelSST, xlsLabel, xlsNumber, xlsRK, xlsBoolErr, xlsFormula:
+		if len(dados) < 6 {
+			return 0, 0, 0, false
+		}
+		xf = binary.LittleEndian.Uint16(dados[4:])
+	case xlsMulRK:
+		if len(dados) < 6 {
+			return 0, 0, 0, false
+		}
+	default:
+		return 0, 0, 0, false
+	}
+	return int(binary.LittleEndian.Uint16(dados)), int(binary.LittleEndian.Uint16(dados[2:])), xf, true
+}
+
+// numeroRK decodifica o número compactado dos registros RK e MULRK
+func numeroRK(rk uint32) float64 {
+	var v float64
+	if rk&0x02 != 0 {
+		v = float64(int32(rk) >> 2)
+	} else {
+		v = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		v /= 100
+	}
+	return v
+}
+
+func valorBoolErrXLS(valor byte, erro bool) string {
+	if erro {
+		return errosXLS[valor]
+	}
+	if valor != 0 {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// registrosXLS lê os registros BIFF (tipo, tamanho e dados) do stream Workbook
+type registrosXLS struct {
+	leitor   *bufio.Reader
+	tipo     uint16
+	dados    []byte
+	buffer   []byte
+	devolver bool // o registro atual foi devolvido e será lido de novo
+	err      error
+}
+
+func novoRegistrosXLS(workbook io.Reader) *registrosXLS {
+	return &registrosXLS{leitor: bufio.NewReader(workbook), buffer: make([]byte, 8224)}
+}
+
+// proximo lê o próximo registro; os dados valem até a próxima leitura
+func (r *registrosXLS) proximo() bool {
+	if r.devolver {
+		r.devolver = false
+		return true
+	}
+	if r.err != nil {
+		return false
+	}
+
+	var cabecalho [4]byte
+	if _, err := io.ReadFull(r.leitor, cabecalho[:]); err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		return false
+	}
+	tamanho := int(binary.LittleEndian.Uint16(cabecalho[2:]))
+	if tamanho > len(r.buffer) {
+		r.buffer = make([]byte, tamanho)
+	}
+	r.tipo, r.dados = binary.LittleEndian.Uint16(cabecalho[:]), r.buffer[:tamanho]
+	if _, err := io.ReadFull(r.leitor, r.dados); err != nil {
+		r.err = fmt.Errorf("registro 0x%04X incompleto: %v", r.tipo, err)
+		return false
+	}
+	return true
+}
+
+// comContinuacoes junta os dados do registro atual, a partir de inicio, aos dos
+// registros CONTINUE que o seguem
+func (r *registrosXLS) comContinuacoes(inicio int) (*textoXLS, error) {
+	texto := &textoXLS{segmentos: [][]byte{append([]byte(nil), r.dados[inicio:]...)}}
+	for r.proximo() {
+		if r.tipo != xlsContinue {
+			r.devolver = true
+			return texto, nil
+		}
+		texto.segmentos = append(texto.segmentos, append([]byte(nil), r.dados...))
+	}
+	return texto, r.err
+}
+
+func (r *registrosXLS) erro() error {
+	return r.err
+}
+
+// textoXLS lê textos Unicode do BIFF8 de um registro e das suas continuações.
+// Quando os caracteres de um texto passam para o CONTINUE seguinte, ele começa
+// com um byte de opções que diz se o resto está em 8 ou 16 bits.
+type textoXLS struct {
+	segmentos [][]byte
+	segmento  int
+	pos       int
+}
+
+var errTextoXLS = errors.New("texto truncado no arquivo .xls")
+
+func novoTextoXLS(dados []byte) *textoXLS {
+	return &textoXLS{segmentos: [][]byte{dados}}
+}
+
+// ler retorna os próximos n bytes, que podem atravessar continuações
+func (t *textoXLS) ler(n int) ([]byte, error) {
+	var lidos []byte
+	for n > 0 {
+		if t.segmento >= len(t.segmentos) {
+			return nil, errTextoXLS
+		}
+		atual := t.segmentos[t.segmento]
+		if t.pos >= len(atual) {
+			t.segmento, t.pos = t.segmento+1, 0
+			continue
+		}
+		parte := min(n, len(atual)-t.pos)
+		lidos = append(lidos, atual[t.pos:t.pos+parte]...)
+		t.pos += parte
+		n -= parte
+	}
+	return lidos, nil
+}
+
+func (t *textoXLS) inteiro(tamanho int) (int, error) {
+	b, err := t.ler(tamanho)
+	if err != nil {
+		return 0, err
+	}
+	switch tamanho {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.LittleEndian.Uint16(b)), nil
+	}
+	return int(binary.LittleEndian.Uint32(b)), nil
+}
+
+// lerTexto lê um texto com o número de caracteres em tamanhoContagem bytes,
+// seguido do byte de opções, e descarta a formatação rica e os dados estendidos
+func (t *textoXLS) lerTexto(tamanhoContagem int) (string, error) {
+	caracteres, err := t.inteiro(tamanhoContagem)
+	if err != nil {
+		return "", err
+	}
+	opcoes, err := t.inteiro(1)
+	if err != nil {
+		return "", err
+	}
+	var extras int
+	if opcoes&0x08 != 0 {
+		trechos, err := t.inteiro(2)
+		if err != nil {
+			return "", err
+		}
+		extras += 4 * trechos
+	}
+	if opcoes&0x04 != 0 {
+		estendido, err := t.inteiro(4)
+		if err != nil {
+			return "", err
+		}
+		extras += estendido
+	}
+
+	largo := opcoes&0x01 != 0
+	unidades := make([]uint16, 0, min(caracteres, 8224))
+	for len(unidades) < caracteres {
+		if t.segmento >= len(t.segmentos) {
+			return "", errTextoXLS
+		}
+		atual := t.segmentos[t.segmento]
+		if t.pos >= len(atual) {
+			t.segmento, t.pos = t.segmento+1, 0
+			opcoes, err := t.inteiro(1)
+			if err != nil {
+				return "", err
+			}
+			largo = opcoes&0x01 != 0
+			continue
+		}
+		if !largo {
+			unidades = append(unidades, uint16(atual[t.pos]))
+			t.pos++
+			continue
+		}
+		if t.pos+2 > len(atual) {
+			return "", errTextoXLS
+		}
+		unidades = append(unidades, binary.LittleEndian.Uint16(atual[t.pos:]))
+		t.pos += 2
+	}
+
+	if _, err := t.ler(extras); err != nil {
+		return "", err
+	}
+	return string(utf16.Decode(unidades)), nil
+}
@@ -0,0 +1,128 @@
+package importacao
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// registroXLS monta um registro BIFF: tipo, tamanho e dados
+func registroXLS(tipo uint16, dados ...[]byte) []byte {
+	corpo := bytes.Join(dados, nil)
+	registro := binary.LittleEndian.AppendUint16(nil, tipo)
+	registro = binary.LittleEndian.AppendUint16(registro, uint16(len(corpo)))
+	return append(registro, corpo...)
+}
+
+// textoCompactoXLS é um texto da SST em 8 bits (Latin-1): contagem, opções e caracteres
+func textoCompactoXLS(texto string) []byte {
+	caracteres := []rune(texto)
+	b := binary.LittleEndian.AppendUint16(nil, uint16(len(caracteres)))
+	b = append(b, 0)
+	for _, c := range caracteres {
+		b = append(b, byte(c))
+	}
+	return b
+}
+
+func utf16XLS(texto string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(texto)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+func TestLerTextosXLS(t *testing.T) {
+	casos := []struct {
+		nome      string
+		registros [][]byte
+		esperado  []string
+	}{
+		{
+			nome: "sem continuação",
+			registros: [][]byte{registroXLS(xlsSST, []byte{2, 0, 0, 0, 2, 0, 0, 0},
+				textoCompactoXLS("BO"), []byte{3, 0, 1}, utf16XLS("Séc"))},
+			esperado: []string{"BO", "Séc"},
+		},
+		{
+			// O CONTINUE começa com o byte de opções: o resto do texto vem em 16 bits
+			nome: "texto dividido entre 8 e 16 bits",
+			registros: [][]byte{
+				registroXLS(xlsSST, []byte{2, 0, 0, 0, 2, 0, 0, 0}, []byte{11, 0, 0}, []byte("Ocorr")),
+				registroXLS(xlsContinue, []byte{1}, utf16XLS("ências"), textoCompactoXLS("Fim")),
+			},
+			esperado: []string{"Ocorrências", "Fim"},
+		},
+		{
+			// Um texto novo no início do CONTINUE não tem o byte de opções extra
+			nome: "texto novo no início da continuação",
+			registros: [][]byte{
+				registroXLS(xlsSST, []byte{2, 0, 0, 0, 2, 0, 0, 0}, textoCompactoXLS("Vítima")),
+				registroXLS(xlsContinue, textoCompactoXLS("Autor")),
+			},
+			esperado: []string{"Vítima", "Autor"},
+		},
+		{
+			// Formatação rica (2 trechos) e dados estendidos são descartados, mesmo na continuação
+			nome: "formatação rica e dados estendidos",
+			registros: [][]byte{
+				registroXLS(xlsSST, []byte{2, 0, 0, 0, 2, 0, 0, 0},
+					[]byte{3, 0, 0x0C, 2, 0, 3, 0, 0, 0}, []byte("PIX"), make([]byte, 4)),
+				registroXLS(xlsContinue, make([]byte, 4+3), textoCompactoXLS("CPF")),
+			},
+			esperado: []string{"PIX", "CPF"},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			stream := append(bytes.Join(c.registros, nil), registroXLS(xlsEOF)...)
+			regs := novoRegistrosXLS(bytes.NewReader(stream))
+			if !regs.proximo() || regs.tipo != xlsSST {
+				t.Fatalf("primeiro registro não é a SST")
+			}
+
+			rel := &relatorioXLS{}
+			if err := rel.lerTextos(regs); err != nil {
+				t.Fatalf("lerTextos: %v", err)
+			}
+			if !reflect.DeepEqual(rel.textos, c.esperado) {
+				t.Errorf("textos = %q; esperado %q", rel.textos, c.esperado)
+			}
+			// O registro seguinte às continuações continua disponível
+			if !regs.proximo() || regs.tipo != xlsEOF {
+				t.Errorf("registro após a SST = 0x%04X; esperado EOF", regs.tipo)
+			}
+		})
+	}
+}
+
+func TestLerTextosXLSTruncado(t *testing.T) {
+	stream := registroXLS(xlsSST, []byte{1, 0, 0, 0, 1, 0, 0, 0}, []byte{10, 0, 0}, []byte("Curto"))
+	regs := novoRegistrosXLS(bytes.NewReader(stream))
+	regs.proximo()
+	if err := (&relatorioXLS{}).lerTextos(regs); err == nil {
+		t.Error("lerTextos com texto truncado não retornou erro")
+	}
+}
+
+func TestNumeroRK(t *testing.T) {
+	casos := []struct {
+		rk       uint32
+		esperado float64
+	}{
+		{0x40977200, 1500.5},     // IEEE com os 32 bits de baixo zerados
+		{150<<2 | 0x02, 150},     // inteiro
+		{15050<<2 | 0x03, 150.5}, // inteiro dividido por 100
+		{0xFFFFFFFE, -1},         // inteiro negativo
+		{0x3FF00001, 0.01},       // IEEE dividido por 100
+	}
+	for _, c := range casos {
+		if obtido := numeroRK(c.rk); obtido != c.esperado {
+			t.Errorf("numeroRK(0x%08X) = %v; esperado %v", c.rk, obtido, c.esperado)
+		}
+	}
+}
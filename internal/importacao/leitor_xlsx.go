@@ -0,0 +1,586 @@
+package importacao
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// limiteTextosMemoriaXLSX é o tamanho do sharedStrings.xml descompactado a partir
+// do qual os textos compartilhados vão para um arquivo temporário, de onde são
+// lidos pela posição, em vez de ficarem na memória
+const limiteTextosMemoriaXLSX = 1 << 20
+
+// Sufixos dos tipos das relações do pacote, iguais no OOXML transicional e no estrito
+const (
+	relacaoDocumento = "/officeDocument"
+	relacaoPlanilha  = "/worksheet"
+	relacaoEstilos   = "/styles"
+	relacaoTextos    = "/sharedStrings"
+)
+
+// relatorioXLSX lê as abas de uma pasta de trabalho do Excel direto do zip em
+// disco, sem descompactá-lo nem carregá-lo na memória. Ao abrir são lidos o
+// workbook.xml, os estilos e os textos compartilhados; as abas são percorridas
+// linha a linha do XML compactado. Os números são lidos como o texto exibido e as
+// datas como DD/MM/AAAA, como no XLS.
+type relatorioXLSX struct {
+	pacote   *zip.ReadCloser
+	abas     []abaXLSX
+	textos   *textosXLSX
+	formatos []string // layout de data de cada estilo de célula ("" se não é data)
+	data1904 bool
+}
+
+type abaXLSX struct {
+	nome  string
+	parte *zip.File
+}
+
+// relacaoXLSX é uma relação de uma parte do pacote, com o alvo já resolvido para
+// o caminho no zip
+type relacaoXLSX struct {
+	id, tipo, alvo string
+}
+
+func abrirXLSX(caminho string) (Relatorio, error) {
+	pacote, err := zip.OpenReader(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("%w: erro ao ler arquivo Excel: %v", ErrArquivoInvalido, err)
+	}
+
+	rel := &relatorioXLSX{pacote: pacote, textos: &textosXLSX{}}
+	if err := rel.lerPastaDeTrabalho(); err != nil {
+		rel.Close()
+		return nil, fmt.Errorf("%w: erro ao ler arquivo Excel: %v", ErrArquivoInvalido, err)
+	}
+	return rel, nil
+}
+
+// lerPastaDeTrabalho localiza o workbook.xml pelas relações do pacote e lê as
+// abas, os estilos e os textos compartilhados
+func (r *relatorioXLSX) lerPastaDeTrabalho() error {
+	caminhoWorkbook := "xl/workbook.xml"
+	relacoes, err := r.lerRelacoes("")
+	if err != nil {
+		return err
+	}
+	for _, relacao := range relacoes {
+		if strings.HasSuffix(relacao.tipo, relacaoDocumento) {
+			caminhoWorkbook = relacao.alvo
+			break
+		}
+	}
+
+	var workbook struct {
+		Propriedades struct {
+			Data1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Abas []struct {
+			Nome string `xml:"name,attr"`
+			ID   string `xml:"id,attr"` // r:id
+		} `xml:"sheets>sheet"`
+	}
+	if err := r.decodificar(caminhoWorkbook, &workbook); err != nil {
+		return err
+	}
+	r.data1904 = workbook.Propriedades.Data1904 == "1" || workbook.Propriedades.Data1904 == "true"
+
+	relacoes, err = r.lerRelacoes(caminhoWorkbook)
+	if err != nil {
+		return err
+	}
+	alvos := map[string]relacaoXLSX{}
+	for _, relacao := range relacoes {
+		alvos[relacao.id] = relacao
+
+		switch {
+		case strings.HasSuffix(relacao.tipo, relacaoEstilos):
+			if err := r.lerEstilos(relacao.alvo); err != nil {
+				return err
+			}
+		case strings.HasSuffix(relacao.tipo, relacaoTextos):
+			if err := r.lerTextos(relacao.alvo); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Só as planilhas de dados; abas de gráfico ficam de fora
+	for _, aba := range workbook.Abas {
+		relacao, ok := alvos[aba.ID]
+		if !ok || !strings.HasSuffix(relacao.tipo, relacaoPlanilha) {
+			continue
+		}
+		parte := r.parte(relacao.alvo)
+		if parte == nil {
+			return fmt.Errorf("a aba '%s' não está no arquivo", aba.Nome)
+		}
+		r.abas = append(r.abas, abaXLSX{nome: aba.Nome, parte: parte})
+	}
+	return nil
+}
+
+// lerRelacoes lê o _rels da parte ("" para as relações do pacote); sem o _rels,
+// não há relações
+func (r *relatorioXLSX) lerRelacoes(parte string) ([]relacaoXLSX, error) {
+	caminho := "_rels/.rels"
+	if parte != "" {
+		caminho = path.Join(path.Dir(parte), "_rels", path.Base(parte)+".rels")
+	}
+	if r.parte(caminho) == nil {
+		return nil, nil
+	}
+
+	var rels struct {
+		Relacoes []struct {
+			ID   string `xml:"Id,attr"`
+			Tipo string `xml:"Type,attr"`
+			Alvo string `xml:"Target,attr"`
+			Modo string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := r.decodificar(caminho, &rels); err != nil {
+		return nil, err
+	}
+
+	relacoes := make([]relacaoXLSX, 0, len(rels.Relacoes))
+	for _, rel := range rels.Relacoes {
+		if rel.Modo == "External" {
+			continue
+		}
+		alvo := strings.TrimPrefix(rel.Alvo, "/")
+		if !strings.HasPrefix(rel.Alvo, "/") {
+			alvo = path.Join(path.Dir(parte), rel.Alvo)
+		}
+		relacoes = append(relacoes, relacaoXLSX{id: rel.ID, tipo: rel.Tipo, alvo: alvo})
+	}
+	return relacoes, nil
+}
+
+// lerEstilos guarda o layout de data do formato de número de cada estilo de célula
+func (r *relatorioXLSX) lerEstilos(caminho string) error {
+	var estilos struct {
+		Formatos []struct {
+			ID     uint16 `xml:"numFmtId,attr"`
+			Codigo string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		Celulas []struct {
+			Formato uint16 `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := r.decodificar(caminho, &estilos); err != nil {
+		return err
+	}
+
+	codigos := map[uint16]string{}
+	for _, formato := range estilos.Formatos {
+		codigos[formato.ID] = formato.Codigo
+	}
+	r.formatos = make([]string, len(estilos.Celulas))
+	for i, celula := range estilos.Celulas {
+		r.formatos[i] = layoutDataExcel(celula.Formato, codigos)
+	}
+	return nil
+}
+
+// lerTextos percorre o sharedStrings.xml; o texto de cada si junta os t dele,
+// menos os da leitura fonética (rPh)
+func (r *relatorioXLSX) lerTextos(caminho string) error {
+	parte := r.parte(caminho)
+	if parte == nil {
+		return nil
+	}
+	conteudo, err := parte.Open()
+	if err != nil {
+		return err
+	}
+	defer conteudo.Close()
+
+	if parte.UncompressedSize64 > limiteTextosMemoriaXLSX {
+		if err := r.textos.usarArquivo(); err != nil {
+			return err
+		}
+	}
+
+	var (
+		decoder  = xml.NewDecoder(conteudo)
+		texto    strings.Builder
+		noTexto  bool
+		fonetica int
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return r.textos.concluir()
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				texto.Reset()
+			case "rPh":
+				fonetica++
+			case "t":
+				noTexto = fonetica == 0
+			}
+		case xml.CharData:
+			if noTexto {
+				texto.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "rPh":
+				fonetica--
+			case "t":
+				noTexto = false
+			case "si":
+				if err := r.textos.adicionar(texto.String()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// parte retorna o arquivo do zip no caminho, sem diferenciar maiúsculas se não
+// houver o nome exato
+func (r *relatorioXLSX) parte(caminho string) *zip.File {
+	for _, f := range r.pacote.File {
+		if f.Name == caminho {
+			return f
+		}
+	}
+	for _, f := range r.pacote.File {
+		if strings.EqualFold(f.Name, caminho) {
+			return f
+		}
+	}
+	return nil
+}
+
+// decodificar lê uma parte pequena do pacote (workbook, relações, estilos) inteira
+func (r *relatorioXLSX) decodificar(caminho string, v any) error {
+	parte := r.parte(caminho)
+	if parte == nil {
+		return fmt.Errorf("parte %s não encontrada", caminho)
+	}
+	conteudo, err := parte.Open()
+	if err != nil {
+		return err
+	}
+	defer conteudo.Close()
+
+	if err := xml.NewDecoder(conteudo).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", caminho, err)
+	}
+	return nil
+}
+
+func (r *relatorioXLSX) Planilhas() []string {
+	nomes := make([]string, len(r.abas))
+	for i, aba := range r.abas {
+		nomes[i] = aba.nome
+	}
+	return nomes
+}
+
+func (r *relatorioXLSX) Linhas(planilha string) (LinhasPlanilha, error) {
+	for _, aba := range r.abas {
+		if aba.nome != planilha {
+			continue
+		}
+		conteudo, err := aba.parte.Open()
+		if err != nil {
+			return nil, err
+		}
+		return &linhasXLSX{rel: r, conteudo: conteudo, decoder: xml.NewDecoder(conteudo), proximaLinha: 1}, nil
+	}
+	return nil, fmt.Errorf("planilha '%s' não existe", planilha)
+}
+
+func (r *relatorioXLSX) Close() error {
+	r.textos.Close()
+	return r.pacote.Close()
+}
+
+// valorCelula converte o conteúdo de uma célula c, conforme o tipo (t) e o
+// estilo (s), no texto exibido
+func (r *relatorioXLSX) valorCelula(tipo, estilo, valor string) (string, error) {
+	var layout string
+	if i, err := strconv.Atoi(estilo); err == nil && i >= 0 && i < len(r.formatos) {
+		layout = r.formatos[i]
+	} else if estilo == "" && len(r.formatos) > 0 {
+		layout = r.formatos[0]
+	}
+
+	switch tipo {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(valor))
+		if err != nil {
+			return "", fmt.Errorf("índice de texto compartilhado inválido: %q", valor)
+		}
+		return r.textos.texto(i)
+	case "str", "inlineStr", "e":
+		return valor, nil
+	case "b":
+		switch valor {
+		case "1":
+			return "TRUE", nil
+		case "0":
+			return "FALSE", nil
+		}
+		return valor, nil
+	case "d":
+		if layout == "" {
+			layout = "02/01/2006"
+		}
+		for _, formato := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+			if instante, err := time.Parse(formato, valor); err == nil {
+				return instante.Format(layout), nil
+			}
+		}
+		return valor, nil
+	}
+
+	if valor == "" {
+		return "", nil
+	}
+	v, err := strconv.ParseFloat(valor, 64)
+	if err != nil {
+		return valor, nil
+	}
+	return numeroExcel(v, layout, r.data1904), nil
+}
+
+// linhasXLSX percorre as row do sheetData de uma aba. Linhas vazias no meio da
+// aba vêm vazias e as do fim são descartadas.
+type linhasXLSX struct {
+	rel      *relatorioXLSX
+	conteudo io.ReadCloser
+	decoder  *xml.Decoder
+
+	ultimaLinha   int      // número da última row lida
+	pendente      []string // próxima linha preenchida
+	linhaPendente int
+	proximaLinha  int // número (base 1) da próxima linha a entregar
+	atual         []string
+	fim           bool
+	err           error
+}
+
+func (l *linhasXLSX) Proxima() bool {
+	for l.pendente == nil {
+		if l.fim {
+			return false
+		}
+		numero, linha, ok := l.lerLinha()
+		if !ok {
+			l.fim = true
+			return false
+		}
+		if len(linha) > 0 {
+			l.pendente, l.linhaPendente = linha, numero
+		}
+	}
+
+	if l.proximaLinha < l.linhaPendente {
+		l.proximaLinha++
+		l.atual = []string{}
+		return true
+	}
+	l.proximaLinha++
+	l.atual, l.pendente = l.pendente, nil
+	return true
+}
+
+// lerLinha lê a próxima row com o número dela (o atributo r ou a seguinte à
+// anterior); false no fim do sheetData ou em erro
+func (l *linhasXLSX) lerLinha() (int, []string, bool) {
+	var (
+		numero = l.ultimaLinha + 1
+		linha  []string
+		coluna int // índice (base 0) da célula, se ela não trouxer a referência
+
+		tipo, estilo string
+		valor        strings.Builder
+		noValor      bool // dentro de v
+		noInline     bool // dentro de is
+		noTexto      bool // dentro de um t de is
+		fonetica     int
+	)
+
+	for {
+		token, err := l.decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("a planilha terminou antes do fim de sheetData")
+			}
+			l.err = err
+			return 0, nil, false
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				if n, err := strconv.Atoi(atributoXML(t, "r")); err == nil && n > 0 {
+					numero = n
+				}
+			case "c":
+				tipo, estilo = atributoXML(t, "t"), atributoXML(t, "s")
+				if c, ok := colunaXLSX(atributoXML(t, "r")); ok {
+					coluna = c
+				}
+				valor.Reset()
+			case "v":
+				noValor = true
+			case "is":
+				noInline = true
+			case "rPh":
+				fonetica++
+			case "t":
+				noTexto = noInline && fonetica == 0
+			}
+
+		case xml.CharData:
+			if noValor || noTexto {
+				valor.Write(t)
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v":
+				noValor = false
+			case "is":
+				noInline = false
+			case "rPh":
+				fonetica--
+			case "t":
+				noTexto = false
+			case "c":
+				texto, err := l.rel.valorCelula(tipo, estilo, valor.String())
+				if err != nil {
+					l.err = err
+					return 0, nil, false
+				}
+				if texto != "" {
+					for len(linha) <= coluna {
+						linha = append(linha, "")
+					}
+					linha[coluna] = texto
+				}
+				coluna++
+			case "row":
+				l.ultimaLinha = numero
+				return numero, linha, true
+			case "sheetData":
+				return 0, nil, false
+			}
+		}
+	}
+}
+
+func (l *linhasXLSX) Colunas() []string {
+	return l.atual
+}
+
+func (l *linhasXLSX) Err() error {
+	return l.err
+}
+
+func (l *linhasXLSX) Close() error {
+	return l.conteudo.Close()
+}
+
+// colunaXLSX retorna o índice (base 0) da coluna de uma referência como "AB12"
+func colunaXLSX(referencia string) (int, bool) {
+	coluna, letras := 0, 0
+	for _, c := range strings.ToUpper(referencia) {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		coluna = coluna*26 + int(c-'A'+1)
+		letras++
+	}
+	if letras == 0 || letras > 3 {
+		return 0, false
+	}
+	return coluna - 1, true
+}
+
+// textosXLSX guarda os textos compartilhados na memória ou, nos arquivos grandes,
+// em um arquivo temporário, com só a posição de cada texto na memória
+type textosXLSX struct {
+	memoria  []string
+	arquivo  *os.File
+	escrita  *bufio.Writer
+	posicoes []int64 // início de cada texto no arquivo e, por último, o fim
+}
+
+func (t *textosXLSX) usarArquivo() error {
+	arquivo, err := os.CreateTemp("", "fraudbase-textos-*")
+	if err != nil {
+		return err
+	}
+	// Apagado ainda aberto: o espaço é liberado ao fechar, mesmo se o processo cair
+	os.Remove(arquivo.Name())
+	t.arquivo, t.escrita, t.posicoes = arquivo, bufio.NewWriter(arquivo), []int64{0}
+	return nil
+}
+
+func (t *textosXLSX) adicionar(texto string) error {
+	if t.arquivo == nil {
+		t.memoria = append(t.memoria, texto)
+		return nil
+	}
+	if _, err := t.escrita.WriteString(texto); err != nil {
+		return err
+	}
+	t.posicoes = append(t.posicoes, t.posicoes[len(t.posicoes)-1]+int64(len(texto)))
+	return nil
+}
+
+func (t *textosXLSX) concluir() error {
+	if t.escrita == nil {
+		return nil
+	}
+	return t.escrita.Flush()
+}
+
+func (t *textosXLSX) texto(i int) (string, error) {
+	if t.arquivo == nil {
+		if i < 0 || i >= len(t.memoria) {
+			return "", fmt.Errorf("texto compartilhado %d não existe", i)
+		}
+		return t.memoria[i], nil
+	}
+
+	if i < 0 || i+1 >= len(t.posicoes) {
+		return "", fmt.Errorf("texto compartilhado %d não existe", i)
+	}
+	b := make([]byte, t.posicoes[i+1]-t.posicoes[i])
+	if _, err := t.arquivo.ReadAt(b, t.posicoes[i]); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (t *textosXLSX) Close() error {
+	if t.arquivo == nil {
+		return nil
+	}
+	return t.arquivo.Close()
+}
@@ -0,0 +1,201 @@
+package importacao
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const estilosTesteXLSX = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="dd/mm/yyyy hh:mm"/></numFmts>` +
+	`<cellXfs count="4"><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="4"/></cellXfs></styleSheet>`
+
+// textosTesteXLSX: texto simples, texto rico em trechos, texto com leitura
+// fonética (ignorada) e texto com espaços nas pontas
+const textosTesteXLSX = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="4" uniqueCount="4">` +
+	`<si><t>Nome</t></si>` +
+	`<si><r><rPr><b/></rPr><t>Jo</t></r><r><t>sé</t></r></si>` +
+	`<si><t>東京</t><rPh sb="0" eb="2"><t>トウキョウ</t></rPh></si>` +
+	`<si><t xml:space="preserve"> espaço </t></si></sst>`
+
+// xlsxTemporario grava uma pasta de trabalho com os estilos e textos de teste e
+// uma aba "Aba" com as linhas (conteúdo de sheetData)
+func xlsxTemporario(t *testing.T, propriedades, linhas string) string {
+	t.Helper()
+	return arquivoTemporario(t, "relatorio.xlsx", "",
+		[2]string{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		[2]string{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` + propriedades +
+			`<sheets><sheet name="Aba" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		[2]string{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/></Relationships>`},
+		[2]string{"xl/styles.xml", estilosTesteXLSX},
+		[2]string{"xl/sharedStrings.xml", textosTesteXLSX},
+		[2]string{"xl/worksheets/sheet1.xml", `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<sheetData>` + linhas + `</sheetData></worksheet>`},
+	)
+}
+
+func TestLinhasXLSX(t *testing.T) {
+	casos := []struct {
+		nome         string
+		propriedades string // elementos do workbook.xml antes de sheets
+		linhas       string
+		esperado     [][]string
+	}{
+		{
+			nome:     "textos compartilhados",
+			linhas:   `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>`,
+			esperado: [][]string{{"Nome", "José", "東京", " espaço "}},
+		},
+		{
+			nome: "datas e números",
+			linhas: `<row r="1"><c r="A1" s="1"><v>45366</v></c><c r="B1" s="2"><v>45366.75</v></c><c r="C1" s="3"><v>1500.5</v></c>` +
+				`<c r="D1"><v>0.30000000000000004</v></c><c r="E1" s="1" t="d"><v>2024-03-15T00:00:00</v></c><c r="F1" t="d"><v>2024-03-16</v></c>` +
+				`<c r="G1" s="9"><v>52998224725</v></c></row>`,
+			esperado: [][]string{{"15/03/2024", "15/03/2024 18:00", "1500.5", "0.3", "15/03/2024", "16/03/2024", "52998224725"}},
+		},
+		{
+			nome:         "datas no sistema de 1904",
+			propriedades: `<workbookPr date1904="1"/>`,
+			linhas:       `<row r="1"><c r="A1" s="1"><v>43904</v></c></row>`,
+			esperado:     [][]string{{"15/03/2024"}},
+		},
+		{
+			nome: "outros tipos",
+			linhas: `<row r="1"><c r="A1" t="b"><v>1</v></c><c r="B1" t="b"><v>0</v></c><c r="C1" t="str"><f>A1&amp;"x"</f><v>TRUEx</v></c>` +
+				`<c r="D1" t="inlineStr"><is><r><t>in</t></r><r><t>line</t></r></is></c><c r="E1" t="e"><v>#DIV/0!</v></c></row>`,
+			esperado: [][]string{{"TRUE", "FALSE", "TRUEx", "inline", "#DIV/0!"}},
+		},
+		{
+			nome: "células vazias e colunas puladas",
+			linhas: `<row r="1"><c r="A1"><v>1</v></c><c r="B1" s="1"/><c r="D1"><v>4</v></c><c><v>5</v></c>` +
+				`<c r="G1" t="s"><v>0</v></c><c r="H1" t="inlineStr"><is><t></t></is></c></row>`,
+			esperado: [][]string{{"1", "", "", "4", "5", "", "Nome"}},
+		},
+		{
+			nome: "linhas puladas e vazias no fim",
+			linhas: `<row r="1"><c r="A1"><v>1</v></c></row><row r="3"><c r="B3"><v>3</v></c></row>` +
+				`<row><c r="A4"><v>4</v></c></row><row r="6" s="1"><c r="A6" s="1"/></row><row r="9"/>`,
+			esperado: [][]string{{"1"}, {}, {"", "3"}, {"4"}},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			rel, err := abrirXLSX(xlsxTemporario(t, c.propriedades, c.linhas))
+			if err != nil {
+				t.Fatalf("abrirXLSX: %v", err)
+			}
+			defer rel.Close()
+
+			if obtido := lerAbas(t, rel)["Aba"]; !reflect.DeepEqual(obtido, c.esperado) {
+				t.Errorf("linhas = %q; esperado %q", obtido, c.esperado)
+			}
+		})
+	}
+}
+
+func TestLinhasXLSXInvalidas(t *testing.T) {
+	casos := []struct {
+		nome   string
+		linhas string
+		erro   string
+	}{
+		{"índice de texto inválido", `<row r="1"><c r="A1" t="s"><v>x</v></c></row>`, "índice de texto compartilhado inválido"},
+		{"texto fora da tabela", `<row r="1"><c r="A1" t="s"><v>4</v></c></row>`, "texto compartilhado 4 não existe"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			rel, err := abrirXLSX(xlsxTemporario(t, "", c.linhas))
+			if err != nil {
+				t.Fatalf("abrirXLSX: %v", err)
+			}
+			defer rel.Close()
+
+			linhas, err := rel.Linhas("Aba")
+			if err != nil {
+				t.Fatalf("Linhas: %v", err)
+			}
+			defer linhas.Close()
+			for linhas.Proxima() {
+			}
+			if err := linhas.Err(); err == nil || !strings.Contains(err.Error(), c.erro) {
+				t.Errorf("Err() = %v; esperado %q", err, c.erro)
+			}
+		})
+	}
+}
+
+// TestAbasXLSX confere as abas lidas pelas relações: na ordem do workbook.xml,
+// sem a aba de gráfico, com alvo absoluto e com o nome da parte em outra caixa
+func TestAbasXLSX(t *testing.T) {
+	planilha := `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		`<row r="1"><c r="A1" t="inlineStr"><is><t>%s</t></is></c></row></sheetData></worksheet>`
+	caminho := arquivoTemporario(t, "relatorio.xlsx", "",
+		[2]string{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://purl.oclc.org/ooxml/officeDocument/relationships/officeDocument" Target="/xl/pasta.xml"/></Relationships>`},
+		[2]string{"xl/pasta.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+			`<sheet name="Dados Fato" sheetId="2" r:id="rId9"/><sheet name="Gráfico" sheetId="3" r:id="rId2"/>` +
+			`<sheet name="Envolvidos" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		[2]string{"xl/_rels/pasta.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://purl.oclc.org/ooxml/officeDocument/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://purl.oclc.org/ooxml/officeDocument/relationships/chartsheet" Target="chartsheets/sheet1.xml"/>` +
+			`<Relationship Id="rId9" Type="http://purl.oclc.org/ooxml/officeDocument/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/></Relationships>`},
+		[2]string{"xl/worksheets/sheet1.xml", strings.Replace(planilha, "%s", "envolvidos", 1)},
+		[2]string{"XL/Worksheets/Sheet2.xml", strings.Replace(planilha, "%s", "fato", 1)},
+	)
+
+	rel, err := abrirXLSX(caminho)
+	if err != nil {
+		t.Fatalf("abrirXLSX: %v", err)
+	}
+	defer rel.Close()
+
+	if obtido := rel.Planilhas(); !reflect.DeepEqual(obtido, []string{"Dados Fato", "Envolvidos"}) {
+		t.Errorf("Planilhas() = %q; esperado [Dados Fato Envolvidos]", obtido)
+	}
+	esperado := map[string][][]string{"Dados Fato": {{"fato"}}, "Envolvidos": {{"envolvidos"}}}
+	if obtido := lerAbas(t, rel); !reflect.DeepEqual(obtido, esperado) {
+		t.Errorf("abas = %q; esperado %q", obtido, esperado)
+	}
+	if nome, ok := localizarPlanilha(rel, "dados_fato"); !ok || nome != "Dados Fato" {
+		t.Errorf("localizarPlanilha(dados_fato) = %q, %v; esperado Dados Fato", nome, ok)
+	}
+	if _, err := rel.Linhas("Gráfico"); err == nil {
+		t.Error("Linhas(Gráfico) não retornou erro para a aba de gráfico")
+	}
+}
+
+// TestTextosXLSXEmArquivo confere os textos compartilhados guardados em arquivo
+// temporário, usados quando o sharedStrings.xml passa de limiteTextosMemoriaXLSX
+func TestTextosXLSXEmArquivo(t *testing.T) {
+	textos := &textosXLSX{}
+	if err := textos.usarArquivo(); err != nil {
+		t.Fatalf("usarArquivo: %v", err)
+	}
+	defer textos.Close()
+
+	esperados := []string{"Nome", "", "Ocorrência nº 1", strings.Repeat("x", 70000)}
+	for _, texto := range esperados {
+		if err := textos.adicionar(texto); err != nil {
+			t.Fatalf("adicionar: %v", err)
+		}
+	}
+	if err := textos.concluir(); err != nil {
+		t.Fatalf("concluir: %v", err)
+	}
+
+	for i := len(esperados) - 1; i >= 0; i-- {
+		if obtido, err := textos.texto(i); err != nil || obtido != esperados[i] {
+			t.Errorf("texto(%d) = %.20q, %v; esperado %.20q", i, obtido, err, esperados[i])
+		}
+	}
+	if _, err := textos.texto(len(esperados)); err == nil {
+		t.Errorf("texto(%d) não retornou erro", len(esperados))
+	}
+}
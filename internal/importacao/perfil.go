@@ -0,0 +1,222 @@
+package importacao
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fraudbase/internal/repository"
+	"fraudbase/internal/validacao"
+)
+
+// Abas lógicas do relatório; o perfil diz o nome de cada uma no arquivo
+const (
+	PlanilhaRegistro   = repository.PlanilhaRegistro
+	PlanilhaFato       = repository.PlanilhaFato
+	PlanilhaEnvolvidos = repository.PlanilhaEnvolvidos
+	PlanilhaRelato     = repository.PlanilhaRelato
+)
+
+// ordemPlanilhas é a ordem em que as abas são lidas e listadas no diagnóstico
+var ordemPlanilhas = []string{PlanilhaRegistro, PlanilhaFato, PlanilhaEnvolvidos, PlanilhaRelato}
+
+// camposPlanilhas são os campos lidos de cada aba. O campo "id" liga as abas e é
+// sempre obrigatório.
+var camposPlanilhas = map[string][]string{
+	PlanilhaRegistro:   {"id", "numero", "unidade", "situacao", "naturezas"},
+	PlanilhaFato:       {"id", "data_hora", "cep", "latitude", "longitude", "logradouro", "numero", "bairro", "municipio", "pais"},
+	PlanilhaEnvolvidos: {"id", "participacao", "nome", "cpf", "filiacao", "nascimento", "nacionalidade", "naturalidade", "uf", "sexo", "telefone"},
+	PlanilhaRelato:     {"id", "relato"},
+}
+
+// estadosValidos são as siglas aceitas como sufixo do número do BO
+var estadosValidos = []string{
+	"AC", "AL", "AP", "AM", "BA", "CE", "DF", "ES", "GO",
+	"MA", "MT", "MS", "MG", "PA", "PB", "PR", "PE", "PI",
+	"RJ", "RN", "RS", "RO", "RR", "SC", "SP", "SE", "TO",
+}
+
+// PerfilPadrao é o leiaute do relatório "Resultado da pesquisa de BO", usado
+// quando nenhum perfil do banco está marcado como padrão. A migração 0015 grava
+// o mesmo perfil.
+func PerfilPadrao() *repository.PerfilImportacao {
+	return &repository.PerfilImportacao{
+		Nome:          "padrao",
+		Descricao:     `Relatório "Resultado da pesquisa de BO"`,
+		PadraoArquivo: "resultado_da_pesquisa_bo_",
+		RegraSufixo:   repository.SufixoUFUsuario,
+		Padrao:        true,
+		Planilhas: map[string]repository.PlanilhaPerfil{
+			PlanilhaRegistro: {
+				Nome: "Dados do Registro",
+				Colunas: map[string][]string{
+					"id": {"Id"}, "numero": {"Número"}, "unidade": {"Unidade de Apuração"},
+					"situacao": {"Situação"}, "naturezas": {"Naturezas"},
+				},
+				Obrigatorias: []string{"id", "numero", "unidade", "situacao", "naturezas"},
+			},
+			PlanilhaFato: {
+				Nome: "Dados do Fato",
+				Colunas: map[string][]string{
+					"id": {"Id"}, "data_hora": {"Data/Hora Início"}, "cep": {"CEP"}, "latitude": {"Latitude"},
+					"longitude": {"Longitude"}, "logradouro": {"Logradouro"}, "numero": {"Número"},
+					"bairro": {"Bairro"}, "municipio": {"Município"}, "pais": {"Pais"},
+				},
+				Obrigatorias: []string{"id"},
+			},
+			PlanilhaEnvolvidos: {
+				Nome: "Envolvidos",
+				Colunas: map[string][]string{
+					"id": {"Id"}, "participacao": {"Participações"}, "nome": {"Nome Completo"}, "cpf": {"CPF"},
+					"filiacao": {"Filiação 1"}, "nascimento": {"Data de Nascimento"}, "nacionalidade": {"Nacionalidade"},
+					"naturalidade": {"Naturalidade"}, "uf": {"UF"}, "sexo": {"Sexo"}, "telefone": {"Telefone"},
+				},
+				Obrigatorias: []string{"id"},
+			},
+			PlanilhaRelato: {
+				Nome:         "Relato Histórico",
+				Colunas:      map[string][]string{"id": {"Id"}, "relato": {"Relato / Histórico"}},
+				Obrigatorias: []string{"id", "relato"},
+			},
+		},
+	}
+}
+
+// BuscarPerfil retorna o perfil de importação pelo nome ou, sem nome, o perfil
+// padrão. Sem perfil padrão no banco vale PerfilPadrao.
+func BuscarPerfil(perfis *repository.PerfilImportacaoRepository, nome string) (*repository.PerfilImportacao, error) {
+	if nome != "" {
+		return perfis.GetPerfilPorNome(nome)
+	}
+	perfil, err := perfis.GetPerfilPadrao()
+	if errors.Is(err, repository.ErrNotFound) {
+		return PerfilPadrao(), nil
+	}
+	return perfil, err
+}
+
+// ValidarPerfil confere se o perfil descreve as quatro abas com campos conhecidos
+// e uma regra de sufixo válida, removendo espaços das bordas dos nomes e cabeçalhos.
+// Retorna nil quando o perfil é válido.
+func ValidarPerfil(p *repository.PerfilImportacao) validacao.Erros {
+	erros := validacao.Erros{}
+
+	p.Nome = strings.TrimSpace(p.Nome)
+	p.PadraoArquivo = strings.TrimSpace(p.PadraoArquivo)
+	p.Sufixo = strings.ToUpper(strings.TrimSpace(p.Sufixo))
+
+	if p.Nome == "" {
+		erros["nome"] = "campo obrigatório"
+	} else if len(p.Nome) > 100 {
+		erros["nome"] = "use no máximo 100 caracteres"
+	}
+	if len(p.PadraoArquivo) > 200 {
+		erros["padrao_arquivo"] = "use no máximo 200 caracteres"
+	}
+
+	switch p.RegraSufixo {
+	case "":
+		p.RegraSufixo = repository.SufixoUFUsuario
+	case repository.SufixoUFUsuario, repository.SufixoNenhum:
+	case repository.SufixoFixo:
+		if p.Sufixo == "" || len(p.Sufixo) > 10 || strings.Contains(p.Sufixo, "/") {
+			erros["sufixo"] = "informe o sufixo (até 10 caracteres, sem '/') para a regra 'fixo'"
+		}
+	default:
+		erros["regra_sufixo"] = "regra inválida (use uf_usuario, fixo ou nenhum)"
+	}
+
+	for chave := range p.Planilhas {
+		if _, ok := camposPlanilhas[chave]; !ok {
+			erros["planilhas."+chave] = "aba desconhecida (use registro, fato, envolvidos e relato)"
+		}
+	}
+
+	nomesAbas := map[string]string{}
+	for _, chave := range ordemPlanilhas {
+		planilha, ok := p.Planilhas[chave]
+		if !ok {
+			erros["planilhas."+chave] = "aba obrigatória no perfil"
+			continue
+		}
+
+		planilha.Nome = strings.TrimSpace(planilha.Nome)
+		if planilha.Nome == "" {
+			erros["planilhas."+chave+".nome"] = "campo obrigatório"
+		} else if outra, repetida := nomesAbas[planilha.Nome]; repetida {
+			erros["planilhas."+chave+".nome"] = "mesmo nome da aba " + outra
+		}
+		nomesAbas[planilha.Nome] = chave
+
+		campos := camposPlanilhas[chave]
+		colunas := make(map[string][]string, len(planilha.Colunas))
+		for campo, apelidos := range planilha.Colunas {
+			if !contem(campos, campo) {
+				erros["planilhas."+chave+".colunas."+campo] = "campo desconhecido (use " + strings.Join(campos, ", ") + ")"
+				continue
+			}
+			var limpos []string
+			for _, apelido := range apelidos {
+				if apelido = strings.TrimSpace(apelido); apelido != "" {
+					limpos = append(limpos, apelido)
+				}
+			}
+			if len(limpos) == 0 {
+				erros["planilhas."+chave+".colunas."+campo] = "informe ao menos um cabeçalho"
+				continue
+			}
+			colunas[campo] = limpos
+		}
+		if _, ok := planilha.Colunas["id"]; !ok {
+			erros["planilhas."+chave+".colunas.id"] = "o campo id liga as abas e é obrigatório"
+		}
+		planilha.Colunas = colunas
+
+		for _, campo := range planilha.Obrigatorias {
+			if !contem(campos, campo) {
+				erros["planilhas."+chave+".obrigatorias"] = fmt.Sprintf("campo desconhecido '%s'", campo)
+			} else if _, ok := colunas[campo]; !ok {
+				erros["planilhas."+chave+".obrigatorias"] = fmt.Sprintf("o campo obrigatório '%s' não tem cabeçalhos", campo)
+			}
+		}
+		if !contem(planilha.Obrigatorias, "id") {
+			planilha.Obrigatorias = append([]string{"id"}, planilha.Obrigatorias...)
+		}
+
+		p.Planilhas[chave] = planilha
+	}
+
+	if len(erros) == 0 {
+		return nil
+	}
+	return erros
+}
+
+// aplicarSufixo completa o número do BO conforme a regra do perfil. Com a regra
+// uf_usuario o sufixo só é acrescentado se o estado for uma UF válida e o número
+// ainda não terminar em "/UF".
+func aplicarSufixo(numeroBO string, perfil *repository.PerfilImportacao, estadoUsuario string) string {
+	if numeroBO == "" {
+		return numeroBO
+	}
+
+	switch perfil.RegraSufixo {
+	case repository.SufixoNenhum:
+		return numeroBO
+	case repository.SufixoFixo:
+		if perfil.Sufixo == "" || strings.HasSuffix(numeroBO, "/"+perfil.Sufixo) {
+			return numeroBO
+		}
+		return numeroBO + "/" + perfil.Sufixo
+	}
+
+	if !contem(estadosValidos, estadoUsuario) {
+		return numeroBO
+	}
+	for _, estado := range estadosValidos {
+		if strings.HasSuffix(numeroBO, "/"+estado) {
+			return numeroBO
+		}
+	}
+	return numeroBO + "/" + estadoUsuario
+}
@@ -0,0 +1,279 @@
+package importacao
+
+import (
+	"errors"
+	"fmt"
+	"fraudbase/internal/repository"
+	"log"
+	"strings"
+)
+
+// ErrArquivoInvalido indica um arquivo que não é um relatório válido (erro do usuário)
+var ErrArquivoInvalido = errors.New("arquivo inválido")
+
+// AbrirRelatorio abre o arquivo em caminho no formato indicado pela extensão do
+// nome original (XLSX, XLS, ODS, CSVs compactados em .zip ou JSON) e confere se
+// todas as abas do perfil existem. Erros de formato embrulham ErrArquivoInvalido.
+func AbrirRelatorio(caminho, nomeArquivo string, perfil *repository.PerfilImportacao) (Relatorio, error) {
+	rel, err := abrirFormato(caminho, nomeArquivo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chave := range ordemPlanilhas {
+		sheet := perfil.Planilhas[chave].Nome
+		if _, ok := localizarPlanilha(rel, sheet); !ok {
+			rel.Close()
+			return nil, fmt.Errorf("%w: planilha '%s' não encontrada no arquivo (perfil '%s')", ErrArquivoInvalido, sheet, perfil.Nome)
+		}
+	}
+
+	return rel, nil
+}
+
+// planilhaLida é uma aba do relatório com as colunas localizadas pelo perfil,
+// posicionada após o cabeçalho
+type planilhaLida struct {
+	nome    string
+	linhas  LinhasPlanilha
+	indices map[string]int // campo → índice da coluna (-1 se ausente)
+}
+
+// lerPlanilha abre a aba do perfil, localiza a coluna de cada campo pelos
+// cabeçalhos aceitos e confere as colunas obrigatórias
+func lerPlanilha(rel Relatorio, perfil *repository.PerfilImportacao, chave string, diag *Diagnostico) (*planilhaLida, error) {
+	config := perfil.Planilhas[chave]
+
+	nome, ok := localizarPlanilha(rel, config.Nome)
+	if !ok {
+		return nil, fmt.Errorf("planilha '%s' não encontrada no arquivo", config.Nome)
+	}
+	linhas, err := rel.Linhas(nome)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler planilha '%s': %v", config.Nome, err)
+	}
+	if !linhas.Proxima() {
+		linhas.Close()
+		if err := linhas.Err(); err != nil {
+			return nil, fmt.Errorf("erro ao ler planilha '%s': %v", config.Nome, err)
+		}
+		return nil, fmt.Errorf("planilha '%s' está vazia", config.Nome)
+	}
+	cabecalho := linhas.Colunas()
+	diag.conferirColunas(config.Nome, cabecalho, config.Colunas, camposPlanilhas[chave])
+
+	p := &planilhaLida{nome: config.Nome, linhas: linhas, indices: map[string]int{}}
+	for _, campo := range camposPlanilhas[chave] {
+		p.indices[campo] = indiceColuna(cabecalho, config.Colunas[campo])
+	}
+
+	var faltando []string
+	for _, campo := range config.Obrigatorias {
+		if p.indices[campo] == -1 {
+			faltando = append(faltando, strings.Join(config.Colunas[campo], " / "))
+		}
+	}
+	if p.indices["id"] == -1 && !contem(config.Obrigatorias, "id") {
+		faltando = append(faltando, strings.Join(config.Colunas["id"], " / "))
+	}
+	if len(faltando) > 0 {
+		linhas.Close()
+		return nil, fmt.Errorf("colunas obrigatórias não encontradas na planilha '%s': %s", config.Nome, strings.Join(faltando, ", "))
+	}
+
+	return p, nil
+}
+
+// percorrer chama fn para cada linha após o cabeçalho, com a numeração do Excel,
+// e fecha a aba
+func (p *planilhaLida) percorrer(fn func(linha int, row []string) error) error {
+	defer p.linhas.Close()
+
+	for linha := 2; p.linhas.Proxima(); linha++ {
+		if err := fn(linha, p.linhas.Colunas()); err != nil {
+			return err
+		}
+	}
+	if err := p.linhas.Err(); err != nil {
+		return fmt.Errorf("erro ao ler planilha '%s': %v", p.nome, err)
+	}
+	return nil
+}
+
+// valor retorna a célula do campo na linha ("" se a coluna não existir)
+func (p *planilhaLida) valor(row []string, campo string) string {
+	return getValueSafely(row, p.indices[campo])
+}
+
+// indiceColuna retorna a primeira coluna do cabeçalho que corresponde a um dos
+// cabeçalhos aceitos, sem diferenciar maiúsculas nem espaços nas bordas
+func indiceColuna(cabecalho []string, apelidos []string) int {
+	for i, cell := range cabecalho {
+		if correspondeColuna(cell, apelidos) {
+			return i
+		}
+	}
+	return -1
+}
+
+func correspondeColuna(cell string, apelidos []string) bool {
+	cell = strings.TrimSpace(cell)
+	for _, apelido := range apelidos {
+		if strings.EqualFold(cell, strings.TrimSpace(apelido)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CarregarRelatorio percorre as abas do relatório conforme o perfil de
+// importação, linha a linha, e as copia para a carga, que junta as abas pelo Id
+// no banco. O diagnóstico lista as linhas ignoradas e as colunas que não foram
+// mapeadas. A memória usada não depende do tamanho do arquivo.
+func CarregarRelatorio(rel Relatorio, perfil *repository.PerfilImportacao, estadoUsuario string, carga *repository.CargaRelatorio) (Diagnostico, error) {
+	diag := Diagnostico{LinhasIgnoradas: []LinhaIgnorada{}, Avisos: []string{}}
+
+	log.Printf("Processando dados com perfil '%s' e estado do usuário: '%s'", perfil.Nome, estadoUsuario)
+
+	// As linhas descartadas vão também para o relatório de rejeições da importação
+	ignorar := func(planilha string, linha int, row []string, motivo string) error {
+		if !diag.ignorar(planilha, linha, row, motivo) {
+			return nil
+		}
+		return carga.Ignorar(planilha, linha, motivo)
+	}
+
+	// 1. Processar a aba de registro
+	registros, err := lerPlanilha(rel, perfil, PlanilhaRegistro, &diag)
+	if err != nil {
+		return diag, err
+	}
+
+	idxId, idxNumero := registros.indices["id"], registros.indices["numero"]
+	err = registros.percorrer(func(linha int, row []string) error {
+		if len(row) <= idxId || len(row) <= idxNumero {
+			return ignorar(registros.nome, linha, row, "linha sem Id ou Número") // Linha vazia ou inválida
+		}
+
+		id := row[idxId]
+		if id == "" {
+			return ignorar(registros.nome, linha, row, "linha sem Id")
+		}
+
+		// O número do BO é completado conforme a regra de sufixo do perfil
+		return carga.Registro(linha, id, repository.DadosRelatorio{
+			NumeroBo:             aplicarSufixo(registros.valor(row, "numero"), perfil, estadoUsuario),
+			DelegaciaResponsavel: registros.valor(row, "unidade"),
+			Situacao:             registros.valor(row, "situacao"),
+			Natureza:             registros.valor(row, "naturezas"),
+		})
+	})
+	if err != nil {
+		return diag, err
+	}
+
+	// 2. Processar a aba do fato
+	fatos, err := lerPlanilha(rel, perfil, PlanilhaFato, &diag)
+	if err != nil {
+		return diag, err
+	}
+
+	idxIdFato := fatos.indices["id"]
+	err = fatos.percorrer(func(linha int, row []string) error {
+		if len(row) <= idxIdFato || row[idxIdFato] == "" {
+			return ignorar(fatos.nome, linha, row, "linha sem Id") // Linha vazia ou inválida
+		}
+
+		return carga.Fato(linha, row[idxIdFato], repository.DadosRelatorio{
+			DataFato:       fatos.valor(row, "data_hora"),
+			CepFato:        fatos.valor(row, "cep"),
+			LatitudeFato:   fatos.valor(row, "latitude"),
+			LongitudeFato:  fatos.valor(row, "longitude"),
+			LogradouroFato: fatos.valor(row, "logradouro"),
+			NumeroCasaFato: fatos.valor(row, "numero"),
+			BairroFato:     fatos.valor(row, "bairro"),
+			MunicipioFato:  fatos.valor(row, "municipio"),
+			PaisFato:       fatos.valor(row, "pais"),
+		})
+	})
+	if err != nil {
+		return diag, err
+	}
+
+	// 3. Processar a aba de envolvidos (pode ter múltiplos por ID)
+	envolvidosAba, err := lerPlanilha(rel, perfil, PlanilhaEnvolvidos, &diag)
+	if err != nil {
+		return diag, err
+	}
+
+	idxIdEnvolvido := envolvidosAba.indices["id"]
+	err = envolvidosAba.percorrer(func(linha int, row []string) error {
+		if len(row) <= idxIdEnvolvido || row[idxIdEnvolvido] == "" {
+			return ignorar(envolvidosAba.nome, linha, row, "linha sem Id") // Linha vazia ou inválida
+		}
+
+		return carga.Envolvido(linha, row[idxIdEnvolvido], repository.DadosRelatorio{
+			TipoEnvolvido:     envolvidosAba.valor(row, "participacao"),
+			NomeCompleto:      envolvidosAba.valor(row, "nome"),
+			Cpf:               envolvidosAba.valor(row, "cpf"),
+			NomeDaMae:         envolvidosAba.valor(row, "filiacao"),
+			Nascimento:        envolvidosAba.valor(row, "nascimento"),
+			Nacionalidade:     envolvidosAba.valor(row, "nacionalidade"),
+			Naturalidade:      envolvidosAba.valor(row, "naturalidade"),
+			UfEnvolvido:       envolvidosAba.valor(row, "uf"),
+			SexoEnvolvido:     envolvidosAba.valor(row, "sexo"),
+			TelefoneEnvolvido: envolvidosAba.valor(row, "telefone"),
+		})
+	})
+	if err != nil {
+		return diag, err
+	}
+
+	// 4. Processar a aba do relato
+	relatos, err := lerPlanilha(rel, perfil, PlanilhaRelato, &diag)
+	if err != nil {
+		return diag, err
+	}
+
+	idxIdRelato, idxRelato := relatos.indices["id"], relatos.indices["relato"]
+	err = relatos.percorrer(func(linha int, row []string) error {
+		if len(row) <= idxIdRelato || row[idxIdRelato] == "" {
+			return ignorar(relatos.nome, linha, row, "linha sem Id") // Linha vazia ou inválida
+		}
+		if len(row) <= idxRelato {
+			return nil // Relato vazio
+		}
+
+		return carga.Relato(linha, row[idxIdRelato], getValueSafely(row, idxRelato))
+	})
+	if err != nil {
+		return diag, err
+	}
+
+	// 5. Relacionar os dados pelo Id; linhas cujo Id não aparece na aba de
+	// registro não geram registros
+	nomes := map[string]string{
+		PlanilhaRegistro:   registros.nome,
+		PlanilhaFato:       fatos.nome,
+		PlanilhaEnvolvidos: envolvidosAba.nome,
+		PlanilhaRelato:     relatos.nome,
+	}
+	err = carga.Concluir(nomes, func(l repository.LinhaRejeitada) {
+		diag.ignorarLinha(l.Planilha, l.Linha, l.Motivo)
+	})
+	if err != nil {
+		return diag, err
+	}
+	diag.ordenar([]string{registros.nome, fatos.nome, envolvidosAba.nome, relatos.nome})
+
+	log.Printf("Processamento concluído. Total de registros: %d", carga.Total())
+	return diag, nil
+}
+
+// Função auxiliar para obter valor seguro de uma linha
+func getValueSafely(row []string, index int) string {
+	if index >= 0 && index < len(row) {
+		return row[index]
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"fraudbase/internal/repository"
+)
+
+// intervaloPadraoAneis é usado quando RINGS_INTERVAL não está definido
+const intervaloPadraoAneis = time.Hour
+
+// AneisJob recalcula periodicamente os anéis de fraude em background
+type AneisJob struct {
+	repo      *repository.AneisRepository
+	intervalo time.Duration
+	mu        sync.Mutex
+}
+
+// NewAneisJob cria o job de detecção de anéis de fraude. O intervalo pode ser
+// configurado pela variável de ambiente RINGS_INTERVAL (ex.: "30m", "6h").
+func NewAneisJob(repo *repository.AneisRepository) *AneisJob {
+	intervalo := intervaloPadraoAneis
+	if valor := os.Getenv("RINGS_INTERVAL"); valor != "" {
+		if d, err := time.ParseDuration(valor); err == nil && d > 0 {
+			intervalo = d
+		} else {
+			log.Printf("Aviso: RINGS_INTERVAL inválido (%q), usando %v", valor, intervaloPadraoAneis)
+		}
+	}
+
+	return &AneisJob{repo: repo, intervalo: intervalo}
+}
+
+// Start inicia o job em uma goroutine: uma execução logo após a inicialização
+// e depois uma a cada intervalo
+func (j *AneisJob) Start() {
+	go func() {
+		time.Sleep(30 * time.Second) // Aguardar a API terminar de subir
+		j.Executar()
+
+		ticker := time.NewTicker(j.intervalo)
+		defer ticker.Stop()
+		for range ticker.C {
+			j.Executar()
+		}
+	}()
+
+	log.Printf("Job de detecção de anéis de fraude agendado (intervalo: %v)", j.intervalo)
+}
+
+// Executar recalcula os anéis imediatamente. Retorna false se já houver um
+// cálculo em andamento neste processo.
+func (j *AneisJob) Executar() bool {
+	if !j.mu.TryLock() {
+		log.Println("Cálculo de anéis de fraude já em andamento, execução ignorada")
+		return false
+	}
+	defer j.mu.Unlock()
+
+	if _, err := j.repo.RecalcularAneis(); err != nil {
+		log.Printf("Erro ao recalcular anéis de fraude: %v", err)
+	}
+	return true
+}
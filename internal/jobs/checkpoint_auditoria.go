@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"fraudbase/internal/repository"
+)
+
+// intervaloPadraoCheckpoint é usado quando AUDIT_CHECKPOINT_INTERVAL não está definido
+const intervaloPadraoCheckpoint = time.Hour
+
+// CheckpointAuditoriaJob assina periodicamente o estado da cadeia de hashes da auditoria
+type CheckpointAuditoriaJob struct {
+	repo      *repository.AuditoriaRepository
+	intervalo time.Duration
+}
+
+// NewCheckpointAuditoriaJob cria o job de checkpoints da auditoria. O intervalo pode
+// ser configurado pela variável de ambiente AUDIT_CHECKPOINT_INTERVAL (ex.: "15m").
+func NewCheckpointAuditoriaJob(repo *repository.AuditoriaRepository) *CheckpointAuditoriaJob {
+	intervalo := intervaloPadraoCheckpoint
+	if valor := os.Getenv("AUDIT_CHECKPOINT_INTERVAL"); valor != "" {
+		if d, err := time.ParseDuration(valor); err == nil && d > 0 {
+			intervalo = d
+		} else {
+			log.Printf("Aviso: AUDIT_CHECKPOINT_INTERVAL inválido (%q), usando %v", valor, intervaloPadraoCheckpoint)
+		}
+	}
+
+	return &CheckpointAuditoriaJob{repo: repo, intervalo: intervalo}
+}
+
+// Start inicia o job em uma goroutine. Sem AUDIT_SIGNING_KEY o job não é iniciado.
+func (j *CheckpointAuditoriaJob) Start() {
+	if os.Getenv("AUDIT_SIGNING_KEY") == "" {
+		log.Println("Aviso: AUDIT_SIGNING_KEY não configurada, checkpoints assinados da auditoria desativados")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(j.intervalo)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := j.repo.CriarCheckpoint(); err != nil {
+				log.Printf("Erro ao criar checkpoint da auditoria: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("Job de checkpoint da auditoria agendado (intervalo: %v)", j.intervalo)
+}
@@ -0,0 +1,508 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fraudbase/internal/importacao"
+	"fraudbase/internal/repository"
+)
+
+// workersPadraoImportacao é usado quando IMPORT_WORKERS não está definido
+const workersPadraoImportacao = 2
+
+// tamanhoFilaImportacao limita quantos relatórios podem aguardar processamento;
+// cada um fica em um arquivo temporário até ser processado
+const tamanhoFilaImportacao = 20
+
+// validadePrevia é por quanto tempo uma prévia (dryRun) pode ser confirmada depois
+// de calculada; até lá o arquivo fica em disco
+const validadePrevia = 30 * time.Minute
+
+// limiteAmostraPrevia é quantos registros novos e quantas duplicatas a prévia lista
+const limiteAmostraPrevia = 1000
+
+// Limites de prévias guardadas (cada uma mantém o arquivo em disco até expirar)
+const (
+	maxPreviasPorUsuario = 3
+	maxPreviasTotal      = 20
+)
+
+var (
+	// ErrPreviaExpirada indica que a prévia não pode mais ser confirmada
+	ErrPreviaExpirada = errors.New("prévia expirada")
+	// ErrPreviaEmCalculo indica que a prévia ainda não foi calculada
+	ErrPreviaEmCalculo = errors.New("prévia ainda em cálculo")
+	// ErrFilaCheia indica que não há espaço na fila de importação
+	ErrFilaCheia = errors.New("fila de importação cheia")
+	// ErrLimitePrevias indica que o usuário (ou a API) já tem o máximo de prévias guardadas
+	ErrLimitePrevias = errors.New("limite de prévias aguardando confirmação atingido")
+)
+
+// NomeProcesso identifica quem processa as importações: a instância
+// (FRAUDBASE_INSTANCIA ou o nome da máquina) e o papel (a API ou a ingestão de uma
+// pasta). O nome precisa se manter entre reinicializações para que o processo
+// reiniciado encerre as importações que eram suas.
+func NomeProcesso(papel string) string {
+	instancia := os.Getenv("FRAUDBASE_INSTANCIA")
+	if instancia == "" {
+		instancia, _ = os.Hostname()
+	}
+	return instancia + "/" + papel
+}
+
+// TarefaImportacao é um relatório já validado aguardando processamento
+type TarefaImportacao struct {
+	ID      int64
+	Autor   repository.Autor
+	Estado  string // UF do usuário, usada como sufixo do número do BO
+	Perfil  *repository.PerfilImportacao
+	Opcoes  repository.OpcoesImportacao
+	Arquivo importacao.Relatorio
+	Caminho string // arquivo temporário com o upload, removido por Liberar
+	Previa  bool   // dryRun: o worker só calcula a prévia, sem gravar nada
+}
+
+// Liberar fecha o relatório e remove o arquivo temporário
+func (t TarefaImportacao) Liberar() {
+	t.Arquivo.Close()
+	if t.Caminho != "" {
+		if err := os.Remove(t.Caminho); err != nil && !os.IsNotExist(err) {
+			log.Printf("Erro ao remover arquivo temporário da importação %d: %v", t.ID, err)
+		}
+	}
+}
+
+// ImportacaoJob processa os relatórios enviados em um pool de workers
+type ImportacaoJob struct {
+	importacoes *repository.ImportacaoRepository
+	relatorios  *repository.RelatorioRepository
+	workers     int
+	fila        chan TarefaImportacao
+	diretorio   string // diretório temporário exclusivo do processo, com os uploads
+
+	mu      sync.Mutex
+	previas map[int64]previaImportacao
+}
+
+// previaImportacao guarda o arquivo de uma prévia até a confirmação
+type previaImportacao struct {
+	tarefa     TarefaImportacao
+	calculando bool      // na fila ou em cálculo; ainda não pode ser confirmada nem expira
+	expiraEm   time.Time // definido quando a prévia fica pronta
+}
+
+// NewImportacaoJob cria o pool de importação. A quantidade de workers pode ser
+// configurada pela variável de ambiente IMPORT_WORKERS.
+func NewImportacaoJob(importacoes *repository.ImportacaoRepository, relatorios *repository.RelatorioRepository) *ImportacaoJob {
+	workers := workersPadraoImportacao
+	if valor := os.Getenv("IMPORT_WORKERS"); valor != "" {
+		if n, err := strconv.Atoi(valor); err == nil && n > 0 {
+			workers = n
+		} else {
+			log.Printf("Aviso: IMPORT_WORKERS inválido (%q), usando %d", valor, workersPadraoImportacao)
+		}
+	}
+
+	// Um diretório por processo: outro processo na mesma máquina (outra instância
+	// da API ou a ingestão por pasta) não apaga os uploads deste
+	diretorio, err := os.MkdirTemp("", "fraudbase-importacao-*")
+	if err != nil {
+		log.Printf("Erro ao criar diretório temporário das importações, usando %s: %v", os.TempDir(), err)
+	}
+
+	return &ImportacaoJob{
+		importacoes: importacoes,
+		relatorios:  relatorios,
+		workers:     workers,
+		fila:        make(chan TarefaImportacao, tamanhoFilaImportacao),
+		diretorio:   diretorio,
+		previas:     make(map[int64]previaImportacao),
+	}
+}
+
+// Start encerra as importações interrompidas por uma reinicialização anterior
+// e inicia os workers
+func (j *ImportacaoJob) Start() {
+	j.InterromperPendentes()
+
+	for i := 0; i < j.workers; i++ {
+		go func() {
+			for tarefa := range j.fila {
+				if tarefa.Previa {
+					j.CalcularPrevia(tarefa)
+				} else {
+					j.Processar(tarefa)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			j.expirarPrevias()
+		}
+	}()
+
+	log.Printf("Workers de importação iniciados: %d", j.workers)
+}
+
+// InterromperPendentes encerra as importações deste processo interrompidas por
+// uma reinicialização anterior
+func (j *ImportacaoJob) InterromperPendentes() {
+	if n, err := j.importacoes.InterromperPendentes(); err != nil {
+		log.Printf("Erro ao encerrar importações interrompidas: %v", err)
+	} else if n > 0 {
+		log.Printf("%d importações interrompidas pela reinicialização marcadas como erro", n)
+	}
+}
+
+// Enfileirar coloca a importação na fila. Retorna false se a fila estiver cheia.
+func (j *ImportacaoJob) Enfileirar(tarefa TarefaImportacao) bool {
+	select {
+	case j.fila <- tarefa:
+		return true
+	default:
+		return false
+	}
+}
+
+// VerificarLimitePrevias retorna ErrLimitePrevias se o usuário ou a API já têm o
+// máximo de prévias aguardando confirmação
+func (j *ImportacaoJob) VerificarLimitePrevias(usuarioID int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.verificarLimitePrevias(usuarioID)
+}
+
+// verificarLimitePrevias deve ser chamada com j.mu obtido
+func (j *ImportacaoJob) verificarLimitePrevias(usuarioID int) error {
+	if len(j.previas) >= maxPreviasTotal {
+		return ErrLimitePrevias
+	}
+	doUsuario := 0
+	for _, previa := range j.previas {
+		if previa.tarefa.Autor.UsuarioID == usuarioID {
+			doUsuario++
+		}
+	}
+	if doUsuario >= maxPreviasPorUsuario {
+		return ErrLimitePrevias
+	}
+	return nil
+}
+
+// EnfileirarPrevia guarda o arquivo da prévia e a coloca na fila para ser
+// calculada (CalcularPrevia). Retorna ErrLimitePrevias ou ErrFilaCheia sem
+// guardar a tarefa.
+func (j *ImportacaoJob) EnfileirarPrevia(tarefa TarefaImportacao) error {
+	tarefa.Previa = true
+
+	// Com j.mu obtido até a prévia estar guardada, o worker não a conclui antes disso
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.verificarLimitePrevias(tarefa.Autor.UsuarioID); err != nil {
+		return err
+	}
+	if !j.Enfileirar(tarefa) {
+		return ErrFilaCheia
+	}
+	j.previas[tarefa.ID] = previaImportacao{tarefa: tarefa, calculando: true}
+	return nil
+}
+
+// ConfirmarPrevia coloca na fila o arquivo de uma prévia guardada
+func (j *ImportacaoJob) ConfirmarPrevia(id int64) error {
+	j.mu.Lock()
+	previa, ok := j.previas[id]
+	if ok && previa.calculando {
+		j.mu.Unlock()
+		return ErrPreviaEmCalculo
+	}
+	delete(j.previas, id)
+	j.mu.Unlock()
+
+	if !ok {
+		return ErrPreviaExpirada
+	}
+	if time.Now().After(previa.expiraEm) {
+		previa.tarefa.Liberar()
+		j.importacoes.ExpirarPrevia(id)
+		return ErrPreviaExpirada
+	}
+
+	previa.tarefa.Previa = false
+	if !j.Enfileirar(previa.tarefa) {
+		j.mu.Lock()
+		j.previas[id] = previa
+		j.mu.Unlock()
+		return ErrFilaCheia
+	}
+
+	// O worker pode já ter começado; nesse caso a prévia já saiu da situação 'previa'
+	if _, err := j.importacoes.ConfirmarPrevia(id); err != nil {
+		log.Printf("Erro ao marcar prévia %d como confirmada: %v", id, err)
+	}
+	return nil
+}
+
+// expirarPrevias libera os arquivos das prévias que não foram confirmadas a tempo
+func (j *ImportacaoJob) expirarPrevias() {
+	agora := time.Now()
+
+	j.mu.Lock()
+	var expiradas []previaImportacao
+	for id, previa := range j.previas {
+		if !previa.calculando && agora.After(previa.expiraEm) {
+			expiradas = append(expiradas, previa)
+			delete(j.previas, id)
+		}
+	}
+	j.mu.Unlock()
+
+	for _, previa := range expiradas {
+		previa.tarefa.Liberar()
+		if err := j.importacoes.ExpirarPrevia(previa.tarefa.ID); err != nil {
+			log.Printf("Erro ao expirar prévia %d: %v", previa.tarefa.ID, err)
+		}
+	}
+}
+
+// CalcularPrevia lê o relatório da tarefa e calcula, sem gravar nada, o que a
+// importação faria. O resultado fica em import_jobs.previa e o arquivo fica
+// guardado até a confirmação ou a expiração; em erro a prévia termina como erro
+// e o arquivo é liberado.
+func (j *ImportacaoJob) CalcularPrevia(tarefa TarefaImportacao) {
+	// Um relatório malformado não pode derrubar a API
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Pânico ao calcular prévia %d: %v", tarefa.ID, p)
+			j.descartarPrevia(tarefa.ID, fmt.Sprintf("erro inesperado ao processar o arquivo: %v", p))
+		}
+	}()
+
+	log.Printf("Calculando prévia %d", tarefa.ID)
+
+	total, duplicados, resultado, err := j.calcularPrevia(tarefa)
+	if err != nil {
+		log.Printf("Erro ao calcular prévia %d: %v", tarefa.ID, err)
+		j.descartarPrevia(tarefa.ID, err.Error())
+		return
+	}
+
+	// Com j.mu obtido, a prévia só pode ser confirmada depois de gravada
+	expiraEm := time.Now().Add(validadePrevia)
+	resultado["expiraEm"] = expiraEm
+	j.mu.Lock()
+	err = j.importacoes.ConcluirPrevia(tarefa.ID, total, duplicados, resultado)
+	if previa, ok := j.previas[tarefa.ID]; ok && err == nil {
+		previa.calculando, previa.expiraEm = false, expiraEm
+		j.previas[tarefa.ID] = previa
+	}
+	j.mu.Unlock()
+
+	if err != nil {
+		j.descartarPrevia(tarefa.ID, "Erro ao registrar prévia da importação")
+	}
+}
+
+// calcularPrevia carrega o relatório e verifica as duplicatas (ou simula a
+// atualização). As listas de registros novos e de duplicatas trazem no máximo
+// limiteAmostraPrevia itens; os totais vêm em totalNovos e totalDuplicatas.
+func (j *ImportacaoJob) calcularPrevia(tarefa TarefaImportacao) (int, int, map[string]interface{}, error) {
+	carga, err := j.relatorios.IniciarCarga()
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("Erro ao processar dados: %v", err)
+	}
+	defer carga.Descartar()
+
+	diag, err := importacao.CarregarRelatorio(tarefa.Arquivo, tarefa.Perfil, tarefa.Estado, carga)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("Erro ao processar dados: %v", err)
+	}
+
+	resultado := map[string]interface{}{
+		"dryRun":               true,
+		"importId":             tarefa.ID,
+		"modo":                 tarefa.Opcoes.Modo,
+		"gravacaoParcial":      tarefa.Opcoes.GravacaoParcial,
+		"perfil":               tarefa.Perfil.Nome,
+		"totalRegistros":       carga.Total(),
+		"linhasIgnoradas":      diag.LinhasIgnoradas,
+		"totalLinhasIgnoradas": diag.TotalIgnoradas,
+		"avisos":               diag.Avisos,
+	}
+
+	if tarefa.Opcoes.Modo == repository.ModoAtualizacao {
+		simulacao, err := j.relatorios.SimularAtualizacao(carga, tarefa.Autor)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("Erro ao comparar registros existentes: %v", err)
+		}
+		if simulacao.Alteracoes == nil {
+			simulacao.Alteracoes = []repository.AlteracaoBO{}
+		}
+		resultado["inseridos"] = simulacao.Inseridos
+		resultado["atualizados"] = simulacao.Atualizados
+		resultado["duplicados"] = simulacao.Duplicados
+		resultado["alteracoes"] = simulacao.Alteracoes
+
+		log.Printf("Prévia %d (atualização): %d registros, %d novos, %d atualizados, %d sem alteração",
+			tarefa.ID, carga.Total(), simulacao.Inseridos, simulacao.Atualizados, simulacao.Duplicados)
+		return carga.Total(), simulacao.Duplicados, resultado, nil
+	}
+
+	verificacao, err := j.relatorios.VerificarDuplicatas(carga, limiteAmostraPrevia)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("Erro ao verificar duplicatas: %v", err)
+	}
+	resultado["totalNovos"] = verificacao.Novos
+	resultado["totalDuplicatas"] = verificacao.Duplicatas
+	resultado["registros"] = verificacao.AmostraNovos
+	resultado["duplicatas"] = verificacao.AmostraDuplicatas
+
+	log.Printf("Prévia %d: %d registros, %d duplicatas, %d linhas ignoradas",
+		tarefa.ID, carga.Total(), verificacao.Duplicatas, diag.TotalIgnoradas)
+	return carga.Total(), verificacao.Duplicatas, resultado, nil
+}
+
+// descartarPrevia libera o arquivo de uma prévia que não pôde ser calculada e a
+// encerra como erro
+func (j *ImportacaoJob) descartarPrevia(id int64, erro string) {
+	j.mu.Lock()
+	previa, ok := j.previas[id]
+	delete(j.previas, id)
+	j.mu.Unlock()
+
+	if ok {
+		previa.tarefa.Liberar()
+	}
+	j.importacoes.FinalizarImportacao(id, repository.ImportacaoErro, repository.TransacaoDesfeita, []string{erro})
+}
+
+// Processar grava a importação da tarefa na goroutine atual, registra o
+// resultado em import_jobs e libera o arquivo. Os workers o chamam para cada
+// tarefa da fila; a ingestão por pasta, diretamente.
+func (j *ImportacaoJob) Processar(tarefa TarefaImportacao) {
+	defer tarefa.Liberar()
+
+	// Um relatório malformado não pode derrubar a API
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Pânico ao processar importação %d: %v", tarefa.ID, p)
+			j.importacoes.FinalizarImportacao(tarefa.ID, repository.ImportacaoErro, repository.TransacaoDesfeita,
+				[]string{fmt.Sprintf("importação desfeita, nenhum registro gravado: erro inesperado ao processar o arquivo: %v", p)})
+		}
+	}()
+
+	log.Printf("Processando importação %d", tarefa.ID)
+
+	carga, err := j.relatorios.IniciarCarga()
+	if err != nil {
+		log.Printf("Erro ao iniciar carga da importação %d: %v", tarefa.ID, err)
+		j.importacoes.FinalizarImportacao(tarefa.ID, repository.ImportacaoErro, repository.TransacaoDesfeita,
+			[]string{"Erro ao processar dados: " + err.Error()})
+		return
+	}
+	defer carga.Descartar()
+
+	diag, err := importacao.CarregarRelatorio(tarefa.Arquivo, tarefa.Perfil, tarefa.Estado, carga)
+	if diag.TotalIgnoradas > 0 {
+		log.Printf("Importação %d: %d linhas ignoradas na leitura do relatório", tarefa.ID, diag.TotalIgnoradas)
+	}
+	if err != nil {
+		log.Printf("Erro ao ler relatório da importação %d: %v", tarefa.ID, err)
+		j.importacoes.FinalizarImportacao(tarefa.ID, repository.ImportacaoErro, repository.TransacaoDesfeita,
+			[]string{"Erro ao processar dados: " + err.Error()})
+		return
+	}
+
+	if err := j.importacoes.IniciarImportacao(tarefa.ID, carga.Total()); err != nil {
+		log.Printf("Erro ao iniciar importação %d: %v", tarefa.ID, err)
+	}
+
+	resultado, err := j.relatorios.GravarCarga(carga, tarefa.Autor, tarefa.ID, tarefa.Opcoes, func(processados, inseridos, duplicados int) {
+		if err := j.importacoes.AtualizarProgresso(tarefa.ID, processados, inseridos, duplicados); err != nil {
+			log.Printf("Erro ao atualizar progresso da importação %d: %v", tarefa.ID, err)
+		}
+	})
+
+	if err != nil {
+		// A transação foi desfeita: as contagens voltam a zero para não sugerir registros gravados
+		log.Printf("Erro ao inserir dados da importação %d, transação desfeita: %v", tarefa.ID, err)
+		j.importacoes.AtualizarProgresso(tarefa.ID, 0, 0, 0)
+		j.importacoes.FinalizarImportacao(tarefa.ID, repository.ImportacaoErro, repository.TransacaoDesfeita,
+			[]string{"Importação desfeita, nenhum registro foi gravado: " + err.Error()})
+		return
+	}
+
+	j.importacoes.AtualizarProgresso(tarefa.ID, carga.Total(), resultado.Inseridos, resultado.Duplicados)
+	if tarefa.Opcoes.Modo == repository.ModoAtualizacao {
+		j.importacoes.RegistrarAtualizacoes(tarefa.ID, resultado.Atualizados, resultado.Alteracoes)
+	}
+	// Gravação parcial em que todos os lotes com registros a gravar foram descartados:
+	// nada foi gravado, então a importação é um erro e não uma conclusão parcial
+	if len(resultado.LotesDescartados) > 0 && resultado.Inseridos == 0 && resultado.Atualizados == 0 {
+		log.Printf("Importação %d: todos os lotes foram descartados, nenhum registro gravado", tarefa.ID)
+		j.importacoes.FinalizarImportacao(tarefa.ID, repository.ImportacaoErro, repository.TransacaoDesfeita,
+			append([]string{"Importação desfeita, nenhum registro foi gravado: todos os lotes tiveram erro"}, resultado.LotesDescartados...))
+		return
+	}
+
+	transacao := repository.TransacaoConfirmada
+	if len(resultado.LotesDescartados) > 0 {
+		transacao = repository.TransacaoParcial
+	}
+	avisos := resultado.LotesDescartados
+	if resultado.NaoConvertidos > 0 {
+		avisos = append(avisos, fmt.Sprintf("%d datas ou coordenadas não reconhecidas foram gravadas só como texto", resultado.NaoConvertidos))
+	}
+	if len(resultado.Mantidos) > 0 {
+		avisos = append(avisos, avisoCamposMantidos(resultado.Mantidos))
+	}
+	j.importacoes.FinalizarImportacao(tarefa.ID, repository.ImportacaoConcluida, transacao, avisos)
+	log.Printf("Importação %d concluída (%s): %d inseridos, %d atualizados, %d duplicatas, %d lotes descartados",
+		tarefa.ID, transacao, resultado.Inseridos, resultado.Atualizados, resultado.Duplicados, len(resultado.LotesDescartados))
+}
+
+// avisoCamposMantidos descreve as linhas cujo BO ou pessoa já estava gravado com
+// outros dados, que foram mantidos
+func avisoCamposMantidos(mantidos map[string]int) string {
+	campos := make([]string, 0, len(mantidos))
+	for campo := range mantidos {
+		campos = append(campos, campo)
+	}
+	sort.Strings(campos)
+
+	partes := make([]string, len(campos))
+	for i, campo := range campos {
+		partes[i] = fmt.Sprintf("%s em %d linhas", campo, mantidos[campo])
+	}
+	return "BOs e pessoas já gravados mantiveram os dados da primeira gravação, diferentes dos do arquivo: " +
+		strings.Join(partes, ", ")
+}
+
+// ArquivoTemporario cria, no diretório temporário do processo, o arquivo que
+// guarda um upload até a tarefa ser liberada
+func (j *ImportacaoJob) ArquivoTemporario() (*os.File, error) {
+	return os.CreateTemp(j.diretorio, "relatorio-*")
+}
+
+// Encerrar apaga o diretório temporário do processo, com os uploads ainda na
+// fila ou em prévias guardadas. Deve ser chamado ao encerrar o processo.
+func (j *ImportacaoJob) Encerrar() {
+	if j.diretorio == "" {
+		return
+	}
+	if err := os.RemoveAll(j.diretorio); err != nil {
+		log.Printf("Erro ao remover diretório temporário %s: %v", j.diretorio, err)
+	}
+}
@@ -0,0 +1,240 @@
+package jobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fraudbase/internal/importacao"
+	"fraudbase/internal/repository"
+)
+
+// Pastas, dentro da pasta observada, para onde vão os arquivos já importados
+const (
+	PastaConcluidos = "done"
+	PastaFalhas     = "failed"
+)
+
+// PadraoIngestaoPasta é o nome dos relatórios exportados pelas unidades
+const PadraoIngestaoPasta = "resultado_da_pesquisa_bo_*.xlsx"
+
+// intervaloPadraoIngestao é usado quando o intervalo não é configurado
+const intervaloPadraoIngestao = time.Minute
+
+// ConfigIngestaoPasta descreve a pasta observada e em nome de quem os relatórios
+// deixados nela são importados
+type ConfigIngestaoPasta struct {
+	Pasta     string
+	Padrao    string // padrão do nome dos arquivos (filepath.Match)
+	Autor     repository.Autor
+	Estado    string // UF usada como sufixo do número do BO
+	Perfil    *repository.PerfilImportacao
+	Opcoes    repository.OpcoesImportacao
+	Intervalo time.Duration
+}
+
+// IngestaoPasta importa os relatórios deixados em uma pasta pelo mesmo
+// processamento do upload. Cada arquivo gera uma importação no histórico, em nome
+// do usuário de serviço, e depois vai para done/ ou failed/ com o id da
+// importação no início do nome.
+type IngestaoPasta struct {
+	config      ConfigIngestaoPasta
+	importacoes *repository.ImportacaoRepository
+	job         *ImportacaoJob
+
+	// vistos guarda o tamanho e a data de cada arquivo na última varredura: só é
+	// importado o arquivo que não mudou desde então (a cópia já terminou)
+	vistos map[string]estadoArquivo
+	// presos são os arquivos importados que não puderam ser movidos; não são
+	// importados de novo enquanto o processo estiver no ar
+	presos map[string]bool
+}
+
+type estadoArquivo struct {
+	tamanho    int64
+	modificado time.Time
+}
+
+// NewIngestaoPasta confere a pasta e cria as pastas de destino
+func NewIngestaoPasta(config ConfigIngestaoPasta, importacoes *repository.ImportacaoRepository, job *ImportacaoJob) (*IngestaoPasta, error) {
+	info, err := os.Stat(config.Pasta)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s não é uma pasta", config.Pasta)
+	}
+	if config.Padrao == "" {
+		config.Padrao = PadraoIngestaoPasta
+	}
+	if _, err := filepath.Match(config.Padrao, ""); err != nil {
+		return nil, fmt.Errorf("padrão de arquivo inválido %q: %v", config.Padrao, err)
+	}
+	if config.Intervalo <= 0 {
+		config.Intervalo = intervaloPadraoIngestao
+	}
+
+	for _, destino := range []string{PastaConcluidos, PastaFalhas} {
+		if err := os.MkdirAll(filepath.Join(config.Pasta, destino), 0o750); err != nil {
+			return nil, err
+		}
+	}
+
+	return &IngestaoPasta{
+		config:      config,
+		importacoes: importacoes,
+		job:         job,
+		vistos:      map[string]estadoArquivo{},
+		presos:      map[string]bool{},
+	}, nil
+}
+
+// Executar varre a pasta a cada intervalo até que parar seja fechado. A
+// importação em andamento termina antes do retorno.
+func (i *IngestaoPasta) Executar(parar <-chan struct{}) {
+	log.Printf("Observando %s (arquivos %s, a cada %v) em nome de %s", i.config.Pasta, i.config.Padrao, i.config.Intervalo, i.config.Autor.Login)
+
+	ticker := time.NewTicker(i.config.Intervalo)
+	defer ticker.Stop()
+	for {
+		i.Varrer(parar)
+		select {
+		case <-parar:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Varrer importa, um de cada vez, os arquivos da pasta que não mudaram desde a
+// varredura anterior
+func (i *IngestaoPasta) Varrer(parar <-chan struct{}) {
+	entradas, err := os.ReadDir(i.config.Pasta)
+	if err != nil {
+		log.Printf("Erro ao listar a pasta %s: %v", i.config.Pasta, err)
+		return
+	}
+
+	atuais := map[string]estadoArquivo{}
+	for _, entrada := range entradas {
+		nome := entrada.Name()
+		if entrada.IsDir() || i.presos[nome] {
+			continue
+		}
+		if ok, _ := filepath.Match(i.config.Padrao, nome); !ok {
+			continue
+		}
+		info, err := entrada.Info()
+		if err != nil {
+			continue // removido durante a varredura
+		}
+
+		estado := estadoArquivo{tamanho: info.Size(), modificado: info.ModTime()}
+		anterior, visto := i.vistos[nome]
+		if !visto || anterior != estado {
+			atuais[nome] = estado // ainda pode estar sendo copiado
+			continue
+		}
+
+		select {
+		case <-parar:
+			return
+		default:
+		}
+		if !i.importar(nome) {
+			atuais[nome] = estado
+		}
+	}
+	i.vistos = atuais
+}
+
+// importar cria a importação do arquivo, processa e move o arquivo conforme o
+// resultado. Retorna false se o arquivo deve ser tentado de novo.
+func (i *IngestaoPasta) importar(nome string) bool {
+	caminho := filepath.Join(i.config.Pasta, nome)
+
+	hash, err := sha256Arquivo(caminho)
+	if err != nil {
+		log.Printf("Erro ao ler %s: %v", caminho, err)
+		return false
+	}
+
+	origem := repository.OrigemImportacao{
+		NomeArquivo: nome,
+		SHA256:      hash,
+		Estado:      i.config.Estado,
+		Perfil:      i.config.Perfil.Nome,
+	}
+	id, err := i.importacoes.CriarImportacao(i.config.Autor, origem, i.config.Opcoes)
+	if err != nil {
+		log.Printf("Erro ao registrar importação de %s: %v", nome, err)
+		return false
+	}
+	log.Printf("Importação %d criada para o arquivo %s", id, caminho)
+
+	if erro := i.abrirEProcessar(id, caminho, nome); erro != "" {
+		i.importacoes.FinalizarImportacao(id, repository.ImportacaoErro, repository.TransacaoDesfeita, []string{erro})
+		log.Printf("Importação %d de %s recusada: %s", id, nome, erro)
+	}
+
+	destino := PastaFalhas
+	if imp, err := i.importacoes.GetImportacao(id); err != nil {
+		log.Printf("Erro ao consultar importação %d: %v", id, err)
+	} else if imp.Status == repository.ImportacaoConcluida {
+		destino = PastaConcluidos
+	}
+
+	novoCaminho := filepath.Join(i.config.Pasta, destino, fmt.Sprintf("%d_%s", id, nome))
+	if err := os.Rename(caminho, novoCaminho); err != nil {
+		log.Printf("Erro ao mover %s para %s/ (não será importado de novo até reiniciar): %v", nome, destino, err)
+		i.presos[nome] = true
+		return true
+	}
+	log.Printf("Arquivo %s movido para %s", nome, novoCaminho)
+	return true
+}
+
+// abrirEProcessar confere o arquivo como no upload e o processa. Retorna o motivo
+// da recusa de um arquivo que não chegou a ser processado.
+func (i *IngestaoPasta) abrirEProcessar(id int64, caminho, nome string) string {
+	perfil := i.config.Perfil
+	if !strings.Contains(nome, perfil.PadraoArquivo) {
+		return fmt.Sprintf("nome do arquivo não segue o padrão esperado pelo perfil '%s' (deve conter '%s')", perfil.Nome, perfil.PadraoArquivo)
+	}
+
+	rel, err := importacao.AbrirRelatorio(caminho, nome, perfil)
+	if err != nil {
+		return "Erro ao ler arquivo: " + err.Error()
+	}
+
+	// Sem Caminho, Liberar não apaga o arquivo, que ainda será movido
+	i.job.Processar(TarefaImportacao{
+		ID:      id,
+		Autor:   i.config.Autor,
+		Estado:  i.config.Estado,
+		Perfil:  perfil,
+		Opcoes:  i.config.Opcoes,
+		Arquivo: rel,
+	})
+	return ""
+}
+
+func sha256Arquivo(caminho string) (string, error) {
+	arquivo, err := os.Open(caminho)
+	if err != nil {
+		return "", err
+	}
+	defer arquivo.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, arquivo); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
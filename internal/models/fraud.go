@@ -9,7 +9,7 @@ type Fraud struct {
     DataRegistro string `json:"data_registro"`
 }
 
-// Envolvido representa um registro na tabela tabela_estelionato
+// Envolvido representa a participação de uma pessoa em um BO (linha de vw_envolvidos)
 type Envolvido struct {
     ID                    int    `json:"id"`
     NumeroBO              string `json:"numero_do_bo"`
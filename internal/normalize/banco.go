@@ -0,0 +1,123 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+)
+
+// bancoAliases mapeia o código COMPE de cada instituição para os nomes
+// (já sem acentos e em maiúsculas) com que ela costuma ser digitada nos BOs
+var bancoAliases = []struct {
+	codigo  string
+	nome    string
+	aliases []string
+}{
+	{"001", "BANCO DO BRASIL", []string{"BANCO DO BRASIL", "BB"}},
+	{"104", "CAIXA ECONOMICA FEDERAL", []string{"CAIXA ECONOMICA FEDERAL", "CAIXA ECONOMICA", "CAIXA", "CEF"}},
+	{"237", "BRADESCO", []string{"BRADESCO", "NEXT"}},
+	{"341", "ITAU UNIBANCO", []string{"ITAU UNIBANCO", "ITAU"}},
+	{"033", "SANTANDER", []string{"SANTANDER"}},
+	{"260", "NU PAGAMENTOS (NUBANK)", []string{"NU PAGAMENTOS", "NUBANK", "NU BANK"}},
+	{"077", "BANCO INTER", []string{"BANCO INTER", "INTER"}},
+	{"290", "PAGSEGURO (PAGBANK)", []string{"PAGSEGURO", "PAGBANK", "PAG SEGURO"}},
+	{"323", "MERCADO PAGO", []string{"MERCADO PAGO", "MERCADOPAGO"}},
+	{"380", "PICPAY", []string{"PICPAY", "PIC PAY"}},
+	{"336", "C6 BANK", []string{"C6 BANK", "C6"}},
+	{"208", "BTG PACTUAL", []string{"BTG PACTUAL", "BTG"}},
+	{"756", "SICOOB", []string{"SICOOB", "BANCOOB"}},
+	{"748", "SICREDI", []string{"SICREDI"}},
+	{"041", "BANRISUL", []string{"BANRISUL", "BANCO DO ESTADO DO RIO GRANDE DO SUL"}},
+	{"070", "BRB", []string{"BRB", "BANCO DE BRASILIA"}},
+	{"212", "BANCO ORIGINAL", []string{"BANCO ORIGINAL", "ORIGINAL"}},
+	{"197", "STONE", []string{"STONE"}},
+	{"403", "CORA", []string{"CORA"}},
+	{"655", "BANCO VOTORANTIM", []string{"BANCO VOTORANTIM", "VOTORANTIM", "NEON"}},
+	{"004", "BANCO DO NORDESTE", []string{"BANCO DO NORDESTE", "BNB"}},
+	{"037", "BANPARA", []string{"BANPARA", "BANCO DO ESTADO DO PARA"}},
+	{"047", "BANESE", []string{"BANESE"}},
+	{"021", "BANESTES", []string{"BANESTES"}},
+	{"003", "BANCO DA AMAZONIA", []string{"BANCO DA AMAZONIA", "BASA"}},
+}
+
+var (
+	codigoBancoRegex = regexp.MustCompile(`^0*([0-9]{1,3})\b`)
+	removerAcentos   = strings.NewReplacer(
+		"Á", "A", "À", "A", "Â", "A", "Ã", "A", "Ä", "A",
+		"É", "E", "È", "E", "Ê", "E", "Ë", "E",
+		"Í", "I", "Ì", "I", "Î", "I", "Ï", "I",
+		"Ó", "O", "Ò", "O", "Ô", "O", "Õ", "O", "Ö", "O",
+		"Ú", "U", "Ù", "U", "Û", "U", "Ü", "U",
+		"Ç", "C",
+	)
+	naoAlfanumerico = regexp.MustCompile(`[^A-Z0-9 ]+`)
+	sufixosBanco    = regexp.MustCompile(`\b(S ?A|LTDA|IP|SCFI|BANCO MULTIPLO)\b`)
+)
+
+// TextoSemAcentos converte um texto para maiúsculas, sem acentos e com espaços simples
+func TextoSemAcentos(valor string) string {
+	valor = removerAcentos.Replace(strings.ToUpper(strings.TrimSpace(valor)))
+	return strings.Join(strings.Fields(valor), " ")
+}
+
+// Banco retorna o nome canônico de uma instituição bancária, resolvendo
+// códigos COMPE ("001", "260 - Nu Pagamentos") e apelidos comuns ("BB", "Nubank")
+func Banco(valor string) string {
+	texto := TextoSemAcentos(valor)
+	if texto == "" {
+		return ""
+	}
+
+	// Código COMPE no início do texto
+	if m := codigoBancoRegex.FindStringSubmatch(texto); m != nil {
+		codigo := m[1]
+		for len(codigo) < 3 {
+			codigo = "0" + codigo
+		}
+		for _, banco := range bancoAliases {
+			if banco.codigo == codigo {
+				return banco.codigo + " - " + banco.nome
+			}
+		}
+	}
+
+	limpo := naoAlfanumerico.ReplaceAllString(texto, " ")
+	limpo = sufixosBanco.ReplaceAllString(limpo, " ")
+	limpo = " " + strings.Join(strings.Fields(limpo), " ") + " "
+
+	for _, banco := range bancoAliases {
+		for _, alias := range banco.aliases {
+			if strings.Contains(limpo, " "+alias+" ") {
+				return banco.codigo + " - " + banco.nome
+			}
+		}
+	}
+
+	return strings.TrimSpace(limpo)
+}
+
+// Agencia normaliza o número da agência bancária, descartando o dígito
+// verificador (quando separado por hífen) e zeros à esquerda
+func Agencia(valor string) string {
+	valor = strings.TrimSpace(valor)
+	if i := strings.IndexAny(valor, "-/"); i >= 0 {
+		valor = valor[:i]
+	}
+	return strings.TrimLeft(SomenteDigitos(valor), "0")
+}
+
+// ContaBancaria retorna a chave canônica de uma conta ("banco|agência|conta"),
+// igual para a mesma conta escrita de formas diferentes, ou "" sem número de conta
+func ContaBancaria(banco, agencia, conta string) string {
+	conta = Conta(conta)
+	if conta == "" {
+		return ""
+	}
+	return Banco(banco) + "|" + Agencia(agencia) + "|" + conta
+}
+
+// Conta normaliza o número da conta bancária, mantendo o dígito verificador
+// mas removendo separadores, pontuação e zeros à esquerda
+func Conta(valor string) string {
+	digitos := SomenteDigitos(strings.ReplaceAll(strings.ToUpper(valor), "X", "0"))
+	return strings.TrimLeft(digitos, "0")
+}
@@ -0,0 +1,132 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	naoDigitos = regexp.MustCompile(`[^0-9]`)
+	uuidRegex  = regexp.MustCompile(`^[0-9a-f]{8}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{4}-?[0-9a-f]{12}$`)
+)
+
+// SomenteDigitos remove todos os caracteres não numéricos de um valor
+func SomenteDigitos(valor string) string {
+	return naoDigitos.ReplaceAllString(valor, "")
+}
+
+// CPFValido verifica os dígitos verificadores de um CPF (apenas números)
+func CPFValido(cpf string) bool {
+	cpf = SomenteDigitos(cpf)
+	if len(cpf) != 11 {
+		return false
+	}
+
+	// Sequências repetidas (ex.: 111.111.111-11) passam no cálculo mas são inválidas
+	if strings.Count(cpf, cpf[:1]) == 11 {
+		return false
+	}
+
+	for _, tamanho := range []int{9, 10} {
+		soma := 0
+		for i := 0; i < tamanho; i++ {
+			soma += int(cpf[i]-'0') * (tamanho + 1 - i)
+		}
+		digito := (soma * 10) % 11
+		if digito == 10 {
+			digito = 0
+		}
+		if digito != int(cpf[tamanho]-'0') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CPF retorna os 11 dígitos de um CPF, recompondo os zeros à esquerda perdidos
+// quando o valor passou por uma célula numérica de planilha. Não confere os
+// dígitos verificadores (CPFs inválidos também precisam ser agrupados). Retorna
+// string vazia quando o valor não tem a quantidade de dígitos de um CPF.
+func CPF(valor string) string {
+	digitos := SomenteDigitos(valor)
+	if len(digitos) >= 9 && len(digitos) < 11 {
+		if completo := strings.Repeat("0", 11-len(digitos)) + digitos; CPFValido(completo) {
+			return completo
+		}
+	}
+	if len(digitos) != 11 {
+		return ""
+	}
+	return digitos
+}
+
+// Telefone converte um telefone brasileiro para o formato E.164 (+55DDNNNNNNNNN).
+// Retorna string vazia quando o valor não pode ser interpretado como telefone.
+func Telefone(valor string) string {
+	digitos := SomenteDigitos(valor)
+
+	// Remover prefixo de operadora/discagem nacional (ex.: 0XX, 0DD)
+	digitos = strings.TrimLeft(digitos, "0")
+
+	switch {
+	case len(digitos) == 10 || len(digitos) == 11:
+		return "+55" + digitos
+	case (len(digitos) == 12 || len(digitos) == 13) && strings.HasPrefix(digitos, "55"):
+		return "+" + digitos
+	}
+
+	return ""
+}
+
+// Tipos de chave PIX reconhecidos
+const (
+	PixCPF       = "cpf"
+	PixCNPJ      = "cnpj"
+	PixTelefone  = "telefone"
+	PixEmail     = "email"
+	PixAleatoria = "aleatoria"
+	PixOutro     = "outro"
+)
+
+// ChavePix identifica o tipo de uma chave PIX e retorna sua forma canônica,
+// permitindo agrupar a mesma chave escrita de formas diferentes
+// (ex.: "123.456.789-09" e "12345678909", "(69) 99999-0000" e "+5569999990000").
+func ChavePix(valor string) (tipo string, chave string) {
+	valor = strings.TrimSpace(valor)
+	if valor == "" {
+		return "", ""
+	}
+
+	// E-mail
+	if strings.Contains(valor, "@") {
+		return PixEmail, strings.ToLower(strings.ReplaceAll(valor, " ", ""))
+	}
+
+	// Chave aleatória (EVP) - UUID
+	minusculo := strings.ToLower(strings.ReplaceAll(valor, " ", ""))
+	if uuidRegex.MatchString(minusculo) {
+		semHifen := strings.ReplaceAll(minusculo, "-", "")
+		return PixAleatoria, semHifen[0:8] + "-" + semHifen[8:12] + "-" + semHifen[12:16] + "-" + semHifen[16:20] + "-" + semHifen[20:32]
+	}
+
+	digitos := SomenteDigitos(valor)
+	pareceTelefone := strings.HasPrefix(valor, "+") || strings.Contains(valor, "(")
+
+	if digitos != "" && !pareceTelefone {
+		switch len(digitos) {
+		case 11:
+			if CPFValido(digitos) {
+				return PixCPF, digitos
+			}
+		case 14:
+			return PixCNPJ, digitos
+		}
+	}
+
+	if telefone := Telefone(valor); telefone != "" {
+		return PixTelefone, telefone
+	}
+
+	return PixOutro, strings.ToUpper(strings.Join(strings.Fields(valor), " "))
+}
@@ -0,0 +1,119 @@
+package normalize
+
+import "testing"
+
+func TestCPFValido(t *testing.T) {
+	casos := []struct {
+		entrada string
+		valido  bool
+	}{
+		{"52998224725", true},
+		{"529.982.247-25", true},
+		{"01234567890", true},
+		{"52998224724", false},
+		{"00000000000", false},
+		{"11111111111", false},
+		{"5299822472", false},
+		{"529982247250", false},
+		{"", false},
+	}
+	for _, c := range casos {
+		if obtido := CPFValido(c.entrada); obtido != c.valido {
+			t.Errorf("CPFValido(%q) = %v; esperado %v", c.entrada, obtido, c.valido)
+		}
+	}
+}
+
+func TestCPF(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+	}{
+		{"529.982.247-25", "52998224725"},
+		{"52998224725", "52998224725"},
+		{"1234567890", "01234567890"},  // zero à esquerda perdido na planilha
+		{"123456797", "00123456797"},   // dois zeros perdidos
+		{"52998224724", "52998224724"}, // inválido, mas agrupável
+		{"1234567891", ""},             // 10 dígitos que não formam CPF válido
+		{"123", ""},
+		{"", ""},
+	}
+	for _, c := range casos {
+		if obtido := CPF(c.entrada); obtido != c.esperado {
+			t.Errorf("CPF(%q) = %q; esperado %q", c.entrada, obtido, c.esperado)
+		}
+	}
+}
+
+func TestTelefone(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+	}{
+		{"(69) 99999-1234", "+5569999991234"},
+		{"69 3223-1234", "+556932231234"},
+		{"+55 69 99999-1234", "+5569999991234"},
+		{"5569999991234", "+5569999991234"},
+		{"069999991234", "+5569999991234"},
+		{"+5569999991234", "+5569999991234"},
+		{"99999-1234", ""},
+		{"691234", ""},
+		{"", ""},
+	}
+	for _, c := range casos {
+		if obtido := Telefone(c.entrada); obtido != c.esperado {
+			t.Errorf("Telefone(%q) = %q; esperado %q", c.entrada, obtido, c.esperado)
+		}
+	}
+}
+
+func TestChavePix(t *testing.T) {
+	casos := []struct {
+		entrada string
+		tipo    string
+		chave   string
+	}{
+		{"529.982.247-25", PixCPF, "52998224725"},
+		{"12.345.678/0001-95", PixCNPJ, "12345678000195"},
+		{"(69) 99999-1234", PixTelefone, "+5569999991234"},
+		{"+55 69 99999-1234", PixTelefone, "+5569999991234"},
+		{" Fulano@Exemplo.com ", PixEmail, "fulano@exemplo.com"},
+		{"123E4567-E89B-12D3-A456-426614174000", PixAleatoria, "123e4567-e89b-12d3-a456-426614174000"},
+		{"123e4567e89b12d3a456426614174000", PixAleatoria, "123e4567-e89b-12d3-a456-426614174000"},
+		{"chave  qualquer", PixOutro, "CHAVE QUALQUER"},
+		{"  ", "", ""},
+	}
+	for _, c := range casos {
+		tipo, chave := ChavePix(c.entrada)
+		if tipo != c.tipo || chave != c.chave {
+			t.Errorf("ChavePix(%q) = %q, %q; esperado %q, %q", c.entrada, tipo, chave, c.tipo, c.chave)
+		}
+
+		// A forma canônica é gravada em pix_normalizado e o tipo é derivado dela
+		if chave == "" {
+			continue
+		}
+		if tipoNovo, chaveNova := ChavePix(chave); tipoNovo != tipo || chaveNova != chave {
+			t.Errorf("ChavePix(%q) não é idempotente: %q, %q", chave, tipoNovo, chaveNova)
+		}
+	}
+}
+
+func TestContaBancaria(t *testing.T) {
+	casos := []struct {
+		banco, agencia, conta string
+		esperado              string
+	}{
+		{"001", "1234-5", "12.345-6", "001 - BANCO DO BRASIL|1234|123456"},
+		{"Banco do Brasil S.A.", "01234", "0012345-6", "001 - BANCO DO BRASIL|1234|123456"},
+		{"Nubank", "0001", "9876543-X", "260 - NU PAGAMENTOS (NUBANK)|1|98765430"},
+		{"Itaú", "", "123", "341 - ITAU UNIBANCO||123"},
+		{"001", "1234", "", ""},
+		{"001", "1234", "000", ""},
+	}
+	for _, c := range casos {
+		if obtido := ContaBancaria(c.banco, c.agencia, c.conta); obtido != c.esperado {
+			t.Errorf("ContaBancaria(%q, %q, %q) = %q; esperado %q", c.banco, c.agencia, c.conta, obtido, c.esperado)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package normalize
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FusoBrasilia é o fuso usado para interpretar datas sem fuso explícito.
+// Usamos um fuso fixo para não depender do tzdata na imagem Alpine.
+var FusoBrasilia = time.FixedZone("BRT", -3*60*60)
+
+// layoutsData lista os formatos de data encontrados nos BOs importados e cadastrados
+var layoutsData = []string{
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02/01/2006",
+	"2/1/2006 15:04:05",
+	"2/1/2006 15:04",
+	"2/1/2006",
+	"02-01-2006 15:04:05",
+	"02-01-2006",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Data interpreta uma data nos formatos brasileiros (DD/MM/AAAA, com ou sem hora)
+// e ISO (AAAA-MM-DD). Retorna false quando o valor está vazio ou não é reconhecido.
+func Data(valor string) (time.Time, bool) {
+	valor = strings.Join(strings.Fields(valor), " ")
+	if valor == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range layoutsData {
+		if t, err := time.ParseInLocation(layout, valor, FusoBrasilia); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Valor interpreta um valor monetário em formato brasileiro ("R$ 1.234,56",
+// "1234,56", "1.500") ou com ponto decimal ("1234.56").
+func Valor(valor string) (float64, bool) {
+	valor = strings.ToUpper(strings.TrimSpace(valor))
+	valor = strings.TrimPrefix(valor, "R$")
+	valor = strings.ReplaceAll(valor, " ", "")
+	if valor == "" {
+		return 0, false
+	}
+
+	switch {
+	case strings.Contains(valor, ","):
+		// Formato brasileiro: ponto como separador de milhar e vírgula decimal
+		valor = strings.ReplaceAll(valor, ".", "")
+		valor = strings.Replace(valor, ",", ".", 1)
+	case strings.Count(valor, ".") > 1:
+		valor = strings.ReplaceAll(valor, ".", "")
+	case strings.Count(valor, ".") == 1 && len(valor)-strings.Index(valor, ".") == 4:
+		// "1.500" é mil e quinhentos, não um e meio
+		valor = strings.ReplaceAll(valor, ".", "")
+	}
+
+	numero, err := strconv.ParseFloat(valor, 64)
+	if err != nil || numero < 0 {
+		return 0, false
+	}
+
+	return numero, true
+}
+
+// Coordenada interpreta latitude/longitude aceitando vírgula como separador decimal
+func Coordenada(valor string) (float64, bool) {
+	valor = strings.ReplaceAll(strings.TrimSpace(valor), ",", ".")
+	if valor == "" {
+		return 0, false
+	}
+
+	numero, err := strconv.ParseFloat(valor, 64)
+	if err != nil || numero < -180 || numero > 180 {
+		return 0, false
+	}
+
+	return numero, true
+}
@@ -0,0 +1,581 @@
+package repository
+
+import (
+	"database/sql"
+	"fraudbase/internal/models"
+	"fraudbase/internal/normalize"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// lockAneisFraude é a chave do advisory lock que impede dois recálculos simultâneos
+// (por exemplo, duas réplicas da API executando o job ao mesmo tempo)
+const lockAneisFraude = 7301001
+
+// AneisRepository gerencia a detecção e consulta de anéis de fraude
+type AneisRepository struct {
+	db *sql.DB
+}
+
+// NewAneisRepository cria um novo repositório de anéis de fraude
+func NewAneisRepository(db *sql.DB) *AneisRepository {
+	return &AneisRepository{db: db}
+}
+
+// AnelFraude representa um grupo de suspeitos ligados por identificadores compartilhados
+type AnelFraude struct {
+	RingID              int        `json:"ring_id"`
+	QuantidadeRegistros int        `json:"quantidade_registros"`
+	QuantidadePessoas   int        `json:"quantidade_pessoas"`
+	QuantidadeBOs       int        `json:"quantidade_bos"`
+	ValorTotal          float64    `json:"valor_total"`
+	Delegacias          []string   `json:"delegacias"`
+	DataInicio          *time.Time `json:"data_inicio"`
+	DataFim             *time.Time `json:"data_fim"`
+	CalculadoEm         time.Time  `json:"calculado_em"`
+}
+
+// unionFind implementa conjuntos disjuntos com compressão de caminho
+type unionFind struct {
+	pai []int
+}
+
+func novoUnionFind(n int) *unionFind {
+	uf := &unionFind{pai: make([]int, n)}
+	for i := range uf.pai {
+		uf.pai[i] = i
+	}
+	return uf
+}
+
+func (uf *unionFind) raiz(i int) int {
+	for uf.pai[i] != i {
+		uf.pai[i] = uf.pai[uf.pai[i]]
+		i = uf.pai[i]
+	}
+	return i
+}
+
+func (uf *unionFind) unir(a, b int) {
+	ra, rb := uf.raiz(a), uf.raiz(b)
+	if ra != rb {
+		uf.pai[ra] = rb
+	}
+}
+
+// componenteAnel acumula os dados de um componente conexo durante o cálculo
+type componenteAnel struct {
+	registros []int
+	pessoas   map[string]bool
+	bos       map[string]bool
+}
+
+// maxSuspeitosPorIdentificador é o máximo de suspeitos distintos com o mesmo
+// identificador para que ele ainda vincule os suspeitos em um anel
+const maxSuspeitosPorIdentificador = 50
+
+// redesNaoPublicas são as faixas de IP privadas, de CGNAT e de uso local, que não
+// identificam quem acessou
+var redesNaoPublicas = func() []*net.IPNet {
+	var redes []*net.IPNet
+	for _, cidr := range []string{"100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15"} {
+		_, rede, _ := net.ParseCIDR(cidr)
+		redes = append(redes, rede)
+	}
+	return redes
+}()
+
+// ipPublico retorna a forma canônica do endereço IP, ou "" se o valor não é um IP
+// ou é um endereço privado, de CGNAT, de loopback, não especificado ou de uso local
+func ipPublico(valor string) string {
+	ip := net.ParseIP(strings.TrimSpace(valor))
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return ""
+	}
+	for _, rede := range redesNaoPublicas {
+		if rede.Contains(ip) {
+			return ""
+		}
+	}
+	return ip.String()
+}
+
+// RecalcularAneis calcula os componentes conexos de suspeitos ligados por CPF,
+// telefone, chave PIX, conta bancária ou endereço IP e regrava as tabelas
+// aneis_fraude e aneis_fraude_registros. Apenas componentes com pelo menos duas
+// pessoas distintas são considerados anéis. Chaves PIX sem formato reconhecido, IPs
+// que não são públicos e identificadores presentes em mais de
+// maxSuspeitosPorIdentificador suspeitos não vinculam registros. Retorna a quantidade de anéis gravados.
+func (r *AneisRepository) RecalcularAneis() (int, error) {
+	log.Println("Iniciando cálculo de anéis de fraude...")
+	inicio := time.Now()
+
+	query := `
+	SELECT id,
+		COALESCE(numero_do_bo, '') as numero_do_bo,
+		COALESCE(nomecompleto, '') as nomecompleto,
+		COALESCE(cpf, '') as cpf,
+		COALESCE(nomedamae, '') as nomedamae,
+		COALESCE(telefone_envolvido, '') as telefone_envolvido,
+		COALESCE(pix_utilizado, '') as pix_utilizado,
+		COALESCE(instituicao_bancaria, '') as instituicao_bancaria,
+		COALESCE(numero_agencia_bancaria, '') as numero_agencia_bancaria,
+		COALESCE(numero_conta_bancaria, '') as numero_conta_bancaria,
+		COALESCE(endereco_ip, '') as endereco_ip
+	FROM vw_envolvidos
+	WHERE tipo_envolvido = 'Suposto Autor/infrator'
+	ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		log.Printf("Erro ao consultar suspeitos para cálculo de anéis: %v", err)
+		return 0, err
+	}
+
+	var registros []registroGrafo
+	var identificadores []identificadoresRegistro
+	var ips []string
+	for rows.Next() {
+		var reg registroGrafo
+		var ip string
+		if err := rows.Scan(&reg.id, &reg.numeroBO, &reg.nome, &reg.cpf, &reg.nomeMae, &reg.telefone,
+			&reg.pix, &reg.banco, &reg.agencia, &reg.conta, &ip); err != nil {
+			rows.Close()
+			log.Printf("Erro ao processar resultado: %v", err)
+			return 0, err
+		}
+		registros = append(registros, reg)
+		identificadores = append(identificadores, identificar(reg))
+		ips = append(ips, strings.TrimSpace(ip))
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		log.Printf("Erro após iteração: %v", err)
+		return 0, err
+	}
+	rows.Close()
+
+	// Chaves de vínculo de cada registro; PIX sem formato reconhecido e IPs que não
+	// são públicos costumam ser preenchimentos ("NÃO INFORMADO", "0.0.0.0") ou
+	// endereços compartilhados, e não identificam ninguém
+	chaves := make([][]string, len(registros))
+	pessoasPorChave := make(map[string]map[string]bool)
+	descartadas := 0
+	for i, ids := range identificadores {
+		if ids.cpf != "" {
+			chaves[i] = append(chaves[i], "cpf:"+ids.cpf)
+		}
+		if ids.telefone != "" {
+			chaves[i] = append(chaves[i], "telefone:"+ids.telefone)
+		}
+		if ids.pix != "" {
+			if ids.tipoPix == normalize.PixOutro {
+				descartadas++
+			} else {
+				chaves[i] = append(chaves[i], "pix:"+ids.pix)
+			}
+		}
+		if ids.conta != "" {
+			chaves[i] = append(chaves[i], "conta:"+ids.conta)
+		}
+		if ips[i] != "" {
+			if ip := ipPublico(ips[i]); ip != "" {
+				chaves[i] = append(chaves[i], "ip:"+ip)
+			} else {
+				descartadas++
+			}
+		}
+
+		pessoa := ids.pessoa
+		if pessoa == "" {
+			pessoa = "registro:" + strconv.Itoa(registros[i].id)
+		}
+		for _, chave := range chaves[i] {
+			if pessoasPorChave[chave] == nil {
+				pessoasPorChave[chave] = make(map[string]bool)
+			}
+			pessoasPorChave[chave][pessoa] = true
+		}
+	}
+
+	// Um identificador presente em muitos suspeitos (NAT de operadora, telefone ou
+	// conta de preenchimento) juntaria grupos sem relação em um único anel
+	comuns := 0
+	for _, pessoas := range pessoasPorChave {
+		if len(pessoas) > maxSuspeitosPorIdentificador {
+			comuns++
+		}
+	}
+	if descartadas > 0 || comuns > 0 {
+		log.Printf("Anéis de fraude: %d valores de PIX/IP descartados (não identificam o suspeito) e %d identificadores ignorados por aparecerem em mais de %d suspeitos",
+			descartadas, comuns, maxSuspeitosPorIdentificador)
+	}
+
+	// Unir registros que compartilham algum identificador
+	uf := novoUnionFind(len(registros))
+	primeiroPorChave := make(map[string]int)
+	for i := range registros {
+		for _, chave := range chaves[i] {
+			if len(pessoasPorChave[chave]) > maxSuspeitosPorIdentificador {
+				continue
+			}
+			if j, exists := primeiroPorChave[chave]; exists {
+				uf.unir(i, j)
+			} else {
+				primeiroPorChave[chave] = i
+			}
+		}
+	}
+
+	componentes := make(map[int]*componenteAnel)
+	for i, ids := range identificadores {
+		raiz := uf.raiz(i)
+		comp, exists := componentes[raiz]
+		if !exists {
+			comp = &componenteAnel{pessoas: make(map[string]bool), bos: make(map[string]bool)}
+			componentes[raiz] = comp
+		}
+		comp.registros = append(comp.registros, registros[i].id)
+		if ids.pessoa != "" {
+			comp.pessoas[ids.pessoa] = true
+		}
+		if ids.bo != "" {
+			comp.bos[ids.bo] = true
+		}
+	}
+
+	var aneis []*componenteAnel
+	for _, comp := range componentes {
+		if len(comp.pessoas) >= 2 {
+			aneis = append(aneis, comp)
+		}
+	}
+
+	resumos, err := r.resumirAneis(aneis)
+	if err != nil {
+		return 0, err
+	}
+
+	// Ranking: maiores grupos primeiro; ring_id reflete a posição no ranking
+	ordem := make([]int, len(aneis))
+	for i := range ordem {
+		ordem[i] = i
+	}
+	sort.Slice(ordem, func(a, b int) bool {
+		ra, rb := resumos[ordem[a]], resumos[ordem[b]]
+		if ra.QuantidadePessoas != rb.QuantidadePessoas {
+			return ra.QuantidadePessoas > rb.QuantidadePessoas
+		}
+		if ra.QuantidadeBOs != rb.QuantidadeBOs {
+			return ra.QuantidadeBOs > rb.QuantidadeBOs
+		}
+		if ra.ValorTotal != rb.ValorTotal {
+			return ra.ValorTotal > rb.ValorTotal
+		}
+		return aneis[ordem[a]].registros[0] < aneis[ordem[b]].registros[0]
+	})
+
+	if err := r.gravarAneis(aneis, resumos, ordem); err != nil {
+		return 0, err
+	}
+
+	log.Printf("Cálculo de anéis de fraude concluído: %d anéis a partir de %d registros de suspeitos (%v)",
+		len(aneis), len(registros), time.Since(inicio))
+	return len(aneis), nil
+}
+
+// resumirAneis calcula valor total, delegacias e período de cada anel a partir de
+// todos os registros dos BOs envolvidos (o valor costuma estar na linha da vítima)
+func (r *AneisRepository) resumirAneis(aneis []*componenteAnel) ([]AnelFraude, error) {
+	resumos := make([]AnelFraude, len(aneis))
+
+	// Um BO pode ter suspeitos em anéis diferentes (sem identificador em comum)
+	aneisPorBO := make(map[string][]int)
+	var bos []string
+	for i, comp := range aneis {
+		resumos[i] = AnelFraude{
+			QuantidadeRegistros: len(comp.registros),
+			QuantidadePessoas:   len(comp.pessoas),
+			QuantidadeBOs:       len(comp.bos),
+			Delegacias:          []string{},
+		}
+		for bo := range comp.bos {
+			if len(aneisPorBO[bo]) == 0 {
+				bos = append(bos, bo)
+			}
+			aneisPorBO[bo] = append(aneisPorBO[bo], i)
+		}
+	}
+
+	if len(bos) == 0 {
+		return resumos, nil
+	}
+
+	query := `
+	SELECT numero_do_bo,
+		valor_numerico,
+		data_fato_ts,
+		COALESCE(delegacia_responsavel, '') as delegacia_responsavel
+	FROM vw_envolvidos
+	WHERE numero_do_bo = ANY($1)`
+
+	rows, err := r.db.Query(query, pq.Array(bos))
+	if err != nil {
+		log.Printf("Erro ao consultar BOs dos anéis de fraude: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	// O mesmo valor costuma se repetir em todas as linhas do BO; usar o maior por BO
+	valorPorBO := make(map[string]float64)
+	delegacias := make([]map[string]bool, len(aneis))
+	for i := range delegacias {
+		delegacias[i] = make(map[string]bool)
+	}
+
+	for rows.Next() {
+		var bo, delegacia string
+		var valor sql.NullFloat64
+		var dataFato sql.NullTime
+		if err := rows.Scan(&bo, &valor, &dataFato, &delegacia); err != nil {
+			log.Printf("Erro ao processar resultado: %v", err)
+			return nil, err
+		}
+		if valor.Valid && valor.Float64 > valorPorBO[bo] {
+			valorPorBO[bo] = valor.Float64
+		}
+
+		delegacia = strings.TrimSpace(delegacia)
+		for _, i := range aneisPorBO[bo] {
+			if delegacia != "" {
+				delegacias[i][delegacia] = true
+			}
+
+			if dataFato.Valid {
+				data := dataFato.Time
+				if resumos[i].DataInicio == nil || data.Before(*resumos[i].DataInicio) {
+					resumos[i].DataInicio = &data
+				}
+				if resumos[i].DataFim == nil || data.After(*resumos[i].DataFim) {
+					resumos[i].DataFim = &data
+				}
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro após iteração: %v", err)
+		return nil, err
+	}
+
+	for bo, valor := range valorPorBO {
+		for _, i := range aneisPorBO[bo] {
+			resumos[i].ValorTotal += valor
+		}
+	}
+
+	for i := range resumos {
+		resumos[i].ValorTotal = math.Round(resumos[i].ValorTotal*100) / 100
+		for delegacia := range delegacias[i] {
+			resumos[i].Delegacias = append(resumos[i].Delegacias, delegacia)
+		}
+		sort.Strings(resumos[i].Delegacias)
+	}
+
+	return resumos, nil
+}
+
+// gravarAneis substitui o resultado anterior em uma única transação
+func (r *AneisRepository) gravarAneis(aneis []*componenteAnel, resumos []AnelFraude, ordem []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var obtido bool
+	if err := tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", lockAneisFraude).Scan(&obtido); err != nil {
+		return err
+	}
+	if !obtido {
+		log.Println("Outro processo já está gravando anéis de fraude; resultado descartado")
+		return nil
+	}
+
+	if _, err := tx.Exec("DELETE FROM aneis_fraude"); err != nil {
+		log.Printf("Erro ao limpar anéis de fraude: %v", err)
+		return err
+	}
+
+	stmtAnel, err := tx.Prepare(`
+		INSERT INTO aneis_fraude (
+			ring_id, quantidade_registros, quantidade_pessoas, quantidade_bos,
+			valor_total, delegacias, data_inicio, data_fim
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		return err
+	}
+	defer stmtAnel.Close()
+
+	for posicao, i := range ordem {
+		resumo := resumos[i]
+		if _, err := stmtAnel.Exec(posicao+1, resumo.QuantidadeRegistros, resumo.QuantidadePessoas,
+			resumo.QuantidadeBOs, resumo.ValorTotal, pq.Array(resumo.Delegacias),
+			resumo.DataInicio, resumo.DataFim); err != nil {
+			log.Printf("Erro ao inserir anel de fraude: %v", err)
+			return err
+		}
+	}
+
+	stmtRegistros, err := tx.Prepare(pq.CopyIn("aneis_fraude_registros", "registro_id", "ring_id"))
+	if err != nil {
+		return err
+	}
+
+	for posicao, i := range ordem {
+		for _, registroID := range aneis[i].registros {
+			if _, err := stmtRegistros.Exec(registroID, posicao+1); err != nil {
+				stmtRegistros.Close()
+				return err
+			}
+		}
+	}
+
+	if _, err := stmtRegistros.Exec(); err != nil {
+		stmtRegistros.Close()
+		log.Printf("Erro ao gravar registros dos anéis de fraude: %v", err)
+		return err
+	}
+	if err := stmtRegistros.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAneis retorna o ranking paginado dos anéis de fraude
+func (r *AneisRepository) GetAneis(page int, limit int) ([]AnelFraude, int, error) {
+	var totalCount int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM aneis_fraude").Scan(&totalCount); err != nil {
+		log.Printf("Erro ao contar anéis de fraude: %v", err)
+		return nil, 0, err
+	}
+
+	query := `
+	SELECT ring_id, quantidade_registros, quantidade_pessoas, quantidade_bos,
+		valor_total, delegacias, data_inicio, data_fim, calculado_em
+	FROM aneis_fraude
+	ORDER BY ring_id
+	OFFSET $1 LIMIT $2`
+
+	offset := (page - 1) * limit
+	rows, err := r.db.Query(query, offset, limit)
+	if err != nil {
+		log.Printf("Erro ao consultar anéis de fraude: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	aneis := []AnelFraude{}
+	for rows.Next() {
+		anel, err := scanAnel(rows)
+		if err != nil {
+			log.Printf("Erro ao processar resultado: %v", err)
+			return nil, 0, err
+		}
+		aneis = append(aneis, anel)
+	}
+
+	return aneis, totalCount, rows.Err()
+}
+
+// GetAnelByID retorna um anel de fraude e os registros que o compõem
+func (r *AneisRepository) GetAnelByID(ringID int) (AnelFraude, []models.Envolvido, error) {
+	query := `
+	SELECT ring_id, quantidade_registros, quantidade_pessoas, quantidade_bos,
+		valor_total, delegacias, data_inicio, data_fim, calculado_em
+	FROM aneis_fraude
+	WHERE ring_id = $1`
+
+	anel, err := scanAnel(r.db.QueryRow(query, ringID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return AnelFraude{}, nil, ErrNotFound
+		}
+		log.Printf("Erro ao buscar anel de fraude: %v", err)
+		return AnelFraude{}, nil, err
+	}
+
+	membrosQuery := `
+	SELECT t.id,
+		COALESCE(t.numero_do_bo, '') as numero_do_bo,
+		COALESCE(t.tipo_envolvido, '') as tipo_envolvido,
+		COALESCE(t.nomecompleto, '') as nomecompleto,
+		COALESCE(t.cpf, '') as cpf,
+		COALESCE(t.telefone_envolvido, '') as telefone_envolvido,
+		COALESCE(t.pix_utilizado, '') as pix_utilizado,
+		COALESCE(t.instituicao_bancaria, '') as instituicao_bancaria,
+		COALESCE(t.numero_agencia_bancaria, '') as numero_agencia_bancaria,
+		COALESCE(t.numero_conta_bancaria, '') as numero_conta_bancaria,
+		COALESCE(t.endereco_ip, '') as endereco_ip,
+		COALESCE(t.data_fato, '') as data_fato,
+		COALESCE(t.delegacia_responsavel, '') as delegacia_responsavel
+	FROM aneis_fraude_registros a
+	JOIN vw_envolvidos t ON t.id = a.registro_id
+	WHERE a.ring_id = $1
+	ORDER BY t.numero_do_bo, t.id`
+
+	rows, err := r.db.Query(membrosQuery, ringID)
+	if err != nil {
+		log.Printf("Erro ao consultar registros do anel de fraude: %v", err)
+		return AnelFraude{}, nil, err
+	}
+	defer rows.Close()
+
+	membros := []models.Envolvido{}
+	for rows.Next() {
+		var e models.Envolvido
+		if err := rows.Scan(&e.ID, &e.NumeroBO, &e.TipoEnvolvido, &e.NomeCompleto, &e.CPF,
+			&e.TelefoneEnvolvido, &e.PixUtilizado, &e.InstituicaoBancaria, &e.NumeroAgenciaBancaria,
+			&e.NumeroContaBancaria, &e.EnderecoIP, &e.DataFato, &e.DelegaciaResponsavel); err != nil {
+			log.Printf("Erro ao processar resultado: %v", err)
+			return AnelFraude{}, nil, err
+		}
+		membros = append(membros, e)
+	}
+
+	return anel, membros, rows.Err()
+}
+
+// scanner abstrai *sql.Row e *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAnel(s scanner) (AnelFraude, error) {
+	var anel AnelFraude
+	var inicio, fim sql.NullTime
+	err := s.Scan(&anel.RingID, &anel.QuantidadeRegistros, &anel.QuantidadePessoas, &anel.QuantidadeBOs,
+		&anel.ValorTotal, pq.Array(&anel.Delegacias), &inicio, &fim, &anel.CalculadoEm)
+	if err != nil {
+		return AnelFraude{}, err
+	}
+	if inicio.Valid {
+		anel.DataInicio = &inicio.Time
+	}
+	if fim.Valid {
+		anel.DataFim = &fim.Time
+	}
+	if anel.Delegacias == nil {
+		anel.Delegacias = []string{}
+	}
+	return anel, nil
+}
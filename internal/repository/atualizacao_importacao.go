@@ -0,0 +1,475 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// No modo de atualização cada linha do relatório é comparada ao participante já
+// cadastrado no mesmo BO (mesma pessoa, pela chave de identidade ou, sem ela, pelo
+// nome, e mesmo papel). Os campos alterados são gravados e entram no histórico; os
+// participantes que não existem são inseridos como na inclusão. Campos vazios no
+// arquivo não apagam valores existentes. Os campos que identificam a pessoa (nome,
+// CPF, mãe e nascimento) não são alterados, pois formam a chave de identidade.
+// Para quem não é administrador, dados compartilhados com registros de outra
+// unidade não são alterados e as linhas entram nas rejeições.
+
+// AlteracaoBO resume o que uma importação em modo de atualização mudou em um BO
+type AlteracaoBO struct {
+	NumeroBO               string                    `json:"numero_do_bo"`
+	Novo                   bool                      `json:"novo"` // o BO não existia
+	Ocorrencia             map[string]AlteracaoCampo `json:"ocorrencia,omitempty"`
+	ParticipantesNovos     []ParticipanteImportado   `json:"participantes_novos,omitempty"`
+	ParticipantesAlterados []ParticipanteImportado   `json:"participantes_alterados,omitempty"`
+}
+
+// ParticipanteImportado identifica um participante inserido ou alterado pela importação
+type ParticipanteImportado struct {
+	RegistroID    int                       `json:"registro_id,omitempty"`
+	NomeCompleto  string                    `json:"nomecompleto"`
+	TipoEnvolvido string                    `json:"tipo_envolvido"`
+	Alteracoes    map[string]AlteracaoCampo `json:"alteracoes,omitempty"`
+}
+
+// sqlPrimeiraLinhaBO é a linha da staging que representa a ocorrência de cada BO
+// (a primeira do arquivo), como na inclusão
+const sqlPrimeiraLinhaBO = `(
+	SELECT DISTINCT ON (numero_do_bo) * FROM staging_importacao
+	WHERE numero_do_bo <> '' AND NOT participante_excluido
+	ORDER BY numero_do_bo, ordem
+)`
+
+// etapasCompararStaging identificam os participantes já cadastrados e registram em
+// alteracoes_importacao os campos que mudaram (participacao_id NULL para os campos
+// da ocorrência)
+var etapasCompararStaging = []string{
+	// Prefere o participante ativo; um excluído casado não volta nem é alterado
+	`UPDATE staging_importacao
+	SET participacao_id = m.id, ocorrencia_id = m.ocorrencia_id, pessoa_id = m.pessoa_id,
+		participante_excluido = m.excluido_em IS NOT NULL
+	FROM (
+		SELECT DISTINCT ON (s.ordem) s.ordem, pa.id, pa.ocorrencia_id, pa.pessoa_id, pa.excluido_em
+		FROM staging_importacao s
+		JOIN ocorrencias o ON o.numero_do_bo = s.numero_do_bo
+		JOIN participacoes pa ON pa.ocorrencia_id = o.id AND COALESCE(pa.tipo_envolvido, '') = s.tipo_envolvido
+		JOIN pessoas pe ON pe.id = pa.pessoa_id
+		WHERE ` + sqlMesmaPessoaStaging + `
+		ORDER BY s.ordem, pa.excluido_em IS NOT NULL, pa.id
+	) m
+	WHERE m.ordem = staging_importacao.ordem`,
+
+	`CREATE TEMP TABLE alteracoes_importacao (
+		numero_do_bo TEXT NOT NULL,
+		participacao_id INTEGER,
+		campo TEXT NOT NULL,
+		antes TEXT,
+		depois TEXT NOT NULL
+	) ON COMMIT DROP`,
+
+	`INSERT INTO alteracoes_importacao (numero_do_bo, participacao_id, campo, antes, depois)
+	SELECT o.numero_do_bo, NULL, c.campo, c.antes, c.depois
+	FROM ` + sqlPrimeiraLinhaBO + ` s
+	JOIN ocorrencias o ON o.numero_do_bo = s.numero_do_bo
+	CROSS JOIN LATERAL (VALUES
+		('delegacia_responsavel', o.delegacia_responsavel::TEXT, s.delegacia_responsavel),
+		('situacao', o.situacao::TEXT, s.situacao),
+		('natureza', o.natureza, s.natureza),
+		('data_fato', o.data_fato::TEXT, s.data_fato),
+		('cep_fato', o.cep_fato::TEXT, s.cep_fato),
+		('latitude_fato', o.latitude_fato::TEXT, s.latitude_fato),
+		('longitude_fato', o.longitude_fato::TEXT, s.longitude_fato),
+		('logradouro_fato', o.logradouro_fato::TEXT, s.logradouro_fato),
+		('numerocasa_fato', o.numerocasa_fato::TEXT, s.numerocasa_fato),
+		('bairro_fato', o.bairro_fato::TEXT, s.bairro_fato),
+		('municipio_fato', o.municipio_fato::TEXT, s.municipio_fato),
+		('pais_fato', o.pais_fato::TEXT, s.pais_fato),
+		('relato_historico', o.relato_historico, s.relato_historico)
+	) AS c(campo, antes, depois)
+	WHERE c.depois <> '' AND c.depois IS DISTINCT FROM c.antes`,
+
+	`INSERT INTO alteracoes_importacao (numero_do_bo, participacao_id, campo, antes, depois)
+	SELECT s.numero_do_bo, s.participacao_id, c.campo, c.antes, c.depois
+	FROM staging_importacao s
+	JOIN participacoes pa ON pa.id = s.participacao_id
+	JOIN pessoas pe ON pe.id = pa.pessoa_id
+	CROSS JOIN LATERAL (VALUES
+		('telefone_envolvido', pa.telefone_envolvido::TEXT, s.telefone_envolvido),
+		('nacionalidade', pe.nacionalidade::TEXT, s.nacionalidade),
+		('naturalidade', pe.naturalidade::TEXT, s.naturalidade),
+		('uf_envolvido', pe.uf_envolvido::TEXT, s.uf_envolvido),
+		('sexo_envolvido', pe.sexo_envolvido::TEXT, s.sexo_envolvido)
+	) AS c(campo, antes, depois)
+	WHERE NOT s.participante_excluido AND c.depois <> '' AND c.depois IS DISTINCT FROM c.antes`,
+}
+
+// sqlOutraUnidade (formatada com o apelido da tabela participacoes) é verdadeira
+// quando a participação não foi cadastrada pela unidade do usuário $1; sem
+// unidade de cadastro conta como de outra unidade
+const sqlOutraUnidade = `(TRIM(COALESCE(%[1]s.unidade_cadastro, '')) = ''
+		OR UPPER(TRIM(%[1]s.unidade_cadastro)) IS DISTINCT FROM
+			(SELECT UPPER(TRIM(COALESCE(unidade_policial, ''))) FROM usuarios WHERE id = $1))`
+
+// etapasRecusarOutrasUnidades retiram de alteracoes_importacao, para quem não é
+// administrador, as alterações em dados compartilhados com registros de outra
+// unidade, como na alteração manual: a ocorrência, se algum participante do BO é
+// de outra unidade; o telefone, se o participante é de outra unidade; e os dados
+// da pessoa, se ela tem registros de outra unidade. As linhas recusadas são
+// anotadas em rejeicoes_carga. $1 é o id do usuário.
+var etapasRecusarOutrasUnidades = []string{
+	`WITH recusadas AS (
+		DELETE FROM alteracoes_importacao a
+		WHERE a.participacao_id IS NULL AND EXISTS (
+			SELECT 1 FROM ocorrencias o
+			JOIN participacoes pa ON pa.ocorrencia_id = o.id
+			WHERE o.numero_do_bo = a.numero_do_bo AND ` + fmt.Sprintf(sqlOutraUnidade, "pa") + `)
+		RETURNING a.numero_do_bo
+	)
+	INSERT INTO rejeicoes_carga (ordem, motivo)
+	SELECT s.ordem, 'dados do BO não atualizados: o BO tem registros cadastrados por outra unidade'
+	FROM ` + sqlPrimeiraLinhaBO + ` s
+	WHERE s.numero_do_bo IN (SELECT numero_do_bo FROM recusadas)`,
+
+	`WITH recusadas AS (
+		DELETE FROM alteracoes_importacao a
+		USING participacoes pa
+		WHERE pa.id = a.participacao_id AND (
+			(a.campo = 'telefone_envolvido' AND ` + fmt.Sprintf(sqlOutraUnidade, "pa") + `)
+			OR (a.campo <> 'telefone_envolvido' AND EXISTS (
+				SELECT 1 FROM participacoes p
+				WHERE p.pessoa_id = pa.pessoa_id AND ` + fmt.Sprintf(sqlOutraUnidade, "p") + `)))
+		RETURNING a.participacao_id
+	)
+	INSERT INTO rejeicoes_carga (ordem, motivo)
+	SELECT s.ordem, 'participante não atualizado: registro ou pessoa cadastrados por outra unidade'
+	FROM staging_importacao s
+	WHERE s.participacao_id IN (SELECT participacao_id FROM recusadas)`,
+}
+
+// sqlDescartarRepetidosStaging remove as linhas que repetem um participante já
+// visto no arquivo (mesmo BO, pessoa e papel), as anota em rejeicoes_carga e
+// retorna a ordem das removidas
+const sqlDescartarRepetidosStaging = `
+	WITH descartados AS (
+		DELETE FROM staging_importacao s
+		USING (
+			SELECT ordem, ROW_NUMBER() OVER (PARTITION BY ` + sqlParticipanteStaging + ` ORDER BY ordem) AS repeticao
+			FROM staging_importacao
+		) n
+		WHERE n.ordem = s.ordem AND n.repeticao > 1
+		RETURNING s.ordem
+	)
+	INSERT INTO rejeicoes_carga (ordem, motivo)
+	SELECT ordem, 'duplicata: repete um participante anterior do próprio arquivo' FROM descartados
+	RETURNING ordem`
+
+// etapasAplicarAlteracoes guardam o estado dos registros afetados (todos os
+// participantes ativos dos BOs e das pessoas alteradas) e gravam as alterações
+var etapasAplicarAlteracoes = []string{
+	`CREATE TEMP TABLE IF NOT EXISTS historico_pendente (id INTEGER PRIMARY KEY, antes JSONB) ON COMMIT DROP`,
+
+	`INSERT INTO historico_pendente (id, antes)
+	SELECT pa.id, snapshot_envolvido(pa.id)
+	FROM participacoes pa
+	WHERE pa.excluido_em IS NULL AND (
+		pa.ocorrencia_id IN (
+			SELECT o.id FROM ocorrencias o
+			JOIN alteracoes_importacao a ON a.numero_do_bo = o.numero_do_bo AND a.participacao_id IS NULL)
+		OR pa.pessoa_id IN (
+			SELECT p.pessoa_id FROM participacoes p
+			JOIN alteracoes_importacao a ON a.participacao_id = p.id)
+	)
+	ON CONFLICT (id) DO NOTHING`,
+
+	// As colunas tipadas acompanham o texto de que são derivadas
+	`UPDATE ocorrencias o SET
+		delegacia_responsavel = CASE WHEN s.delegacia_responsavel <> '' THEN s.delegacia_responsavel ELSE o.delegacia_responsavel END,
+		situacao = CASE WHEN s.situacao <> '' THEN s.situacao ELSE o.situacao END,
+		natureza = CASE WHEN s.natureza <> '' THEN s.natureza ELSE o.natureza END,
+		data_fato = CASE WHEN s.data_fato <> '' THEN s.data_fato ELSE o.data_fato END,
+		data_fato_ts = CASE WHEN s.data_fato <> '' THEN s.data_fato_ts ELSE o.data_fato_ts END,
+		cep_fato = CASE WHEN s.cep_fato <> '' THEN s.cep_fato ELSE o.cep_fato END,
+		latitude_fato = CASE WHEN s.latitude_fato <> '' THEN s.latitude_fato ELSE o.latitude_fato END,
+		latitude_fato_num = CASE WHEN s.latitude_fato <> '' THEN s.latitude_fato_num ELSE o.latitude_fato_num END,
+		longitude_fato = CASE WHEN s.longitude_fato <> '' THEN s.longitude_fato ELSE o.longitude_fato END,
+		longitude_fato_num = CASE WHEN s.longitude_fato <> '' THEN s.longitude_fato_num ELSE o.longitude_fato_num END,
+		logradouro_fato = CASE WHEN s.logradouro_fato <> '' THEN s.logradouro_fato ELSE o.logradouro_fato END,
+		numerocasa_fato = CASE WHEN s.numerocasa_fato <> '' THEN s.numerocasa_fato ELSE o.numerocasa_fato END,
+		bairro_fato = CASE WHEN s.bairro_fato <> '' THEN s.bairro_fato ELSE o.bairro_fato END,
+		municipio_fato = CASE WHEN s.municipio_fato <> '' THEN s.municipio_fato ELSE o.municipio_fato END,
+		pais_fato = CASE WHEN s.pais_fato <> '' THEN s.pais_fato ELSE o.pais_fato END,
+		relato_historico = CASE WHEN s.relato_historico <> '' THEN s.relato_historico ELSE o.relato_historico END,
+		updated_at = CURRENT_TIMESTAMP
+	FROM ` + sqlPrimeiraLinhaBO + ` s
+	WHERE o.numero_do_bo = s.numero_do_bo
+	  AND EXISTS (SELECT 1 FROM alteracoes_importacao a WHERE a.numero_do_bo = o.numero_do_bo AND a.participacao_id IS NULL)`,
+
+	`UPDATE participacoes pa SET
+		telefone_envolvido = s.telefone_envolvido,
+		telefone_normalizado = s.telefone_normalizado,
+		updated_at = CURRENT_TIMESTAMP
+	FROM staging_importacao s
+	WHERE pa.id = s.participacao_id
+	  AND EXISTS (SELECT 1 FROM alteracoes_importacao a WHERE a.participacao_id = pa.id AND a.campo = 'telefone_envolvido')`,
+
+	// Uma pessoa presente em vários BOs do arquivo é atualizada pela primeira linha
+	`UPDATE pessoas pe SET
+		nacionalidade = CASE WHEN s.nacionalidade <> '' THEN s.nacionalidade ELSE pe.nacionalidade END,
+		naturalidade = CASE WHEN s.naturalidade <> '' THEN s.naturalidade ELSE pe.naturalidade END,
+		uf_envolvido = CASE WHEN s.uf_envolvido <> '' THEN s.uf_envolvido ELSE pe.uf_envolvido END,
+		sexo_envolvido = CASE WHEN s.sexo_envolvido <> '' THEN s.sexo_envolvido ELSE pe.sexo_envolvido END,
+		updated_at = CURRENT_TIMESTAMP
+	FROM (
+		SELECT DISTINCT ON (s2.pessoa_id) s2.*
+		FROM staging_importacao s2
+		WHERE EXISTS (
+			SELECT 1 FROM alteracoes_importacao a
+			WHERE a.participacao_id = s2.participacao_id AND a.campo <> 'telefone_envolvido')
+		ORDER BY s2.pessoa_id, s2.ordem
+	) s
+	WHERE pe.id = s.pessoa_id`,
+}
+
+// sqlHistoricoAtualizacao grava no histórico os registros capturados que mudaram
+const sqlHistoricoAtualizacao = `
+	INSERT INTO tabela_estelionato_history (registro_id, acao, usuario_id, usuario_login, motivo, antes, depois, alteracoes)
+	SELECT h.id, $1, NULLIF($2, 0), $3, NULLIF($4, ''), h.antes, estado.depois, diff_jsonb(h.antes, estado.depois)
+	FROM historico_pendente h
+	CROSS JOIN LATERAL (SELECT snapshot_envolvido(h.id) AS depois) estado
+	WHERE diff_jsonb(h.antes, estado.depois) <> '{}'::jsonb
+	ORDER BY h.id`
+
+// atualizarStaging grava no modo de atualização os registros da carga com ordem
+// entre inicio (inclusive) e fim e retorna as contagens e o relatório por BO.
+// Participantes sem alteração, repetidos no arquivo ou já excluídos contam como
+// duplicatas.
+func atualizarStaging(tx *sql.Tx, inicio, fim int, autor Autor, importID int64) (ResultadoImportacao, error) {
+	var resultado ResultadoImportacao
+
+	if err := prepararStaging(tx, inicio, fim); err != nil {
+		return resultado, fmt.Errorf("erro ao copiar registros para a tabela temporária: %v", err)
+	}
+
+	repetidos, err := contarLinhas(tx, sqlDescartarRepetidosStaging)
+	if err != nil {
+		return resultado, fmt.Errorf("erro ao descartar linhas repetidas: %v", err)
+	}
+	for i, etapa := range etapasCompararStaging {
+		if _, err := tx.Exec(etapa); err != nil {
+			return resultado, fmt.Errorf("etapa %d da comparação: %v", i+1, err)
+		}
+	}
+	if !autor.Admin {
+		for i, etapa := range etapasRecusarOutrasUnidades {
+			if _, err := tx.Exec(etapa, autor.UsuarioID); err != nil {
+				return resultado, fmt.Errorf("etapa %d da verificação de unidade: %v", i+1, err)
+			}
+		}
+	}
+
+	// Participantes existentes: alterados (por campo próprio ou da ocorrência) ou sem alteração
+	var existentes int
+	err = tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE NOT participante_excluido AND (
+				EXISTS (SELECT 1 FROM alteracoes_importacao a WHERE a.participacao_id = s.participacao_id)
+				OR EXISTS (SELECT 1 FROM alteracoes_importacao a WHERE a.numero_do_bo = s.numero_do_bo AND a.participacao_id IS NULL))),
+			COUNT(*)
+		FROM staging_importacao s
+		WHERE participacao_id IS NOT NULL`).Scan(&resultado.Atualizados, &existentes)
+	if err != nil {
+		return resultado, err
+	}
+	resultado.Duplicados = repetidos + existentes - resultado.Atualizados
+
+	relatorio, err := lerAlteracoesStaging(tx)
+	if err != nil {
+		return resultado, fmt.Errorf("erro ao ler alterações: %v", err)
+	}
+
+	for i, etapa := range etapasAplicarAlteracoes {
+		if _, err := tx.Exec(etapa); err != nil {
+			return resultado, fmt.Errorf("etapa %d da atualização: %v", i+1, err)
+		}
+	}
+	motivo := ""
+	if importID != 0 {
+		motivo = fmt.Sprintf("atualização pela importação %d", importID)
+	}
+	if _, err := tx.Exec(sqlHistoricoAtualizacao, AcaoAtualizacao, autor.UsuarioID, autor.Login, motivo); err != nil {
+		return resultado, fmt.Errorf("erro ao registrar histórico: %v", err)
+	}
+
+	// Restam na staging os participantes novos, gravados como na inclusão
+	if _, err := tx.Exec(`DELETE FROM staging_importacao WHERE participacao_id IS NOT NULL`); err != nil {
+		return resultado, err
+	}
+	bosNovos := make(map[string]bool)
+	rows, err := tx.Query(`
+		SELECT DISTINCT numero_do_bo FROM staging_importacao s
+		WHERE NOT EXISTS (SELECT 1 FROM ocorrencias o WHERE o.numero_do_bo = s.numero_do_bo)`)
+	if err != nil {
+		return resultado, err
+	}
+	for rows.Next() {
+		var numeroBO string
+		if err := rows.Scan(&numeroBO); err != nil {
+			rows.Close()
+			return resultado, err
+		}
+		bosNovos[numeroBO] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return resultado, err
+	}
+
+	if resultado.Inseridos, resultado.Mantidos, err = gravarStaging(tx, autor, importID); err != nil {
+		return resultado, fmt.Errorf("erro ao inserir participantes novos: %v", err)
+	}
+
+	rows, err = tx.Query(`SELECT numero_do_bo, participacao_id, nomecompleto, tipo_envolvido FROM staging_importacao ORDER BY ordem`)
+	if err != nil {
+		return resultado, err
+	}
+	for rows.Next() {
+		var numeroBO string
+		var p ParticipanteImportado
+		if err := rows.Scan(&numeroBO, &p.RegistroID, &p.NomeCompleto, &p.TipoEnvolvido); err != nil {
+			rows.Close()
+			return resultado, err
+		}
+		bo := relatorio.bo(numeroBO)
+		bo.Novo = bosNovos[numeroBO]
+		bo.ParticipantesNovos = append(bo.ParticipantesNovos, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return resultado, err
+	}
+
+	if _, err := tx.Exec(`DROP TABLE staging_importacao, alteracoes_importacao, historico_pendente`); err != nil {
+		return resultado, err
+	}
+
+	resultado.Alteracoes = relatorio.lista()
+	return resultado, nil
+}
+
+// lerAlteracoesStaging monta o relatório por BO com os campos alterados
+func lerAlteracoesStaging(tx *sql.Tx) (*relatorioAlteracoes, error) {
+	rows, err := tx.Query(`
+		SELECT a.numero_do_bo, COALESCE(a.participacao_id, 0), COALESCE(s.nomecompleto, ''),
+			COALESCE(s.tipo_envolvido, ''), a.campo, a.antes, a.depois
+		FROM alteracoes_importacao a
+		LEFT JOIN staging_importacao s ON s.participacao_id = a.participacao_id
+		ORDER BY a.numero_do_bo, a.participacao_id NULLS FIRST, a.campo`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relatorio := novoRelatorioAlteracoes()
+	for rows.Next() {
+		var numeroBO, nome, tipo, campo string
+		var registroID int
+		var antes sql.NullString
+		var depois string
+		if err := rows.Scan(&numeroBO, &registroID, &nome, &tipo, &campo, &antes, &depois); err != nil {
+			return nil, err
+		}
+
+		alteracao := AlteracaoCampo{Depois: &depois}
+		if antes.Valid {
+			alteracao.Antes = &antes.String
+		}
+
+		bo := relatorio.bo(numeroBO)
+		if registroID == 0 {
+			if bo.Ocorrencia == nil {
+				bo.Ocorrencia = make(map[string]AlteracaoCampo)
+			}
+			bo.Ocorrencia[campo] = alteracao
+			continue
+		}
+
+		n := len(bo.ParticipantesAlterados)
+		if n == 0 || bo.ParticipantesAlterados[n-1].RegistroID != registroID {
+			bo.ParticipantesAlterados = append(bo.ParticipantesAlterados, ParticipanteImportado{
+				RegistroID: registroID, NomeCompleto: nome, TipoEnvolvido: tipo,
+				Alteracoes: make(map[string]AlteracaoCampo),
+			})
+			n++
+		}
+		bo.ParticipantesAlterados[n-1].Alteracoes[campo] = alteracao
+	}
+
+	return relatorio, rows.Err()
+}
+
+// relatorioAlteracoes agrupa as alterações por BO, na ordem em que aparecem
+type relatorioAlteracoes struct {
+	ordem []string
+	bos   map[string]*AlteracaoBO
+}
+
+func novoRelatorioAlteracoes() *relatorioAlteracoes {
+	return &relatorioAlteracoes{bos: make(map[string]*AlteracaoBO)}
+}
+
+func (r *relatorioAlteracoes) bo(numeroBO string) *AlteracaoBO {
+	bo, ok := r.bos[numeroBO]
+	if !ok {
+		bo = &AlteracaoBO{NumeroBO: numeroBO}
+		r.bos[numeroBO] = bo
+		r.ordem = append(r.ordem, numeroBO)
+	}
+	return bo
+}
+
+func (r *relatorioAlteracoes) lista() []AlteracaoBO {
+	lista := make([]AlteracaoBO, 0, len(r.ordem))
+	for _, numeroBO := range r.ordem {
+		lista = append(lista, *r.bos[numeroBO])
+	}
+	return lista
+}
+
+// mesclarAlteracoes junta os relatórios de dois lotes; um BO presente nos dois
+// aparece uma vez só
+func mesclarAlteracoes(a, b []AlteracaoBO) []AlteracaoBO {
+	if len(a) == 0 {
+		return b
+	}
+
+	relatorio := novoRelatorioAlteracoes()
+	for _, lote := range [][]AlteracaoBO{a, b} {
+		for _, alteracao := range lote {
+			bo := relatorio.bo(alteracao.NumeroBO)
+			bo.Novo = bo.Novo || alteracao.Novo
+			for campo, valor := range alteracao.Ocorrencia {
+				if bo.Ocorrencia == nil {
+					bo.Ocorrencia = make(map[string]AlteracaoCampo)
+				}
+				bo.Ocorrencia[campo] = valor
+			}
+			bo.ParticipantesNovos = append(bo.ParticipantesNovos, alteracao.ParticipantesNovos...)
+			bo.ParticipantesAlterados = append(bo.ParticipantesAlterados, alteracao.ParticipantesAlterados...)
+		}
+	}
+	return relatorio.lista()
+}
+
+// contarLinhas executa um comando com RETURNING e conta as linhas retornadas
+func contarLinhas(tx *sql.Tx, query string) (int, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err()
+}
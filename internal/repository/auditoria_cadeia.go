@@ -0,0 +1,360 @@
+package repository
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// lockAuditoria serializa as gravações na trilha de auditoria, garantindo que
+// cada registro seja encadeado ao anterior
+const lockAuditoria = 7301002
+
+// hashGenese é o "hash anterior" do primeiro registro da cadeia
+var hashGenese = strings.Repeat("0", 64)
+
+// ErrChaveAuditoriaAusente indica que AUDIT_SIGNING_KEY não foi configurada
+var ErrChaveAuditoriaAusente = errors.New("chave de assinatura da auditoria (AUDIT_SIGNING_KEY) não configurada")
+
+// hashRegistroAuditoria calcula o SHA-256 do conteúdo canônico do registro
+// (array JSON com todos os campos, na ordem abaixo) encadeado ao hash anterior
+func hashRegistroAuditoria(reg RegistroAuditoria, hashAnterior string) (string, error) {
+	parametros := reg.Parametros
+	if parametros == nil {
+		parametros = map[string]string{}
+	}
+
+	conteudo, err := json.Marshal([]interface{}{
+		reg.ID,
+		reg.UsuarioID,
+		reg.UsuarioLogin,
+		reg.Endpoint,
+		parametros,
+		reg.QuantidadeResultados,
+		reg.IP,
+		reg.CriadoEm.UTC().Format(time.RFC3339Nano),
+		hashAnterior,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	soma := sha256.Sum256(conteudo)
+	return hex.EncodeToString(soma[:]), nil
+}
+
+// consultaLinha é satisfeita por *sql.DB e *sql.Tx
+type consultaLinha interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// limiteLegadoAuditoria retorna o maior id gravado antes da cadeia de hashes
+// existir; só esses registros podem ficar sem hash
+func limiteLegadoAuditoria(q consultaLinha) (int64, error) {
+	var limite int64
+	err := q.QueryRow("SELECT ultimo_registro_id FROM auditoria_legado").Scan(&limite)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return limite, err
+}
+
+// ultimoHashAuditoria retorna o hash do último registro encadeado, selando antes
+// os registros gravados antes da cadeia existir. Deve ser chamada com o lock obtido.
+func ultimoHashAuditoria(tx *sql.Tx) (string, error) {
+	var ultimoID sql.NullInt64
+	var ultimo sql.NullString
+	err := tx.QueryRow(`SELECT id, hash FROM auditoria_consultas WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1`).Scan(&ultimoID, &ultimo)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	hashAnterior := hashGenese
+	if ultimo.Valid {
+		hashAnterior = ultimo.String
+	}
+
+	limite, err := limiteLegadoAuditoria(tx)
+	if err != nil {
+		return "", err
+	}
+
+	// Só são selados os registros anteriores à cadeia que ainda não foram
+	// encadeados; um hash apagado depois disso aparece como quebra na verificação
+	rows, err := tx.Query("SELECT "+colunasAuditoria+` FROM auditoria_consultas
+		WHERE hash IS NULL AND id <= $1 AND id > $2 ORDER BY id`, limite, ultimoID.Int64)
+	if err != nil {
+		return "", err
+	}
+	var pendentes []RegistroAuditoria
+	for rows.Next() {
+		reg, err := scanRegistroAuditoria(rows)
+		if err != nil {
+			rows.Close()
+			return "", err
+		}
+		pendentes = append(pendentes, reg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	for _, reg := range pendentes {
+		hash, err := hashRegistroAuditoria(reg, hashAnterior)
+		if err != nil {
+			return "", err
+		}
+		if _, err := tx.Exec(`UPDATE auditoria_consultas SET hash_anterior = $1, hash = $2 WHERE id = $3`,
+			hashAnterior, hash, reg.ID); err != nil {
+			return "", err
+		}
+		hashAnterior = hash
+	}
+	if len(pendentes) > 0 {
+		log.Printf("Auditoria: %d registros anteriores à cadeia de hashes foram selados", len(pendentes))
+	}
+
+	return hashAnterior, nil
+}
+
+// chavePrivadaAuditoria lê a semente Ed25519 (32 bytes em base64) de AUDIT_SIGNING_KEY
+func chavePrivadaAuditoria() (ed25519.PrivateKey, error) {
+	valor := os.Getenv("AUDIT_SIGNING_KEY")
+	if valor == "" {
+		return nil, ErrChaveAuditoriaAusente
+	}
+
+	semente, err := base64.StdEncoding.DecodeString(valor)
+	if err != nil || len(semente) != ed25519.SeedSize {
+		return nil, fmt.Errorf("AUDIT_SIGNING_KEY deve conter %d bytes em base64", ed25519.SeedSize)
+	}
+
+	return ed25519.NewKeyFromSeed(semente), nil
+}
+
+// chavePublicaAuditoria usa AUDIT_PUBLIC_KEY (base64) quando definida, permitindo
+// verificar os checkpoints sem acesso à chave privada
+func chavePublicaAuditoria() (ed25519.PublicKey, error) {
+	if valor := os.Getenv("AUDIT_PUBLIC_KEY"); valor != "" {
+		chave, err := base64.StdEncoding.DecodeString(valor)
+		if err != nil || len(chave) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("AUDIT_PUBLIC_KEY deve conter %d bytes em base64", ed25519.PublicKeySize)
+		}
+		return ed25519.PublicKey(chave), nil
+	}
+
+	privada, err := chavePrivadaAuditoria()
+	if err != nil {
+		return nil, err
+	}
+	return privada.Public().(ed25519.PublicKey), nil
+}
+
+// CheckpointAuditoria é uma assinatura do estado da cadeia em um instante
+type CheckpointAuditoria struct {
+	ID                  int       `json:"id"`
+	UltimoRegistroID    int64     `json:"ultimo_registro_id"`
+	UltimoHash          string    `json:"ultimo_hash"`
+	QuantidadeRegistros int64     `json:"quantidade_registros"`
+	CriadoEm            time.Time `json:"criado_em"`
+	Assinatura          string    `json:"assinatura"`
+}
+
+// mensagemCheckpoint é o conteúdo assinado de um checkpoint
+func mensagemCheckpoint(c CheckpointAuditoria) []byte {
+	return []byte(fmt.Sprintf("fraudbase-auditoria|%d|%s|%d|%s",
+		c.UltimoRegistroID, c.UltimoHash, c.QuantidadeRegistros, c.CriadoEm.UTC().Format(time.RFC3339Nano)))
+}
+
+// CriarCheckpoint assina o último registro da cadeia. Retorna false quando não há
+// registros novos desde o último checkpoint.
+func (r *AuditoriaRepository) CriarCheckpoint() (bool, error) {
+	privada, err := chavePrivadaAuditoria()
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", lockAuditoria); err != nil {
+		return false, err
+	}
+
+	ultimoHash, err := ultimoHashAuditoria(tx)
+	if err != nil {
+		return false, err
+	}
+
+	c := CheckpointAuditoria{UltimoHash: ultimoHash, CriadoEm: time.Now().Truncate(time.Microsecond)}
+	err = tx.QueryRow(`SELECT COALESCE(MAX(id), 0), COUNT(*) FROM auditoria_consultas`).Scan(&c.UltimoRegistroID, &c.QuantidadeRegistros)
+	if err != nil {
+		return false, err
+	}
+	if c.QuantidadeRegistros == 0 {
+		return false, nil
+	}
+
+	var ultimoCheckpoint int64
+	err = tx.QueryRow(`SELECT COALESCE(MAX(ultimo_registro_id), 0) FROM auditoria_checkpoints`).Scan(&ultimoCheckpoint)
+	if err != nil {
+		return false, err
+	}
+	if ultimoCheckpoint == c.UltimoRegistroID {
+		return false, nil
+	}
+
+	c.Assinatura = base64.StdEncoding.EncodeToString(ed25519.Sign(privada, mensagemCheckpoint(c)))
+	_, err = tx.Exec(`
+		INSERT INTO auditoria_checkpoints (ultimo_registro_id, ultimo_hash, quantidade_registros, criado_em, assinatura)
+		VALUES ($1, $2, $3, $4, $5)`,
+		c.UltimoRegistroID, c.UltimoHash, c.QuantidadeRegistros, c.CriadoEm, c.Assinatura)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	log.Printf("Checkpoint da auditoria assinado: registro %d, %d registros", c.UltimoRegistroID, c.QuantidadeRegistros)
+	return true, nil
+}
+
+// FalhaCadeiaAuditoria descreve o primeiro elo quebrado encontrado
+type FalhaCadeiaAuditoria struct {
+	RegistroID   int64  `json:"registro_id,omitempty"`
+	CheckpointID int    `json:"checkpoint_id,omitempty"`
+	Motivo       string `json:"motivo"`
+}
+
+// VerificacaoAuditoria é o resultado da verificação da cadeia
+type VerificacaoAuditoria struct {
+	Valida                 bool                  `json:"valida"`
+	RegistrosVerificados   int64                 `json:"registros_verificados"`
+	CheckpointsVerificados int                   `json:"checkpoints_verificados"`
+	AssinaturasVerificadas bool                  `json:"assinaturas_verificadas"`
+	PrimeiraFalha          *FalhaCadeiaAuditoria `json:"primeira_falha,omitempty"`
+}
+
+// VerificarCadeia percorre a trilha de auditoria recalculando cada hash e
+// conferindo o encadeamento, e depois confere os checkpoints: a assinatura
+// (quando há chave configurada) e se o registro assinado ainda existe com o
+// mesmo hash (o que detecta remoção de registros do fim da cadeia).
+func (r *AuditoriaRepository) VerificarCadeia() (VerificacaoAuditoria, error) {
+	var v VerificacaoAuditoria
+
+	limite, err := limiteLegadoAuditoria(r.db)
+	if err != nil {
+		return v, err
+	}
+
+	rows, err := r.db.Query("SELECT " + colunasAuditoria + ", hash_anterior, hash FROM auditoria_consultas ORDER BY id")
+	if err != nil {
+		return v, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[int64]string)
+	hashAnterior := hashGenese
+	for rows.Next() {
+		var reg RegistroAuditoria
+		var parametros []byte
+		var anterior, hash sql.NullString
+		if err := rows.Scan(&reg.ID, &reg.UsuarioID, &reg.UsuarioLogin, &reg.Endpoint, &parametros,
+			&reg.QuantidadeResultados, &reg.IP, &reg.CriadoEm, &anterior, &hash); err != nil {
+			return v, err
+		}
+		if err := json.Unmarshal(parametros, &reg.Parametros); err != nil {
+			return v, err
+		}
+
+		if !hash.Valid {
+			if v.RegistrosVerificados > 0 || reg.ID > limite {
+				v.PrimeiraFalha = &FalhaCadeiaAuditoria{RegistroID: reg.ID,
+					Motivo: "registro sem hash depois do início da cadeia (hash removido)"}
+				return v, nil
+			}
+			// Registro ainda não selado (gravado antes da cadeia); será selado na próxima gravação
+			continue
+		}
+
+		if anterior.String != hashAnterior {
+			v.PrimeiraFalha = &FalhaCadeiaAuditoria{RegistroID: reg.ID,
+				Motivo: "hash anterior não corresponde ao registro precedente (registro removido ou inserido fora de ordem)"}
+			return v, nil
+		}
+
+		calculado, err := hashRegistroAuditoria(reg, anterior.String)
+		if err != nil {
+			return v, err
+		}
+		if calculado != hash.String {
+			v.PrimeiraFalha = &FalhaCadeiaAuditoria{RegistroID: reg.ID, Motivo: "conteúdo do registro foi alterado"}
+			return v, nil
+		}
+
+		hashes[reg.ID] = hash.String
+		hashAnterior = hash.String
+		v.RegistrosVerificados++
+	}
+	if err := rows.Err(); err != nil {
+		return v, err
+	}
+
+	chavePublica, err := chavePublicaAuditoria()
+	if err != nil && err != ErrChaveAuditoriaAusente {
+		return v, err
+	}
+	v.AssinaturasVerificadas = chavePublica != nil
+
+	checkpoints, err := r.db.Query(`
+		SELECT id, ultimo_registro_id, ultimo_hash, quantidade_registros, criado_em, assinatura
+		FROM auditoria_checkpoints ORDER BY id`)
+	if err != nil {
+		return v, err
+	}
+	defer checkpoints.Close()
+
+	for checkpoints.Next() {
+		var c CheckpointAuditoria
+		if err := checkpoints.Scan(&c.ID, &c.UltimoRegistroID, &c.UltimoHash, &c.QuantidadeRegistros, &c.CriadoEm, &c.Assinatura); err != nil {
+			return v, err
+		}
+
+		if chavePublica != nil {
+			assinatura, err := base64.StdEncoding.DecodeString(c.Assinatura)
+			if err != nil || !ed25519.Verify(chavePublica, mensagemCheckpoint(c), assinatura) {
+				v.PrimeiraFalha = &FalhaCadeiaAuditoria{CheckpointID: c.ID, Motivo: "assinatura do checkpoint inválida"}
+				return v, nil
+			}
+		}
+
+		if hash, ok := hashes[c.UltimoRegistroID]; !ok || hash != c.UltimoHash {
+			v.PrimeiraFalha = &FalhaCadeiaAuditoria{CheckpointID: c.ID, RegistroID: c.UltimoRegistroID,
+				Motivo: "registro assinado no checkpoint foi removido ou alterado"}
+			return v, nil
+		}
+
+		v.CheckpointsVerificados++
+	}
+	if err := checkpoints.Err(); err != nil {
+		return v, err
+	}
+
+	v.Valida = true
+	return v, nil
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// RegistroAuditoria representa uma consulta a dados de pessoas feita por um usuário
+type RegistroAuditoria struct {
+	ID                   int64             `json:"id"`
+	UsuarioID            int               `json:"usuario_id"`
+	UsuarioLogin         string            `json:"usuario_login"`
+	Endpoint             string            `json:"endpoint"`
+	Parametros           map[string]string `json:"parametros"`
+	QuantidadeResultados int               `json:"quantidade_resultados"`
+	IP                   string            `json:"ip"`
+	CriadoEm             time.Time         `json:"criado_em"`
+}
+
+// FiltroAuditoria reúne os filtros da busca na trilha de auditoria (campos vazios são ignorados)
+type FiltroAuditoria struct {
+	UsuarioID int
+	Login     string
+	Endpoint  string
+	Termo     string // procurado nos valores dos parâmetros (ex.: um CPF)
+	IP        string
+	De        *time.Time
+	Ate       *time.Time
+}
+
+// AuditoriaRepository grava e consulta a trilha de auditoria (somente inserção)
+type AuditoriaRepository struct {
+	db *sql.DB
+}
+
+// NewAuditoriaRepository cria um novo repositório de auditoria
+func NewAuditoriaRepository(db *sql.DB) *AuditoriaRepository {
+	return &AuditoriaRepository{db: db}
+}
+
+// Registrar grava um registro de auditoria encadeado ao anterior (ver auditoria_cadeia.go)
+func (r *AuditoriaRepository) Registrar(reg RegistroAuditoria) error {
+	err := r.registrar(reg)
+	if err != nil {
+		log.Printf("Erro ao registrar auditoria: %v", err)
+	}
+	return err
+}
+
+func (r *AuditoriaRepository) registrar(reg RegistroAuditoria) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", lockAuditoria); err != nil {
+		return err
+	}
+
+	hashAnterior, err := ultimoHashAuditoria(tx)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.QueryRow("SELECT nextval(pg_get_serial_sequence('auditoria_consultas', 'id'))").Scan(&reg.ID); err != nil {
+		return err
+	}
+	if reg.Parametros == nil {
+		reg.Parametros = map[string]string{}
+	}
+	// O PostgreSQL guarda microssegundos; truncar para que o hash confira na verificação
+	reg.CriadoEm = time.Now().Truncate(time.Microsecond)
+
+	hash, err := hashRegistroAuditoria(reg, hashAnterior)
+	if err != nil {
+		return err
+	}
+
+	parametros, err := json.Marshal(reg.Parametros)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO auditoria_consultas (id, usuario_id, usuario_login, endpoint, parametros,
+			quantidade_resultados, ip, criado_em, hash_anterior, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		reg.ID, reg.UsuarioID, reg.UsuarioLogin, reg.Endpoint, string(parametros),
+		reg.QuantidadeResultados, reg.IP, reg.CriadoEm, hashAnterior, hash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// condicoesAuditoria monta a cláusula WHERE a partir do filtro
+func condicoesAuditoria(f FiltroAuditoria) (string, []interface{}) {
+	var conditions []string
+	var params []interface{}
+
+	adicionar := func(condicao string, valor interface{}) {
+		params = append(params, valor)
+		conditions = append(conditions, fmt.Sprintf(condicao, len(params)))
+	}
+
+	if f.UsuarioID > 0 {
+		adicionar("usuario_id = $%d", f.UsuarioID)
+	}
+	if f.Login != "" {
+		adicionar("usuario_login ILIKE $%d", "%"+f.Login+"%")
+	}
+	if f.Endpoint != "" {
+		adicionar("endpoint ILIKE $%d", "%"+f.Endpoint+"%")
+	}
+	if f.Termo != "" {
+		adicionar("EXISTS (SELECT 1 FROM jsonb_each_text(parametros) p WHERE p.value ILIKE $%d)", "%"+f.Termo+"%")
+	}
+	if f.IP != "" {
+		adicionar("ip = $%d", f.IP)
+	}
+	if f.De != nil {
+		adicionar("criado_em >= $%d", *f.De)
+	}
+	if f.Ate != nil {
+		adicionar("criado_em < $%d", *f.Ate)
+	}
+
+	if len(conditions) == 0 {
+		return "", params
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), params
+}
+
+const colunasAuditoria = `id, usuario_id, usuario_login, endpoint, parametros, quantidade_resultados, ip, criado_em`
+
+func scanRegistroAuditoria(s scanner) (RegistroAuditoria, error) {
+	var reg RegistroAuditoria
+	var parametros []byte
+	if err := s.Scan(&reg.ID, &reg.UsuarioID, &reg.UsuarioLogin, &reg.Endpoint, &parametros,
+		&reg.QuantidadeResultados, &reg.IP, &reg.CriadoEm); err != nil {
+		return reg, err
+	}
+	if err := json.Unmarshal(parametros, &reg.Parametros); err != nil {
+		return reg, err
+	}
+	return reg, nil
+}
+
+// Buscar retorna uma página da trilha de auditoria, do registro mais recente para o mais antigo
+func (r *AuditoriaRepository) Buscar(f FiltroAuditoria, page, limit int) ([]RegistroAuditoria, int, error) {
+	where, params := condicoesAuditoria(f)
+
+	var totalCount int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM auditoria_consultas"+where, params...).Scan(&totalCount); err != nil {
+		log.Printf("Erro ao contar registros de auditoria: %v", err)
+		return nil, 0, err
+	}
+
+	query := "SELECT " + colunasAuditoria + " FROM auditoria_consultas" + where +
+		fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(params)+1, len(params)+2)
+	params = append(params, limit, (page-1)*limit)
+
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		log.Printf("Erro ao consultar auditoria: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	registros := []RegistroAuditoria{}
+	for rows.Next() {
+		reg, err := scanRegistroAuditoria(rows)
+		if err != nil {
+			log.Printf("Erro ao escanear registro de auditoria: %v", err)
+			return nil, 0, err
+		}
+		registros = append(registros, reg)
+	}
+
+	return registros, totalCount, rows.Err()
+}
+
+// Exportar percorre, em ordem cronológica, todos os registros que atendem ao filtro
+func (r *AuditoriaRepository) Exportar(f FiltroAuditoria, fn func(RegistroAuditoria) error) error {
+	where, params := condicoesAuditoria(f)
+
+	rows, err := r.db.Query("SELECT "+colunasAuditoria+" FROM auditoria_consultas"+where+" ORDER BY id", params...)
+	if err != nil {
+		log.Printf("Erro ao exportar auditoria: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		reg, err := scanRegistroAuditoria(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(reg); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
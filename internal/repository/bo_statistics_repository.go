@@ -33,9 +33,10 @@ func (r *BOStatisticsRepository) BuscarBOMaisNovo() (*BOData, error) {
 			CAST(SUBSTRING(numero_do_bo FROM POSITION('/' IN numero_do_bo) + 1 FOR 4) AS INTEGER) AS ano,
 			-- Extrair o número (assumindo que está no início)
 			CAST(SUBSTRING(numero_do_bo FROM 1 FOR POSITION('/' IN numero_do_bo) - 1) AS INTEGER) AS numero
-		FROM tabela_estelionato
+		FROM ocorrencias o
 		WHERE 
 			numero_do_bo ~ E'^\\d+/\\d{4}(-[A-Z])?$' -- Validar formato
+			AND EXISTS (SELECT 1 FROM vw_envolvidos v WHERE v.ocorrencia_id = o.id) -- Ignorar BOs só com registros excluídos
 	)
 	SELECT numero_do_bo
 	FROM parsed_bo
@@ -66,9 +67,10 @@ func (r *BOStatisticsRepository) BuscarBOMaisAntigo() (*BOData, error) {
 			CAST(SUBSTRING(numero_do_bo FROM POSITION('/' IN numero_do_bo) + 1 FOR 4) AS INTEGER) AS ano,
 			-- Extrair o número (assumindo que está no início)
 			CAST(SUBSTRING(numero_do_bo FROM 1 FOR POSITION('/' IN numero_do_bo) - 1) AS INTEGER) AS numero
-		FROM tabela_estelionato
+		FROM ocorrencias o
 		WHERE 
 			numero_do_bo ~ E'^\\d+/\\d{4}(-[A-Z])?$' -- Validar formato
+			AND EXISTS (SELECT 1 FROM vw_envolvidos v WHERE v.ocorrencia_id = o.id) -- Ignorar BOs só com registros excluídos
 	)
 	SELECT numero_do_bo
 	FROM parsed_bo
@@ -0,0 +1,441 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"fraudbase/internal/database"
+	"fraudbase/internal/normalize"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Um relatório é carregado aba por aba, uma linha de cada vez, em tabelas
+// temporárias (COPY), e a junção das abas pelo Id é feita pelo banco na tabela
+// relatorio_importacao, com um registro por envolvido. A memória usada na
+// importação não depende do tamanho do arquivo. Os lotes da gravação são faixas
+// de ordem dessa tabela, copiadas para a staging_importacao. As tabelas somem ao
+// fim da transação da carga.
+//
+// As linhas que não geram registro (sem Id ou sem correspondente na aba de
+// registro) ficam em carga_ignoradas e os registros descartados na gravação
+// (duplicatas, lotes com erro) em rejeicoes_carga; GravarCarga as guarda em
+// import_rejeicoes para o relatório de rejeições da importação.
+
+// Abas lógicas do relatório; o perfil de importação diz o nome de cada uma no arquivo
+const (
+	PlanilhaRegistro   = "registro"
+	PlanilhaFato       = "fato"
+	PlanilhaEnvolvidos = "envolvidos"
+	PlanilhaRelato     = "relato"
+)
+
+const sqlCriarCarga = `
+	CREATE TEMP TABLE carga_registro (
+		linha INTEGER NOT NULL,
+		id TEXT NOT NULL,
+		numero_do_bo TEXT NOT NULL,
+		delegacia_responsavel TEXT NOT NULL,
+		situacao TEXT NOT NULL,
+		natureza TEXT NOT NULL
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE carga_fato (
+		linha INTEGER NOT NULL,
+		id TEXT NOT NULL,
+		data_fato TEXT NOT NULL,
+		cep_fato TEXT NOT NULL,
+		latitude_fato TEXT NOT NULL,
+		longitude_fato TEXT NOT NULL,
+		logradouro_fato TEXT NOT NULL,
+		numerocasa_fato TEXT NOT NULL,
+		bairro_fato TEXT NOT NULL,
+		municipio_fato TEXT NOT NULL,
+		pais_fato TEXT NOT NULL,
+		data_fato_ts TIMESTAMPTZ,
+		latitude_fato_num DOUBLE PRECISION,
+		longitude_fato_num DOUBLE PRECISION
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE carga_envolvidos (
+		linha INTEGER NOT NULL,
+		id TEXT NOT NULL,
+		tipo_envolvido TEXT NOT NULL,
+		nomecompleto TEXT NOT NULL,
+		cpf TEXT NOT NULL,
+		nomedamae TEXT NOT NULL,
+		nascimento TEXT NOT NULL,
+		nacionalidade TEXT NOT NULL,
+		naturalidade TEXT NOT NULL,
+		uf_envolvido TEXT NOT NULL,
+		sexo_envolvido TEXT NOT NULL,
+		telefone_envolvido TEXT NOT NULL,
+		nascimento_data DATE,
+		cpf_normalizado TEXT NOT NULL,
+		telefone_normalizado TEXT NOT NULL
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE carga_relato (
+		linha INTEGER NOT NULL,
+		id TEXT NOT NULL,
+		relato_historico TEXT NOT NULL
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE carga_ignoradas (
+		seq SERIAL,
+		planilha TEXT NOT NULL,
+		linha INTEGER NOT NULL,
+		motivo TEXT NOT NULL
+	) ON COMMIT DROP;
+	CREATE TEMP TABLE rejeicoes_carga (
+		ordem INTEGER NOT NULL,
+		motivo TEXT NOT NULL
+	) ON COMMIT DROP`
+
+var (
+	colunasCargaRegistro = []string{"linha", "id", "numero_do_bo", "delegacia_responsavel", "situacao", "natureza"}
+	colunasCargaFato     = []string{
+		"linha", "id", "data_fato", "cep_fato", "latitude_fato", "longitude_fato", "logradouro_fato",
+		"numerocasa_fato", "bairro_fato", "municipio_fato", "pais_fato", "data_fato_ts",
+		"latitude_fato_num", "longitude_fato_num",
+	}
+	colunasCargaEnvolvidos = []string{
+		"linha", "id", "tipo_envolvido", "nomecompleto", "cpf", "nomedamae", "nascimento", "nacionalidade",
+		"naturalidade", "uf_envolvido", "sexo_envolvido", "telefone_envolvido", "nascimento_data",
+		"cpf_normalizado", "telefone_normalizado",
+	}
+	colunasCargaRelato    = []string{"linha", "id", "relato_historico"}
+	colunasCargaIgnoradas = []string{"planilha", "linha", "motivo"}
+)
+
+// sqlMontarRelatorio junta as abas pelo Id: um registro por envolvido (ou um só,
+// sem envolvido, para o BO que não tem nenhum), na ordem das linhas do arquivo.
+// Um Id repetido na aba de registro, do fato ou do relato vale pela última linha.
+// As linhas de origem do registro e do envolvido identificam o registro no
+// relatório de rejeições.
+const sqlMontarRelatorio = `
+	CREATE TEMP TABLE relatorio_importacao ON COMMIT DROP AS
+	WITH registros AS (
+		SELECT DISTINCT ON (id) * FROM carga_registro ORDER BY id, linha DESC
+	), fatos AS (
+		SELECT DISTINCT ON (id) * FROM carga_fato ORDER BY id, linha DESC
+	), relatos AS (
+		SELECT DISTINCT ON (id) * FROM carga_relato ORDER BY id, linha DESC
+	)
+	SELECT
+		(ROW_NUMBER() OVER (ORDER BY r.linha, e.linha) - 1)::INTEGER AS ordem,
+		r.numero_do_bo, r.delegacia_responsavel, r.situacao, r.natureza,
+		COALESCE(f.data_fato, '') AS data_fato,
+		COALESCE(f.cep_fato, '') AS cep_fato,
+		COALESCE(f.latitude_fato, '') AS latitude_fato,
+		COALESCE(f.longitude_fato, '') AS longitude_fato,
+		COALESCE(f.logradouro_fato, '') AS logradouro_fato,
+		COALESCE(f.numerocasa_fato, '') AS numerocasa_fato,
+		COALESCE(f.bairro_fato, '') AS bairro_fato,
+		COALESCE(f.municipio_fato, '') AS municipio_fato,
+		COALESCE(f.pais_fato, '') AS pais_fato,
+		COALESCE(e.tipo_envolvido, '') AS tipo_envolvido,
+		COALESCE(e.nomecompleto, '') AS nomecompleto,
+		COALESCE(e.cpf, '') AS cpf,
+		COALESCE(e.nomedamae, '') AS nomedamae,
+		COALESCE(e.nascimento, '') AS nascimento,
+		COALESCE(e.nacionalidade, '') AS nacionalidade,
+		COALESCE(e.naturalidade, '') AS naturalidade,
+		COALESCE(e.uf_envolvido, '') AS uf_envolvido,
+		COALESCE(e.sexo_envolvido, '') AS sexo_envolvido,
+		COALESCE(e.telefone_envolvido, '') AS telefone_envolvido,
+		COALESCE(rl.relato_historico, '') AS relato_historico,
+		f.data_fato_ts,
+		e.nascimento_data,
+		f.latitude_fato_num,
+		f.longitude_fato_num,
+		COALESCE(e.cpf_normalizado, '') AS cpf_normalizado,
+		COALESCE(e.telefone_normalizado, '') AS telefone_normalizado,
+		r.linha AS linha_registro,
+		e.linha AS linha_envolvido
+	FROM registros r
+	LEFT JOIN fatos f ON f.id = r.id
+	LEFT JOIN relatos rl ON rl.id = r.id
+	LEFT JOIN carga_envolvidos e ON e.id = r.id`
+
+// sqlIgnorarSemRegistro guarda em carga_ignoradas as linhas das outras abas cujo
+// Id não está na aba de registro e as retorna. $1 a $4 são os nomes das abas de
+// registro, do fato, de envolvidos e do relato no arquivo.
+const sqlIgnorarSemRegistro = `
+	INSERT INTO carga_ignoradas (planilha, linha, motivo)
+	SELECT planilha, linha, 'Id ' || id || ' sem correspondente em ''' || $1::TEXT || ''''
+	FROM (
+		SELECT 1 AS aba, $2::TEXT AS planilha, linha, id FROM carga_fato c
+		WHERE NOT EXISTS (SELECT 1 FROM carga_registro r WHERE r.id = c.id)
+		UNION ALL
+		SELECT 2, $3::TEXT, linha, id FROM carga_envolvidos c
+		WHERE NOT EXISTS (SELECT 1 FROM carga_registro r WHERE r.id = c.id)
+		UNION ALL
+		SELECT 3, $4::TEXT, linha, id FROM carga_relato c
+		WHERE NOT EXISTS (SELECT 1 FROM carga_registro r WHERE r.id = c.id)
+		ORDER BY 1, 3
+	) sem_registro
+	RETURNING planilha, linha, motivo`
+
+// maxIgnoradasPendentes é quantas linhas ignoradas são acumuladas antes de
+// copiadas, para não alternar o COPY a cada linha
+const maxIgnoradasPendentes = 1000
+
+// LinhaRejeitada é uma linha do arquivo que não gerou registro
+type LinhaRejeitada struct {
+	Planilha string // nome da aba no arquivo
+	Linha    int
+	Motivo   string
+}
+
+// CargaRelatorio recebe as linhas de um relatório em uma transação. Depois de
+// Concluir, a carga é gravada (GravarCarga) ou verificada para a prévia
+// (VerificarDuplicatas, SimularAtualizacao); Descartar desfaz o que não foi confirmado.
+type CargaRelatorio struct {
+	tx     *sql.Tx
+	tabela string    // tabela do COPY em andamento
+	copia  *sql.Stmt // só um COPY por vez na conexão
+	total  int
+
+	ignoradas []LinhaRejeitada // ainda não copiadas para carga_ignoradas
+	nomes     map[string]string
+}
+
+// IniciarCarga abre a transação e cria as tabelas temporárias da carga
+func (r *RelatorioRepository) IniciarCarga() (*CargaRelatorio, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(sqlCriarCarga); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("erro ao criar tabelas temporárias da carga: %v", err)
+	}
+	return &CargaRelatorio{tx: tx}, nil
+}
+
+// Registro adiciona uma linha da aba de registro (número do BO, unidade,
+// situação e natureza de d)
+func (c *CargaRelatorio) Registro(linha int, id string, d DadosRelatorio) error {
+	return c.copiar("carga_registro", colunasCargaRegistro,
+		linha, id, d.NumeroBo, d.DelegaciaResponsavel, d.Situacao, d.Natureza)
+}
+
+// Fato adiciona uma linha da aba do fato (data e local de d)
+func (c *CargaRelatorio) Fato(linha int, id string, d DadosRelatorio) error {
+	tipados := database.ConverterCamposTipados(d.DataFato, "", "", d.LatitudeFato, d.LongitudeFato)
+	return c.copiar("carga_fato", colunasCargaFato,
+		linha, id, d.DataFato, d.CepFato, d.LatitudeFato, d.LongitudeFato, d.LogradouroFato,
+		d.NumeroCasaFato, d.BairroFato, d.MunicipioFato, d.PaisFato, tipados.DataFato,
+		tipados.Latitude, tipados.Longitude)
+}
+
+// Envolvido adiciona uma linha da aba de envolvidos (campos da pessoa em d)
+func (c *CargaRelatorio) Envolvido(linha int, id string, d DadosRelatorio) error {
+	tipados := database.ConverterCamposTipados("", d.Nascimento, "", "", "")
+	return c.copiar("carga_envolvidos", colunasCargaEnvolvidos,
+		linha, id, d.TipoEnvolvido, d.NomeCompleto, d.Cpf, d.NomeDaMae, d.Nascimento,
+		d.Nacionalidade, d.Naturalidade, d.UfEnvolvido, d.SexoEnvolvido, d.TelefoneEnvolvido,
+		tipados.Nascimento, normalize.CPF(d.Cpf),
+		normalize.Telefone(d.TelefoneEnvolvido))
+}
+
+// Relato adiciona uma linha da aba do relato
+func (c *CargaRelatorio) Relato(linha int, id string, relato string) error {
+	return c.copiar("carga_relato", colunasCargaRelato, linha, id, relato)
+}
+
+// Ignorar registra uma linha do arquivo que não gerou registro
+func (c *CargaRelatorio) Ignorar(planilha string, linha int, motivo string) error {
+	c.ignoradas = append(c.ignoradas, LinhaRejeitada{Planilha: planilha, Linha: linha, Motivo: motivo})
+	if len(c.ignoradas) < maxIgnoradasPendentes {
+		return nil
+	}
+	return c.copiarIgnoradas()
+}
+
+func (c *CargaRelatorio) copiarIgnoradas() error {
+	for _, l := range c.ignoradas {
+		if err := c.copiar("carga_ignoradas", colunasCargaIgnoradas, l.Planilha, l.Linha, l.Motivo); err != nil {
+			return err
+		}
+	}
+	c.ignoradas = c.ignoradas[:0]
+	return nil
+}
+
+func (c *CargaRelatorio) copiar(tabela string, colunas []string, valores ...interface{}) error {
+	if c.tabela != tabela {
+		if err := c.encerrarCopia(); err != nil {
+			return err
+		}
+		copia, err := c.tx.Prepare(pq.CopyIn(tabela, colunas...))
+		if err != nil {
+			return err
+		}
+		c.tabela, c.copia = tabela, copia
+	}
+	_, err := c.copia.Exec(valores...)
+	return err
+}
+
+func (c *CargaRelatorio) encerrarCopia() error {
+	if c.copia == nil {
+		return nil
+	}
+	_, err := c.copia.Exec()
+	if errClose := c.copia.Close(); err == nil {
+		err = errClose
+	}
+	c.tabela, c.copia = "", nil
+	return err
+}
+
+// Concluir encerra a cópia, junta as abas em relatorio_importacao e chama
+// semRegistro para cada linha que ficou de fora por não ter o Id na aba de
+// registro. nomes traz o nome no arquivo de cada aba lógica (PlanilhaRegistro...).
+func (c *CargaRelatorio) Concluir(nomes map[string]string, semRegistro func(LinhaRejeitada)) error {
+	if err := c.copiarIgnoradas(); err != nil {
+		return fmt.Errorf("erro ao copiar linhas do relatório: %v", err)
+	}
+	if err := c.encerrarCopia(); err != nil {
+		return fmt.Errorf("erro ao copiar linhas do relatório: %v", err)
+	}
+	c.nomes = nomes
+
+	// Tabelas temporárias não são analisadas pelo autovacuum
+	etapas := []string{
+		`ANALYZE carga_registro, carga_fato, carga_envolvidos, carga_relato`,
+		sqlMontarRelatorio,
+		`ALTER TABLE relatorio_importacao ADD PRIMARY KEY (ordem)`,
+		`ANALYZE relatorio_importacao`,
+	}
+	for _, etapa := range etapas {
+		if _, err := c.tx.Exec(etapa); err != nil {
+			return fmt.Errorf("erro ao juntar as abas do relatório: %v", err)
+		}
+	}
+	if err := c.tx.QueryRow(`SELECT COUNT(*) FROM relatorio_importacao`).Scan(&c.total); err != nil {
+		return err
+	}
+
+	rows, err := c.tx.Query(sqlIgnorarSemRegistro, nomes[PlanilhaRegistro], nomes[PlanilhaFato],
+		nomes[PlanilhaEnvolvidos], nomes[PlanilhaRelato])
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l LinhaRejeitada
+		if err := rows.Scan(&l.Planilha, &l.Linha, &l.Motivo); err != nil {
+			return err
+		}
+		semRegistro(l)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("Carga do relatório concluída: %d registros", c.total)
+	return nil
+}
+
+// sqlGuardarRejeicoes grava em import_rejeicoes, para a importação $1, as linhas
+// ignoradas na leitura e os registros descartados na gravação; estes apontam para
+// a linha do envolvido ou, no BO sem envolvidos, para a linha do registro. $2 e
+// $3 são os nomes das abas de registro e de envolvidos no arquivo.
+const sqlGuardarRejeicoes = `
+	INSERT INTO import_rejeicoes (import_id, planilha, linha, motivo, numero_do_bo, nomecompleto, cpf)
+	SELECT $1, planilha, linha, motivo, numero_do_bo, nomecompleto, cpf
+	FROM (
+		SELECT 0 AS grupo, seq AS posicao, planilha, linha, motivo,
+			'' AS numero_do_bo, '' AS nomecompleto, '' AS cpf
+		FROM carga_ignoradas
+		UNION ALL
+		SELECT 1, r.ordem,
+			CASE WHEN r.linha_envolvido IS NULL THEN $2::TEXT ELSE $3::TEXT END,
+			COALESCE(r.linha_envolvido, r.linha_registro), rc.motivo,
+			r.numero_do_bo, r.nomecompleto, r.cpf
+		FROM rejeicoes_carga rc
+		JOIN relatorio_importacao r ON r.ordem = rc.ordem
+	) rejeicoes
+	ORDER BY grupo, posicao`
+
+// guardarRejeicoes grava as rejeições da carga para a importação e retorna quantas são
+func (c *CargaRelatorio) guardarRejeicoes(importID int64) (int64, error) {
+	if importID == 0 {
+		return 0, nil
+	}
+	res, err := c.tx.Exec(sqlGuardarRejeicoes, importID, c.nomes[PlanilhaRegistro], c.nomes[PlanilhaEnvolvidos])
+	if err != nil {
+		return 0, fmt.Errorf("erro ao gravar rejeições da importação: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+// guardarIgnoradas desfaz a gravação iniciada no savepoint gravacao_carga e
+// confirma só as linhas ignoradas na leitura como rejeições da importação, para
+// que a importação que falhou ainda mostre o que foi recusado no arquivo. As
+// tabelas temporárias são descartadas no commit.
+func (c *CargaRelatorio) guardarIgnoradas(importID int64) {
+	if _, err := c.tx.Exec(`ROLLBACK TO SAVEPOINT gravacao_carga`); err != nil {
+		log.Printf("Erro ao desfazer gravação da importação %d: %v", importID, err)
+		return
+	}
+	rejeicoes, err := c.guardarRejeicoes(importID)
+	if err != nil {
+		log.Printf("Erro ao guardar linhas ignoradas da importação %d: %v", importID, err)
+		return
+	}
+	if rejeicoes == 0 {
+		return
+	}
+	if err := c.tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar linhas ignoradas da importação %d: %v", importID, err)
+	}
+}
+
+// Total é a quantidade de registros (um por envolvido) após Concluir
+func (c *CargaRelatorio) Total() int {
+	return c.total
+}
+
+// Descartar desfaz a transação da carga, se ela não foi confirmada
+func (c *CargaRelatorio) Descartar() {
+	if c.copia != nil {
+		c.copia.Close()
+		c.tabela, c.copia = "", nil
+	}
+	c.tx.Rollback()
+}
+
+// colunasRelatorio são as colunas de DadosRelatorio, na ordem de scanDadosRelatorio
+var colunasRelatorio = strings.Join([]string{
+	"numero_do_bo", "delegacia_responsavel", "situacao", "natureza", "data_fato", "cep_fato",
+	"latitude_fato", "longitude_fato", "logradouro_fato", "numerocasa_fato", "bairro_fato",
+	"municipio_fato", "pais_fato", "tipo_envolvido", "nomecompleto", "cpf", "nomedamae",
+	"nascimento", "nacionalidade", "naturalidade", "uf_envolvido", "sexo_envolvido",
+	"telefone_envolvido", "relato_historico",
+}, ", ")
+
+// amostraRelatorio lê até limite registros de uma consulta sobre relatorio_importacao
+// ou staging_importacao que seleciona colunasRelatorio
+func amostraRelatorio(tx *sql.Tx, query string, limite int) ([]DadosRelatorio, error) {
+	rows, err := tx.Query(query, limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dados := []DadosRelatorio{}
+	for rows.Next() {
+		var d DadosRelatorio
+		if err := rows.Scan(&d.NumeroBo, &d.DelegaciaResponsavel, &d.Situacao, &d.Natureza, &d.DataFato,
+			&d.CepFato, &d.LatitudeFato, &d.LongitudeFato, &d.LogradouroFato, &d.NumeroCasaFato,
+			&d.BairroFato, &d.MunicipioFato, &d.PaisFato, &d.TipoEnvolvido, &d.NomeCompleto, &d.Cpf,
+			&d.NomeDaMae, &d.Nascimento, &d.Nacionalidade, &d.Naturalidade, &d.UfEnvolvido,
+			&d.SexoEnvolvido, &d.TelefoneEnvolvido, &d.RelatoHistorico); err != nil {
+			return nil, err
+		}
+		dados = append(dados, d)
+	}
+	return dados, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"fraudbase/internal/models"
+	"fraudbase/internal/normalize"
 	"log"
 	"strings"
 )
@@ -22,6 +23,15 @@ func NewConsultaRepository(db *sql.DB) *ConsultaRepository {
 	return &ConsultaRepository{db: db}
 }
 
+// cpfBusca normaliza o CPF digitado na busca; valores que não têm a quantidade de
+// dígitos de um CPF são buscados como digitados (e não encontram nada)
+func cpfBusca(cpf string) string {
+	if normalizado := normalize.CPF(cpf); normalizado != "" {
+		return normalizado
+	}
+	return normalize.SomenteDigitos(cpf)
+}
+
 // FindEnvolvidos busca envolvidos com os filtros especificados (versão otimizada)
 func (r *ConsultaRepository) FindEnvolvidos(nome, cpf, bo, telefone string) ([]models.Envolvido, error) {
 	// Query base otimizada com LIMIT para evitar resultados excessivos
@@ -38,7 +48,7 @@ func (r *ConsultaRepository) FindEnvolvidos(nome, cpf, bo, telefone string) ([]m
 		COALESCE(delegacia_responsavel, '') as delegacia_responsavel,
 		COALESCE(situacao, '') as situacao,
 		COALESCE(natureza, '') as natureza
-	FROM tabela_estelionato
+	FROM vw_envolvidos
 	WHERE 1=1`
 
 	var params []interface{}
@@ -52,10 +62,10 @@ func (r *ConsultaRepository) FindEnvolvidos(nome, cpf, bo, telefone string) ([]m
 		paramIndex++
 	}
 
-	// Busca exata otimizada para CPF
+	// Busca exata pelo CPF normalizado (indexado)
 	if cpf != "" {
-		conditions = append(conditions, fmt.Sprintf("cpf = $%d", paramIndex))
-		params = append(params, cpf)
+		conditions = append(conditions, fmt.Sprintf("cpf_normalizado = $%d", paramIndex))
+		params = append(params, cpfBusca(cpf))
 		paramIndex++
 	}
 
@@ -66,10 +76,11 @@ func (r *ConsultaRepository) FindEnvolvidos(nome, cpf, bo, telefone string) ([]m
 		paramIndex++
 	}
 
-	// Busca exata para telefone
+	// Busca pelo telefone em E.164 (ou parcial nos dígitos), como na consulta paginada
 	if telefone != "" {
-		conditions = append(conditions, fmt.Sprintf("telefone_envolvido = $%d", paramIndex))
-		params = append(params, telefone)
+		condicao, valor := condicaoTelefone(telefone, paramIndex)
+		conditions = append(conditions, condicao)
+		params = append(params, valor)
 		paramIndex++
 	}
 
@@ -112,27 +123,23 @@ func (r *ConsultaRepository) FindEnvolvidos(nome, cpf, bo, telefone string) ([]m
 	return envolvidos, nil
 }
 
-// FindEnvolvidosPaginated busca envolvidos com paginação real
-func (r *ConsultaRepository) FindEnvolvidosPaginated(nome, cpf, bo, telefone string, page, limit int) ([]models.Envolvido, int, error) {
-	// Query para contar total de registros
-	baseCountQuery := `SELECT COUNT(*) FROM tabela_estelionato WHERE 1=1`
-	
-	// Query principal com paginação
-	baseQuery := `
-	SELECT id, numero_do_bo, tipo_envolvido, nomecompleto, cpf, 
-		COALESCE(nomedamae, '') as nomedamae,
-		COALESCE(nascimento, '') as nascimento, 
-		COALESCE(nacionalidade, '') as nacionalidade, 
-		COALESCE(naturalidade, '') as naturalidade, 
-		COALESCE(uf_envolvido, '') as uf_envolvido, 
-		COALESCE(sexo_envolvido, '') as sexo_envolvido,
-		COALESCE(telefone_envolvido, '') as telefone_envolvido, 
-		COALESCE(data_fato, '') as data_fato, 
-		COALESCE(delegacia_responsavel, '') as delegacia_responsavel, 
-		COALESCE(situacao, '') as situacao, 
-		COALESCE(natureza, '') as natureza
-	FROM tabela_estelionato WHERE 1=1`
+// condicaoTelefone monta a busca por telefone: exata no E.164 (indexado) quando o
+// número está completo; senão parcial nos dígitos, pelo índice trigram do telefone
+// normalizado ou, nos registros cujo telefone não pôde ser normalizado, nos
+// dígitos do telefone original
+func condicaoTelefone(telefone string, paramIndex int) (string, interface{}) {
+	if e164 := normalize.Telefone(telefone); e164 != "" {
+		return fmt.Sprintf("telefone_normalizado = $%d", paramIndex), e164
+	}
 
+	condicao := fmt.Sprintf(`(telefone_normalizado LIKE $%[1]d
+		OR (COALESCE(telefone_normalizado, '') = '' AND regexp_replace(telefone_envolvido, '\D', '', 'g') LIKE $%[1]d))`, paramIndex)
+	return condicao, "%" + normalize.SomenteDigitos(telefone) + "%"
+}
+
+// condicoesEnvolvidos monta os filtros da consulta de envolvidos (a partir de
+// "WHERE 1=1") e os seus parâmetros, numerados a partir de $1
+func condicoesEnvolvidos(nome, cpf, bo, telefone string) (string, []interface{}) {
 	var params []interface{}
 	var conditions []string
 	paramIndex := 1
@@ -144,12 +151,10 @@ func (r *ConsultaRepository) FindEnvolvidosPaginated(nome, cpf, bo, telefone str
 		paramIndex++
 	}
 	
-	// BUSCA POR CPF - busca exata apenas nos números
+	// BUSCA POR CPF - busca exata no CPF normalizado (indexado)
 	if cpf != "" {
-		// Remove caracteres não numéricos do CPF para busca
-		cleanCPF := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(cpf, ".", ""), "-", ""), " ", "")
-		conditions = append(conditions, fmt.Sprintf("REGEXP_REPLACE(cpf, '[^0-9]', '', 'g') = $%d", paramIndex))
-		params = append(params, cleanCPF)
+		conditions = append(conditions, fmt.Sprintf("cpf_normalizado = $%d", paramIndex))
+		params = append(params, cpfBusca(cpf))
 		paramIndex++
 	}
 	
@@ -160,21 +165,47 @@ func (r *ConsultaRepository) FindEnvolvidosPaginated(nome, cpf, bo, telefone str
 		paramIndex++
 	}
 	
-	// BUSCA POR TELEFONE - busca parcial nos números do telefone
+	// BUSCA POR TELEFONE - exata no E.164 quando o número está completo,
+	// senão parcial nos dígitos
 	if telefone != "" {
-		// Remove caracteres não numéricos para busca mais flexível
-		cleanTelefone := strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(telefone, "+", ""), " ", ""), "(", ""), ")", "")
-		conditions = append(conditions, fmt.Sprintf("REGEXP_REPLACE(telefone_envolvido, '[^0-9]', '', 'g') LIKE $%d", paramIndex))
-		params = append(params, "%"+cleanTelefone+"%") // Busca parcial
+		condicao, valor := condicaoTelefone(telefone, paramIndex)
+		conditions = append(conditions, condicao)
+		params = append(params, valor)
 		paramIndex++
 	}
 
-	// Anexar condições
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = " AND " + strings.Join(conditions, " AND ")
 	}
 
+	return whereClause, params
+}
+
+// FindEnvolvidosPaginated busca envolvidos com paginação real
+func (r *ConsultaRepository) FindEnvolvidosPaginated(nome, cpf, bo, telefone string, page, limit int) ([]models.Envolvido, int, error) {
+	// Query para contar total de registros
+	baseCountQuery := `SELECT COUNT(*) FROM vw_envolvidos WHERE 1=1`
+	
+	// Query principal com paginação
+	baseQuery := `
+	SELECT id, numero_do_bo, tipo_envolvido, nomecompleto, cpf, 
+		COALESCE(nomedamae, '') as nomedamae,
+		COALESCE(nascimento, '') as nascimento, 
+		COALESCE(nacionalidade, '') as nacionalidade, 
+		COALESCE(naturalidade, '') as naturalidade, 
+		COALESCE(uf_envolvido, '') as uf_envolvido, 
+		COALESCE(sexo_envolvido, '') as sexo_envolvido,
+		COALESCE(telefone_envolvido, '') as telefone_envolvido, 
+		COALESCE(data_fato, '') as data_fato, 
+		COALESCE(delegacia_responsavel, '') as delegacia_responsavel, 
+		COALESCE(situacao, '') as situacao, 
+		COALESCE(natureza, '') as natureza
+	FROM vw_envolvidos WHERE 1=1`
+
+	whereClause, params := condicoesEnvolvidos(nome, cpf, bo, telefone)
+	paramIndex := len(params) + 1
+
 	// Contar total de registros
 	countQuery := baseCountQuery + whereClause
 	var totalCount int
@@ -220,10 +251,10 @@ func (r *ConsultaRepository) FindEnvolvidosPaginated(nome, cpf, bo, telefone str
 	return envolvidos, totalCount, nil
 }
 
-// FindEnvolvidoById busca um envolvido específico pelo ID
-func (r *ConsultaRepository) FindEnvolvidoById(id int) (models.Envolvido, error) {
-	query := `
-	SELECT id, numero_do_bo, tipo_envolvido, nomecompleto, cpf,
+// colunasEnvolvidoCompleto são todas as colunas de models.Envolvido em vw_envolvidos,
+// na ordem de scanEnvolvidoCompleto
+const colunasEnvolvidoCompleto = `
+		id, numero_do_bo, tipo_envolvido, nomecompleto, cpf,
 		COALESCE(nomedamae, '') as nomedamae,
 		COALESCE(nascimento, '') as nascimento,
 		COALESCE(nacionalidade, '') as nacionalidade,
@@ -260,11 +291,11 @@ func (r *ConsultaRepository) FindEnvolvidoById(id int) (models.Envolvido, error)
 		COALESCE(terminal_conexao, '') as terminal_conexao,
 		COALESCE(erb, '') as erb,
 		COALESCE(operacao_policial, '') as operacao_policial,
-		COALESCE(numero_laudo_pericial, '') as numero_laudo_pericial
-	FROM tabela_estelionato WHERE id = $1`
+		COALESCE(numero_laudo_pericial, '') as numero_laudo_pericial`
 
+func scanEnvolvidoCompleto(s scanner) (models.Envolvido, error) {
 	var e models.Envolvido
-	err := r.db.QueryRow(query, id).Scan(
+	err := s.Scan(
 		&e.ID, &e.NumeroBO, &e.TipoEnvolvido, &e.NomeCompleto, &e.CPF, &e.NomeMae,
 		&e.Nascimento, &e.Nacionalidade, &e.Naturalidade, &e.UFEnvolvido, &e.SexoEnvolvido,
 		&e.TelefoneEnvolvido, &e.DataFato, &e.CEPFato, &e.LatitudeFato, &e.LongitudeFato,
@@ -275,7 +306,12 @@ func (r *ConsultaRepository) FindEnvolvidoById(id int) (models.Envolvido, error)
 		&e.OrgaoConcessionaria, &e.Veiculo, &e.TerminalConexao, &e.ERB, &e.OperacaoPolicial,
 		&e.NumeroLaudoPericial,
 	)
+	return e, err
+}
 
+// FindEnvolvidoById busca um envolvido específico pelo ID
+func (r *ConsultaRepository) FindEnvolvidoById(id int) (models.Envolvido, error) {
+	e, err := scanEnvolvidoCompleto(r.db.QueryRow(`SELECT `+colunasEnvolvidoCompleto+` FROM vw_envolvidos WHERE id = $1`, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.Envolvido{}, ErrNotFound
@@ -286,3 +322,40 @@ func (r *ConsultaRepository) FindEnvolvidoById(id int) (models.Envolvido, error)
 
 	return e, nil
 }
+
+// ContarEnvolvidos conta os envolvidos que atendem aos filtros de FindEnvolvidosPaginated
+func (r *ConsultaRepository) ContarEnvolvidos(nome, cpf, bo, telefone string) (int, error) {
+	whereClause, params := condicoesEnvolvidos(nome, cpf, bo, telefone)
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM vw_envolvidos WHERE 1=1`+whereClause, params...).Scan(&total); err != nil {
+		log.Printf("Erro na consulta de contagem: %v", err)
+		return 0, err
+	}
+	return total, nil
+}
+
+// ExportarEnvolvidos percorre, com todas as colunas e na ordem da consulta
+// paginada, todos os envolvidos que atendem aos filtros de FindEnvolvidosPaginated
+func (r *ConsultaRepository) ExportarEnvolvidos(nome, cpf, bo, telefone string, fn func(models.Envolvido) error) error {
+	whereClause, params := condicoesEnvolvidos(nome, cpf, bo, telefone)
+
+	rows, err := r.db.Query(`SELECT `+colunasEnvolvidoCompleto+` FROM vw_envolvidos WHERE 1=1`+whereClause+` ORDER BY id DESC`, params...)
+	if err != nil {
+		log.Printf("Erro ao exportar envolvidos: %v", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEnvolvidoCompleto(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
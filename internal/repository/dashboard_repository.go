@@ -74,7 +74,7 @@ func (r *DashboardRepository) getVitimasPorSexoFallback() ([]SexoStats, error) {
 		SELECT 
 			sexo_envolvido AS sexo,
 			COUNT(*) AS quantidade
-		FROM tabela_estelionato
+		FROM vw_envolvidos
 		WHERE tipo_envolvido IN ('Comunicante, Vítima', 'Vítima') 
 		  AND sexo_envolvido IN ('Masculino', 'Feminino')
 		GROUP BY sexo_envolvido
@@ -98,7 +98,7 @@ func (r *DashboardRepository) getVitimasPorSexoFallback() ([]SexoStats, error) {
 	return stats, nil
 }
 
-// GetVitimasPorFaixaEtaria retorna estatísticas de vítimas por faixa etária
+// GetVitimasPorFaixaEtaria retorna estatísticas de vítimas por faixa etária (usa a coluna tipada nascimento_data)
 func (r *DashboardRepository) GetVitimasPorFaixaEtaria() ([]FaixaEtariaStats, error) {
 	query := `
 	SELECT
@@ -111,11 +111,12 @@ func (r *DashboardRepository) GetVitimasPorFaixaEtaria() ([]FaixaEtariaStats, er
 		COUNT(*) AS quantidade
 	FROM (
 		SELECT
-			EXTRACT(YEAR FROM AGE(CURRENT_DATE, TO_DATE(nascimento, 'DD/MM/YYYY'))) AS idade
+			EXTRACT(YEAR FROM AGE(CURRENT_DATE, nascimento_data)) AS idade
 		FROM
-			tabela_estelionato
+			vw_envolvidos
 		WHERE
 			tipo_envolvido IN ('Comunicante, Vítima', 'Vítima')
+			AND nascimento_data IS NOT NULL
 	) AS subquery
 	GROUP BY
 		faixa_etaria
@@ -160,7 +161,8 @@ func (r *DashboardRepository) GetQuantidadeBOs() (CountStats, error) {
 	err := r.db.QueryRow(query).Scan(&stats.Quantidade)
 	if err != nil {
 		// Fallback para query tradicional
-		query = `SELECT COUNT(DISTINCT numero_do_bo) AS quantidade FROM tabela_estelionato;`
+		query = `SELECT COUNT(*) AS quantidade FROM ocorrencias o WHERE o.numero_do_bo IS NOT NULL
+			AND EXISTS (SELECT 1 FROM vw_envolvidos v WHERE v.ocorrencia_id = o.id);`
 		err = r.db.QueryRow(query).Scan(&stats.Quantidade)
 		if err != nil {
 			log.Printf("Erro ao consultar quantidade de BOs: %v", err)
@@ -179,7 +181,7 @@ func (r *DashboardRepository) GetQuantidadeInfratores() (CountStats, error) {
 	err := r.db.QueryRow(query).Scan(&stats.Quantidade)
 	if err != nil {
 		// Fallback para query tradicional
-		query = `SELECT COUNT(*) AS quantidade FROM tabela_estelionato WHERE tipo_envolvido = 'Suposto Autor/infrator';`
+		query = `SELECT COUNT(*) AS quantidade FROM vw_envolvidos WHERE tipo_envolvido = 'Suposto Autor/infrator';`
 		err = r.db.QueryRow(query).Scan(&stats.Quantidade)
 		if err != nil {
 			log.Printf("Erro ao consultar quantidade de infratores: %v", err)
@@ -198,7 +200,7 @@ func (r *DashboardRepository) GetQuantidadeVitimas() (CountStats, error) {
 	err := r.db.QueryRow(query).Scan(&stats.Quantidade)
 	if err != nil {
 		// Fallback para query tradicional
-		query = `SELECT COUNT(*) AS quantidade FROM tabela_estelionato WHERE tipo_envolvido IN ('Comunicante, Vítima', 'Vítima');`
+		query = `SELECT COUNT(*) AS quantidade FROM vw_envolvidos WHERE tipo_envolvido IN ('Comunicante, Vítima', 'Vítima');`
 		err = r.db.QueryRow(query).Scan(&stats.Quantidade)
 		if err != nil {
 			log.Printf("Erro ao consultar quantidade de vítimas: %v", err)
@@ -218,7 +220,7 @@ func (r *DashboardRepository) GetInfratoresPorDelegacia() ([]InfratoresPorDelega
 		// Fallback para query tradicional
 		query = `
 			SELECT delegacia_responsavel, COUNT(*) AS quantidade
-			FROM tabela_estelionato
+			FROM vw_envolvidos
 			WHERE tipo_envolvido = 'Suposto Autor/infrator'
 			  AND delegacia_responsavel != ''
 			GROUP BY delegacia_responsavel
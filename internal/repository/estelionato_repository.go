@@ -2,8 +2,16 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fraudbase/internal/database"
+	"fraudbase/internal/models"
+	"fraudbase/internal/normalize"
+	"fraudbase/internal/validacao"
 	"log"
-	"time"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
 type EnvolvidoRepository struct {
@@ -59,62 +67,449 @@ func NewEnvolvidoRepository(db *sql.DB) *EnvolvidoRepository {
 	return &EnvolvidoRepository{db: db}
 }
 
-func (r *EnvolvidoRepository) CreateEnvolvido(e Envolvido) (string, error) {
-	log.Println("Iniciando cadastro de envolvido em caso de estelionato")
+// converterEnvolvido valida os dados recebidos do formulário, substitui os valores
+// pela forma canônica e calcula as colunas tipadas. Campos inválidos são
+// retornados como validacao.Erros.
+func converterEnvolvido(e Envolvido) (models.Envolvido, database.CamposTipados, error) {
+	registro := models.Envolvido{
+		NumeroBO: e.NumeroDoBo, TipoEnvolvido: e.TipoEnvolvido, NomeCompleto: e.NomeCompleto, CPF: e.CPF,
+		NomeMae: e.NomeDaMae, Nascimento: e.Nascimento, Nacionalidade: e.Nacionalidade, Naturalidade: e.Naturalidade,
+		UFEnvolvido: e.UFEnvolvido, SexoEnvolvido: e.SexoEnvolvido, TelefoneEnvolvido: e.TelefoneEnvolvido,
+		DataFato: e.DataFato, CEPFato: e.CEPFato, LatitudeFato: e.LatitudeFato, LongitudeFato: e.LongitudeFato,
+		LogradouroFato: e.LogradouroFato, NumeroCasaFato: e.NumeroCasaFato, BairroFato: e.BairroFato,
+		MunicipioFato: e.MunicipioFato, PaisFato: e.PaisFato, DelegaciaResponsavel: e.DelegaciaResponsavel,
+		Situacao: e.Situacao, Natureza: e.Natureza, RelatoHistorico: e.RelatoHistorico,
+		InstituicaoBancaria: e.InstituicaoBancaria, EnderecoIP: e.EnderecoIP, Valor: e.Valor,
+		PixUtilizado: e.PixUtilizado, NumeroContaBancaria: e.NumeroContaBancaria, NumeroBoleto: e.NumeroBoleto,
+		ProcessoBanco: e.ProcessoBanco, NumeroAgenciaBancaria: e.NumeroAgenciaBancaria, Cartao: e.Cartao,
+		Terminal: e.Terminal, TipoPagamento: e.TipoPagamento, OrgaoConcessionaria: e.OrgaoConcessionaria,
+		Veiculo: e.Veiculo, TerminalConexao: e.TerminalConexao, ERB: e.ERB, OperacaoPolicial: e.OperacaoPolicial,
+		NumeroLaudoPericial: e.NumeroLaudoPericial,
+	}
 	
-	// Converter data de nascimento para formato brasileiro
-	nascimento := e.Nascimento
-	if nascimento != "" {
-		if t, err := time.Parse("2006-01-02", nascimento); err == nil {
-			nascimento = t.Format("02/01/2006")
-		}
+	if erros := validacao.Envolvido(&registro); erros != nil {
+		return registro, database.CamposTipados{}, erros
 	}
 	
-	// Converter data do fato para formato brasileiro
-	dataFato := e.DataFato
-	if dataFato != "" {
-		if t, err := time.Parse("2006-01-02", dataFato); err == nil {
-			dataFato = t.Format("02/01/2006")
-		}
+	// Converter campos textuais para as colunas tipadas
+	tipados := database.ConverterCamposTipados(registro.DataFato, registro.Nascimento, registro.Valor,
+		registro.LatitudeFato, registro.LongitudeFato)
+	
+	return registro, tipados, nil
+}
+
+// AvisosCadastro são os valores de um cadastro que não foram gravados como enviados
+type AvisosCadastro struct {
+	// NaoConvertidos foram gravados só como texto, sem a coluna tipada
+	NaoConvertidos []database.CampoInvalido
+	// Mantidos são os campos do BO ou da pessoa já cadastrados com valor diferente
+	// do enviado, que continuam com o valor gravado
+	Mantidos []string
+}
+
+// CreateEnvolvido cadastra o envolvido e retorna o id e os avisos do cadastro
+func (r *EnvolvidoRepository) CreateEnvolvido(e Envolvido, autor Autor) (string, AvisosCadastro, error) {
+	log.Println("Iniciando cadastro de envolvido em caso de estelionato")
+	
+	registro, tipados, err := converterEnvolvido(e)
+	if err != nil {
+		return "", AvisosCadastro{}, err
 	}
 	
-	query := `
-		INSERT INTO tabela_estelionato (
-			numero_do_bo, tipo_envolvido, nomecompleto, cpf, nomedamae, 
-			nascimento, nacionalidade, naturalidade, uf_envolvido, sexo_envolvido, 
-			telefone_envolvido, data_fato, cep_fato, latitude_fato, longitude_fato, 
-			logradouro_fato, numerocasa_fato, bairro_fato, municipio_fato, pais_fato, 
-			delegacia_responsavel, situacao, natureza, relato_historico, instituicao_bancaria, 
-			endereco_ip, valor, pix_utilizado, numero_conta_bancaria, numero_boleto, 
-			processo_banco, numero_agencia_bancaria, cartao, terminal, tipo_pagamento, 
-			orgao_concessionaria, veiculo, terminal_conexao, erb, operacao_policial, 
-			numero_laudo_pericial
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 
-			$11, $12, $13, $14, $15, $16, $17, $18, $19, $20, 
-			$21, $22, $23, $24, $25, $26, $27, $28, $29, $30, 
-			$31, $32, $33, $34, $35, $36, $37, $38, $39, $40, $41
-		) RETURNING id`
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		return "", AvisosCadastro{}, err
+	}
+	defer tx.Rollback()
 	
-	var id string
-	err := r.db.QueryRow(
-		query,
-		e.NumeroDoBo, e.TipoEnvolvido, e.NomeCompleto, e.CPF, e.NomeDaMae,
-		nascimento, e.Nacionalidade, e.Naturalidade, e.UFEnvolvido, e.SexoEnvolvido, 
-		e.TelefoneEnvolvido, dataFato, e.CEPFato, e.LatitudeFato, e.LongitudeFato,
-		e.LogradouroFato, e.NumeroCasaFato, e.BairroFato, e.MunicipioFato, e.PaisFato,
-		e.DelegaciaResponsavel, e.Situacao, e.Natureza, e.RelatoHistorico, e.InstituicaoBancaria,
-		e.EnderecoIP, e.Valor, e.PixUtilizado, e.NumeroContaBancaria, e.NumeroBoleto,
-		e.ProcessoBanco, e.NumeroAgenciaBancaria, e.Cartao, e.Terminal, e.TipoPagamento,
-		e.OrgaoConcessionaria, e.Veiculo, e.TerminalConexao, e.ERB, e.OperacaoPolicial,
-		e.NumeroLaudoPericial,
-	).Scan(&id)
+	gravador, err := novoGravadorEnvolvidos(tx, AcaoCriacao, autor, 0)
+	if err != nil {
+		log.Printf("Erro ao preparar gravação do envolvido: %v", err)
+		return "", AvisosCadastro{}, err
+	}
+	defer gravador.Close()
 	
+	novoID, mantidos, err := gravador.Gravar(registro, tipados)
 	if err != nil {
-		log.Printf("Erro ao inserir dados na tabela_estelionato: %v", err)
-		return "", err
+		log.Printf("Erro ao inserir envolvido: %v", err)
+		return "", AvisosCadastro{}, err
+	}
+	
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar cadastro do envolvido: %v", err)
+		return "", AvisosCadastro{}, err
 	}
+	id := strconv.Itoa(novoID)
 	
 	log.Printf("Envolvido cadastrado com sucesso. ID: %s", id)
-	return id, nil
+	avisos := AvisosCadastro{Mantidos: mantidos}
+	for _, campo := range tipados.Invalidos {
+		if !contem(mantidos, campo.Campo) {
+			avisos.NaoConvertidos = append(avisos.NaoConvertidos, campo)
+		}
+	}
+	if len(mantidos) > 0 {
+		log.Printf("Envolvido %s: campos já cadastrados mantidos: %v", id, mantidos)
+	}
+	return id, avisos, nil
+}
+
+// ErrSemPermissao indica que o usuário não pode alterar o registro
+var ErrSemPermissao = errors.New("usuário sem permissão para alterar o registro")
+
+// registroAtual é a situação de uma participação antes da alteração
+type registroAtual struct {
+	ocorrenciaID int
+	pessoaID     int
+	numeroBO     sql.NullString
+	chavePessoa  sql.NullString
+}
+
+// travarParaAlteracao bloqueia a participação até o fim da transação e confere se
+// o autor pode alterá-la: administradores alteram qualquer registro; os demais,
+// apenas os cadastrados pela sua unidade policial
+func travarParaAlteracao(tx *sql.Tx, id int, autor Autor) (registroAtual, error) {
+	var atual registroAtual
+	var unidadeRegistro, unidadeAutor string
+
+	err := tx.QueryRow(`
+		SELECT pa.ocorrencia_id, pa.pessoa_id, o.numero_do_bo, pe.chave_identidade,
+			COALESCE(pa.unidade_cadastro, ''),
+			COALESCE((SELECT unidade_policial FROM usuarios WHERE id = $2), '')
+		FROM participacoes pa
+		JOIN ocorrencias o ON o.id = pa.ocorrencia_id
+		JOIN pessoas pe ON pe.id = pa.pessoa_id
+		WHERE pa.id = $1 AND pa.excluido_em IS NULL
+		FOR UPDATE OF pa`, id, autor.UsuarioID,
+	).Scan(&atual.ocorrenciaID, &atual.pessoaID, &atual.numeroBO, &atual.chavePessoa, &unidadeRegistro, &unidadeAutor)
+	if err == sql.ErrNoRows {
+		return atual, ErrNotFound
+	}
+	if err != nil {
+		return atual, err
+	}
+
+	if !autor.Admin {
+		unidadeRegistro = strings.TrimSpace(unidadeRegistro)
+		if unidadeRegistro == "" || !strings.EqualFold(unidadeRegistro, strings.TrimSpace(unidadeAutor)) {
+			return atual, ErrSemPermissao
+		}
+	}
+
+	return atual, nil
+}
+
+// ErrDadosCompartilhados indica alteração nos dados de um BO ou de uma pessoa que
+// também têm registros cadastrados por outra unidade
+var ErrDadosCompartilhados = errors.New("dados do BO ou da pessoa compartilhados com registros de outra unidade")
+
+// podeAlterarCompartilhado confere se o autor pode reescrever a ocorrência ou a
+// pessoa (coluna ocorrencia_id ou pessoa_id das participações): administradores
+// sempre; os demais, apenas se todos os registros ligados a ela, inclusive os
+// excluídos, foram cadastrados pela sua unidade
+func podeAlterarCompartilhado(tx *sql.Tx, coluna string, id int, autor Autor) (bool, error) {
+	if autor.Admin {
+		return true, nil
+	}
+	var pode bool
+	err := tx.QueryRow(`
+		SELECT NOT EXISTS (
+			SELECT 1 FROM participacoes pa
+			WHERE pa.`+coluna+` = $1
+				AND UPPER(TRIM(COALESCE(pa.unidade_cadastro, ''))) IS DISTINCT FROM
+					UPPER(TRIM(COALESCE((SELECT unidade_policial FROM usuarios WHERE id = $2), ''))))`,
+		id, autor.UsuarioID).Scan(&pode)
+	return pode, err
+}
+
+// UpdateEnvolvido substitui os dados de um envolvido. O BO e a pessoa são
+// resolvidos como na importação: se o número do BO ou a identidade da pessoa
+// (CPF ou nome + mãe + nascimento) mudarem, o registro passa a apontar para a
+// ocorrência/pessoa correspondente. Os dados do BO e da pessoa são compartilhados:
+// só são reescritos conforme podeAlterarCompartilhado, e os demais registros do
+// mesmo BO ou da mesma pessoa também entram no histórico.
+func (r *EnvolvidoRepository) UpdateEnvolvido(id int, e Envolvido, autor Autor) error {
+	registro, tipados, err := converterEnvolvido(e)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	atual, err := travarParaAlteracao(tx, id, autor)
+	if err != nil {
+		return err
+	}
+
+	// Ocorrência: mesmo número de BO reaproveita a atual; outro número passa a
+	// apontar para a ocorrência existente desse BO ou cria uma nova
+	ocorrenciaID := atual.ocorrenciaID
+	ocorrenciaNova := false
+	if registro.NumeroBO != atual.numeroBO.String {
+		err = tx.QueryRow("SELECT id FROM ocorrencias WHERE numero_do_bo = NULLIF($1, '')", registro.NumeroBO).Scan(&ocorrenciaID)
+		if err == sql.ErrNoRows {
+			ocorrenciaNova = true
+			err = tx.QueryRow(sqlGravarOcorrencia,
+				registro.NumeroBO, registro.DataFato, registro.CEPFato, registro.LatitudeFato, registro.LongitudeFato,
+				registro.LogradouroFato, registro.NumeroCasaFato, registro.BairroFato, registro.MunicipioFato,
+				registro.PaisFato, registro.DelegaciaResponsavel, registro.Situacao, registro.Natureza,
+				registro.RelatoHistorico, tipados.DataFato, tipados.Latitude, tipados.Longitude,
+			).Scan(&ocorrenciaID)
+		}
+		if err != nil {
+			log.Printf("Erro ao resolver ocorrência do envolvido %d: %v", id, err)
+			return err
+		}
+	}
+
+	// Pessoa: mesma chave de identidade reaproveita a atual; outra chave passa a
+	// apontar para a pessoa existente com essa chave ou cria uma nova
+	pessoaID := atual.pessoaID
+	pessoaNova := false
+	var novaChave sql.NullString
+	err = tx.QueryRow("SELECT chave_pessoa($1, $2, $3, $4)",
+		registro.CPF, registro.NomeCompleto, registro.NomeMae, registro.Nascimento).Scan(&novaChave)
+	if err != nil {
+		return err
+	}
+	if novaChave != atual.chavePessoa {
+		err = tx.QueryRow("SELECT id FROM pessoas WHERE chave_identidade = $1", novaChave).Scan(&pessoaID)
+		if err == sql.ErrNoRows {
+			pessoaNova = true
+			err = tx.QueryRow(sqlGravarPessoa,
+				registro.NomeCompleto, registro.CPF, registro.NomeMae, registro.Nascimento, registro.Nacionalidade,
+				registro.Naturalidade, registro.UFEnvolvido, registro.SexoEnvolvido, tipados.Nascimento,
+				normalize.CPF(registro.CPF),
+			).Scan(&pessoaID)
+		}
+		if err != nil {
+			log.Printf("Erro ao resolver pessoa do envolvido %d: %v", id, err)
+			return err
+		}
+	}
+
+	// Ocorrência e pessoa já existentes são compartilhadas: só são reescritas por
+	// administradores ou pela unidade dona de todos os registros ligados a elas.
+	// Sem essa permissão o registro apenas passa a apontar para elas; alterar os
+	// dados da ocorrência/pessoa atual é recusado.
+	alterarOcorrencia := ocorrenciaNova
+	if !alterarOcorrencia {
+		if alterarOcorrencia, err = podeAlterarCompartilhado(tx, "ocorrencia_id", ocorrenciaID, autor); err != nil {
+			return err
+		}
+	}
+	if !alterarOcorrencia && ocorrenciaID == atual.ocorrenciaID {
+		var mudou bool
+		err = tx.QueryRow(`
+			SELECT (data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato, numerocasa_fato,
+				bairro_fato, municipio_fato, pais_fato, delegacia_responsavel, situacao, natureza, relato_historico)
+			IS DISTINCT FROM ($2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			FROM ocorrencias WHERE id = $1`,
+			ocorrenciaID, registro.DataFato, registro.CEPFato, registro.LatitudeFato, registro.LongitudeFato,
+			registro.LogradouroFato, registro.NumeroCasaFato, registro.BairroFato, registro.MunicipioFato,
+			registro.PaisFato, registro.DelegaciaResponsavel, registro.Situacao, registro.Natureza,
+			registro.RelatoHistorico).Scan(&mudou)
+		if err != nil {
+			return err
+		}
+		if mudou {
+			return ErrDadosCompartilhados
+		}
+	}
+
+	alterarPessoa := pessoaNova
+	if !alterarPessoa {
+		if alterarPessoa, err = podeAlterarCompartilhado(tx, "pessoa_id", pessoaID, autor); err != nil {
+			return err
+		}
+	}
+	if !alterarPessoa && pessoaID == atual.pessoaID {
+		var mudou bool
+		err = tx.QueryRow(`
+			SELECT (nomecompleto, cpf, nomedamae, nascimento, nacionalidade, naturalidade, uf_envolvido, sexo_envolvido)
+			IS DISTINCT FROM ($2, $3, $4, $5, $6, $7, $8, $9)
+			FROM pessoas WHERE id = $1`,
+			pessoaID, registro.NomeCompleto, registro.CPF, registro.NomeMae, registro.Nascimento,
+			registro.Nacionalidade, registro.Naturalidade, registro.UFEnvolvido, registro.SexoEnvolvido).Scan(&mudou)
+		if err != nil {
+			return err
+		}
+		if mudou {
+			return ErrDadosCompartilhados
+		}
+	}
+
+	// Guardar o estado de todos os registros afetados antes de alterar
+	var afetados []int
+	rows, err := tx.Query(`
+		SELECT id FROM participacoes
+		WHERE excluido_em IS NULL
+			AND (id = $1 OR ocorrencia_id = ANY($2::int[]) OR pessoa_id = ANY($3::int[]))`,
+		id, pq.Array([]int{atual.ocorrenciaID, ocorrenciaID}), pq.Array([]int{atual.pessoaID, pessoaID}))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var afetado int
+		if err := rows.Scan(&afetado); err != nil {
+			rows.Close()
+			return err
+		}
+		afetados = append(afetados, afetado)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := capturarEstado(tx, afetados); err != nil {
+		return err
+	}
+
+	if alterarOcorrencia {
+		_, err = tx.Exec(`
+			UPDATE ocorrencias SET
+				data_fato = $2, cep_fato = $3, latitude_fato = $4, longitude_fato = $5, logradouro_fato = $6,
+				numerocasa_fato = $7, bairro_fato = $8, municipio_fato = $9, pais_fato = $10,
+				delegacia_responsavel = $11, situacao = $12, natureza = $13, relato_historico = $14,
+				data_fato_ts = $15, latitude_fato_num = $16, longitude_fato_num = $17
+			WHERE id = $1`,
+			ocorrenciaID, registro.DataFato, registro.CEPFato, registro.LatitudeFato, registro.LongitudeFato,
+			registro.LogradouroFato, registro.NumeroCasaFato, registro.BairroFato, registro.MunicipioFato,
+			registro.PaisFato, registro.DelegaciaResponsavel, registro.Situacao, registro.Natureza,
+			registro.RelatoHistorico, tipados.DataFato, tipados.Latitude, tipados.Longitude)
+		if err != nil {
+			log.Printf("Erro ao atualizar ocorrência do envolvido %d: %v", id, err)
+			return err
+		}
+	}
+
+	if alterarPessoa {
+		_, err = tx.Exec(`
+			UPDATE pessoas SET
+				chave_identidade = $2, nomecompleto = $3, cpf = $4, nomedamae = $5, nascimento = $6,
+				nacionalidade = $7, naturalidade = $8, uf_envolvido = $9, sexo_envolvido = $10, nascimento_data = $11,
+				cpf_normalizado = $12
+			WHERE id = $1`,
+			pessoaID, novaChave, registro.NomeCompleto, registro.CPF, registro.NomeMae, registro.Nascimento,
+			registro.Nacionalidade, registro.Naturalidade, registro.UFEnvolvido, registro.SexoEnvolvido, tipados.Nascimento,
+			normalize.CPF(registro.CPF))
+		if err != nil {
+			log.Printf("Erro ao atualizar pessoa do envolvido %d: %v", id, err)
+			return err
+		}
+	}
+
+	_, chavePix := normalize.ChavePix(registro.PixUtilizado)
+	_, err = tx.Exec(`
+		UPDATE participacoes SET
+			ocorrencia_id = $2, pessoa_id = $3, tipo_envolvido = $4, telefone_envolvido = $5,
+			instituicao_bancaria = $6, endereco_ip = $7, valor = $8, pix_utilizado = $9,
+			numero_conta_bancaria = $10, numero_boleto = $11, processo_banco = $12,
+			numero_agencia_bancaria = $13, cartao = $14, terminal = $15, tipo_pagamento = $16,
+			orgao_concessionaria = $17, veiculo = $18, terminal_conexao = $19, erb = $20,
+			operacao_policial = $21, numero_laudo_pericial = $22, valor_numerico = $23,
+			telefone_normalizado = $24, pix_normalizado = $25, conta_normalizada = $26,
+			campos_tipados = TRUE, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		id, ocorrenciaID, pessoaID, registro.TipoEnvolvido, registro.TelefoneEnvolvido,
+		registro.InstituicaoBancaria, registro.EnderecoIP, registro.Valor, registro.PixUtilizado,
+		registro.NumeroContaBancaria, registro.NumeroBoleto, registro.ProcessoBanco,
+		registro.NumeroAgenciaBancaria, registro.Cartao, registro.Terminal, registro.TipoPagamento,
+		registro.OrgaoConcessionaria, registro.Veiculo, registro.TerminalConexao, registro.ERB,
+		registro.OperacaoPolicial, registro.NumeroLaudoPericial, tipados.Valor,
+		normalize.Telefone(registro.TelefoneEnvolvido), chavePix,
+		normalize.ContaBancaria(registro.InstituicaoBancaria, registro.NumeroAgenciaBancaria, registro.NumeroContaBancaria))
+	if err != nil {
+		log.Printf("Erro ao atualizar envolvido %d: %v", id, err)
+		return err
+	}
+
+	// Os erros de conversão passam a ser os dos valores novos
+	if _, err := tx.Exec(`DELETE FROM tabela_estelionato_conversao_erros WHERE registro_id = $1`, id); err != nil {
+		return err
+	}
+	for _, campo := range tipados.Invalidos {
+		if _, err := tx.Exec(sqlGravarConversaoErro, id, campo.Campo, campo.Valor); err != nil {
+			return err
+		}
+	}
+
+	// Ocorrência e pessoa anteriores podem ter ficado sem participação
+	if ocorrenciaID != atual.ocorrenciaID {
+		_, err = tx.Exec(`DELETE FROM ocorrencias o WHERE o.id = $1
+			AND NOT EXISTS (SELECT 1 FROM participacoes pa WHERE pa.ocorrencia_id = o.id)`, atual.ocorrenciaID)
+		if err != nil {
+			return err
+		}
+	}
+	if pessoaID != atual.pessoaID {
+		_, err = tx.Exec(`DELETE FROM pessoas pe WHERE pe.id = $1
+			AND NOT EXISTS (SELECT 1 FROM participacoes pa WHERE pa.pessoa_id = pe.id)`, atual.pessoaID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := registrarHistoricoCapturado(tx, id, AcaoEdicao, autor, ""); err != nil {
+		log.Printf("Erro ao registrar histórico do envolvido %d: %v", id, err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar alteração do envolvido %d: %v", id, err)
+		return err
+	}
+
+	log.Printf("Envolvido %d alterado por %s", id, autor.Login)
+	go database.RefreshMaterializedViews(r.db)
+	return nil
+}
+
+// DeleteEnvolvido exclui logicamente um envolvido, guardando autor e motivo. O
+// registro deixa de aparecer nas consultas, mas o histórico é preservado.
+func (r *EnvolvidoRepository) DeleteEnvolvido(id int, motivo string, autor Autor) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Erro ao iniciar transação: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := travarParaAlteracao(tx, id, autor); err != nil {
+		return err
+	}
+
+	if err := capturarEstado(tx, []int{id}); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE participacoes
+		SET excluido_em = CURRENT_TIMESTAMP, excluido_por = NULLIF($2, 0), motivo_exclusao = $3,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, id, autor.UsuarioID, motivo)
+	if err != nil {
+		log.Printf("Erro ao excluir envolvido %d: %v", id, err)
+		return err
+	}
+
+	if err := registrarHistoricoCapturado(tx, id, AcaoExclusao, autor, motivo); err != nil {
+		log.Printf("Erro ao registrar histórico do envolvido %d: %v", id, err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar exclusão do envolvido %d: %v", id, err)
+		return err
+	}
+
+	log.Printf("Envolvido %d excluído por %s: %s", id, autor.Login, motivo)
+	go database.RefreshMaterializedViews(r.db)
+	return nil
 }
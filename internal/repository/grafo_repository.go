@@ -0,0 +1,420 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fraudbase/internal/normalize"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrIdentificadorInvalido é retornado quando o ponto de partida do grafo não pode ser normalizado
+var ErrIdentificadorInvalido = errors.New("identificador de partida inválido")
+
+// Tipos de nó do grafo de vínculos
+const (
+	NoPessoa   = "pessoa"
+	NoBO       = "bo"
+	NoTelefone = "telefone"
+	NoPix      = "pix"
+	NoConta    = "conta"
+)
+
+// GrafoNo representa uma entidade do grafo (pessoa, BO, telefone, chave PIX ou conta)
+type GrafoNo struct {
+	ID        string            `json:"id"`
+	Tipo      string            `json:"tipo"`
+	Rotulo    string            `json:"rotulo"`
+	Atributos map[string]string `json:"atributos,omitempty"`
+}
+
+// GrafoAresta representa um vínculo entre dois nós
+type GrafoAresta struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Tipo   string `json:"tipo"`
+	Rotulo string `json:"rotulo,omitempty"`
+}
+
+// Grafo é o resultado da expansão de vínculos a partir de um identificador
+type Grafo struct {
+	Nodes    []GrafoNo     `json:"nodes"`
+	Edges    []GrafoAresta `json:"edges"`
+	Truncado bool          `json:"truncado"`
+}
+
+// GrafoRepository monta grafos de vínculos a partir dos envolvidos cadastrados
+type GrafoRepository struct {
+	db *sql.DB
+}
+
+// NewGrafoRepository cria um novo repositório de análise de vínculos
+func NewGrafoRepository(db *sql.DB) *GrafoRepository {
+	return &GrafoRepository{db: db}
+}
+
+// fronteiraGrafo guarda os identificadores (já normalizados) a expandir no próximo salto
+type fronteiraGrafo struct {
+	bos       map[string]bool
+	cpfs      map[string]bool
+	telefones map[string]bool
+	pix       map[string]bool
+	contas    map[string]bool
+}
+
+func novaFronteira() *fronteiraGrafo {
+	return &fronteiraGrafo{
+		bos:       make(map[string]bool),
+		cpfs:      make(map[string]bool),
+		telefones: make(map[string]bool),
+		pix:       make(map[string]bool),
+		contas:    make(map[string]bool),
+	}
+}
+
+func (f *fronteiraGrafo) vazia() bool {
+	return len(f.bos)+len(f.cpfs)+len(f.telefones)+len(f.pix)+len(f.contas) == 0
+}
+
+// registroGrafo contém os campos de uma linha de vw_envolvidos relevantes para o grafo
+type registroGrafo struct {
+	id            int
+	numeroBO      string
+	tipoEnvolvido string
+	nome          string
+	cpf           string
+	nomeMae       string
+	telefone      string
+	pix           string
+	banco         string
+	agencia       string
+	conta         string
+	delegacia     string
+	dataFato      string
+}
+
+// identificadores normalizados de um registro; campos vazios indicam ausência
+type identificadoresRegistro struct {
+	bo        string
+	cpf       string
+	pessoa    string
+	telefone  string
+	pix       string
+	tipoPix   string
+	conta     string
+	contaInfo [3]string
+}
+
+func identificar(reg registroGrafo) identificadoresRegistro {
+	var ids identificadoresRegistro
+	ids.bo = strings.TrimSpace(reg.numeroBO)
+
+	if cpf := normalize.CPF(reg.cpf); cpf != "" {
+		ids.cpf = cpf
+		ids.pessoa = NoPessoa + ":cpf:" + cpf
+	} else if nome := normalize.TextoSemAcentos(reg.nome); nome != "" {
+		ids.pessoa = NoPessoa + ":nome:" + nome + "|" + normalize.TextoSemAcentos(reg.nomeMae)
+	}
+
+	ids.telefone = normalize.Telefone(reg.telefone)
+	ids.tipoPix, ids.pix = normalize.ChavePix(reg.pix)
+
+	if conta := normalize.ContaBancaria(reg.banco, reg.agencia, reg.conta); conta != "" {
+		ids.conta = conta
+		copy(ids.contaInfo[:], strings.SplitN(conta, "|", 3))
+	}
+
+	return ids
+}
+
+// montadorGrafo acumula nós e arestas sem duplicá-los
+type montadorGrafo struct {
+	nos      map[string]*GrafoNo
+	ordemNos []string
+	arestas  map[string]bool
+	grafo    Grafo
+}
+
+func (m *montadorGrafo) adicionarNo(id, tipo, rotulo string, atributos map[string]string) bool {
+	if _, exists := m.nos[id]; exists {
+		return false
+	}
+	m.nos[id] = &GrafoNo{ID: id, Tipo: tipo, Rotulo: rotulo, Atributos: atributos}
+	m.ordemNos = append(m.ordemNos, id)
+	return true
+}
+
+func (m *montadorGrafo) adicionarAresta(origem, destino, tipo, rotulo string) {
+	id := tipo + "|" + origem + "|" + destino
+	if m.arestas[id] {
+		return
+	}
+	m.arestas[id] = true
+	m.grafo.Edges = append(m.grafo.Edges, GrafoAresta{ID: id, Source: origem, Target: destino, Tipo: tipo, Rotulo: rotulo})
+}
+
+// fronteiraInicial normaliza o ponto de partida informado pelo usuário
+func fronteiraInicial(tipo, valor string) (*fronteiraGrafo, error) {
+	f := novaFronteira()
+	valor = strings.TrimSpace(valor)
+
+	switch tipo {
+	case "cpf":
+		cpf := normalize.CPF(valor)
+		if cpf == "" {
+			return nil, ErrIdentificadorInvalido
+		}
+		f.cpfs[cpf] = true
+	case "telefone":
+		telefone := normalize.Telefone(valor)
+		if telefone == "" {
+			return nil, ErrIdentificadorInvalido
+		}
+		f.telefones[telefone] = true
+	case "pix":
+		_, chave := normalize.ChavePix(valor)
+		if chave == "" {
+			return nil, ErrIdentificadorInvalido
+		}
+		f.pix[chave] = true
+	case "bo":
+		if valor == "" {
+			return nil, ErrIdentificadorInvalido
+		}
+		f.bos[valor] = true
+	default:
+		return nil, ErrIdentificadorInvalido
+	}
+
+	return f, nil
+}
+
+// buscarRegistros retorna as linhas que contêm algum dos identificadores da fronteira.
+// Os identificadores são comparados com as colunas normalizadas (indexadas), que
+// guardam a mesma forma canônica calculada em identificar.
+func (r *GrafoRepository) buscarRegistros(f *fronteiraGrafo, limite int) ([]registroGrafo, error) {
+	var bos, cpfs, telefones, pix, contas []string
+
+	for bo := range f.bos {
+		bos = append(bos, bo)
+	}
+	for cpf := range f.cpfs {
+		cpfs = append(cpfs, cpf)
+	}
+	for telefone := range f.telefones {
+		telefones = append(telefones, telefone)
+	}
+	for chave := range f.pix {
+		pix = append(pix, chave)
+	}
+	for conta := range f.contas {
+		contas = append(contas, conta)
+	}
+
+	query := `
+	SELECT id,
+		COALESCE(numero_do_bo, '') as numero_do_bo,
+		COALESCE(tipo_envolvido, '') as tipo_envolvido,
+		COALESCE(nomecompleto, '') as nomecompleto,
+		COALESCE(cpf, '') as cpf,
+		COALESCE(nomedamae, '') as nomedamae,
+		COALESCE(telefone_envolvido, '') as telefone_envolvido,
+		COALESCE(pix_utilizado, '') as pix_utilizado,
+		COALESCE(instituicao_bancaria, '') as instituicao_bancaria,
+		COALESCE(numero_agencia_bancaria, '') as numero_agencia_bancaria,
+		COALESCE(numero_conta_bancaria, '') as numero_conta_bancaria,
+		COALESCE(delegacia_responsavel, '') as delegacia_responsavel,
+		COALESCE(data_fato, '') as data_fato
+	FROM vw_envolvidos
+	WHERE numero_do_bo = ANY($1)
+	   OR cpf_normalizado = ANY($2)
+	   OR telefone_normalizado = ANY($3)
+	   OR pix_normalizado = ANY($4)
+	   OR conta_normalizada = ANY($5)
+	ORDER BY id
+	LIMIT $6`
+
+	rows, err := r.db.Query(query,
+		pq.Array(bos), pq.Array(cpfs), pq.Array(telefones),
+		pq.Array(pix), pq.Array(contas), limite)
+	if err != nil {
+		log.Printf("Erro ao consultar registros para o grafo: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registros []registroGrafo
+	for rows.Next() {
+		var reg registroGrafo
+		if err := rows.Scan(&reg.id, &reg.numeroBO, &reg.tipoEnvolvido, &reg.nome, &reg.cpf, &reg.nomeMae,
+			&reg.telefone, &reg.pix, &reg.banco, &reg.agencia, &reg.conta, &reg.delegacia, &reg.dataFato); err != nil {
+			log.Printf("Erro ao processar resultado: %v", err)
+			return nil, err
+		}
+		registros = append(registros, reg)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro após iteração: %v", err)
+		return nil, err
+	}
+
+	return registros, nil
+}
+
+// GetGrafoVinculos expande, a partir de um CPF, telefone, chave PIX ou número de BO,
+// até "saltos" níveis de vínculos por identificadores compartilhados nos envolvidos (vw_envolvidos).
+// A expansão é interrompida (Truncado = true) ao atingir maxNos nós ou quando a
+// busca de um salto atinge o limite de registros da consulta.
+func (r *GrafoRepository) GetGrafoVinculos(tipo, valor string, saltos, maxNos int) (*Grafo, error) {
+	fronteira, err := fronteiraInicial(tipo, valor)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &montadorGrafo{
+		nos:     make(map[string]*GrafoNo),
+		arestas: make(map[string]bool),
+	}
+	expandidos := novaFronteira()
+	registrosVistos := make(map[int]bool)
+
+	for salto := 0; salto < saltos && !fronteira.vazia(); salto++ {
+		for bo := range fronteira.bos {
+			expandidos.bos[bo] = true
+		}
+		for cpf := range fronteira.cpfs {
+			expandidos.cpfs[cpf] = true
+		}
+		for telefone := range fronteira.telefones {
+			expandidos.telefones[telefone] = true
+		}
+		for chave := range fronteira.pix {
+			expandidos.pix[chave] = true
+		}
+		for conta := range fronteira.contas {
+			expandidos.contas[conta] = true
+		}
+
+		limite := maxNos * 5
+		registros, err := r.buscarRegistros(fronteira, limite)
+		if err != nil {
+			return nil, err
+		}
+		// Linhas além do limite da consulta ficam de fora do grafo
+		if len(registros) == limite {
+			m.grafo.Truncado = true
+		}
+
+		proxima := novaFronteira()
+		for _, reg := range registros {
+			if registrosVistos[reg.id] {
+				continue
+			}
+
+			ids := identificar(reg)
+
+			// Confirmar que o registro realmente compartilha um identificador da fronteira
+			if !fronteira.bos[ids.bo] && !fronteira.cpfs[ids.cpf] && !fronteira.telefones[ids.telefone] &&
+				!fronteira.pix[ids.pix] && !fronteira.contas[ids.conta] {
+				continue
+			}
+			registrosVistos[reg.id] = true
+
+			if len(m.nos) >= maxNos {
+				m.grafo.Truncado = true
+				break
+			}
+
+			adicionarRegistro(m, reg, ids)
+
+			if ids.bo != "" && !expandidos.bos[ids.bo] {
+				proxima.bos[ids.bo] = true
+			}
+			if ids.cpf != "" && !expandidos.cpfs[ids.cpf] {
+				proxima.cpfs[ids.cpf] = true
+			}
+			if ids.telefone != "" && !expandidos.telefones[ids.telefone] {
+				proxima.telefones[ids.telefone] = true
+			}
+			if ids.pix != "" && !expandidos.pix[ids.pix] {
+				proxima.pix[ids.pix] = true
+			}
+			if ids.conta != "" && !expandidos.contas[ids.conta] {
+				proxima.contas[ids.conta] = true
+			}
+		}
+
+		if m.grafo.Truncado {
+			break
+		}
+		fronteira = proxima
+	}
+
+	for _, id := range m.ordemNos {
+		m.grafo.Nodes = append(m.grafo.Nodes, *m.nos[id])
+	}
+	if m.grafo.Nodes == nil {
+		m.grafo.Nodes = []GrafoNo{}
+	}
+	if m.grafo.Edges == nil {
+		m.grafo.Edges = []GrafoAresta{}
+	}
+
+	return &m.grafo, nil
+}
+
+// adicionarRegistro transforma uma linha de vw_envolvidos em nós e arestas.
+// Os identificadores (telefone, PIX, conta) são ligados à pessoa do registro; quando
+// o registro não identifica ninguém, são ligados diretamente ao BO.
+func adicionarRegistro(m *montadorGrafo, reg registroGrafo, ids identificadoresRegistro) {
+	boID := ""
+	if ids.bo != "" {
+		boID = NoBO + ":" + ids.bo
+		m.adicionarNo(boID, NoBO, ids.bo, map[string]string{
+			"delegacia_responsavel": reg.delegacia,
+			"data_fato":             reg.dataFato,
+		})
+	}
+
+	ancora := boID
+	if ids.pessoa != "" {
+		m.adicionarNo(ids.pessoa, NoPessoa, strings.TrimSpace(reg.nome), map[string]string{
+			"cpf":       ids.cpf,
+			"nomedamae": strings.TrimSpace(reg.nomeMae),
+		})
+		if boID != "" {
+			m.adicionarAresta(ids.pessoa, boID, "participou", reg.tipoEnvolvido)
+		}
+		ancora = ids.pessoa
+	}
+
+	if ancora == "" {
+		return
+	}
+
+	if ids.telefone != "" {
+		id := NoTelefone + ":" + ids.telefone
+		m.adicionarNo(id, NoTelefone, ids.telefone, nil)
+		m.adicionarAresta(ancora, id, "usa_telefone", "")
+	}
+
+	if ids.pix != "" {
+		id := NoPix + ":" + ids.pix
+		m.adicionarNo(id, NoPix, ids.pix, map[string]string{"tipo_chave": ids.tipoPix})
+		m.adicionarAresta(ancora, id, "usa_pix", "")
+	}
+
+	if ids.conta != "" {
+		id := NoConta + ":" + ids.conta
+		m.adicionarNo(id, NoConta, ids.contaInfo[0]+" ag. "+ids.contaInfo[1]+" cc "+ids.contaInfo[2], map[string]string{
+			"instituicao_bancaria":    ids.contaInfo[0],
+			"numero_agencia_bancaria": ids.contaInfo[1],
+			"numero_conta_bancaria":   ids.contaInfo[2],
+		})
+		m.adicionarAresta(ancora, id, "usa_conta", "")
+	}
+}
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"fraudbase/internal/database"
+	"fraudbase/internal/models"
+	"fraudbase/internal/normalize"
+	"strings"
+)
+
+// Um envolvido é gravado em três tabelas: a ocorrência (uma por BO), a pessoa
+// (deduplicada pela função chave_pessoa) e a participação que liga as duas.
+// Ocorrências e pessoas já existentes são reaproveitadas sem alteração: vale a
+// primeira gravação do BO ou da pessoa, como na normalização (0004_normalizacao),
+// e os campos enviados com valor diferente do gravado são informados como
+// divergentes em vez de descartados em silêncio. Para alterá-los há a edição do
+// registro e o modo de atualização da importação. A participação
+// guarda o usuário que a cadastrou e a unidade policial dele (dona do registro) e,
+// quando veio de um relatório, a importação que a criou. As colunas tipadas são
+// convertidas na gravação e os valores não reconhecidos vão, na mesma transação,
+// para tabela_estelionato_conversao_erros.
+const (
+	sqlGravarOcorrencia = `
+	INSERT INTO ocorrencias (
+		numero_do_bo, data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato,
+		numerocasa_fato, bairro_fato, municipio_fato, pais_fato, delegacia_responsavel,
+		situacao, natureza, relato_historico, data_fato_ts, latitude_fato_num, longitude_fato_num
+	) VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+	ON CONFLICT (numero_do_bo) DO UPDATE SET numero_do_bo = EXCLUDED.numero_do_bo
+	RETURNING id`
+
+	sqlGravarPessoa = `
+	INSERT INTO pessoas (
+		chave_identidade, nomecompleto, cpf, nomedamae, nascimento, nacionalidade,
+		naturalidade, uf_envolvido, sexo_envolvido, nascimento_data, cpf_normalizado
+	) VALUES (chave_pessoa($2, $1, $3, $4), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (chave_identidade) DO UPDATE SET chave_identidade = EXCLUDED.chave_identidade
+	RETURNING id`
+
+	sqlGravarParticipacao = `
+	INSERT INTO participacoes (
+		ocorrencia_id, pessoa_id, tipo_envolvido, telefone_envolvido, instituicao_bancaria,
+		endereco_ip, valor, pix_utilizado, numero_conta_bancaria, numero_boleto, processo_banco,
+		numero_agencia_bancaria, cartao, terminal, tipo_pagamento, orgao_concessionaria, veiculo,
+		terminal_conexao, erb, operacao_policial, numero_laudo_pericial, valor_numerico, campos_tipados,
+		cadastrado_por, unidade_cadastro, telefone_normalizado, import_id, pix_normalizado, conta_normalizada
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, TRUE,
+		NULLIF($23, 0), (SELECT unidade_policial FROM usuarios WHERE id = $23), $24, NULLIF($25, 0), $26, $27)
+	RETURNING id`
+
+	sqlGravarConversaoErro = `
+	INSERT INTO tabela_estelionato_conversao_erros (registro_id, campo, valor) VALUES ($1, $2, $3)`
+)
+
+// camposOcorrencia e camposPessoa são os campos do BO e da pessoa comparados com
+// os já gravados; o CPF e a chave de identidade não entram, pois identificam a pessoa
+var (
+	camposOcorrencia = []string{
+		"data_fato", "cep_fato", "latitude_fato", "longitude_fato", "logradouro_fato",
+		"numerocasa_fato", "bairro_fato", "municipio_fato", "pais_fato", "delegacia_responsavel",
+		"situacao", "natureza", "relato_historico",
+	}
+	camposPessoa = []string{
+		"nomecompleto", "nomedamae", "nascimento", "nacionalidade", "naturalidade",
+		"uf_envolvido", "sexo_envolvido",
+	}
+
+	valoresOcorrenciaGravador = valoresComparados("o", camposOcorrencia, parametroGravador)
+	valoresPessoaGravador     = valoresComparados("pe", camposPessoa, parametroGravador)
+
+	sqlDivergenciasOcorrencia = `
+	SELECT c.campo FROM ocorrencias o
+	CROSS JOIN LATERAL ` + valoresOcorrenciaGravador + `
+	WHERE o.id = $1 AND c.novo <> '' AND c.novo IS DISTINCT FROM c.atual
+	ORDER BY c.posicao`
+
+	sqlDivergenciasPessoa = `
+	SELECT c.campo FROM pessoas pe
+	CROSS JOIN LATERAL ` + valoresPessoaGravador + `
+	WHERE pe.id = $1 AND c.novo <> '' AND c.novo IS DISTINCT FROM c.atual
+	ORDER BY c.posicao`
+)
+
+// parametroGravador é o parâmetro do campo i nas consultas de divergência ($1 é o id)
+func parametroGravador(i int, _ string) string {
+	return fmt.Sprintf("$%d::TEXT", i+2)
+}
+
+// valoresComparados monta a lista VALUES (posição, campo, valor gravado em
+// tabela.campo, valor novo) com o apelido c(posicao, campo, atual, novo)
+func valoresComparados(tabela string, campos []string, novo func(i int, campo string) string) string {
+	valores := make([]string, len(campos))
+	for i, campo := range campos {
+		valores[i] = fmt.Sprintf("(%d, '%s', %s.%s::TEXT, %s)", i, campo, tabela, campo, novo(i, campo))
+	}
+	return "(VALUES\n\t\t" + strings.Join(valores, ",\n\t\t") + "\n\t) AS c(posicao, campo, atual, novo)"
+}
+
+// gravadorEnvolvidos mantém os comandos preparados para gravar envolvidos em uma
+// transação. Cada envolvido gravado entra no histórico com a ação e o autor informados.
+// importID é a importação de origem (0 para cadastros manuais).
+type gravadorEnvolvidos struct {
+	ocorrencia             *sql.Stmt
+	pessoa                 *sql.Stmt
+	participacao           *sql.Stmt
+	divergenciasOcorrencia *sql.Stmt
+	divergenciasPessoa     *sql.Stmt
+	conversao              *sql.Stmt
+	historico              *sql.Stmt
+	acao                   string
+	autor                  Autor
+	importID               int64
+}
+
+func novoGravadorEnvolvidos(tx *sql.Tx, acao string, autor Autor, importID int64) (*gravadorEnvolvidos, error) {
+	g := &gravadorEnvolvidos{acao: acao, autor: autor, importID: importID}
+	var err error
+
+	if g.ocorrencia, err = tx.Prepare(sqlGravarOcorrencia); err != nil {
+		return nil, err
+	}
+	if g.pessoa, err = tx.Prepare(sqlGravarPessoa); err != nil {
+		g.Close()
+		return nil, err
+	}
+	if g.participacao, err = tx.Prepare(sqlGravarParticipacao); err != nil {
+		g.Close()
+		return nil, err
+	}
+	if g.divergenciasOcorrencia, err = tx.Prepare(sqlDivergenciasOcorrencia); err != nil {
+		g.Close()
+		return nil, err
+	}
+	if g.divergenciasPessoa, err = tx.Prepare(sqlDivergenciasPessoa); err != nil {
+		g.Close()
+		return nil, err
+	}
+	if g.conversao, err = tx.Prepare(sqlGravarConversaoErro); err != nil {
+		g.Close()
+		return nil, err
+	}
+	if g.historico, err = tx.Prepare(sqlRegistrarHistorico); err != nil {
+		g.Close()
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Close libera os comandos preparados
+func (g *gravadorEnvolvidos) Close() {
+	for _, stmt := range []*sql.Stmt{g.ocorrencia, g.pessoa, g.participacao, g.divergenciasOcorrencia,
+		g.divergenciasPessoa, g.conversao, g.historico} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// Gravar insere o envolvido e retorna o id da participação (o id exposto pela API)
+// e os campos do BO ou da pessoa já cadastrados que divergem dos enviados e foram
+// mantidos. Os campos em tipados.Invalidos que foram gravados são registrados
+// como erros de conversão.
+func (g *gravadorEnvolvidos) Gravar(e models.Envolvido, tipados database.CamposTipados) (int, []string, error) {
+	var ocorrenciaID, pessoaID, id int
+
+	err := g.ocorrencia.QueryRow(
+		e.NumeroBO, e.DataFato, e.CEPFato, e.LatitudeFato, e.LongitudeFato, e.LogradouroFato,
+		e.NumeroCasaFato, e.BairroFato, e.MunicipioFato, e.PaisFato, e.DelegaciaResponsavel,
+		e.Situacao, e.Natureza, e.RelatoHistorico, tipados.DataFato, tipados.Latitude, tipados.Longitude,
+	).Scan(&ocorrenciaID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = g.pessoa.QueryRow(
+		e.NomeCompleto, e.CPF, e.NomeMae, e.Nascimento, e.Nacionalidade,
+		e.Naturalidade, e.UFEnvolvido, e.SexoEnvolvido, tipados.Nascimento, normalize.CPF(e.CPF),
+	).Scan(&pessoaID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	divergentes, err := listarCampos(g.divergenciasOcorrencia, ocorrenciaID,
+		e.DataFato, e.CEPFato, e.LatitudeFato, e.LongitudeFato, e.LogradouroFato,
+		e.NumeroCasaFato, e.BairroFato, e.MunicipioFato, e.PaisFato, e.DelegaciaResponsavel,
+		e.Situacao, e.Natureza, e.RelatoHistorico)
+	if err != nil {
+		return 0, nil, err
+	}
+	camposPessoaDivergentes, err := listarCampos(g.divergenciasPessoa, pessoaID,
+		e.NomeCompleto, e.NomeMae, e.Nascimento, e.Nacionalidade, e.Naturalidade,
+		e.UFEnvolvido, e.SexoEnvolvido)
+	if err != nil {
+		return 0, nil, err
+	}
+	divergentes = append(divergentes, camposPessoaDivergentes...)
+
+	_, chavePix := normalize.ChavePix(e.PixUtilizado)
+	err = g.participacao.QueryRow(
+		ocorrenciaID, pessoaID, e.TipoEnvolvido, e.TelefoneEnvolvido, e.InstituicaoBancaria,
+		e.EnderecoIP, e.Valor, e.PixUtilizado, e.NumeroContaBancaria, e.NumeroBoleto, e.ProcessoBanco,
+		e.NumeroAgenciaBancaria, e.Cartao, e.Terminal, e.TipoPagamento, e.OrgaoConcessionaria, e.Veiculo,
+		e.TerminalConexao, e.ERB, e.OperacaoPolicial, e.NumeroLaudoPericial, tipados.Valor,
+		g.autor.UsuarioID, normalize.Telefone(e.TelefoneEnvolvido), g.importID,
+		chavePix, normalize.ContaBancaria(e.InstituicaoBancaria, e.NumeroAgenciaBancaria, e.NumeroContaBancaria),
+	).Scan(&id)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, campo := range tipados.Invalidos {
+		// O valor de um campo mantido não foi gravado
+		if contem(divergentes, campo.Campo) {
+			continue
+		}
+		if _, err := g.conversao.Exec(id, campo.Campo, campo.Valor); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	motivo := ""
+	if g.importID != 0 {
+		motivo = fmt.Sprintf("importação %d", g.importID)
+	}
+	if _, err := g.historico.Exec(id, g.acao, g.autor.UsuarioID, g.autor.Login, motivo, nil); err != nil {
+		return 0, nil, err
+	}
+
+	return id, divergentes, nil
+}
+
+// listarCampos executa uma consulta de divergência e retorna os campos divergentes
+func listarCampos(stmt *sql.Stmt, args ...interface{}) ([]string, error) {
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campos []string
+	for rows.Next() {
+		var campo string
+		if err := rows.Scan(&campo); err != nil {
+			return nil, err
+		}
+		campos = append(campos, campo)
+	}
+	return campos, rows.Err()
+}
+
+func contem(lista []string, valor string) bool {
+	for _, item := range lista {
+		if item == valor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Ações registradas no histórico de envolvidos
+const (
+	AcaoCriacao     = "criacao"
+	AcaoImportacao  = "importacao"
+	AcaoEdicao      = "edicao"
+	AcaoExclusao    = "exclusao"
+	AcaoReversao    = "reversao_importacao"
+	AcaoAtualizacao = "atualizacao" // importação em modo de atualização
+)
+
+// Autor identifica o usuário responsável por uma alteração (UsuarioID 0 para o sistema)
+type Autor struct {
+	UsuarioID int
+	Login     string
+	Admin     bool
+}
+
+// sqlRegistrarHistorico grava o estado atual do registro ($1) comparado ao estado
+// anterior ($6, NULL para registros novos). Alterações sem diferença não são gravadas.
+const sqlRegistrarHistorico = `
+	INSERT INTO tabela_estelionato_history (registro_id, acao, usuario_id, usuario_login, motivo, antes, depois, alteracoes)
+	SELECT $1, $2, NULLIF($3, 0), $4, NULLIF($5, ''), estado.antes, estado.depois, diff_jsonb(estado.antes, estado.depois)
+	FROM (SELECT $6::jsonb AS antes, snapshot_envolvido($1) AS depois) estado
+	WHERE diff_jsonb(estado.antes, estado.depois) <> '{}'::jsonb`
+
+// capturarEstado guarda, em uma tabela temporária da transação, o estado atual dos
+// registros que serão alterados; registrarHistoricoCapturado grava a diferença depois
+func capturarEstado(tx *sql.Tx, ids []int) error {
+	_, err := tx.Exec(`CREATE TEMP TABLE IF NOT EXISTS historico_pendente (id INTEGER PRIMARY KEY, antes JSONB) ON COMMIT DROP`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO historico_pendente (id, antes)
+		SELECT id, snapshot_envolvido(id) FROM unnest($1::int[]) AS id
+		ON CONFLICT (id) DO NOTHING`, pq.Array(ids))
+	return err
+}
+
+// registrarHistoricoCapturado grava no histórico os registros capturados que mudaram.
+// Registros afetados indiretamente (mesmo BO ou mesma pessoa) recebem como motivo
+// a referência ao registro alterado.
+func registrarHistoricoCapturado(tx *sql.Tx, registroID int, acao string, autor Autor, motivo string) error {
+	_, err := tx.Exec(`
+		INSERT INTO tabela_estelionato_history (registro_id, acao, usuario_id, usuario_login, motivo, antes, depois, alteracoes)
+		SELECT h.id, $1, NULLIF($2, 0), $3,
+			CASE WHEN h.id = $5 THEN NULLIF($4, '') ELSE 'alteração do registro ' || $5 END,
+			h.antes, s.depois, diff_jsonb(h.antes, s.depois)
+		FROM historico_pendente h
+		CROSS JOIN LATERAL (SELECT snapshot_envolvido(h.id) AS depois) s
+		WHERE diff_jsonb(h.antes, s.depois) <> '{}'::jsonb`,
+		acao, autor.UsuarioID, autor.Login, motivo, registroID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DROP TABLE historico_pendente`)
+	return err
+}
+
+// EntradaHistorico é uma alteração de um envolvido: para cada campo alterado,
+// o valor antes e depois (null quando o campo não existia)
+type EntradaHistorico struct {
+	ID           int64                     `json:"id"`
+	RegistroID   int                       `json:"registro_id"`
+	Acao         string                    `json:"acao"`
+	UsuarioID    *int                      `json:"usuario_id"`
+	UsuarioLogin string                    `json:"usuario_login"`
+	Motivo       string                    `json:"motivo,omitempty"`
+	Alteracoes   map[string]AlteracaoCampo `json:"alteracoes"`
+	CriadoEm     time.Time                 `json:"criado_em"`
+}
+
+// AlteracaoCampo guarda os valores de um campo antes e depois da alteração
+type AlteracaoCampo struct {
+	Antes  *string `json:"antes"`
+	Depois *string `json:"depois"`
+}
+
+// HistoricoRepository consulta o histórico de alterações dos envolvidos
+type HistoricoRepository struct {
+	db *sql.DB
+}
+
+// NewHistoricoRepository cria um novo repositório de histórico
+func NewHistoricoRepository(db *sql.DB) *HistoricoRepository {
+	return &HistoricoRepository{db: db}
+}
+
+// GetHistorico retorna as alterações de um envolvido em ordem cronológica
+func (r *HistoricoRepository) GetHistorico(registroID int) ([]EntradaHistorico, error) {
+	rows, err := r.db.Query(`
+		SELECT id, registro_id, acao, usuario_id, usuario_login, COALESCE(motivo, ''), alteracoes, criado_em
+		FROM tabela_estelionato_history
+		WHERE registro_id = $1
+		ORDER BY id`, registroID)
+	if err != nil {
+		log.Printf("Erro ao consultar histórico do envolvido %d: %v", registroID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	historico := []EntradaHistorico{}
+	for rows.Next() {
+		var h EntradaHistorico
+		var usuarioID sql.NullInt64
+		var alteracoes []byte
+		if err := rows.Scan(&h.ID, &h.RegistroID, &h.Acao, &usuarioID, &h.UsuarioLogin, &h.Motivo, &alteracoes, &h.CriadoEm); err != nil {
+			log.Printf("Erro ao escanear histórico: %v", err)
+			return nil, err
+		}
+		if usuarioID.Valid {
+			id := int(usuarioID.Int64)
+			h.UsuarioID = &id
+		}
+		if err := json.Unmarshal(alteracoes, &h.Alteracoes); err != nil {
+			return nil, err
+		}
+		historico = append(historico, h)
+	}
+
+	return historico, rows.Err()
+}
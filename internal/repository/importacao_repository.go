@@ -0,0 +1,519 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fraudbase/internal/database"
+	"log"
+	"time"
+)
+
+// Situações de uma importação de relatório
+const (
+	ImportacaoPrevia      = "previa"   // dryRun aguardando confirmação
+	ImportacaoExpirada    = "expirada" // prévia não confirmada a tempo
+	ImportacaoPendente    = "pendente"
+	ImportacaoProcessando = "processando"
+	ImportacaoConcluida   = "concluido"
+	ImportacaoErro        = "erro"
+	ImportacaoRevertida   = "revertida"
+)
+
+// Resultado da transação de uma importação finalizada
+const (
+	TransacaoConfirmada = "confirmada" // todos os registros novos gravados
+	TransacaoParcial    = "parcial"    // gravação parcial com lotes descartados por erro
+	TransacaoDesfeita   = "desfeita"   // nenhum registro gravado
+)
+
+// ErrImportacaoNaoReversivel é retornado ao reverter uma importação que ainda está
+// na fila, em processamento, em prévia ou que já foi revertida
+var ErrImportacaoNaoReversivel = errors.New("importação não pode ser revertida na situação atual")
+
+// ErrImportacaoComAtualizacoes é retornado ao reverter uma importação em modo de
+// atualização que alterou registros existentes: as alterações não são desfeitas
+var ErrImportacaoComAtualizacoes = errors.New("importação alterou registros existentes e não pode ser revertida")
+
+// OrigemImportacao identifica o arquivo de uma importação
+type OrigemImportacao struct {
+	NomeArquivo string
+	SHA256      string
+	Estado      string // UF usada como sufixo do número do BO
+	Perfil      string // nome do perfil de importação
+}
+
+// Modos de importação de um relatório
+const (
+	ModoInclusao    = "inclusao"    // só grava registros novos; os existentes são duplicatas
+	ModoAtualizacao = "atualizacao" // atualiza os participantes já cadastrados em cada BO
+)
+
+// OpcoesImportacao controla como os registros de um relatório são gravados
+type OpcoesImportacao struct {
+	Modo string // ModoInclusao (padrão) ou ModoAtualizacao
+
+	// GravacaoParcial grava o relatório em lotes, cada um com o seu savepoint: um
+	// lote com erro é descartado e os demais são gravados. Sem ela a importação é
+	// tudo ou nada.
+	GravacaoParcial bool
+}
+
+func (o OpcoesImportacao) modo() string {
+	if o.Modo == "" {
+		return ModoInclusao
+	}
+	return o.Modo
+}
+
+// Importacao acompanha o processamento em background de um relatório enviado
+type Importacao struct {
+	ID             int64      `json:"id"`
+	UsuarioID      *int       `json:"usuario_id"`
+	UsuarioLogin   string     `json:"usuario_login"`
+	NomeArquivo    string     `json:"nome_arquivo"`
+	SHA256         string     `json:"sha256"`
+	Estado         string     `json:"estado"`
+	Perfil         string     `json:"perfil"`
+	Status         string     `json:"status"`
+	Modo           string     `json:"modo"`
+	Parcial        bool       `json:"gravacao_parcial"`
+	Transacao      string     `json:"transacao,omitempty"` // preenchida ao finalizar
+	TotalRegistros int        `json:"total_registros"`
+	Processados    int        `json:"processados"`
+	Inseridos      int        `json:"inseridos"`
+	Atualizados    int        `json:"atualizados"`
+	Duplicados     int        `json:"duplicados"`
+	Erros          []string   `json:"erros"`
+	CriadoEm       time.Time  `json:"criado_em"`
+	IniciadoEm     *time.Time `json:"iniciado_em"`
+	ConcluidoEm    *time.Time `json:"concluido_em"`
+	RevertidoEm    *time.Time `json:"revertido_em,omitempty"`
+	RevertidoPor   *int       `json:"revertido_por,omitempty"`
+	Processo       string     `json:"-"` // processo que recebeu o upload (ver NewImportacaoRepository)
+
+	// Previa é o resultado da prévia pronta, só em GET /api/imports/{id}
+	Previa json.RawMessage `json:"previa,omitempty"`
+}
+
+// ImportacaoRepository grava e consulta as importações de relatórios (tabela import_jobs)
+type ImportacaoRepository struct {
+	db       *sql.DB
+	processo string
+}
+
+// NewImportacaoRepository cria um novo repositório de importações. processo
+// identifica o processo que vai processar as importações criadas por este
+// repositório (ver InterromperPendentes).
+func NewImportacaoRepository(db *sql.DB, processo string) *ImportacaoRepository {
+	return &ImportacaoRepository{db: db, processo: processo}
+}
+
+const colunasImportacao = `id, usuario_id, usuario_login, nome_arquivo, COALESCE(sha256, ''), COALESCE(estado, ''), COALESCE(perfil, ''),
+	status, modo, gravacao_parcial, COALESCE(transacao, ''), total_registros, processados, inseridos, atualizados, duplicados, erros,
+	criado_em, iniciado_em, concluido_em, revertido_em, revertido_por, COALESCE(processo, '')`
+
+// CriarImportacao registra uma importação pendente e retorna o id
+func (r *ImportacaoRepository) CriarImportacao(autor Autor, origem OrigemImportacao, opcoes OpcoesImportacao) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(`
+		INSERT INTO import_jobs (usuario_id, usuario_login, nome_arquivo, sha256, estado, perfil, modo, gravacao_parcial, processo)
+		VALUES (NULLIF($1, 0), $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9)
+		RETURNING id`, autor.UsuarioID, autor.Login, origem.NomeArquivo, origem.SHA256, origem.Estado, origem.Perfil,
+		opcoes.modo(), opcoes.GravacaoParcial, r.processo).Scan(&id)
+	if err != nil {
+		log.Printf("Erro ao criar importação: %v", err)
+	}
+	return id, err
+}
+
+// CriarPrevia registra a prévia (dryRun) de uma importação, que será calculada
+// em background
+func (r *ImportacaoRepository) CriarPrevia(autor Autor, origem OrigemImportacao, opcoes OpcoesImportacao) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(`
+		INSERT INTO import_jobs (usuario_id, usuario_login, nome_arquivo, sha256, estado, perfil, modo, gravacao_parcial, status, processo)
+		VALUES (NULLIF($1, 0), $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10)
+		RETURNING id`, autor.UsuarioID, autor.Login, origem.NomeArquivo, origem.SHA256, origem.Estado, origem.Perfil,
+		opcoes.modo(), opcoes.GravacaoParcial, ImportacaoPrevia, r.processo).Scan(&id)
+	if err != nil {
+		log.Printf("Erro ao criar prévia de importação: %v", err)
+	}
+	return id, err
+}
+
+// ConcluirPrevia grava as contagens e o resultado calculado da prévia, retornado
+// em GetPrevia
+func (r *ImportacaoRepository) ConcluirPrevia(id int64, total, duplicados int, previa interface{}) error {
+	previaJSON, err := json.Marshal(previa)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE import_jobs SET total_registros = $2, duplicados = $3, previa = $4
+		WHERE id = $1 AND status = $5`, id, total, duplicados, previaJSON, ImportacaoPrevia)
+	if err != nil {
+		log.Printf("Erro ao gravar prévia %d: %v", id, err)
+	}
+	return err
+}
+
+// GetPrevia retorna o resultado da prévia, ou nil enquanto ela é calculada (e
+// depois de confirmada ou expirada)
+func (r *ImportacaoRepository) GetPrevia(id int64) (json.RawMessage, error) {
+	var previa []byte
+	err := r.db.QueryRow(`SELECT previa FROM import_jobs WHERE id = $1`, id).Scan(&previa)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Erro ao consultar prévia %d: %v", id, err)
+		return nil, err
+	}
+	return previa, nil
+}
+
+// ConfirmarPrevia coloca a prévia na fila (pendente), zerando as contagens da prévia.
+// Retorna false se a importação não estava mais em prévia.
+func (r *ImportacaoRepository) ConfirmarPrevia(id int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE import_jobs SET status = $2, duplicados = 0, previa = NULL
+		WHERE id = $1 AND status = $3`, id, ImportacaoPendente, ImportacaoPrevia)
+	if err != nil {
+		log.Printf("Erro ao confirmar prévia %d: %v", id, err)
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ExpirarPrevia marca como expirada uma prévia que não foi confirmada a tempo
+func (r *ImportacaoRepository) ExpirarPrevia(id int64) error {
+	_, err := r.db.Exec(`
+		UPDATE import_jobs SET status = $2, concluido_em = CURRENT_TIMESTAMP, previa = NULL
+		WHERE id = $1 AND status = $3`, id, ImportacaoExpirada, ImportacaoPrevia)
+	return err
+}
+
+// IniciarImportacao marca a importação como em processamento com o total de registros lidos
+func (r *ImportacaoRepository) IniciarImportacao(id int64, total int) error {
+	_, err := r.db.Exec(`
+		UPDATE import_jobs SET status = $2, total_registros = $3, iniciado_em = COALESCE(iniciado_em, CURRENT_TIMESTAMP)
+		WHERE id = $1`, id, ImportacaoProcessando, total)
+	return err
+}
+
+// AtualizarProgresso grava as contagens acumuladas da importação
+func (r *ImportacaoRepository) AtualizarProgresso(id int64, processados, inseridos, duplicados int) error {
+	_, err := r.db.Exec(`
+		UPDATE import_jobs SET processados = $2, inseridos = $3, duplicados = $4
+		WHERE id = $1`, id, processados, inseridos, duplicados)
+	return err
+}
+
+// RegistrarAtualizacoes grava quantos participantes foram atualizados e o relatório
+// por BO de uma importação em modo de atualização
+func (r *ImportacaoRepository) RegistrarAtualizacoes(id int64, atualizados int, alteracoes []AlteracaoBO) error {
+	if alteracoes == nil {
+		alteracoes = []AlteracaoBO{}
+	}
+	alteracoesJSON, err := json.Marshal(alteracoes)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`UPDATE import_jobs SET atualizados = $2, alteracoes = $3 WHERE id = $1`, id, atualizados, alteracoesJSON)
+	if err != nil {
+		log.Printf("Erro ao gravar alterações da importação %d: %v", id, err)
+	}
+	return err
+}
+
+// GetAlteracoes retorna o relatório por BO de uma importação (vazio no modo de inclusão)
+func (r *ImportacaoRepository) GetAlteracoes(id int64) ([]AlteracaoBO, error) {
+	var alteracoesJSON []byte
+	err := r.db.QueryRow(`SELECT alteracoes FROM import_jobs WHERE id = $1`, id).Scan(&alteracoesJSON)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Erro ao consultar alterações da importação %d: %v", id, err)
+		return nil, err
+	}
+
+	alteracoes := []AlteracaoBO{}
+	if err := json.Unmarshal(alteracoesJSON, &alteracoes); err != nil {
+		return nil, err
+	}
+	return alteracoes, nil
+}
+
+// RejeicaoImportacao é uma linha do relatório que a importação não gravou
+type RejeicaoImportacao struct {
+	Planilha     string
+	Linha        int
+	Motivo       string
+	NumeroBo     string // vazios nas linhas ignoradas na leitura
+	NomeCompleto string
+	Cpf          string
+}
+
+// ExportarRejeicoes percorre as rejeições de uma importação na ordem do arquivo
+func (r *ImportacaoRepository) ExportarRejeicoes(id int64, fn func(RejeicaoImportacao) error) error {
+	rows, err := r.db.Query(`
+		SELECT planilha, linha, motivo, numero_do_bo, nomecompleto, cpf
+		FROM import_rejeicoes WHERE import_id = $1 ORDER BY id`, id)
+	if err != nil {
+		log.Printf("Erro ao consultar rejeições da importação %d: %v", id, err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rej RejeicaoImportacao
+		if err := rows.Scan(&rej.Planilha, &rej.Linha, &rej.Motivo, &rej.NumeroBo, &rej.NomeCompleto, &rej.Cpf); err != nil {
+			return err
+		}
+		if err := fn(rej); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ContarRejeicoes retorna quantas rejeições a importação tem guardadas
+func (r *ImportacaoRepository) ContarRejeicoes(id int64) (int, error) {
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM import_rejeicoes WHERE import_id = $1`, id).Scan(&total)
+	if err != nil {
+		log.Printf("Erro ao contar rejeições da importação %d: %v", id, err)
+	}
+	return total, err
+}
+
+// FinalizarImportacao grava a situação final (concluido ou erro), o resultado da
+// transação e os erros encontrados
+func (r *ImportacaoRepository) FinalizarImportacao(id int64, status, transacao string, erros []string) error {
+	if erros == nil {
+		erros = []string{}
+	}
+	errosJSON, err := json.Marshal(erros)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE import_jobs SET status = $2, transacao = $3, erros = $4, concluido_em = CURRENT_TIMESTAMP
+		WHERE id = $1`, id, status, transacao, errosJSON)
+	if err != nil {
+		log.Printf("Erro ao finalizar importação %d: %v", id, err)
+	}
+	return err
+}
+
+// InterromperPendentes marca como erro as importações deste processo que estavam
+// na fila ou em processamento quando ele parou (a transação foi desfeita), e como
+// expiradas as prévias não confirmadas; o arquivo só existia na memória do
+// processo. As importações de outros processos não são tocadas.
+func (r *ImportacaoRepository) InterromperPendentes() (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE import_jobs
+		SET status = CASE WHEN status = $4 THEN $5 ELSE $1 END,
+			concluido_em = CURRENT_TIMESTAMP,
+			previa = NULL,
+			transacao = CASE WHEN status = $4 THEN NULL ELSE $6 END,
+			erros = CASE WHEN status = $4 THEN erros
+				ELSE erros || '["importação interrompida pela reinicialização da API; envie o arquivo novamente"]'::jsonb END
+		WHERE status IN ($2, $3, $4) AND (processo = $7 OR processo IS NULL)`,
+		ImportacaoErro, ImportacaoPendente, ImportacaoProcessando, ImportacaoPrevia, ImportacaoExpirada,
+		TransacaoDesfeita, r.processo)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DesteProcesso informa se a importação foi criada por este processo. O arquivo
+// de uma prévia fica só no processo que recebeu o upload.
+func (r *ImportacaoRepository) DesteProcesso(imp *Importacao) bool {
+	return imp.Processo == r.processo
+}
+
+// GetImportacao retorna uma importação pelo id (ErrNotFound se não existir)
+func (r *ImportacaoRepository) GetImportacao(id int64) (*Importacao, error) {
+	imp, err := scanImportacao(r.db.QueryRow(`SELECT `+colunasImportacao+` FROM import_jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Erro ao consultar importação %d: %v", id, err)
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// ListarImportacoes lista as importações de um usuário, das mais recentes para as mais antigas
+func (r *ImportacaoRepository) ListarImportacoes(usuarioID, page, limit int) ([]Importacao, int, error) {
+	var totalCount int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM import_jobs WHERE usuario_id = $1`, usuarioID).Scan(&totalCount); err != nil {
+		log.Printf("Erro ao contar importações: %v", err)
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+colunasImportacao+` FROM import_jobs
+		WHERE usuario_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3`, usuarioID, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Erro ao listar importações: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	importacoes := []Importacao{}
+	for rows.Next() {
+		imp, err := scanImportacao(rows)
+		if err != nil {
+			log.Printf("Erro ao escanear importação: %v", err)
+			return nil, 0, err
+		}
+		importacoes = append(importacoes, imp)
+	}
+
+	return importacoes, totalCount, rows.Err()
+}
+
+// ReverterImportacao apaga, em uma única transação, todas as participações criadas
+// pela importação (inclusive as já excluídas ou editadas depois), as ocorrências e
+// pessoas que ficarem sem participação, e marca a importação como revertida. As
+// participações apagadas entram no histórico. Retorna quantas foram apagadas.
+// Uma importação em modo de atualização que alterou registros existentes não
+// pode ser revertida (ErrImportacaoComAtualizacoes); as alterações ficam no
+// histórico de cada registro.
+func (r *ImportacaoRepository) ReverterImportacao(id int64, autor Autor) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var status, modo string
+	var atualizados int
+	err = tx.QueryRow(`SELECT status, modo, atualizados FROM import_jobs WHERE id = $1 FOR UPDATE`, id).Scan(&status, &modo, &atualizados)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status != ImportacaoConcluida && status != ImportacaoErro {
+		return 0, ErrImportacaoNaoReversivel
+	}
+	if modo == ModoAtualizacao && atualizados > 0 {
+		return 0, ErrImportacaoComAtualizacoes
+	}
+
+	_, err = tx.Exec(`
+		CREATE TEMP TABLE reversao_importacao ON COMMIT DROP AS
+		SELECT id, ocorrencia_id, pessoa_id FROM participacoes WHERE import_id = $1`, id)
+	if err != nil {
+		log.Printf("Erro ao selecionar registros da importação %d: %v", id, err)
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO tabela_estelionato_history (registro_id, acao, usuario_id, usuario_login, motivo, antes, alteracoes)
+		SELECT ri.id, $1, NULLIF($2, 0), $3, 'reversão da importação ' || $4, s.antes, diff_jsonb(s.antes, NULL)
+		FROM reversao_importacao ri
+		CROSS JOIN LATERAL (SELECT COALESCE(snapshot_envolvido(ri.id), '{}'::jsonb) AS antes) s`,
+		AcaoReversao, autor.UsuarioID, autor.Login, id)
+	if err != nil {
+		log.Printf("Erro ao registrar histórico da reversão da importação %d: %v", id, err)
+		return 0, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM participacoes WHERE id IN (SELECT id FROM reversao_importacao)`)
+	if err != nil {
+		log.Printf("Erro ao apagar registros da importação %d: %v", id, err)
+		return 0, err
+	}
+	apagados, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM ocorrencias o
+		WHERE o.id IN (SELECT ocorrencia_id FROM reversao_importacao)
+		  AND NOT EXISTS (SELECT 1 FROM participacoes pa WHERE pa.ocorrencia_id = o.id)`)
+	if err != nil {
+		log.Printf("Erro ao apagar ocorrências da importação %d: %v", id, err)
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM pessoas pe
+		WHERE pe.id IN (SELECT pessoa_id FROM reversao_importacao)
+		  AND NOT EXISTS (SELECT 1 FROM participacoes pa WHERE pa.pessoa_id = pe.id)`)
+	if err != nil {
+		log.Printf("Erro ao apagar pessoas da importação %d: %v", id, err)
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE import_jobs SET status = $2, revertido_em = CURRENT_TIMESTAMP, revertido_por = NULLIF($3, 0)
+		WHERE id = $1`, id, ImportacaoRevertida, autor.UsuarioID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar reversão da importação %d: %v", id, err)
+		return 0, err
+	}
+
+	log.Printf("Importação %d revertida por %s: %d registros apagados", id, autor.Login, apagados)
+	go database.RefreshMaterializedViews(r.db)
+	return int(apagados), nil
+}
+
+func scanImportacao(s scanner) (Importacao, error) {
+	var imp Importacao
+	var usuarioID, revertidoPor sql.NullInt64
+	var erros []byte
+	var iniciadoEm, concluidoEm, revertidoEm sql.NullTime
+
+	err := s.Scan(&imp.ID, &usuarioID, &imp.UsuarioLogin, &imp.NomeArquivo, &imp.SHA256, &imp.Estado, &imp.Perfil,
+		&imp.Status, &imp.Modo, &imp.Parcial, &imp.Transacao, &imp.TotalRegistros, &imp.Processados, &imp.Inseridos,
+		&imp.Atualizados, &imp.Duplicados, &erros,
+		&imp.CriadoEm, &iniciadoEm, &concluidoEm, &revertidoEm, &revertidoPor, &imp.Processo)
+	if err != nil {
+		return imp, err
+	}
+
+	if usuarioID.Valid {
+		id := int(usuarioID.Int64)
+		imp.UsuarioID = &id
+	}
+	if iniciadoEm.Valid {
+		imp.IniciadoEm = &iniciadoEm.Time
+	}
+	if concluidoEm.Valid {
+		imp.ConcluidoEm = &concluidoEm.Time
+	}
+	if revertidoEm.Valid {
+		imp.RevertidoEm = &revertidoEm.Time
+	}
+	if revertidoPor.Valid {
+		id := int(revertidoPor.Int64)
+		imp.RevertidoPor = &id
+	}
+	if err := json.Unmarshal(erros, &imp.Erros); err != nil {
+		return imp, err
+	}
+
+	return imp, nil
+}
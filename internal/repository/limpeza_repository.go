@@ -13,50 +13,84 @@ func NewLimpezaRepository(db *sql.DB) *LimpezaRepository {
 	return &LimpezaRepository{db: db}
 }
 
-// LimparRegistrosDuplicados remove registros duplicados da tabela tabela_estelionato
-// e retorna o número de registros antes, depois e quantos foram removidos
-func (r *LimpezaRepository) LimparRegistrosDuplicados() (int, int, error) {
+// LimparRegistrosDuplicados remove participações duplicadas (mesma pessoa, mesmo BO,
+// mesmo papel e mesmos dados da transação) entre os registros não excluídos, e as
+// pessoas que ficarem sem participação.
+// Retorna o número de participações antes e depois da limpeza. As participações
+// removidas entram no histórico como exclusão feita pelo autor.
+func (r *LimpezaRepository) LimparRegistrosDuplicados(autor Autor) (int, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Erro ao iniciar transação de limpeza: %v", err)
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
 	// Contar registros antes da limpeza
 	var totalAntes int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM tabela_estelionato").Scan(&totalAntes)
+	err = tx.QueryRow("SELECT COUNT(*) FROM participacoes WHERE excluido_em IS NULL").Scan(&totalAntes)
 	if err != nil {
 		log.Printf("Erro ao contar registros antes da limpeza: %v", err)
 		return 0, 0, err
 	}
 
-	// Executar a query de limpeza
-	cleanQuery := `
-	DELETE FROM tabela_estelionato
-	WHERE ctid NOT IN (
-		SELECT MIN(ctid)
-		FROM tabela_estelionato
-		GROUP BY numero_do_bo, tipo_envolvido, nomecompleto, cpf, nomedamae, 
-			nascimento, nacionalidade, naturalidade, uf_envolvido, sexo_envolvido, 
-			telefone_envolvido, data_fato, cep_fato, latitude_fato, longitude_fato, 
-			logradouro_fato, numerocasa_fato, bairro_fato, municipio_fato, pais_fato, 
-			delegacia_responsavel, situacao, natureza, relato_historico, instituicao_bancaria, 
-			endereco_ip, valor, pix_utilizado, numero_conta_bancaria, numero_boleto, 
-			processo_banco, numero_agencia_bancaria, cartao, terminal, tipo_pagamento, 
-			orgao_concessionaria, veiculo, terminal_conexao, erb, operacao_policial, 
-			numero_laudo_pericial
-	);`
-
-	_, err = r.db.Exec(cleanQuery)
+	// Os dados do BO e da pessoa já são únicos por construção; basta comparar
+	// os campos próprios da participação
+	_, err = tx.Exec(`
+	CREATE TEMP TABLE limpeza_duplicatas ON COMMIT DROP AS
+	SELECT id FROM participacoes
+	WHERE excluido_em IS NULL AND id NOT IN (
+		SELECT MIN(id)
+		FROM participacoes
+		WHERE excluido_em IS NULL
+		GROUP BY ocorrencia_id, pessoa_id, tipo_envolvido, telefone_envolvido,
+			instituicao_bancaria, endereco_ip, valor, pix_utilizado, numero_conta_bancaria,
+			numero_boleto, processo_banco, numero_agencia_bancaria, cartao, terminal,
+			tipo_pagamento, orgao_concessionaria, veiculo, terminal_conexao, erb,
+			operacao_policial, numero_laudo_pericial
+	)`)
+	if err != nil {
+		log.Printf("Erro ao identificar duplicatas: %v", err)
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO tabela_estelionato_history (registro_id, acao, usuario_id, usuario_login, motivo, antes, alteracoes)
+	SELECT d.id, $1, NULLIF($2, 0), $3, 'limpeza de duplicatas', s.antes, diff_jsonb(s.antes, NULL)
+	FROM limpeza_duplicatas d
+	CROSS JOIN LATERAL (SELECT snapshot_envolvido(d.id) AS antes) s`,
+		AcaoExclusao, autor.UsuarioID, autor.Login)
 	if err != nil {
+		log.Printf("Erro ao registrar histórico da limpeza: %v", err)
+		return 0, 0, err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM participacoes WHERE id IN (SELECT id FROM limpeza_duplicatas)`); err != nil {
 		log.Printf("Erro ao remover duplicatas: %v", err)
 		return 0, 0, err
 	}
 
+	_, err = tx.Exec(`DELETE FROM pessoas pe WHERE NOT EXISTS (SELECT 1 FROM participacoes pa WHERE pa.pessoa_id = pe.id)`)
+	if err != nil {
+		log.Printf("Erro ao remover pessoas sem participação: %v", err)
+		return 0, 0, err
+	}
+
 	// Contar registros após a limpeza
 	var totalDepois int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM tabela_estelionato").Scan(&totalDepois)
+	err = tx.QueryRow("SELECT COUNT(*) FROM participacoes WHERE excluido_em IS NULL").Scan(&totalDepois)
 	if err != nil {
 		log.Printf("Erro ao contar registros após limpeza: %v", err)
 		return totalAntes, 0, err
 	}
 
-	log.Printf("Limpeza de duplicatas concluída. Registros antes: %d, depois: %d, removidos: %d", 
+	if err := tx.Commit(); err != nil {
+		log.Printf("Erro ao confirmar limpeza de duplicatas: %v", err)
+		return 0, 0, err
+	}
+
+	log.Printf("Limpeza de duplicatas concluída. Registros antes: %d, depois: %d, removidos: %d",
 		totalAntes, totalDepois, totalAntes-totalDepois)
-	
+
 	return totalAntes, totalDepois, nil
 }
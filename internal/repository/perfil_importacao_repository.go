@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Regras para completar o número do BO lido do relatório
+const (
+	SufixoUFUsuario = "uf_usuario" // acrescenta "/UF" do usuário, se o número ainda não tiver UF
+	SufixoFixo      = "fixo"       // acrescenta "/" + Sufixo do perfil
+	SufixoNenhum    = "nenhum"     // mantém o número como veio
+)
+
+// ErrPerfilDuplicado indica que já existe um perfil de importação com o mesmo nome
+var ErrPerfilDuplicado = errors.New("já existe um perfil de importação com esse nome")
+
+// PlanilhaPerfil descreve uma aba do relatório em um perfil de importação
+type PlanilhaPerfil struct {
+	Nome         string              `json:"nome"`         // nome da aba no arquivo
+	Colunas      map[string][]string `json:"colunas"`      // campo → cabeçalhos aceitos
+	Obrigatorias []string            `json:"obrigatorias"` // campos sem os quais o arquivo é recusado
+}
+
+// PerfilImportacao é o leiaute do relatório exportado por um sistema estadual.
+// As chaves de Planilhas são as abas lógicas (registro, fato, envolvidos, relato).
+type PerfilImportacao struct {
+	ID            int                       `json:"id"`
+	Nome          string                    `json:"nome"`
+	Descricao     string                    `json:"descricao"`
+	PadraoArquivo string                    `json:"padrao_arquivo"` // trecho exigido no nome do arquivo ("" aceita qualquer nome)
+	Planilhas     map[string]PlanilhaPerfil `json:"planilhas"`
+	RegraSufixo   string                    `json:"regra_sufixo"`
+	Sufixo        string                    `json:"sufixo"` // usado com a regra "fixo"
+	Padrao        bool                      `json:"padrao"`
+	CriadoEm      time.Time                 `json:"criado_em"`
+	AtualizadoEm  time.Time                 `json:"atualizado_em"`
+}
+
+// PerfilImportacaoRepository grava e consulta os perfis de importação
+type PerfilImportacaoRepository struct {
+	db *sql.DB
+}
+
+// NewPerfilImportacaoRepository cria um novo repositório de perfis de importação
+func NewPerfilImportacaoRepository(db *sql.DB) *PerfilImportacaoRepository {
+	return &PerfilImportacaoRepository{db: db}
+}
+
+const colunasPerfil = `id, nome, descricao, padrao_arquivo, planilhas, regra_sufixo, sufixo, padrao, criado_em, atualizado_em`
+
+// ListarPerfis retorna todos os perfis, o padrão primeiro
+func (r *PerfilImportacaoRepository) ListarPerfis() ([]PerfilImportacao, error) {
+	rows, err := r.db.Query(`SELECT ` + colunasPerfil + ` FROM perfis_importacao ORDER BY padrao DESC, nome`)
+	if err != nil {
+		log.Printf("Erro ao listar perfis de importação: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	perfis := []PerfilImportacao{}
+	for rows.Next() {
+		perfil, err := scanPerfil(rows)
+		if err != nil {
+			log.Printf("Erro ao ler perfil de importação: %v", err)
+			return nil, err
+		}
+		perfis = append(perfis, perfil)
+	}
+	return perfis, rows.Err()
+}
+
+// GetPerfil retorna o perfil pelo id
+func (r *PerfilImportacaoRepository) GetPerfil(id int) (*PerfilImportacao, error) {
+	return r.buscarPerfil(`SELECT `+colunasPerfil+` FROM perfis_importacao WHERE id = $1`, id)
+}
+
+// GetPerfilPorNome retorna o perfil pelo nome
+func (r *PerfilImportacaoRepository) GetPerfilPorNome(nome string) (*PerfilImportacao, error) {
+	return r.buscarPerfil(`SELECT `+colunasPerfil+` FROM perfis_importacao WHERE nome = $1`, nome)
+}
+
+// GetPerfilPadrao retorna o perfil marcado como padrão, ou ErrNotFound se nenhum estiver
+func (r *PerfilImportacaoRepository) GetPerfilPadrao() (*PerfilImportacao, error) {
+	return r.buscarPerfil(`SELECT ` + colunasPerfil + ` FROM perfis_importacao WHERE padrao`)
+}
+
+func (r *PerfilImportacaoRepository) buscarPerfil(query string, args ...interface{}) (*PerfilImportacao, error) {
+	perfil, err := scanPerfil(r.db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Erro ao consultar perfil de importação: %v", err)
+		return nil, err
+	}
+	return &perfil, nil
+}
+
+// CriarPerfil grava um novo perfil e retorna o id. Um perfil criado como padrão
+// deixa de fora o padrão anterior.
+func (r *PerfilImportacaoRepository) CriarPerfil(perfil PerfilImportacao) (int, error) {
+	planilhas, err := json.Marshal(perfil.Planilhas)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if perfil.Padrao {
+		if _, err := tx.Exec(`UPDATE perfis_importacao SET padrao = FALSE WHERE padrao`); err != nil {
+			return 0, err
+		}
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO perfis_importacao (nome, descricao, padrao_arquivo, planilhas, regra_sufixo, sufixo, padrao)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`, perfil.Nome, perfil.Descricao, perfil.PadraoArquivo, string(planilhas),
+		perfil.RegraSufixo, perfil.Sufixo, perfil.Padrao).Scan(&id)
+	if err != nil {
+		return 0, erroPerfil(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	log.Printf("Perfil de importação %d (%s) criado", id, perfil.Nome)
+	return id, nil
+}
+
+// AtualizarPerfil substitui os dados do perfil id
+func (r *PerfilImportacaoRepository) AtualizarPerfil(id int, perfil PerfilImportacao) error {
+	planilhas, err := json.Marshal(perfil.Planilhas)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if perfil.Padrao {
+		if _, err := tx.Exec(`UPDATE perfis_importacao SET padrao = FALSE WHERE padrao AND id <> $1`, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`
+		UPDATE perfis_importacao
+		SET nome = $2, descricao = $3, padrao_arquivo = $4, planilhas = $5, regra_sufixo = $6,
+		    sufixo = $7, padrao = $8, atualizado_em = NOW()
+		WHERE id = $1`, id, perfil.Nome, perfil.Descricao, perfil.PadraoArquivo, string(planilhas),
+		perfil.RegraSufixo, perfil.Sufixo, perfil.Padrao)
+	if err != nil {
+		return erroPerfil(err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Perfil de importação %d (%s) alterado", id, perfil.Nome)
+	return nil
+}
+
+// ExcluirPerfil apaga o perfil id. As importações feitas com ele guardam o nome.
+func (r *PerfilImportacaoRepository) ExcluirPerfil(id int) error {
+	result, err := r.db.Exec(`DELETE FROM perfis_importacao WHERE id = $1`, id)
+	if err != nil {
+		log.Printf("Erro ao excluir perfil de importação %d: %v", id, err)
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	log.Printf("Perfil de importação %d excluído", id)
+	return nil
+}
+
+// erroPerfil traduz a violação do nome único para ErrPerfilDuplicado
+func erroPerfil(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrPerfilDuplicado
+	}
+	log.Printf("Erro ao gravar perfil de importação: %v", err)
+	return err
+}
+
+func scanPerfil(s scanner) (PerfilImportacao, error) {
+	var perfil PerfilImportacao
+	var planilhas []byte
+
+	err := s.Scan(&perfil.ID, &perfil.Nome, &perfil.Descricao, &perfil.PadraoArquivo, &planilhas,
+		&perfil.RegraSufixo, &perfil.Sufixo, &perfil.Padrao, &perfil.CriadoEm, &perfil.AtualizadoEm)
+	if err != nil {
+		return perfil, err
+	}
+	if err := json.Unmarshal(planilhas, &perfil.Planilhas); err != nil {
+		return perfil, err
+	}
+	return perfil, nil
+}
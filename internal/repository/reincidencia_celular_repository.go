@@ -25,40 +25,39 @@ type ReincidenciaCelularStats struct {
 
 // GetReincidenciaPorCelular retorna estatísticas de reincidência de infratores por celular (versão otimizada)
 func (r *ReincidenciaCelularRepository) GetReincidenciaPorCelular(page int, limit int) ([]*ReincidenciaCelularStats, int, error) {
-	// Query otimizada usando window functions
+	// Agrupa pelo telefone em E.164, de modo que grafias diferentes do mesmo
+	// número contem como reincidência
 	query := `
 	WITH reincidencia_telefone AS (
 		SELECT 
-			telefone_envolvido,
+			telefone_normalizado,
 			COUNT(*) as quantidade,
 			ARRAY_AGG(numero_do_bo ORDER BY numero_do_bo) as numeros_bo,
 			MAX(nomecompleto) as nome_completo
-		FROM tabela_estelionato
+		FROM vw_envolvidos
 		WHERE tipo_envolvido = 'Suposto Autor/infrator'
-		  AND telefone_envolvido IS NOT NULL 
-		  AND telefone_envolvido != ''
-		GROUP BY telefone_envolvido
+		  AND telefone_normalizado <> ''
+		GROUP BY telefone_normalizado
 		HAVING COUNT(*) > 1
 	)
 	SELECT 
-		telefone_envolvido,
+		telefone_normalizado as telefone,
 		nome_completo,
 		ARRAY_TO_STRING(numeros_bo, ', ') as numeros_do_bo,
 		quantidade
 	FROM reincidencia_telefone
-	ORDER BY quantidade DESC, telefone_envolvido
+	ORDER BY quantidade DESC, telefone_normalizado
 	OFFSET $1 LIMIT $2;`
 
 	// Query de contagem otimizada
 	countQuery := `
 	SELECT COUNT(*)
 	FROM (
-		SELECT telefone_envolvido
-		FROM tabela_estelionato
+		SELECT telefone_normalizado
+		FROM vw_envolvidos
 		WHERE tipo_envolvido = 'Suposto Autor/infrator'
-		  AND telefone_envolvido IS NOT NULL 
-		  AND telefone_envolvido != ''
-		GROUP BY telefone_envolvido
+		  AND telefone_normalizado <> ''
+		GROUP BY telefone_normalizado
 		HAVING COUNT(*) > 1
 	) as contagem;`
 
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// ReincidenciaContaRepository gerencia operações de banco de dados relacionadas à reincidência por conta bancária
+type ReincidenciaContaRepository struct {
+	db *sql.DB
+}
+
+// NewReincidenciaContaRepository cria um novo repositório de reincidência por conta bancária
+func NewReincidenciaContaRepository(db *sql.DB) *ReincidenciaContaRepository {
+	return &ReincidenciaContaRepository{db: db}
+}
+
+// ReincidenciaContaStats representa estatísticas de reincidência por conta bancária
+type ReincidenciaContaStats struct {
+	InstituicaoBancaria   string `json:"instituicao_bancaria"`
+	NumeroAgenciaBancaria string `json:"numero_agencia_bancaria"`
+	NumeroContaBancaria   string `json:"numero_conta_bancaria"`
+	NomeCompleto          string `json:"nomecompleto"`
+	NumerosBOs            string `json:"numeros_do_bo"`
+	Quantidade            int    `json:"quantidade"`
+}
+
+// GetReincidenciaPorConta retorna as contas bancárias (banco + agência + conta)
+// que aparecem em mais de um BO. Agrupa pela conta canônica (conta_normalizada,
+// calculada em Go por normalize.ContaBancaria) para que "BB ag. 1234-5 cc
+// 00012345-X" e "001 / 1234 / 12345-0" sejam tratados como a mesma conta.
+func (r *ReincidenciaContaRepository) GetReincidenciaPorConta(page int, limit int) ([]ReincidenciaContaStats, int, error) {
+	query := `
+	WITH reincidencia_conta AS (
+		SELECT
+			conta_normalizada,
+			COUNT(DISTINCT numero_do_bo) as quantidade,
+			ARRAY_AGG(DISTINCT numero_do_bo ORDER BY numero_do_bo) as numeros_bo,
+			COALESCE(MAX(CASE WHEN tipo_envolvido = 'Suposto Autor/infrator' THEN nomecompleto END), '') as nome_infrator
+		FROM vw_envolvidos
+		WHERE conta_normalizada <> ''
+		  AND numero_do_bo <> ''
+		GROUP BY conta_normalizada
+		HAVING COUNT(DISTINCT numero_do_bo) > 1
+	)
+	SELECT
+		conta_normalizada,
+		nome_infrator,
+		ARRAY_TO_STRING(numeros_bo, ', ') as numeros_do_bo,
+		quantidade
+	FROM reincidencia_conta
+	ORDER BY quantidade DESC, conta_normalizada
+	OFFSET $1 LIMIT $2`
+
+	countQuery := `
+	SELECT COUNT(*)
+	FROM (
+		SELECT conta_normalizada
+		FROM vw_envolvidos
+		WHERE conta_normalizada <> ''
+		  AND numero_do_bo <> ''
+		GROUP BY conta_normalizada
+		HAVING COUNT(DISTINCT numero_do_bo) > 1
+	) as contagem`
+
+	var totalCount int
+	if err := r.db.QueryRow(countQuery).Scan(&totalCount); err != nil {
+		log.Printf("Erro ao contar total de reincidências por conta bancária: %v", err)
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(query, (page-1)*limit, limit)
+	if err != nil {
+		log.Printf("Erro ao consultar reincidência por conta bancária: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	stats := []ReincidenciaContaStats{}
+	for rows.Next() {
+		var stat ReincidenciaContaStats
+		var chave string
+		if err := rows.Scan(&chave, &stat.NomeCompleto, &stat.NumerosBOs, &stat.Quantidade); err != nil {
+			log.Printf("Erro ao processar resultado: %v", err)
+			return nil, 0, err
+		}
+		// A chave é "banco|agência|conta" (ver normalize.ContaBancaria)
+		if partes := strings.SplitN(chave, "|", 3); len(partes) == 3 {
+			stat.InstituicaoBancaria = partes[0]
+			stat.NumeroAgenciaBancaria = partes[1]
+			stat.NumeroContaBancaria = partes[2]
+		}
+		stats = append(stats, stat)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro após iteração: %v", err)
+		return nil, 0, err
+	}
+
+	return stats, totalCount, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"fraudbase/internal/normalize"
+	"log"
+)
+
+// ReincidenciaPIXRepository gerencia operações de banco de dados relacionadas à reincidência por chave PIX
+type ReincidenciaPIXRepository struct {
+	db *sql.DB
+}
+
+// NewReincidenciaPIXRepository cria um novo repositório de reincidência por chave PIX
+func NewReincidenciaPIXRepository(db *sql.DB) *ReincidenciaPIXRepository {
+	return &ReincidenciaPIXRepository{db: db}
+}
+
+// ReincidenciaPIXStats representa estatísticas de reincidência por chave PIX
+type ReincidenciaPIXStats struct {
+	ChavePix     string `json:"chave_pix"`
+	TipoChave    string `json:"tipo_chave"`
+	NomeCompleto string `json:"nomecompleto"`
+	NumerosBOs   string `json:"numeros_do_bo"`
+	Quantidade   int    `json:"quantidade"`
+}
+
+// GetReincidenciaPorPIX retorna as chaves PIX que aparecem em mais de um BO.
+// Agrupa pela chave canônica (pix_normalizado, calculada em Go por
+// normalize.ChavePix), pois a mesma chave costuma ser digitada de formas diferentes.
+func (r *ReincidenciaPIXRepository) GetReincidenciaPorPIX(page int, limit int) ([]ReincidenciaPIXStats, int, error) {
+	query := `
+	WITH reincidencia_pix AS (
+		SELECT
+			pix_normalizado,
+			COUNT(DISTINCT numero_do_bo) as quantidade,
+			ARRAY_AGG(DISTINCT numero_do_bo ORDER BY numero_do_bo) as numeros_bo,
+			COALESCE(MAX(CASE WHEN tipo_envolvido = 'Suposto Autor/infrator' THEN nomecompleto END), '') as nome_infrator
+		FROM vw_envolvidos
+		WHERE pix_normalizado <> ''
+		  AND numero_do_bo <> ''
+		GROUP BY pix_normalizado
+		HAVING COUNT(DISTINCT numero_do_bo) > 1
+	)
+	SELECT
+		pix_normalizado,
+		nome_infrator,
+		ARRAY_TO_STRING(numeros_bo, ', ') as numeros_do_bo,
+		quantidade
+	FROM reincidencia_pix
+	ORDER BY quantidade DESC, pix_normalizado
+	OFFSET $1 LIMIT $2`
+
+	countQuery := `
+	SELECT COUNT(*)
+	FROM (
+		SELECT pix_normalizado
+		FROM vw_envolvidos
+		WHERE pix_normalizado <> ''
+		  AND numero_do_bo <> ''
+		GROUP BY pix_normalizado
+		HAVING COUNT(DISTINCT numero_do_bo) > 1
+	) as contagem`
+
+	var totalCount int
+	if err := r.db.QueryRow(countQuery).Scan(&totalCount); err != nil {
+		log.Printf("Erro ao contar total de reincidências por PIX: %v", err)
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(query, (page-1)*limit, limit)
+	if err != nil {
+		log.Printf("Erro ao consultar reincidência por PIX: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	stats := []ReincidenciaPIXStats{}
+	for rows.Next() {
+		var stat ReincidenciaPIXStats
+		if err := rows.Scan(&stat.ChavePix, &stat.NomeCompleto, &stat.NumerosBOs, &stat.Quantidade); err != nil {
+			log.Printf("Erro ao processar resultado: %v", err)
+			return nil, 0, err
+		}
+		// A forma canônica mantém o tipo reconhecível
+		stat.TipoChave, _ = normalize.ChavePix(stat.ChavePix)
+		stats = append(stats, stat)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Erro após iteração: %v", err)
+		return nil, 0, err
+	}
+
+	return stats, totalCount, nil
+}
@@ -25,40 +25,39 @@ type ReincidenciaCPFStats struct {
 
 // GetReincidenciaPorCPF retorna estatísticas de reincidência de infratores por CPF (versão otimizada)
 func (r *ReincidenciaRepository) GetReincidenciaPorCPF(page int, limit int) ([]ReincidenciaCPFStats, int, error) {
-	// Query otimizada usando window functions e índices específicos
+	// Agrupa pelo CPF normalizado (somente dígitos), de modo que grafias diferentes
+	// do mesmo CPF contem como reincidência
 	query := `
 	WITH reincidencia_cpf AS (
 		SELECT 
-			cpf,
+			cpf_normalizado,
 			COUNT(*) as quantidade,
 			ARRAY_AGG(numero_do_bo ORDER BY numero_do_bo) as numeros_bo,
 			MAX(nomecompleto) as nome_completo
-		FROM tabela_estelionato
+		FROM vw_envolvidos
 		WHERE tipo_envolvido = 'Suposto Autor/infrator'
-		  AND cpf IS NOT NULL 
-		  AND cpf != ''
-		GROUP BY cpf
+		  AND cpf_normalizado <> ''
+		GROUP BY cpf_normalizado
 		HAVING COUNT(*) > 1
 	)
 	SELECT 
-		cpf,
+		regexp_replace(cpf_normalizado, '^(\d{3})(\d{3})(\d{3})(\d{2})$', '\1.\2.\3-\4') as cpf,
 		nome_completo,
 		ARRAY_TO_STRING(numeros_bo, ', ') as numeros_do_bo,
 		quantidade
 	FROM reincidencia_cpf
-	ORDER BY quantidade DESC, cpf
+	ORDER BY quantidade DESC, cpf_normalizado
 	OFFSET $1 LIMIT $2;`
 
 	// Query de contagem otimizada
 	countQuery := `
 	SELECT COUNT(*)
 	FROM (
-		SELECT cpf
-		FROM tabela_estelionato
+		SELECT cpf_normalizado
+		FROM vw_envolvidos
 		WHERE tipo_envolvido = 'Suposto Autor/infrator'
-		  AND cpf IS NOT NULL 
-		  AND cpf != ''
-		GROUP BY cpf
+		  AND cpf_normalizado <> ''
+		GROUP BY cpf_normalizado
 		HAVING COUNT(*) > 1
 	) as contagem;`
 
@@ -3,7 +3,6 @@ package repository
 import (
 	"database/sql"
 	"fmt"
-	"strings"
 	"time"
 	"log"
 	"fraudbase/internal/database"
@@ -19,59 +18,124 @@ func NewRelatorioRepository(db *sql.DB) *RelatorioRepository {
 
 // Estrutura para os dados processados do relatório
 type DadosRelatorio struct {
-	NumeroBo             string
-	DelegaciaResponsavel string
-	Situacao             string
-	Natureza             string
-	DataFato             string
-	CepFato              string
-	LatitudeFato         string
-	LongitudeFato        string
-	LogradouroFato       string
-	NumeroCasaFato       string
-	BairroFato           string
-	MunicipioFato        string
-	PaisFato             string
-	TipoEnvolvido        string
-	NomeCompleto         string
-	Cpf                  string
-	NomeDaMae            string
-	Nascimento           string
-	Nacionalidade        string
-	Naturalidade         string
-	UfEnvolvido          string
-	SexoEnvolvido        string
-	TelefoneEnvolvido    string
-	RelatoHistorico      string
+	NumeroBo             string `json:"numero_do_bo"`
+	DelegaciaResponsavel string `json:"delegacia_responsavel"`
+	Situacao             string `json:"situacao"`
+	Natureza             string `json:"natureza"`
+	DataFato             string `json:"data_fato"`
+	CepFato              string `json:"cep_fato"`
+	LatitudeFato         string `json:"latitude_fato"`
+	LongitudeFato        string `json:"longitude_fato"`
+	LogradouroFato       string `json:"logradouro_fato"`
+	NumeroCasaFato       string `json:"numerocasa_fato"`
+	BairroFato           string `json:"bairro_fato"`
+	MunicipioFato        string `json:"municipio_fato"`
+	PaisFato             string `json:"pais_fato"`
+	TipoEnvolvido        string `json:"tipo_envolvido"`
+	NomeCompleto         string `json:"nomecompleto"`
+	Cpf                  string `json:"cpf"`
+	NomeDaMae            string `json:"nomedamae"`
+	Nascimento           string `json:"nascimento"`
+	Nacionalidade        string `json:"nacionalidade"`
+	Naturalidade         string `json:"naturalidade"`
+	UfEnvolvido          string `json:"uf_envolvido"`
+	SexoEnvolvido        string `json:"sexo_envolvido"`
+	TelefoneEnvolvido    string `json:"telefone_envolvido"`
+	RelatoHistorico      string `json:"relato_historico"`
 }
 
-// InserirDadosRelatorio - versão otimizada com verificação de duplicatas
-func (r *RelatorioRepository) InserirDadosRelatorio(dados []DadosRelatorio) (int, int, error) {
-	if len(dados) == 0 {
-		return 0, 0, nil
-	}
+// ProgressoImportacao recebe as contagens acumuladas durante a importação:
+// processados são os registros já verificados (descartados como duplicata,
+// inseridos ou, na gravação parcial, perdidos em um lote com erro)
+type ProgressoImportacao func(processados, inseridos, duplicados int)
+
+// ResultadoImportacao resume a gravação de um relatório
+type ResultadoImportacao struct {
+	Inseridos   int
+	Atualizados int // só no modo de atualização
+	Duplicados  int
+	// LotesDescartados descreve os lotes desfeitos por erro na gravação parcial
+	LotesDescartados []string
+	// Alteracoes é o relatório por BO do modo de atualização
+	Alteracoes []AlteracaoBO
+	// NaoConvertidos é a quantidade de datas e coordenadas não reconhecidas nos
+	// registros inseridos, gravadas só como texto e em tabela_estelionato_conversao_erros
+	NaoConvertidos int
+	// Mantidos conta, por campo, as linhas com dados de um BO ou pessoa já gravado
+	// diferentes dos gravados, que prevaleceram (ver gravador_envolvidos.go)
+	Mantidos map[string]int
+}
 
-	log.Printf("Iniciando inserção de %d registros com verificação de duplicatas...", len(dados))
+// somar acumula o resultado de um lote
+func (r *ResultadoImportacao) somar(lote ResultadoImportacao) {
+	r.Inseridos += lote.Inseridos
+	r.Atualizados += lote.Atualizados
+	r.Duplicados += lote.Duplicados
+	r.Alteracoes = mesclarAlteracoes(r.Alteracoes, lote.Alteracoes)
+	for campo, linhas := range lote.Mantidos {
+		if r.Mantidos == nil {
+			r.Mantidos = make(map[string]int)
+		}
+		r.Mantidos[campo] += linhas
+	}
+}
 
-	// Verificar e remover duplicatas
-	dadosUnicos, duplicatasRemovidas, err := r.VerificarDuplicatas(dados)
-	if err != nil {
-		return 0, 0, fmt.Errorf("erro ao verificar duplicatas: %v", err)
+// gravarLote grava pela staging, conforme o modo da importação, os registros da
+// carga com ordem entre inicio (inclusive) e fim
+func gravarLote(tx *sql.Tx, inicio, fim int, autor Autor, importID int64, opcoes OpcoesImportacao, verificados func(duplicados int)) (ResultadoImportacao, error) {
+	if opcoes.Modo == ModoAtualizacao {
+		return atualizarStaging(tx, inicio, fim, autor, importID)
 	}
+	return importarStaging(tx, inicio, fim, autor, importID, verificados)
+}
 
-	if len(dadosUnicos) == 0 {
-		log.Println("Todos os registros são duplicatas, nenhum registro será inserido")
-		return 0, duplicatasRemovidas, nil
+// tamanhoLoteParcial é a quantidade de registros de cada savepoint na gravação parcial
+const tamanhoLoteParcial = 500
+
+// GravarCarga grava os registros da carga concluída que ainda não existem no
+// banco, na transação da carga: as duplicatas são descartadas por uma consulta e
+// o restante gravado em conjunto. No modo de atualização (opcoes.Modo) os
+// participantes já cadastrados são atualizados em vez de contados como duplicatas.
+// Se a gravação falhar nenhum registro é gravado, mas as linhas ignoradas na
+// leitura ficam guardadas como rejeições da importação. Com opcoes.GravacaoParcial
+// cada lote tem o seu savepoint: os lotes com erro são descartados e listados no
+// resultado, e os demais são confirmados.
+// Os registros inseridos ficam ligados à importação importID e entram no histórico
+// como importação feita pelo autor, e as linhas ignoradas e os registros
+// descartados são guardados como rejeições da importação. progresso (opcional) é
+// chamado após a verificação de duplicatas e após a gravação de cada lote.
+func (r *RelatorioRepository) GravarCarga(carga *CargaRelatorio, autor Autor, importID int64, opcoes OpcoesImportacao, progresso ProgressoImportacao) (ResultadoImportacao, error) {
+	total := carga.Total()
+	if progresso == nil {
+		progresso = func(int, int, int) {}
 	}
 
-	log.Printf("Inserindo %d registros únicos (%d duplicatas removidas)...", len(dadosUnicos), duplicatasRemovidas)
+	log.Printf("Iniciando inserção de %d registros com verificação de duplicatas...", total)
 
-	// Inserir apenas os dados únicos
-	registrosInseridos, err := r.inserirDadosNormal(dadosUnicos)
+	tx := carga.tx
+	if _, err := tx.Exec(`SAVEPOINT gravacao_carga`); err != nil {
+		return ResultadoImportacao{}, err
+	}
+	resultado, rejeicoes, err := gravarCarga(carga, autor, importID, opcoes, progresso)
 	if err != nil {
-		return registrosInseridos, duplicatasRemovidas, err
+		carga.guardarIgnoradas(importID)
+		return ResultadoImportacao{}, err
 	}
 
+	if resultado.Inseridos == 0 && resultado.Atualizados == 0 {
+		log.Println("Nenhum registro novo ou alterado para gravar")
+		if rejeicoes > 0 {
+			if err := tx.Commit(); err != nil {
+				return ResultadoImportacao{}, fmt.Errorf("erro ao confirmar a transação: %v", err)
+			}
+		}
+		return resultado, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return ResultadoImportacao{}, fmt.Errorf("erro ao confirmar a transação: %v", err)
+	}
+	progresso(total, resultado.Inseridos, resultado.Duplicados)
+
 	// Atualizar views materializadas após inserção
 	go func() {
 		time.Sleep(1 * time.Second) // Pequeno delay
@@ -79,145 +143,150 @@ func (r *RelatorioRepository) InserirDadosRelatorio(dados []DadosRelatorio) (int
 		log.Println("Views materializadas atualizadas após inserção")
 	}()
 
-	log.Printf("Inserção concluída: %d registros inseridos, %d duplicatas evitadas", registrosInseridos, duplicatasRemovidas)
-	return registrosInseridos, duplicatasRemovidas, nil
+	log.Printf("Inserção concluída: %d registros inseridos, %d atualizados, %d duplicatas evitadas, %d lotes descartados, %d valores não convertidos",
+		resultado.Inseridos, resultado.Atualizados, resultado.Duplicados, len(resultado.LotesDescartados), resultado.NaoConvertidos)
+	return resultado, nil
 }
 
-// Função para inserção em lotes otimizada
-func (r *RelatorioRepository) inserirDadosNormal(dados []DadosRelatorio) (int, error) {
-	// Query preparada para inserção em lote
-	query := `INSERT INTO tabela_estelionato (
-		numero_do_bo, delegacia_responsavel, situacao, natureza,
-		data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato,
-		numerocasa_fato, bairro_fato, municipio_fato, pais_fato,
-		tipo_envolvido, nomecompleto, cpf, nomedamae, nascimento,
-		nacionalidade, naturalidade, uf_envolvido, sexo_envolvido,
-		telefone_envolvido, relato_historico) VALUES `
-
-	batchSize := 500 // Lotes para evitar timeout
-	registrosInseridos := 0
-
-	for i := 0; i < len(dados); i += batchSize {
-		end := i + batchSize
-		if end > len(dados) {
-			end = len(dados)
+// gravarCarga faz, na transação da carga e sem confirmá-la, a gravação de
+// GravarCarga e retorna o resultado e a quantidade de rejeições guardadas
+func gravarCarga(carga *CargaRelatorio, autor Autor, importID int64, opcoes OpcoesImportacao, progresso ProgressoImportacao) (ResultadoImportacao, int64, error) {
+	var resultado ResultadoImportacao
+	total := carga.Total()
+	tx := carga.tx
+	var err error
+	if total > 0 && !opcoes.GravacaoParcial {
+		resultado, err = gravarLote(tx, 0, total, autor, importID, opcoes, func(duplicados int) {
+			progresso(duplicados, 0, duplicados)
+		})
+		if err != nil {
+			return ResultadoImportacao{}, 0, err
 		}
-
-		batch := dados[i:end]
-
-		// Construir placeholders
-		valueStrings := make([]string, 0, len(batch))
-		valueArgs := make([]interface{}, 0, len(batch)*24)
-
-		for j, d := range batch {
-			valueStrings = append(valueStrings, fmt.Sprintf("($%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d,$%d)",
-				j*24+1, j*24+2, j*24+3, j*24+4, j*24+5, j*24+6, j*24+7, j*24+8, j*24+9, j*24+10,
-				j*24+11, j*24+12, j*24+13, j*24+14, j*24+15, j*24+16, j*24+17, j*24+18, j*24+19, j*24+20,
-				j*24+21, j*24+22, j*24+23, j*24+24))
-
-			valueArgs = append(valueArgs,
-				d.NumeroBo, d.DelegaciaResponsavel, d.Situacao, d.Natureza,
-				d.DataFato, d.CepFato, d.LatitudeFato, d.LongitudeFato, d.LogradouroFato,
-				d.NumeroCasaFato, d.BairroFato, d.MunicipioFato, d.PaisFato,
-				d.TipoEnvolvido, d.NomeCompleto, d.Cpf, d.NomeDaMae, d.Nascimento,
-				d.Nacionalidade, d.Naturalidade, d.UfEnvolvido, d.SexoEnvolvido,
-				d.TelefoneEnvolvido, d.RelatoHistorico,
-			)
+	} else {
+		for i := 0; i < total; i += tamanhoLoteParcial {
+			end := i + tamanhoLoteParcial
+			if end > total {
+				end = total
+			}
+
+			if _, err := tx.Exec(`SAVEPOINT lote_importacao`); err != nil {
+				return ResultadoImportacao{}, 0, err
+			}
+			lote, err := gravarLote(tx, i, end, autor, importID, opcoes, nil)
+			if err != nil {
+				log.Printf("Lote com os registros %d a %d descartado: %v", i+1, end, err)
+				if _, errRollback := tx.Exec(`ROLLBACK TO SAVEPOINT lote_importacao`); errRollback != nil {
+					return ResultadoImportacao{}, 0, errRollback
+				}
+				resultado.LotesDescartados = append(resultado.LotesDescartados,
+					fmt.Sprintf("registros %d a %d descartados: %v", i+1, end, err))
+				if _, err := tx.Exec(sqlRejeitarLote, i, end, "lote descartado: "+err.Error()); err != nil {
+					return ResultadoImportacao{}, 0, err
+				}
+			} else {
+				if _, err := tx.Exec(`RELEASE SAVEPOINT lote_importacao`); err != nil {
+					return ResultadoImportacao{}, 0, err
+				}
+				resultado.somar(lote)
+			}
+
+			// As contagens só valem após o commit, mas mostram o andamento
+			progresso(end, resultado.Inseridos, resultado.Duplicados)
 		}
+	}
 
-		// Executar inserção do lote
-		batchQuery := query + strings.Join(valueStrings, ",")
-		_, err := r.DB.Exec(batchQuery, valueArgs...) // MUDANÇA: usar r.DB
+	if importID != 0 {
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM tabela_estelionato_conversao_erros ce
+			JOIN participacoes pa ON pa.id = ce.registro_id
+			WHERE pa.import_id = $1`, importID).Scan(&resultado.NaoConvertidos)
 		if err != nil {
-			return registrosInseridos, fmt.Errorf("erro ao inserir lote: %v", err)
-		}
-
-		registrosInseridos += len(batch)
-
-		// Log de progresso
-		if registrosInseridos%2500 == 0 {
-			log.Printf("Inseridos %d registros...", registrosInseridos)
+			return ResultadoImportacao{}, 0, err
 		}
 	}
 
-	log.Printf("Inserção em lotes concluída: %d registros", registrosInseridos)
-	return registrosInseridos, nil
+	// As rejeições são gravadas mesmo que nenhum registro tenha sido
+	rejeicoes, err := carga.guardarRejeicoes(importID)
+	if err != nil {
+		return ResultadoImportacao{}, 0, err
+	}
+	return resultado, rejeicoes, nil
 }
 
-// VerificarDuplicatas verifica quais registros já existem no banco
-func (r *RelatorioRepository) VerificarDuplicatas(dados []DadosRelatorio) ([]DadosRelatorio, int, error) {
-	if len(dados) == 0 {
-		return dados, 0, nil
+// sqlRejeitarLote anota em rejeicoes_carga os registros de um lote desfeito na
+// gravação parcial
+const sqlRejeitarLote = `
+	INSERT INTO rejeicoes_carga (ordem, motivo)
+	SELECT ordem, $3 FROM relatorio_importacao WHERE ordem >= $1 AND ordem < $2`
+
+// SimularAtualizacao executa o modo de atualização na transação da carga e
+// retorna o que seria alterado, para a prévia da importação. A carga deve ser
+// descartada em seguida.
+func (r *RelatorioRepository) SimularAtualizacao(carga *CargaRelatorio, autor Autor) (ResultadoImportacao, error) {
+	if carga.Total() == 0 {
+		return ResultadoImportacao{}, nil
 	}
 
-	log.Printf("Verificando duplicatas para %d registros...", len(dados))
-
-	// Criar um mapa para armazenar hashes dos registros do banco
-	existingHashes := make(map[string]bool)
-	duplicatesCount := 0
-
-	// Buscar registros existentes em lotes para não sobrecarregar a memória
-	batchSize := 1000
-	var dadosUnicos []DadosRelatorio
-
-	for i := 0; i < len(dados); i += batchSize {
-		end := i + batchSize
-		if end > len(dados) {
-			end = len(dados)
-		}
-
-		batch := dados[i:end]
-		uniqueBatch, duplicatesInBatch := r.filtrarDuplicatasBatch(batch, existingHashes)
-		dadosUnicos = append(dadosUnicos, uniqueBatch...)
-		duplicatesCount += duplicatesInBatch
+	resultado, err := atualizarStaging(carga.tx, 0, carga.Total(), autor, 0)
+	if err != nil {
+		return ResultadoImportacao{}, err
+	}
 
-		// Log de progresso
-		if i%5000 == 0 {
-			log.Printf("Verificados %d registros, %d duplicatas encontradas...", i, duplicatesCount)
+	// Os ids dos participantes novos não chegam a existir
+	for i := range resultado.Alteracoes {
+		for j := range resultado.Alteracoes[i].ParticipantesNovos {
+			resultado.Alteracoes[i].ParticipantesNovos[j].RegistroID = 0
 		}
 	}
+	return resultado, nil
+}
 
-	log.Printf("Verificação concluída: %d registros únicos, %d duplicatas removidas", len(dadosUnicos), duplicatesCount)
-	return dadosUnicos, duplicatesCount, nil
+// VerificacaoDuplicatas separa os registros de uma carga entre novos e já
+// existentes; as listas trazem só os primeiros de cada grupo, na ordem do arquivo
+type VerificacaoDuplicatas struct {
+	Novos             int
+	Duplicatas        int
+	AmostraNovos      []DadosRelatorio
+	AmostraDuplicatas []DadosRelatorio
 }
 
-// filtrarDuplicatasBatch processa um lote de dados verificando duplicatas
-func (r *RelatorioRepository) filtrarDuplicatasBatch(batch []DadosRelatorio, existingHashes map[string]bool) ([]DadosRelatorio, int) {
-	var uniqueData []DadosRelatorio
-	duplicatesCount := 0
-
-	// Criar query para verificar se os registros já existem
-	// Usando uma abordagem eficiente com hash dos campos principais
-	for _, registro := range batch {
-		// Criar hash único do registro baseado em campos chave
-		hashKey := r.criarHashRegistro(registro)
-
-		// Se já processamos este hash, é duplicata
-		if existingHashes[hashKey] {
-			duplicatesCount++
-			continue
-		}
+// VerificarDuplicatas separa os registros novos dos que já existem no banco (ou
+// se repetem no próprio arquivo), sem gravar nada, e traz até amostra registros
+// de cada grupo. A verificação é a mesma da importação, feita sobre a staging na
+// transação da carga, que deve ser descartada em seguida.
+func (r *RelatorioRepository) VerificarDuplicatas(carga *CargaRelatorio, amostra int) (VerificacaoDuplicatas, error) {
+	verificacao := VerificacaoDuplicatas{AmostraNovos: []DadosRelatorio{}, AmostraDuplicatas: []DadosRelatorio{}}
+	total := carga.Total()
+	if total == 0 {
+		return verificacao, nil
+	}
 
-		// Verificar se existe no banco usando campos únicos mais prováveis
-		exists, err := r.verificarExistenciaRegistro(registro)
-		if err != nil {
-			log.Printf("Erro ao verificar registro: %v", err)
-			// Em caso de erro, incluir o registro (melhor incluir que perder)
-			uniqueData = append(uniqueData, registro)
-			existingHashes[hashKey] = true
-			continue
-		}
+	log.Printf("Verificando duplicatas para %d registros...", total)
 
-		if exists {
-			duplicatesCount++
-			existingHashes[hashKey] = true
-		} else {
-			uniqueData = append(uniqueData, registro)
-			existingHashes[hashKey] = true
-		}
+	if err := prepararStaging(carga.tx, 0, total); err != nil {
+		return verificacao, err
+	}
+	duplicatas, err := descartarDuplicatasStaging(carga.tx)
+	if err != nil {
+		return verificacao, err
 	}
+	verificacao.Novos, verificacao.Duplicatas = total-duplicatas, duplicatas
 
-	return uniqueData, duplicatesCount
+	verificacao.AmostraNovos, err = amostraRelatorio(carga.tx,
+		`SELECT `+colunasRelatorio+` FROM staging_importacao ORDER BY ordem LIMIT $1`, amostra)
+	if err != nil {
+		return verificacao, err
+	}
+	verificacao.AmostraDuplicatas, err = amostraRelatorio(carga.tx,
+		`SELECT `+colunasRelatorio+` FROM relatorio_importacao r
+		WHERE NOT EXISTS (SELECT 1 FROM staging_importacao s WHERE s.ordem = r.ordem)
+		ORDER BY ordem LIMIT $1`, amostra)
+	if err != nil {
+		return verificacao, err
+	}
+
+	log.Printf("Verificação concluída: %d registros únicos, %d duplicatas removidas", verificacao.Novos, verificacao.Duplicatas)
+	return verificacao, nil
 }
 
 // GetEstadoUsuario obtém o estado do usuário pelo ID
@@ -240,55 +309,10 @@ func (r *RelatorioRepository) GetEstadoUsuario(userID int) (string, error) {
 	return estado.String, nil
 }
 
-// criarHashRegistro cria um hash único baseado nos campos principais
-func (r *RelatorioRepository) criarHashRegistro(registro DadosRelatorio) string {
-	// Usar o número do BO como está (já com sufixo)
-	return fmt.Sprintf("%s|%s|%s|%s|%s|%s", 
-		registro.NumeroBo,      // Já com sufixo do estado
-		registro.TipoEnvolvido, 
-		registro.NomeCompleto,
-		registro.Cpf,
-		registro.TelefoneEnvolvido,
-		registro.DataFato)
-}
-
-// verificarExistenciaRegistro verifica se um registro específico já existe (MÉTODO OTIMIZADO)
-func (r *RelatorioRepository) verificarExistenciaRegistro(registro DadosRelatorio) (bool, error) {
-	// Query OTIMIZADA - verificação direta sem variações
-	query := `
-		SELECT 1 FROM tabela_estelionato 
-		WHERE numero_do_bo = $1
-		  AND COALESCE(tipo_envolvido, '') = $2
-		  AND COALESCE(nomecompleto, '') = $3
-		  AND COALESCE(cpf, '') = $4
-		  AND COALESCE(telefone_envolvido, '') = $5
-		  AND COALESCE(data_fato, '') = $6
-		LIMIT 1`
-	
-	var exists int
-	err := r.DB.QueryRow(query, 
-		registro.NumeroBo,  // Já vem com sufixo do estado
-		registro.TipoEnvolvido,
-		registro.NomeCompleto,
-		registro.Cpf,
-		registro.TelefoneEnvolvido,
-		registro.DataFato,
-	).Scan(&exists)
-	
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return false, nil
-		}
-		return false, err
-	}
-	
-	return true, nil
-}
-
 // Método alternativo mais rigoroso (verifica TODOS os campos)
 func (r *RelatorioRepository) verificarExistenciaCompleta(registro DadosRelatorio) (bool, error) {
 	// Query que verifica TODOS os campos (como a limpeza de duplicatas faz)
-	query := `SELECT 1 FROM tabela_estelionato
+	query := `SELECT 1 FROM vw_envolvidos_todos
 	WHERE numero_do_bo = $1
 	  AND COALESCE(delegacia_responsavel, '') = $2
 	  AND COALESCE(situacao, '') = $3
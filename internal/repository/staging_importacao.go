@@ -0,0 +1,363 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Os registros de um relatório são tratados em conjunto: copiados da carga
+// (relatorio_importacao, ver carga_relatorio.go) para a tabela temporária
+// staging_importacao, as duplicatas são descartadas por uma única consulta e os
+// restantes gravados com INSERT ... SELECT nas três tabelas. A tabela some ao fim
+// da transação.
+
+const sqlCriarStaging = `
+	CREATE TEMP TABLE staging_importacao (
+		ordem INTEGER PRIMARY KEY,
+		numero_do_bo TEXT NOT NULL,
+		delegacia_responsavel TEXT NOT NULL,
+		situacao TEXT NOT NULL,
+		natureza TEXT NOT NULL,
+		data_fato TEXT NOT NULL,
+		cep_fato TEXT NOT NULL,
+		latitude_fato TEXT NOT NULL,
+		longitude_fato TEXT NOT NULL,
+		logradouro_fato TEXT NOT NULL,
+		numerocasa_fato TEXT NOT NULL,
+		bairro_fato TEXT NOT NULL,
+		municipio_fato TEXT NOT NULL,
+		pais_fato TEXT NOT NULL,
+		tipo_envolvido TEXT NOT NULL,
+		nomecompleto TEXT NOT NULL,
+		cpf TEXT NOT NULL,
+		nomedamae TEXT NOT NULL,
+		nascimento TEXT NOT NULL,
+		nacionalidade TEXT NOT NULL,
+		naturalidade TEXT NOT NULL,
+		uf_envolvido TEXT NOT NULL,
+		sexo_envolvido TEXT NOT NULL,
+		telefone_envolvido TEXT NOT NULL,
+		relato_historico TEXT NOT NULL,
+		data_fato_ts TIMESTAMPTZ,
+		nascimento_data DATE,
+		latitude_fato_num DOUBLE PRECISION,
+		longitude_fato_num DOUBLE PRECISION,
+		cpf_normalizado TEXT NOT NULL,
+		telefone_normalizado TEXT NOT NULL,
+		chave_identidade TEXT,
+		ocorrencia_id INTEGER,
+		pessoa_id INTEGER,
+		participacao_id INTEGER,
+		participante_excluido BOOLEAN NOT NULL DEFAULT FALSE
+	) ON COMMIT DROP`
+
+// colunasStaging são as colunas copiadas de relatorio_importacao, que tem os mesmos nomes
+var colunasStaging = []string{
+	"ordem", "numero_do_bo", "delegacia_responsavel", "situacao", "natureza", "data_fato",
+	"cep_fato", "latitude_fato", "longitude_fato", "logradouro_fato", "numerocasa_fato",
+	"bairro_fato", "municipio_fato", "pais_fato", "tipo_envolvido", "nomecompleto", "cpf",
+	"nomedamae", "nascimento", "nacionalidade", "naturalidade", "uf_envolvido", "sexo_envolvido",
+	"telefone_envolvido", "relato_historico", "data_fato_ts", "nascimento_data",
+	"latitude_fato_num", "longitude_fato_num", "cpf_normalizado",
+	"telefone_normalizado",
+}
+
+// Um participante é identificado pelo BO, pela pessoa (chave_pessoa ou, sem
+// chave, o nome) e pelo papel, tanto na deduplicação da inclusão quanto na
+// comparação do modo de atualização. sqlParticipanteStaging é essa chave para as
+// linhas da staging; sqlMesmaPessoaStaging compara a pessoa pe com a da linha s.
+const (
+	sqlParticipanteStaging = `numero_do_bo, COALESCE(chave_identidade, 'nome:' || upper(trim(nomecompleto))), tipo_envolvido`
+
+	sqlMesmaPessoaStaging = `(pe.chave_identidade = s.chave_identidade
+			OR (s.chave_identidade IS NULL AND pe.chave_identidade IS NULL
+				AND upper(trim(COALESCE(pe.nomecompleto, ''))) = upper(trim(s.nomecompleto))))`
+)
+
+// sqlDescartarDuplicatas remove da staging os participantes que já existem no
+// banco (inclusive excluídos, para que a reimportação não os traga de volta) ou
+// que repetem um participante anterior do próprio arquivo, e os anota em
+// rejeicoes_carga. Retorna a ordem dos removidos.
+const sqlDescartarDuplicatas = `
+	WITH numerados AS (
+		SELECT ordem, ROW_NUMBER() OVER (PARTITION BY ` + sqlParticipanteStaging + ` ORDER BY ordem) AS repeticao
+		FROM staging_importacao
+	), descartados AS (
+		DELETE FROM staging_importacao s
+		USING numerados n
+		WHERE n.ordem = s.ordem
+		  AND (n.repeticao > 1 OR EXISTS (
+			SELECT 1
+			FROM ocorrencias o
+			JOIN participacoes pa ON pa.ocorrencia_id = o.id AND COALESCE(pa.tipo_envolvido, '') = s.tipo_envolvido
+			JOIN pessoas pe ON pe.id = pa.pessoa_id
+			WHERE o.numero_do_bo = s.numero_do_bo
+			  AND ` + sqlMesmaPessoaStaging + `
+		  ))
+		RETURNING s.ordem, n.repeticao > 1 AS repetido
+	)
+	INSERT INTO rejeicoes_carga (ordem, motivo)
+	SELECT ordem, CASE WHEN repetido
+		THEN 'duplicata: repete um registro anterior do próprio arquivo'
+		ELSE 'duplicata: registro já existe no banco' END
+	FROM descartados
+	RETURNING ordem`
+
+// sqlPrepararStaging copia para a staging uma faixa de ordem da carga
+var sqlPrepararStaging = fmt.Sprintf(
+	`INSERT INTO staging_importacao (%[1]s) SELECT %[1]s FROM relatorio_importacao WHERE ordem >= $1 AND ordem < $2`,
+	strings.Join(colunasStaging, ", "))
+
+// sqlChaveIdentidadeStaging calcula a chave de deduplicação da pessoa de cada linha,
+// logo ao preparar a staging
+const sqlChaveIdentidadeStaging = `UPDATE staging_importacao SET chave_identidade = chave_pessoa(cpf, nomecompleto, nomedamae, nascimento)`
+
+// etapasGravarStaging gravam os registros restantes na staging, na mesma lógica do
+// gravadorEnvolvidos: ocorrências e pessoas já existentes são reaproveitadas sem
+// alteração; BOs sem número e pessoas sem chave geram uma linha própria, com o id
+// reservado na staging.
+var etapasGravarStaging = []string{
+	`INSERT INTO ocorrencias (
+		numero_do_bo, data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato,
+		numerocasa_fato, bairro_fato, municipio_fato, pais_fato, delegacia_responsavel,
+		situacao, natureza, relato_historico, data_fato_ts, latitude_fato_num, longitude_fato_num
+	)
+	SELECT DISTINCT ON (numero_do_bo)
+		numero_do_bo, data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato,
+		numerocasa_fato, bairro_fato, municipio_fato, pais_fato, delegacia_responsavel,
+		situacao, natureza, relato_historico, data_fato_ts, latitude_fato_num, longitude_fato_num
+	FROM staging_importacao
+	WHERE numero_do_bo <> ''
+	ORDER BY numero_do_bo, ordem
+	ON CONFLICT (numero_do_bo) DO NOTHING`,
+
+	`UPDATE staging_importacao s SET ocorrencia_id = o.id
+	FROM ocorrencias o
+	WHERE o.numero_do_bo = s.numero_do_bo`,
+
+	`UPDATE staging_importacao SET ocorrencia_id = nextval(pg_get_serial_sequence('ocorrencias', 'id'))
+	WHERE numero_do_bo = ''`,
+
+	`INSERT INTO ocorrencias (
+		id, numero_do_bo, data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato,
+		numerocasa_fato, bairro_fato, municipio_fato, pais_fato, delegacia_responsavel,
+		situacao, natureza, relato_historico, data_fato_ts, latitude_fato_num, longitude_fato_num
+	)
+	SELECT
+		ocorrencia_id, NULL, data_fato, cep_fato, latitude_fato, longitude_fato, logradouro_fato,
+		numerocasa_fato, bairro_fato, municipio_fato, pais_fato, delegacia_responsavel,
+		situacao, natureza, relato_historico, data_fato_ts, latitude_fato_num, longitude_fato_num
+	FROM staging_importacao
+	WHERE numero_do_bo = ''`,
+
+	`INSERT INTO pessoas (
+		chave_identidade, nomecompleto, cpf, nomedamae, nascimento, nacionalidade,
+		naturalidade, uf_envolvido, sexo_envolvido, nascimento_data, cpf_normalizado
+	)
+	SELECT DISTINCT ON (chave_identidade)
+		chave_identidade, nomecompleto, cpf, nomedamae, nascimento, nacionalidade,
+		naturalidade, uf_envolvido, sexo_envolvido, nascimento_data, cpf_normalizado
+	FROM staging_importacao
+	WHERE chave_identidade IS NOT NULL
+	ORDER BY chave_identidade, ordem
+	ON CONFLICT (chave_identidade) DO NOTHING`,
+
+	`UPDATE staging_importacao s SET pessoa_id = p.id
+	FROM pessoas p
+	WHERE p.chave_identidade = s.chave_identidade`,
+
+	`UPDATE staging_importacao SET pessoa_id = nextval(pg_get_serial_sequence('pessoas', 'id'))
+	WHERE chave_identidade IS NULL`,
+
+	`INSERT INTO pessoas (
+		id, chave_identidade, nomecompleto, cpf, nomedamae, nascimento, nacionalidade,
+		naturalidade, uf_envolvido, sexo_envolvido, nascimento_data, cpf_normalizado
+	)
+	SELECT
+		pessoa_id, NULL, nomecompleto, cpf, nomedamae, nascimento, nacionalidade,
+		naturalidade, uf_envolvido, sexo_envolvido, nascimento_data, cpf_normalizado
+	FROM staging_importacao
+	WHERE chave_identidade IS NULL`,
+
+	// Os ids das participações seguem a ordem das linhas do arquivo
+	`UPDATE staging_importacao s SET participacao_id = n.id
+	FROM (
+		SELECT ordem, nextval(pg_get_serial_sequence('participacoes', 'id')) AS id
+		FROM (SELECT ordem FROM staging_importacao ORDER BY ordem) o
+	) n
+	WHERE n.ordem = s.ordem`,
+}
+
+// sqlParticipacoesStaging grava as participações; os campos de transação não
+// existem no relatório e ficam vazios, como no cadastro
+const sqlParticipacoesStaging = `
+	INSERT INTO participacoes (
+		id, ocorrencia_id, pessoa_id, tipo_envolvido, telefone_envolvido, instituicao_bancaria,
+		endereco_ip, valor, pix_utilizado, numero_conta_bancaria, numero_boleto, processo_banco,
+		numero_agencia_bancaria, cartao, terminal, tipo_pagamento, orgao_concessionaria, veiculo,
+		terminal_conexao, erb, operacao_policial, numero_laudo_pericial, valor_numerico, campos_tipados,
+		cadastrado_por, unidade_cadastro, telefone_normalizado, import_id, pix_normalizado,
+		conta_normalizada
+	)
+	SELECT
+		participacao_id, ocorrencia_id, pessoa_id, tipo_envolvido, telefone_envolvido, '',
+		'', '', '', '', '', '',
+		'', '', '', '', '', '',
+		'', '', '', '', NULL, TRUE,
+		NULLIF($1, 0), (SELECT unidade_policial FROM usuarios WHERE id = $1), telefone_normalizado, NULLIF($2, 0), '',
+		''
+	FROM staging_importacao
+	ORDER BY ordem`
+
+// sqlConversaoErrosStaging registra como erros de conversão os valores gravados
+// das participações novas que não viraram data ou coordenada, como na conversão
+// de MigrarCamposTipados; o BO ou a pessoa já cadastrados valem pelos seus valores
+const sqlConversaoErrosStaging = `
+	INSERT INTO tabela_estelionato_conversao_erros (registro_id, campo, valor)
+	SELECT s.participacao_id, c.campo, c.valor
+	FROM staging_importacao s
+	JOIN ocorrencias o ON o.id = s.ocorrencia_id
+	JOIN pessoas pe ON pe.id = s.pessoa_id
+	CROSS JOIN LATERAL (VALUES
+		(1, 'data_fato', o.data_fato::TEXT, o.data_fato_ts IS NULL),
+		(2, 'nascimento', pe.nascimento::TEXT, pe.nascimento_data IS NULL),
+		(3, 'latitude_fato', o.latitude_fato::TEXT, o.latitude_fato_num IS NULL),
+		(4, 'longitude_fato', o.longitude_fato::TEXT, o.longitude_fato_num IS NULL)
+	) AS c(posicao, campo, valor, nulo)
+	WHERE c.nulo AND COALESCE(c.valor, '') <> ''
+	ORDER BY s.ordem, c.posicao`
+
+// sqlDivergenciasStaging conta, por campo, as linhas gravadas cujo BO ou pessoa
+// já existia (no banco ou em uma linha anterior do arquivo) com outro valor: vale
+// o valor gravado primeiro, como no cadastro (ver gravador_envolvidos.go)
+var sqlDivergenciasStaging = `
+	SELECT c.campo, COUNT(*) FROM staging_importacao s
+	JOIN ocorrencias o ON o.id = s.ocorrencia_id
+	CROSS JOIN LATERAL ` + valoresComparados("o", camposOcorrencia, colunaStaging) + `
+	WHERE c.novo <> '' AND c.novo IS DISTINCT FROM c.atual
+	GROUP BY c.campo
+	UNION ALL
+	SELECT c.campo, COUNT(*) FROM staging_importacao s
+	JOIN pessoas pe ON pe.id = s.pessoa_id
+	CROSS JOIN LATERAL ` + valoresComparados("pe", camposPessoa, colunaStaging) + `
+	WHERE c.novo <> '' AND c.novo IS DISTINCT FROM c.atual
+	GROUP BY c.campo`
+
+func colunaStaging(_ int, campo string) string {
+	return "s." + campo
+}
+
+// sqlHistoricoStaging registra no histórico a criação de cada participação gravada
+const sqlHistoricoStaging = `
+	INSERT INTO tabela_estelionato_history (registro_id, acao, usuario_id, usuario_login, motivo, antes, depois, alteracoes)
+	SELECT s.participacao_id, $1, NULLIF($2, 0), $3, NULLIF($4, ''), NULL, estado.depois, diff_jsonb(NULL::jsonb, estado.depois)
+	FROM staging_importacao s
+	CROSS JOIN LATERAL (SELECT snapshot_envolvido(s.participacao_id) AS depois) estado
+	WHERE diff_jsonb(NULL::jsonb, estado.depois) <> '{}'::jsonb
+	ORDER BY s.ordem`
+
+// importarStaging grava pela staging os registros da carga com ordem entre inicio
+// (inclusive) e fim e retorna quantos foram inseridos, quantos descartados como
+// duplicata e os campos mantidos de BOs e pessoas já gravados. verificados
+// (opcional) recebe a quantidade de duplicatas antes da gravação.
+func importarStaging(tx *sql.Tx, inicio, fim int, autor Autor, importID int64, verificados func(duplicados int)) (ResultadoImportacao, error) {
+	var resultado ResultadoImportacao
+	if err := prepararStaging(tx, inicio, fim); err != nil {
+		return resultado, fmt.Errorf("erro ao copiar registros para a tabela temporária: %v", err)
+	}
+
+	duplicatas, err := descartarDuplicatasStaging(tx)
+	if err != nil {
+		return resultado, fmt.Errorf("erro ao verificar duplicatas: %v", err)
+	}
+	resultado.Duplicados = duplicatas
+	if verificados != nil {
+		verificados(duplicatas)
+	}
+
+	if duplicatas < fim-inicio {
+		if resultado.Inseridos, resultado.Mantidos, err = gravarStaging(tx, autor, importID); err != nil {
+			return ResultadoImportacao{Duplicados: duplicatas}, fmt.Errorf("erro ao inserir registros: %v", err)
+		}
+	}
+
+	if _, err := tx.Exec(`DROP TABLE staging_importacao`); err != nil {
+		return ResultadoImportacao{}, err
+	}
+	return resultado, nil
+}
+
+// prepararStaging cria a staging na transação, copia para ela os registros da
+// carga com ordem entre inicio (inclusive) e fim e calcula a chave das pessoas
+func prepararStaging(tx *sql.Tx, inicio, fim int) error {
+	if _, err := tx.Exec(sqlCriarStaging); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(sqlPrepararStaging, inicio, fim); err != nil {
+		return err
+	}
+	_, err := tx.Exec(sqlChaveIdentidadeStaging)
+	return err
+}
+
+// descartarDuplicatasStaging remove as duplicatas da staging e retorna quantas eram
+func descartarDuplicatasStaging(tx *sql.Tx) (int, error) {
+	return contarLinhas(tx, sqlDescartarDuplicatas)
+}
+
+// gravarStaging grava os registros da staging e retorna quantos foram inseridos e,
+// por campo, quantas linhas trouxeram dados divergentes de um BO ou pessoa já gravado
+func gravarStaging(tx *sql.Tx, autor Autor, importID int64) (int, map[string]int, error) {
+	for i, etapa := range etapasGravarStaging {
+		if _, err := tx.Exec(etapa); err != nil {
+			return 0, nil, fmt.Errorf("etapa %d da gravação: %v", i+1, err)
+		}
+	}
+	// $1 é o usuário que importou e $2 a importação de origem
+	if _, err := tx.Exec(sqlParticipacoesStaging, autor.UsuarioID, importID); err != nil {
+		return 0, nil, fmt.Errorf("erro ao gravar participações: %v", err)
+	}
+
+	if _, err := tx.Exec(sqlConversaoErrosStaging); err != nil {
+		return 0, nil, fmt.Errorf("erro ao registrar valores não convertidos: %v", err)
+	}
+
+	motivo := ""
+	if importID != 0 {
+		motivo = fmt.Sprintf("importação %d", importID)
+	}
+	if _, err := tx.Exec(sqlHistoricoStaging, AcaoImportacao, autor.UsuarioID, autor.Login, motivo); err != nil {
+		return 0, nil, fmt.Errorf("erro ao registrar histórico: %v", err)
+	}
+
+	mantidos, err := contarDivergenciasStaging(tx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("erro ao comparar com os dados já gravados: %v", err)
+	}
+
+	var inseridos int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM staging_importacao`).Scan(&inseridos); err != nil {
+		return 0, nil, err
+	}
+	return inseridos, mantidos, nil
+}
+
+func contarDivergenciasStaging(tx *sql.Tx) (map[string]int, error) {
+	rows, err := tx.Query(sqlDivergenciasStaging)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mantidos := make(map[string]int)
+	for rows.Next() {
+		var campo string
+		var linhas int
+		if err := rows.Scan(&campo, &linhas); err != nil {
+			return nil, err
+		}
+		mantidos[campo] += linhas
+	}
+	return mantidos, rows.Err()
+}
@@ -0,0 +1,78 @@
+package validacao
+
+import (
+	"strings"
+
+	"fraudbase/internal/models"
+	"fraudbase/internal/normalize"
+)
+
+// Envolvido valida os campos de um envolvido cadastrado ou alterado manualmente
+// e substitui os valores válidos pela forma canônica (CPF 000.000.000-00, telefone
+// (DD) NNNNN-NNNN, CEP 00000-000, sigla da UF e datas DD/MM/AAAA), para que
+// consultas e reincidências agrupem o mesmo dado digitado de formas diferentes.
+// Retorna nil quando todos os campos são válidos.
+func Envolvido(e *models.Envolvido) Erros {
+	erros := Erros{}
+
+	obrigatorios := []struct {
+		campo string
+		valor *string
+	}{
+		{"numero_do_bo", &e.NumeroBO},
+		{"tipo_envolvido", &e.TipoEnvolvido},
+		{"nomecompleto", &e.NomeCompleto},
+	}
+	for _, o := range obrigatorios {
+		*o.valor = strings.TrimSpace(*o.valor)
+		if *o.valor == "" {
+			erros[o.campo] = "campo obrigatório"
+		}
+	}
+
+	canonicos := []struct {
+		campo    string
+		valor    *string
+		validar  func(string) (string, bool)
+		mensagem string
+	}{
+		{"cpf", &e.CPF, CPF, "CPF inválido (dígitos verificadores não conferem)"},
+		{"telefone_envolvido", &e.TelefoneEnvolvido, Telefone, "telefone inválido (use DDD + 8 dígitos, ou 9 dígitos para celular)"},
+		{"cep_fato", &e.CEPFato, CEP, "CEP inválido (use 8 dígitos)"},
+		{"uf_envolvido", &e.UFEnvolvido, UF, "UF inválida (use a sigla, ex.: RO)"},
+		{"nascimento", &e.Nascimento, Data, "data de nascimento inválida (use DD/MM/AAAA)"},
+		{"data_fato", &e.DataFato, Data, "data do fato inválida (use DD/MM/AAAA)"},
+	}
+	for _, c := range canonicos {
+		if strings.TrimSpace(*c.valor) == "" {
+			*c.valor = ""
+			continue
+		}
+		if canonico, ok := c.validar(*c.valor); ok {
+			*c.valor = canonico
+		} else {
+			erros[c.campo] = c.mensagem
+		}
+	}
+
+	if e.Valor != "" {
+		if v, ok := normalize.Valor(e.Valor); !ok || v >= 1e12 {
+			erros["valor"] = "valor inválido (ex.: 1.234,56)"
+		}
+	}
+	if e.LatitudeFato != "" {
+		if v, ok := normalize.Coordenada(e.LatitudeFato); !ok || v < -90 || v > 90 {
+			erros["latitude_fato"] = "latitude inválida"
+		}
+	}
+	if e.LongitudeFato != "" {
+		if v, ok := normalize.Coordenada(e.LongitudeFato); !ok || v < -180 || v > 180 {
+			erros["longitude_fato"] = "longitude inválida"
+		}
+	}
+
+	if len(erros) == 0 {
+		return nil
+	}
+	return erros
+}
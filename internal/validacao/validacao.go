@@ -0,0 +1,112 @@
+package validacao
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"fraudbase/internal/normalize"
+)
+
+// Erros reúne as mensagens de erro por campo (nome do campo no JSON → mensagem)
+type Erros map[string]string
+
+// Error lista os campos inválidos em ordem alfabética
+func (e Erros) Error() string {
+	campos := make([]string, 0, len(e))
+	for campo := range e {
+		campos = append(campos, campo)
+	}
+	sort.Strings(campos)
+
+	mensagens := make([]string, len(campos))
+	for i, campo := range campos {
+		mensagens[i] = campo + ": " + e[campo]
+	}
+	return "dados inválidos (" + strings.Join(mensagens, "; ") + ")"
+}
+
+// ufs mapeia as siglas das unidades da federação para o nome (sem acentos, em maiúsculas)
+var ufs = map[string]string{
+	"AC": "ACRE", "AL": "ALAGOAS", "AP": "AMAPA", "AM": "AMAZONAS", "BA": "BAHIA",
+	"CE": "CEARA", "DF": "DISTRITO FEDERAL", "ES": "ESPIRITO SANTO", "GO": "GOIAS",
+	"MA": "MARANHAO", "MT": "MATO GROSSO", "MS": "MATO GROSSO DO SUL", "MG": "MINAS GERAIS",
+	"PA": "PARA", "PB": "PARAIBA", "PR": "PARANA", "PE": "PERNAMBUCO", "PI": "PIAUI",
+	"RJ": "RIO DE JANEIRO", "RN": "RIO GRANDE DO NORTE", "RS": "RIO GRANDE DO SUL",
+	"RO": "RONDONIA", "RR": "RORAIMA", "SC": "SANTA CATARINA", "SP": "SAO PAULO",
+	"SE": "SERGIPE", "TO": "TOCANTINS",
+}
+
+// CPF valida os dígitos verificadores e retorna o CPF no formato 000.000.000-00
+func CPF(valor string) (string, bool) {
+	digitos := normalize.SomenteDigitos(valor)
+	if !normalize.CPFValido(digitos) {
+		return "", false
+	}
+	return digitos[:3] + "." + digitos[3:6] + "." + digitos[6:9] + "-" + digitos[9:], true
+}
+
+// Telefone valida um telefone brasileiro (DDD + 8 dígitos, ou 9 dígitos começando
+// por 9 para celular, com ou sem +55/0 na frente) e retorna no formato
+// (DD) NNNN-NNNN ou (DD) NNNNN-NNNN
+func Telefone(valor string) (string, bool) {
+	e164 := normalize.Telefone(valor)
+	if e164 == "" {
+		return "", false
+	}
+
+	digitos := strings.TrimPrefix(e164, "+55")
+	ddd, numero := digitos[:2], digitos[2:]
+
+	// DDDs vão de 11 a 99 e nenhum dos dois dígitos é zero
+	if ddd[0] == '0' || ddd[1] == '0' {
+		return "", false
+	}
+
+	switch {
+	case len(numero) == 9 && numero[0] == '9':
+		return "(" + ddd + ") " + numero[:5] + "-" + numero[5:], true
+	case len(numero) == 8 && numero[0] >= '2':
+		return "(" + ddd + ") " + numero[:4] + "-" + numero[4:], true
+	}
+
+	return "", false
+}
+
+// CEP valida um CEP de 8 dígitos e retorna no formato 00000-000
+func CEP(valor string) (string, bool) {
+	digitos := normalize.SomenteDigitos(valor)
+	if len(digitos) != 8 || digitos == "00000000" {
+		return "", false
+	}
+	return digitos[:5] + "-" + digitos[5:], true
+}
+
+// UF aceita a sigla ou o nome da unidade da federação e retorna a sigla
+func UF(valor string) (string, bool) {
+	texto := normalize.TextoSemAcentos(valor)
+	if _, ok := ufs[texto]; ok {
+		return texto, true
+	}
+	for sigla, nome := range ufs {
+		if nome == texto {
+			return sigla, true
+		}
+	}
+	return "", false
+}
+
+// Data valida uma data (DD/MM/AAAA ou AAAA-MM-DD, com ou sem hora) e retorna no
+// formato DD/MM/AAAA, mantendo a hora quando informada. Datas futuras ou anteriores
+// a 1900 são rejeitadas.
+func Data(valor string) (string, bool) {
+	t, ok := normalize.Data(valor)
+	if !ok || t.Year() < 1900 || t.After(time.Now()) {
+		return "", false
+	}
+
+	if t.Hour() != 0 || t.Minute() != 0 {
+		return t.Format("02/01/2006 15:04"), true
+	}
+	return t.Format("02/01/2006"), true
+}
@@ -0,0 +1,182 @@
+package validacao
+
+import (
+	"testing"
+
+	"fraudbase/internal/models"
+)
+
+func TestCPF(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+		valido   bool
+	}{
+		{"52998224725", "529.982.247-25", true},
+		{"529.982.247-25", "529.982.247-25", true},
+		{" 529 982 247 25 ", "529.982.247-25", true},
+		{"52998224724", "", false},
+		{"11111111111", "", false},
+		{"5299822472", "", false},
+		{"", "", false},
+	}
+	for _, c := range casos {
+		obtido, ok := CPF(c.entrada)
+		if ok != c.valido || obtido != c.esperado {
+			t.Errorf("CPF(%q) = %q, %v; esperado %q, %v", c.entrada, obtido, ok, c.esperado, c.valido)
+		}
+	}
+}
+
+func TestTelefone(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+		valido   bool
+	}{
+		{"69999991234", "(69) 99999-1234", true},
+		{"(69) 3223-1234", "(69) 3223-1234", true},
+		{"+55 69 99999-1234", "(69) 99999-1234", true},
+		{"069999991234", "(69) 99999-1234", true},
+		{"6919991234", "", false},  // fixo começando por 1
+		{"1099991234", "", false},  // DDD com zero
+		{"69899991234", "", false}, // 9 dígitos sem começar por 9
+		{"999991234", "", false},   // sem DDD
+		{"", "", false},
+	}
+	for _, c := range casos {
+		obtido, ok := Telefone(c.entrada)
+		if ok != c.valido || obtido != c.esperado {
+			t.Errorf("Telefone(%q) = %q, %v; esperado %q, %v", c.entrada, obtido, ok, c.esperado, c.valido)
+		}
+	}
+}
+
+func TestCEP(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+		valido   bool
+	}{
+		{"76801000", "76801-000", true},
+		{"76.801-000", "76801-000", true},
+		{"00000000", "", false},
+		{"7680100", "", false},
+	}
+	for _, c := range casos {
+		obtido, ok := CEP(c.entrada)
+		if ok != c.valido || obtido != c.esperado {
+			t.Errorf("CEP(%q) = %q, %v; esperado %q, %v", c.entrada, obtido, ok, c.esperado, c.valido)
+		}
+	}
+}
+
+func TestUF(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+		valido   bool
+	}{
+		{"RO", "RO", true},
+		{"ro", "RO", true},
+		{"Rondônia", "RO", true},
+		{"Distrito Federal", "DF", true},
+		{"XX", "", false},
+		{"Rondonia do Sul", "", false},
+	}
+	for _, c := range casos {
+		obtido, ok := UF(c.entrada)
+		if ok != c.valido || obtido != c.esperado {
+			t.Errorf("UF(%q) = %q, %v; esperado %q, %v", c.entrada, obtido, ok, c.esperado, c.valido)
+		}
+	}
+}
+
+func TestData(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado string
+		valido   bool
+	}{
+		{"15/03/2024", "15/03/2024", true},
+		{"2024-03-15", "15/03/2024", true},
+		{"15/03/2024 14:30", "15/03/2024 14:30", true},
+		{"5/3/2024", "05/03/2024", true},
+		{"31/02/2024", "", false},
+		{"15/03/1899", "", false},
+		{"15/03/2999", "", false},
+		{"ontem", "", false},
+	}
+	for _, c := range casos {
+		obtido, ok := Data(c.entrada)
+		if ok != c.valido || obtido != c.esperado {
+			t.Errorf("Data(%q) = %q, %v; esperado %q, %v", c.entrada, obtido, ok, c.esperado, c.valido)
+		}
+	}
+}
+
+func TestEnvolvido(t *testing.T) {
+	valido := func() models.Envolvido {
+		return models.Envolvido{
+			NumeroBO:          " 12345/2024 ",
+			TipoEnvolvido:     "SUSPEITO",
+			NomeCompleto:      "FULANO DE TAL",
+			CPF:               "52998224725",
+			TelefoneEnvolvido: "69999991234",
+			CEPFato:           "76801000",
+			UFEnvolvido:       "rondonia",
+			Nascimento:        "1990-01-02",
+			DataFato:          "15/03/2024",
+			Valor:             "1.234,56",
+			LatitudeFato:      "-8,76",
+			LongitudeFato:     "-63.90",
+		}
+	}
+
+	e := valido()
+	if erros := Envolvido(&e); erros != nil {
+		t.Fatalf("envolvido válido recusado: %v", erros)
+	}
+	if e.NumeroBO != "12345/2024" || e.CPF != "529.982.247-25" || e.TelefoneEnvolvido != "(69) 99999-1234" ||
+		e.CEPFato != "76801-000" || e.UFEnvolvido != "RO" || e.Nascimento != "02/01/1990" {
+		t.Errorf("campos não foram convertidos para a forma canônica: %+v", e)
+	}
+
+	casos := []struct {
+		campo   string
+		alterar func(*models.Envolvido)
+	}{
+		{"numero_do_bo", func(e *models.Envolvido) { e.NumeroBO = "  " }},
+		{"tipo_envolvido", func(e *models.Envolvido) { e.TipoEnvolvido = "" }},
+		{"nomecompleto", func(e *models.Envolvido) { e.NomeCompleto = "" }},
+		{"cpf", func(e *models.Envolvido) { e.CPF = "52998224724" }},
+		{"telefone_envolvido", func(e *models.Envolvido) { e.TelefoneEnvolvido = "1234" }},
+		{"cep_fato", func(e *models.Envolvido) { e.CEPFato = "123" }},
+		{"uf_envolvido", func(e *models.Envolvido) { e.UFEnvolvido = "XX" }},
+		{"nascimento", func(e *models.Envolvido) { e.Nascimento = "31/02/1990" }},
+		{"data_fato", func(e *models.Envolvido) { e.DataFato = "15/03/2999" }},
+		{"valor", func(e *models.Envolvido) { e.Valor = "abc" }},
+		{"valor", func(e *models.Envolvido) { e.Valor = "1000000000000" }},
+		{"latitude_fato", func(e *models.Envolvido) { e.LatitudeFato = "-91" }},
+		{"longitude_fato", func(e *models.Envolvido) { e.LongitudeFato = "180,5" }},
+		{"longitude_fato", func(e *models.Envolvido) { e.LongitudeFato = "oeste" }},
+	}
+	for _, c := range casos {
+		e := valido()
+		c.alterar(&e)
+		erros := Envolvido(&e)
+		if _, ok := erros[c.campo]; !ok || len(erros) != 1 {
+			t.Errorf("esperado erro apenas em %s, obtido %v", c.campo, erros)
+		}
+	}
+
+	// Campos opcionais em branco não são validados
+	e = valido()
+	e.CPF, e.TelefoneEnvolvido, e.Valor, e.LongitudeFato = " ", "", "", ""
+	if erros := Envolvido(&e); erros != nil {
+		t.Errorf("campos opcionais em branco recusados: %v", erros)
+	}
+	if e.CPF != "" {
+		t.Errorf("CPF em branco deveria ficar vazio, obtido %q", e.CPF)
+	}
+}
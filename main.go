@@ -3,8 +3,12 @@ package main
 import (
     "log"
     "net/http"
+    "os"
+    "os/signal"
+    "syscall"
     "fraudbase/internal/database"
     "fraudbase/internal/handlers"
+    "fraudbase/internal/jobs"
     "fraudbase/internal/middleware"
     "fraudbase/internal/repository"
     "github.com/gorilla/mux"
@@ -40,13 +44,35 @@ func corsMiddleware(next http.Handler) http.Handler {
 func main() {
     log.Println("Iniciando FraudBase API...")
 
-    // Conectar ao banco de dados (agora com migrações automáticas)
+    // Conectar ao banco de dados
     db, err := database.ConnectDB()
     if err != nil {
         log.Fatalf("Falha ao conectar com o banco de dados: %v", err)
     }
     defer db.Close()
 
+    // Subcomandos de linha de comando (ex.: fraudbase migrate status)
+    if len(os.Args) > 1 {
+        codigo := executarComando(db, os.Args[1:])
+        db.Close()
+        os.Exit(codigo)
+    }
+
+    // Aplicar migrações pendentes antes de aceitar requisições
+    if err := database.MigrateUp(db); err != nil {
+        log.Fatalf("Falha ao aplicar migrações: %v", err)
+    }
+    if err := database.MigrarCamposTipados(db); err != nil {
+        log.Fatalf("Falha ao converter campos tipados: %v", err)
+    }
+    // O preenchimento das colunas normalizadas pode demorar em bases grandes: roda
+    // em segundo plano, sem atrasar a subida da API (é retomado no próximo início)
+    go func() {
+        if err := database.NormalizarIdentificadores(db); err != nil {
+            log.Printf("Falha ao normalizar identificadores (será retomada no próximo início): %v", err)
+        }
+    }()
+
     log.Println("Banco de dados conectado e configurado com sucesso!")
 
     // Inicializar todos os repositórios (mantendo os existentes)
@@ -63,6 +89,31 @@ func main() {
     limpezaRepo := repository.NewLimpezaRepository(db)
     boStatsRepo := repository.NewBOStatisticsRepository(db)
     reincidenciaCelularRepo := repository.NewReincidenciaCelularRepository(db)
+    reincidenciaPIXRepo := repository.NewReincidenciaPIXRepository(db)
+    reincidenciaContaRepo := repository.NewReincidenciaContaRepository(db)
+    grafoRepo := repository.NewGrafoRepository(db)
+    aneisRepo := repository.NewAneisRepository(db)
+    auditoriaRepo := repository.NewAuditoriaRepository(db)
+    historicoRepo := repository.NewHistoricoRepository(db)
+    importacaoRepo := repository.NewImportacaoRepository(db, jobs.NomeProcesso("api"))
+    perfilImportacaoRepo := repository.NewPerfilImportacaoRepository(db)
+
+    // Jobs em background
+    aneisJob := jobs.NewAneisJob(aneisRepo)
+    aneisJob.Start()
+    checkpointAuditoriaJob := jobs.NewCheckpointAuditoriaJob(auditoriaRepo)
+    checkpointAuditoriaJob.Start()
+    importacaoJob := jobs.NewImportacaoJob(importacaoRepo, relatorioRepo)
+    importacaoJob.Start()
+
+    // Ao encerrar, apaga os uploads ainda guardados por este processo
+    go func() {
+        sinais := make(chan os.Signal, 1)
+        signal.Notify(sinais, syscall.SIGINT, syscall.SIGTERM)
+        <-sinais
+        importacaoJob.Encerrar()
+        os.Exit(0)
+    }()
 
     // Inicializar todos os handlers (mantendo os existentes)
     authHandler := handlers.NewAuthHandler(userRepo)
@@ -72,13 +123,20 @@ func main() {
     delegaciaHandler := handlers.NewDelegaciaHandler(delegaciaRepo)
     bancoHandler := handlers.NewBancoHandler(bancoRepo)
     envolvidoHandler := handlers.NewEnvolvidoHandler(envolvidoRepo)
-    consultaHandler := handlers.NewConsultaEnvolvidoHandler(consultaRepo)
+    consultaHandler := handlers.NewConsultaEnvolvidoHandler(consultaRepo, historicoRepo, auditoriaRepo)
     dashboardStatsHandler := handlers.NewDashboardStatsHandler(dashboardRepo)
-    reincidenciaHandler := handlers.NewReincidenciaHandler(reincidenciaRepo)
-    relatorioHandler := handlers.NewRelatorioHandler(relatorioRepo)
+    reincidenciaHandler := handlers.NewReincidenciaHandler(reincidenciaRepo, auditoriaRepo)
+    relatorioHandler := handlers.NewRelatorioHandler(relatorioRepo, importacaoRepo, perfilImportacaoRepo, importacaoJob)
     limpezaHandler := handlers.NewLimpezaHandler(limpezaRepo)
     boStatsHandler := handlers.NewBOStatisticsHandler(boStatsRepo)
-    reincidenciaCelularHandler := handlers.NewReincidenciaCelularHandler(reincidenciaCelularRepo)
+    reincidenciaCelularHandler := handlers.NewReincidenciaCelularHandler(reincidenciaCelularRepo, auditoriaRepo)
+    reincidenciaPIXHandler := handlers.NewReincidenciaPIXHandler(reincidenciaPIXRepo, auditoriaRepo)
+    reincidenciaContaHandler := handlers.NewReincidenciaContaHandler(reincidenciaContaRepo, auditoriaRepo)
+    grafoHandler := handlers.NewGrafoHandler(grafoRepo, auditoriaRepo)
+    aneisHandler := handlers.NewAneisHandler(aneisRepo, aneisJob, auditoriaRepo)
+    auditoriaHandler := handlers.NewAuditoriaHandler(auditoriaRepo)
+    importacaoHandler := handlers.NewImportacaoHandler(importacaoRepo, importacaoJob, auditoriaRepo)
+    perfilImportacaoHandler := handlers.NewPerfilImportacaoHandler(perfilImportacaoRepo)
     
     r := mux.NewRouter()
     
@@ -104,7 +162,11 @@ func main() {
     // Rotas principais da aplicação
     apiRouter.HandleFunc("/envolvidos", envolvidoHandler.CreateEnvolvido).Methods("POST", "OPTIONS")
     apiRouter.HandleFunc("/consulta-envolvidos", consultaHandler.GetEnvolvidos).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/consulta-envolvidos/export", consultaHandler.ExportarEnvolvidos).Methods("GET", "OPTIONS")
     apiRouter.HandleFunc("/consulta-envolvidos/{id}", consultaHandler.GetEnvolvidoById).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/consulta-envolvidos/{id}", envolvidoHandler.UpdateEnvolvido).Methods("PUT", "OPTIONS")
+    apiRouter.HandleFunc("/consulta-envolvidos/{id}", envolvidoHandler.DeleteEnvolvido).Methods("DELETE", "OPTIONS")
+    apiRouter.HandleFunc("/consulta-envolvidos/{id}/history", consultaHandler.GetHistoricoEnvolvido).Methods("GET", "OPTIONS")
     
     // Rotas de dashboard e estatísticas
     apiRouter.HandleFunc("/dashboard/vitimas-por-sexo", dashboardStatsHandler.GetVitimasPorSexo).Methods("GET", "OPTIONS")
@@ -117,9 +179,26 @@ func main() {
     // Rotas de reincidência
     apiRouter.HandleFunc("/reincidencia/cpf", reincidenciaHandler.GetReincidenciaPorCPF).Methods("GET", "OPTIONS")
     apiRouter.HandleFunc("/reincidencia/celular", reincidenciaCelularHandler.GetReincidenciaPorCelular).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/reincidencia/pix", reincidenciaPIXHandler.GetReincidenciaPorPIX).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/reincidencia/conta-bancaria", reincidenciaContaHandler.GetReincidenciaPorConta).Methods("GET", "OPTIONS")
+    
+    // Rota de análise de vínculos (grafo)
+    apiRouter.HandleFunc("/analise-vinculos", grafoHandler.GetGrafoVinculos).Methods("GET", "OPTIONS")
+    
+    // Rotas de anéis de fraude
+    apiRouter.HandleFunc("/rings", aneisHandler.GetAneis).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/rings/{id:[0-9]+}", aneisHandler.GetAnelByID).Methods("GET", "OPTIONS")
     
     // Rotas de relatórios e limpeza
     apiRouter.HandleFunc("/upload-relatorio", relatorioHandler.UploadRelatorio).Methods("POST", "OPTIONS")
+    apiRouter.HandleFunc("/imports", importacaoHandler.ListarImportacoes).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/imports/{id:[0-9]+}", importacaoHandler.GetImportacao).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/imports/{id:[0-9]+}", importacaoHandler.ReverterImportacao).Methods("DELETE", "OPTIONS")
+    apiRouter.HandleFunc("/imports/{id:[0-9]+}/confirm", importacaoHandler.ConfirmarImportacao).Methods("POST", "OPTIONS")
+    apiRouter.HandleFunc("/imports/{id:[0-9]+}/alteracoes", importacaoHandler.GetAlteracoes).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/imports/{id:[0-9]+}/rejections", importacaoHandler.GetRejeicoes).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/import-profiles", perfilImportacaoHandler.ListarPerfis).Methods("GET", "OPTIONS")
+    apiRouter.HandleFunc("/import-profiles/{id:[0-9]+}", perfilImportacaoHandler.GetPerfil).Methods("GET", "OPTIONS")
     apiRouter.HandleFunc("/clean-duplicates", limpezaHandler.LimparDuplicatasHandler).Methods("POST", "OPTIONS")
     apiRouter.HandleFunc("/bo-statistics", boStatsHandler.GetBOStatistics).Methods("GET", "OPTIONS")
     
@@ -139,6 +218,12 @@ func main() {
     apiRouter.Handle("/users", adminOnly(userHandler.UpdateUser)).Methods("PUT", "OPTIONS")
     apiRouter.Handle("/users", adminOnly(userHandler.CreateUser)).Methods("POST", "OPTIONS")
     apiRouter.Handle("/users/{id}", adminOnly(userHandler.DeleteUser)).Methods("DELETE", "OPTIONS")
+    apiRouter.Handle("/rings/recalcular", adminOnly(aneisHandler.RecalcularAneis)).Methods("POST", "OPTIONS")
+    apiRouter.Handle("/audit", adminOnly(auditoriaHandler.GetAuditoria)).Methods("GET", "OPTIONS")
+    apiRouter.Handle("/audit/verify", adminOnly(auditoriaHandler.VerificarAuditoria)).Methods("GET", "OPTIONS")
+    apiRouter.Handle("/import-profiles", adminOnly(perfilImportacaoHandler.CriarPerfil)).Methods("POST", "OPTIONS")
+    apiRouter.Handle("/import-profiles/{id:[0-9]+}", adminOnly(perfilImportacaoHandler.AtualizarPerfil)).Methods("PUT", "OPTIONS")
+    apiRouter.Handle("/import-profiles/{id:[0-9]+}", adminOnly(perfilImportacaoHandler.ExcluirPerfil)).Methods("DELETE", "OPTIONS")
     
     // Proteção de rotas de settings adicionadas futuramente
     apiRouter.Handle("/settings/users", adminOnly(userHandler.GetAllUsers)).Methods("GET", "OPTIONS")
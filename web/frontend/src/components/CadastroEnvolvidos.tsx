@@ -216,6 +216,10 @@ const CadastroEnvolvidos = () => {
       const data = await response.json();
 
       if (!response.ok) {
+        // 422: o servidor devolve a mensagem de cada campo inválido
+        if (response.status === 422 && data.errors) {
+          setErrors(data.errors);
+        }
         setAlert({
           open: true,
           message: data.message || 'Erro ao cadastrar envolvido',
@@ -224,9 +228,13 @@ const CadastroEnvolvidos = () => {
         return;
       }
 
+      // O BO ou a pessoa já cadastrados mantêm os dados gravados primeiro
+      const mantidos: string[] = data.campos_mantidos || [];
       setAlert({
         open: true,
-        message: 'Envolvido cadastrado com sucesso!',
+        message: mantidos.length > 0
+          ? `Envolvido cadastrado. O BO ou a pessoa já existiam e mantiveram os valores cadastrados em: ${mantidos.join(', ')}.`
+          : 'Envolvido cadastrado com sucesso!',
         severity: 'success'
       });
 
@@ -373,6 +381,8 @@ const CadastroEnvolvidos = () => {
                   value={formData.nascimento}
                   onChange={handleChange}
                   variant="outlined"
+                  error={!!errors.nascimento}
+                  helperText={errors.nascimento}
                   InputLabelProps={{ shrink: true }}
                 />
               </Grid>
@@ -404,6 +414,8 @@ const CadastroEnvolvidos = () => {
                   value={formData.uf_envolvido}
                   onChange={handleChange}
                   variant="outlined"
+                  error={!!errors.uf_envolvido}
+                  helperText={errors.uf_envolvido}
                 />
               </Grid>
               <Grid item xs={12} md={6}>
@@ -460,6 +472,8 @@ const CadastroEnvolvidos = () => {
                   value={formData.data_fato}
                   onChange={handleChange}
                   variant="outlined"
+                  error={!!errors.data_fato}
+                  helperText={errors.data_fato}
                   InputLabelProps={{ shrink: true }}
                 />
               </Grid>
@@ -471,6 +485,8 @@ const CadastroEnvolvidos = () => {
                   value={formData.cep_fato}
                   onChange={handleChange}
                   variant="outlined"
+                  error={!!errors.cep_fato}
+                  helperText={errors.cep_fato}
                 />
               </Grid>
               <Grid item xs={12} md={6}>
@@ -481,6 +497,8 @@ const CadastroEnvolvidos = () => {
                   value={formData.latitude_fato}
                   onChange={handleChange}
                   variant="outlined"
+                  error={!!errors.latitude_fato}
+                  helperText={errors.latitude_fato}
                 />
               </Grid>
               <Grid item xs={12} md={6}>
@@ -491,6 +509,8 @@ const CadastroEnvolvidos = () => {
                   value={formData.longitude_fato}
                   onChange={handleChange}
                   variant="outlined"
+                  error={!!errors.longitude_fato}
+                  helperText={errors.longitude_fato}
                 />
               </Grid>
               <Grid item xs={12} md={6}>
@@ -660,6 +680,8 @@ const CadastroEnvolvidos = () => {
                   value={formData.valor}
                   onChange={handleChange}
                   variant="outlined"
+                  error={!!errors.valor}
+                  helperText={errors.valor}
                 />
               </Grid>
               <Grid item xs={12} md={6}>
@@ -40,6 +40,7 @@ import AssignmentIndIcon from '@mui/icons-material/AssignmentInd';
 import CreditCardIcon from '@mui/icons-material/CreditCard';
 import ContentPasteIcon from '@mui/icons-material/ContentPaste';
 import PhoneIcon from '@mui/icons-material/Phone';
+import FileDownloadIcon from '@mui/icons-material/FileDownload';
 
 import API_BASE_URL from '../config/api';
 
@@ -196,6 +197,31 @@ interface PaginatedResponse {
   totalPages: number;
 }
 
+// Filtros da consulta com dados suficientes, como enviados à API (busca e exportação)
+const parametrosFiltros = (filters: { nome: string; cpf: string; bo: string; telefone: string }): URLSearchParams => {
+  const queryParams = new URLSearchParams();
+
+  if (filters.nome && filters.nome.length >= 3) {
+    // Normalizar o nome para remover acentos antes de enviar
+    queryParams.append('nome', filters.nome);
+  }
+
+  if (filters.cpf && filters.cpf.replace(/\D/g, '').length >= 11) {
+    // Enviar apenas os números do CPF para o backend
+    queryParams.append('cpf', filters.cpf.replace(/\D/g, ''));
+  }
+
+  if (filters.bo && filters.bo.length >= 3) {
+    queryParams.append('bo', filters.bo);
+  }
+
+  if (filters.telefone && filters.telefone.length >= 3) {
+    queryParams.append('telefone', filters.telefone);
+  }
+
+  return queryParams;
+};
+
 const ConsultaEnvolvidos = () => {
 
   // Estados para os filtros de busca
@@ -211,6 +237,7 @@ const ConsultaEnvolvidos = () => {
 
   // Estados para paginação real no servidor
   const [totalCount, setTotalCount] = useState(0);
+  const [exporting, setExporting] = useState(false);
   const [, setTotalPages] = useState(0);
   const [currentPage, setCurrentPage] = useState(1);
   const [rowsPerPage, setRowsPerPage] = useState(20);
@@ -243,25 +270,7 @@ const ConsultaEnvolvidos = () => {
 
     setLoading(true);
     try {
-      const queryParams = new URLSearchParams();
-
-      if (filters.nome && filters.nome.length >= 3) {
-        // Normalizar o nome para remover acentos antes de enviar
-        queryParams.append('nome', filters.nome);
-      }
-
-      if (filters.cpf && filters.cpf.replace(/\D/g, '').length >= 11) {
-        // Enviar apenas os números do CPF para o backend
-        queryParams.append('cpf', filters.cpf.replace(/\D/g, ''));
-      }
-
-      if (filters.bo && filters.bo.length >= 3) {
-        queryParams.append('bo', filters.bo);
-      }
-
-      if (filters.telefone && filters.telefone.length >= 3) {
-        queryParams.append('telefone', filters.telefone);
-      }
+      const queryParams = parametrosFiltros(filters);
 
       // Adicionar parâmetros de paginação
       queryParams.append('page', pageNum.toString());
@@ -312,6 +321,42 @@ const ConsultaEnvolvidos = () => {
     }
   }, [filters.nome, filters.cpf, filters.bo, filters.telefone, rowsPerPage]);
 
+  // Baixa todos os resultados dos filtros atuais (não só a página exibida)
+  const handleExport = async (formato: 'xlsx' | 'csv') => {
+    setExporting(true);
+    try {
+      const queryParams = parametrosFiltros(filters);
+      queryParams.append('format', formato);
+
+      const response = await fetch(`${API_BASE_URL}/consulta-envolvidos/export?${queryParams.toString()}`, {
+        headers: {
+          'Authorization': `Bearer ${sessionStorage.getItem('token')}`
+        }
+      });
+
+      if (!response.ok) {
+        const data: { error?: string } = await response.json().catch(() => ({}));
+        throw new Error(data.error || 'Falha ao exportar envolvidos');
+      }
+
+      const url = URL.createObjectURL(await response.blob());
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = `envolvidos.${formato}`;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (error) {
+      console.error('Erro ao exportar envolvidos:', error);
+      setAlert({
+        open: true,
+        message: error instanceof Error ? error.message : 'Erro ao exportar envolvidos.',
+        severity: 'error'
+      });
+    } finally {
+      setExporting(false);
+    }
+  };
+
   // Função para atualizar os filtros SEM busca automática
   const handleFilterChange = (e: React.ChangeEvent<HTMLInputElement>) => {
     const { name, value } = e.target;
@@ -659,6 +704,30 @@ const ConsultaEnvolvidos = () => {
                 }}
               />
             )}
+            {totalCount > 0 && (
+              <Box sx={{ ml: 'auto', display: 'flex', gap: 1 }}>
+                <Button
+                  size="small"
+                  variant="outlined"
+                  startIcon={<FileDownloadIcon />}
+                  onClick={() => handleExport('xlsx')}
+                  disabled={exporting}
+                  sx={{ color: GOLD_COLOR, borderColor: alpha(GOLD_COLOR, 0.5) }}
+                >
+                  Excel
+                </Button>
+                <Button
+                  size="small"
+                  variant="outlined"
+                  startIcon={<FileDownloadIcon />}
+                  onClick={() => handleExport('csv')}
+                  disabled={exporting}
+                  sx={{ color: GOLD_COLOR, borderColor: alpha(GOLD_COLOR, 0.5) }}
+                >
+                  CSV
+                </Button>
+              </Box>
+            )}
           </Typography>
 
           <TableContainer sx={{ maxHeight: 450 }}>
@@ -10,7 +10,11 @@ import {
   Grid,
   alpha,
   styled,
-  Container
+  Container,
+  Checkbox,
+  FormControlLabel,
+  TextField,
+  MenuItem
 } from '@mui/material'; import { useState, useEffect } from 'react';
 
 import UploadFileIcon from '@mui/icons-material/UploadFile';
@@ -110,29 +114,115 @@ interface BoData {
   numero_do_bo: string;
 }
 
-// Interface de resposta do upload atualizada
+// Resposta do upload: o arquivo é processado em background
 interface UploadResponse {
   success: boolean;
   message: string;
-  registrosInseridos?: number;
-  duplicatasEvitadas?: number;  // NOVO
-  totalProcessados?: number;    // NOVO
+  error?: string;
+  importId?: number;
+  status?: string;
 }
 
+// Situação de uma importação (GET /imports/{id})
+interface Importacao {
+  id: number;
+  status: 'previa' | 'expirada' | 'pendente' | 'processando' | 'concluido' | 'erro';
+  // Resultado da transação: tudo gravado, gravação parcial com lotes descartados ou nada gravado
+  transacao?: 'confirmada' | 'parcial' | 'desfeita';
+  modo?: 'inclusao' | 'atualizacao';
+  total_registros: number;
+  processados: number;
+  inseridos: number;
+  atualizados?: number;
+  duplicados: number;
+  erros: string[];
+  // Resultado da prévia, quando já calculada
+  previa?: PreviaImportacao;
+}
+
+// Alterações de um BO no modo de atualização
+interface AlteracaoBO {
+  numero_do_bo: string;
+  novo: boolean;
+  ocorrencia?: Record<string, { antes: string | null; depois: string | null }>;
+  participantes_novos?: { nomecompleto: string; tipo_envolvido: string }[];
+  participantes_alterados?: { nomecompleto: string; tipo_envolvido: string; alteracoes: Record<string, unknown> }[];
+}
+
+// Prévia do upload com dryRun=true (nada é gravado), calculada em background e
+// retornada em previa no GET /imports/{id}. No modo de
+// atualização vêm as contagens e as alterações por BO em vez das listas de registros.
+// As listas trazem só os primeiros itens; os totais vêm à parte.
+interface PreviaImportacao {
+  importId: number;
+  expiraEm: string;
+  modo: 'inclusao' | 'atualizacao';
+  totalRegistros: number;
+  totalNovos?: number;
+  totalDuplicatas?: number;
+  registros: Record<string, string>[];
+  duplicatas: Record<string, string>[];
+  inseridos?: number;
+  atualizados?: number;
+  duplicados?: number;
+  alteracoes?: AlteracaoBO[];
+  linhasIgnoradas: { planilha: string; linha: number; motivo: string }[];
+  totalLinhasIgnoradas?: number;
+  avisos: string[];
+}
+
+// Leiaute de relatório aceito no upload (GET /api/import-profiles)
+interface PerfilImportacao {
+  id: number;
+  nome: string;
+  descricao: string;
+  padrao_arquivo: string;
+  padrao: boolean;
+}
+
+const INTERVALO_CONSULTA_IMPORTACAO = 2000;
+const REGISTROS_EXIBIDOS_PREVIA = 10;
+
 const UploadRelatorio = () => {
   const [file, setFile] = useState<File | null>(null);
   const [loading, setLoading] = useState<boolean>(false);
-  const [result, setResult] = useState<{ success: boolean, message: string } | null>(null);
+  const [result, setResult] = useState<{ success: boolean, message: string, importId?: number } | null>(null);
   const [newestBO, setNewestBO] = useState<BoData | null>(null);
   const [oldestBO, setOldestBO] = useState<BoData | null>(null);
   const [loadingStats, setLoadingStats] = useState<boolean>(true);
   const [statsError, setStatsError] = useState<string | null>(null);
-
-  // Buscar dados de BO do backend quando o componente montar
+  const [progresso, setProgresso] = useState<Importacao | null>(null);
+  const [previa, setPrevia] = useState<PreviaImportacao | null>(null);
+  const [gravacaoParcial, setGravacaoParcial] = useState<boolean>(false);
+  const [modoAtualizacao, setModoAtualizacao] = useState<boolean>(false);
+  const [perfis, setPerfis] = useState<PerfilImportacao[]>([]);
+  const [perfil, setPerfil] = useState<string>('');
+
+  // Buscar dados de BO e perfis de importação do backend quando o componente montar
   useEffect(() => {
     fetchBOStats();
+    fetchPerfis();
   }, []);
 
+  // Sem perfil escolhido o backend usa o perfil padrão
+  const fetchPerfis = async () => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/import-profiles`, {
+        headers: {
+          'Authorization': `Bearer ${sessionStorage.getItem('token')}`
+        }
+      });
+      if (!response.ok) return;
+
+      const data: PerfilImportacao[] = await response.json();
+      setPerfis(data);
+      const padrao = data.find((p) => p.padrao);
+      if (padrao) setPerfil(padrao.nome);
+    } catch (error) {
+      console.error('Erro ao buscar perfis de importação:', error);
+    }
+  };
+
   // Função para buscar as estatísticas dos BOs
   const fetchBOStats = async () => {
     setLoadingStats(true);
@@ -165,19 +255,29 @@ const UploadRelatorio = () => {
     if (event.target.files && event.target.files.length > 0) {
       setFile(event.target.files[0]);
       setResult(null);
+      setPrevia(null);
     }
   };
 
-  const handleUpload = async () => {
+  // Envia o arquivo; com dryRun apenas exibe a prévia, sem gravar nada
+  const handleUpload = async (dryRun: boolean) => {
     if (!file) return;
 
     const formData = new FormData();
     formData.append('relatorio', file);
     setLoading(true);
     setResult(null);
+    setPrevia(null);
 
     try {
-      const response = await fetch(`${API_BASE_URL}/upload-relatorio`, {
+      const params = new URLSearchParams();
+      if (dryRun) params.set('dryRun', 'true');
+      if (gravacaoParcial) params.set('gravacaoParcial', 'true');
+      if (modoAtualizacao) params.set('modo', 'atualizacao');
+      if (perfil) params.set('perfil', perfil);
+      const query = params.toString();
+
+      const response = await fetch(`${API_BASE_URL}/upload-relatorio${query ? `?${query}` : ''}`, {
         method: 'POST',
         headers: {
           'Authorization': `Bearer ${sessionStorage.getItem('token')}`
@@ -185,45 +285,175 @@ const UploadRelatorio = () => {
         body: formData,
       });
 
-      const data: UploadResponse = await response.json(); // TIPAGEM ADICIONADA
+      const data: UploadResponse & Partial<PreviaImportacao> = await response.json(); // TIPAGEM ADICIONADA
 
-      if (response.ok) {
-        // MENSAGEM MELHORADA COM INFORMAÇÕES DE DUPLICATAS
-        let message = `Upload realizado com sucesso! ${data.registrosInseridos || 0} registros foram inseridos.`;
+      if (!response.ok || !data.importId) {
+        setResult({
+          success: false,
+          message: data.error || data.message || 'Erro ao processar o arquivo.'
+        });
+      } else if (dryRun) {
+        await acompanharPrevia(data.importId);
+      } else {
+        await concluirImportacao(data.importId);
+      }
+    } catch (error) {
+      console.error('Erro durante o upload:', error);
+      setResult({
+        success: false,
+        message: 'Erro de conexão com o servidor.'
+      });
+    } finally {
+      setLoading(false);
+      setProgresso(null);
+    }
+  };
 
-        if (data.duplicatasEvitadas && data.duplicatasEvitadas > 0) {
-          message += ` ${data.duplicatasEvitadas} duplicatas foram automaticamente evitadas.`;
-        }
+  // Confirma a prévia exibida, sem reenviar o arquivo
+  const handleConfirmar = async () => {
+    if (!previa) return;
+
+    setLoading(true);
+    setResult(null);
 
-        if (data.totalProcessados) {
-          message += ` (${data.totalProcessados} registros processados no total)`;
+    try {
+      const response = await fetch(`${API_BASE_URL}/imports/${previa.importId}/confirm`, {
+        method: 'POST',
+        headers: {
+          'Authorization': `Bearer ${sessionStorage.getItem('token')}`
         }
+      });
 
-        setResult({
-          success: true,
-          message: message
-        });
+      const data: UploadResponse = await response.json();
+      setPrevia(null);
 
-        // Atualizar estatísticas após upload bem-sucedido
-        fetchBOStats();
+      if (response.ok) {
+        await concluirImportacao(previa.importId);
       } else {
         setResult({
           success: false,
-          message: data.message || 'Erro ao processar o arquivo.'
+          message: data.error || data.message || 'Erro ao confirmar a importação.'
         });
       }
     } catch (error) {
-      console.error('Erro durante o upload:', error);
+      console.error('Erro ao confirmar importação:', error);
       setResult({
         success: false,
         message: 'Erro de conexão com o servidor.'
       });
     } finally {
       setLoading(false);
+      setProgresso(null);
     }
   };
 
+  // Aguarda o processamento em background e exibe o resultado
+  const concluirImportacao = async (id: number) => {
+    const importacao = await acompanharImportacao(id);
+
+    if (importacao.status === 'concluido') {
+      // MENSAGEM MELHORADA COM INFORMAÇÕES DE DUPLICATAS
+      let message = `Upload realizado com sucesso! ${importacao.inseridos} registros foram inseridos.`;
+
+      if (importacao.modo === 'atualizacao') {
+        message += ` ${importacao.atualizados ?? 0} registros existentes foram atualizados.`;
+      }
+
+      if (importacao.duplicados > 0) {
+        message += ` ${importacao.duplicados} duplicatas foram automaticamente evitadas.`;
+      }
+
+      if (importacao.total_registros) {
+        message += ` (${importacao.total_registros} registros processados no total)`;
+      }
 
+      if (importacao.transacao === 'parcial') {
+        message += ` Gravação parcial: ${importacao.erros.join(' ')}`;
+      } else if (importacao.erros.length > 0) {
+        // Avisos da gravação confirmada (valores não convertidos)
+        message += ` Avisos: ${importacao.erros.join(' ')}`;
+      }
+
+      setResult({
+        success: true,
+        message: message,
+        importId: importacao.id
+      });
+    } else {
+      setResult({
+        success: false,
+        message: importacao.erros.join(' ') || 'Erro ao processar o arquivo. Nenhum registro foi gravado.'
+      });
+    }
+
+    // Atualizar estatísticas após a importação
+    fetchBOStats();
+  };
+
+  // Baixa a planilha com as linhas que a importação não gravou
+  const baixarRejeicoes = async (id: number) => {
+    try {
+      const response = await fetch(`${API_BASE_URL}/imports/${id}/rejections`, {
+        headers: {
+          'Authorization': `Bearer ${sessionStorage.getItem('token')}`
+        }
+      });
+      if (!response.ok) {
+        // A importação que falhou sem rejeições de linhas devolve o motivo da falha
+        const data: Partial<UploadResponse> = await response.json().catch(() => ({}));
+        throw new Error(data.error || 'Falha ao baixar as rejeições');
+      }
+
+      const url = URL.createObjectURL(await response.blob());
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = `rejeicoes_importacao_${id}.xlsx`;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (error) {
+      console.error('Erro ao baixar rejeições:', error);
+      setResult(atual => atual && { ...atual, message: (error as Error).message });
+    }
+  };
+
+  // Aguarda o cálculo da prévia em background e a exibe
+  const acompanharPrevia = async (id: number) => {
+    const importacao = await acompanharImportacao(id, imp => imp.status !== 'previa' || imp.previa !== undefined);
+
+    if (importacao.status === 'previa' && importacao.previa) {
+      setPrevia({ registros: [], duplicatas: [], ...importacao.previa, importId: id });
+    } else {
+      setResult({
+        success: false,
+        message: importacao.erros.join(' ') || 'Erro ao calcular a prévia. Nenhum registro foi gravado.'
+      });
+    }
+  };
+
+  // Consulta a importação até que termine, atualizando o progresso exibido
+  const acompanharImportacao = async (
+    id: number,
+    terminou: (importacao: Importacao) => boolean = imp => imp.status === 'concluido' || imp.status === 'erro'
+  ): Promise<Importacao> => {
+    for (;;) {
+      await new Promise(resolve => setTimeout(resolve, INTERVALO_CONSULTA_IMPORTACAO));
+
+      const response = await fetch(`${API_BASE_URL}/imports/${id}`, {
+        headers: {
+          'Authorization': `Bearer ${sessionStorage.getItem('token')}`
+        }
+      });
+      if (!response.ok) {
+        throw new Error('Falha ao consultar a importação');
+      }
+
+      const importacao: Importacao = await response.json();
+      setProgresso(importacao);
+      if (terminou(importacao)) {
+        return importacao;
+      }
+    }
+  };
 
   // Renderizar o componente de estatísticas de BO
   const renderBOStats = () => {
@@ -413,7 +643,7 @@ const UploadRelatorio = () => {
           </Box>
 
           <Typography variant="body1" sx={{ mb: 3, color: '#ccc' }}>
-            Faça upload do arquivo de relatório no formato Excel (.xlsx) para importação automática dos dados.
+            Faça upload do arquivo de relatório (Excel .xlsx ou .xls, planilha .ods, CSVs compactados em .zip ou .json) para importação automática dos dados.
           </Typography>
 
           <Box sx={{
@@ -428,7 +658,7 @@ const UploadRelatorio = () => {
             border: `1px dashed ${alpha(GOLD_COLOR, 0.3)}`
           }}>
             <input
-              accept=".xlsx"
+              accept=".xlsx,.xls,.ods,.zip,.json"
               style={{ display: 'none' }}
               id="upload-relatorio"
               type="file"
@@ -475,27 +705,158 @@ const UploadRelatorio = () => {
               </Box>
             )}
 
-            <PrimaryButton
-              variant="contained"
-              onClick={handleUpload}
-              disabled={!file || loading}
-              sx={{ mt: 3 }}
-              startIcon={loading ? <CircularProgress size={20} color="inherit" /> : null}
-            >
-              {loading ? 'Processando...' : 'Processar Arquivo'}
-            </PrimaryButton>
+            {perfis.length > 0 && (
+              <TextField
+                select
+                size="small"
+                label="Perfil do relatório"
+                value={perfil}
+                onChange={(e) => setPerfil(e.target.value)}
+                helperText={perfis.find((p) => p.nome === perfil)?.descricao}
+                sx={{
+                  mt: 2,
+                  minWidth: 300,
+                  '& .MuiInputBase-root': { color: 'white' },
+                  '& .MuiInputLabel-root': { color: '#ccc' },
+                  '& .MuiFormHelperText-root': { color: '#aaa' }
+                }}
+              >
+                {perfis.map((p) => (
+                  <MenuItem key={p.id} value={p.nome}>{p.nome}</MenuItem>
+                ))}
+              </TextField>
+            )}
+
+            <FormControlLabel
+              sx={{ mt: 2, color: '#ccc' }}
+              control={
+                <Checkbox
+                  checked={gravacaoParcial}
+                  onChange={(e) => setGravacaoParcial(e.target.checked)}
+                  sx={{ color: GOLD_COLOR, '&.Mui-checked': { color: GOLD_COLOR } }}
+                />
+              }
+              label="Gravação parcial (descartar apenas os lotes com erro em vez de toda a importação)"
+            />
+            <FormControlLabel
+              sx={{ color: '#ccc' }}
+              control={
+                <Checkbox
+                  checked={modoAtualizacao}
+                  onChange={(e) => setModoAtualizacao(e.target.checked)}
+                  sx={{ color: GOLD_COLOR, '&.Mui-checked': { color: GOLD_COLOR } }}
+                />
+              }
+              label="Atualizar BOs já importados (situação, dados do fato e novos envolvidos)"
+            />
+
+            <Box sx={{ display: 'flex', gap: 2, mt: 1 }}>
+              <Button
+                variant="outlined"
+                onClick={() => handleUpload(true)}
+                disabled={!file || loading}
+                sx={{ color: GOLD_COLOR, borderColor: GOLD_COLOR }}
+              >
+                Pré-visualizar
+              </Button>
+              <PrimaryButton
+                variant="contained"
+                onClick={() => handleUpload(false)}
+                disabled={!file || loading}
+                startIcon={loading ? <CircularProgress size={20} color="inherit" /> : null}
+              >
+                {loading ? 'Processando...' : 'Processar Arquivo'}
+              </PrimaryButton>
+            </Box>
           </Box>
 
+          {progresso && (
+            <Typography variant="body2" sx={{ color: '#ccc', mb: 2 }}>
+              {progresso.status === 'previa'
+                ? 'Calculando a prévia da importação...'
+                : progresso.status === 'pendente'
+                ? 'Arquivo na fila de processamento...'
+                : `Processados ${progresso.processados} de ${progresso.total_registros} registros (${progresso.inseridos} inseridos, ${progresso.duplicados} duplicatas)`}
+            </Typography>
+          )}
+
           {result && (
             <Alert
               severity={result.success ? 'success' : 'error'}
               variant="filled"
               sx={{ mt: 2, mb: 3 }}
+              action={result.importId !== undefined && (
+                <Button color="inherit" size="small" onClick={() => baixarRejeicoes(result.importId!)}>
+                  Baixar rejeições
+                </Button>
+              )}
             >
               {result.message}
             </Alert>
           )}
 
+          {previa && (
+            <Box sx={{ mt: 2, mb: 3, p: 2, borderRadius: 2, bgcolor: alpha('#2c2c2c', 0.5) }}>
+              <Typography variant="subtitle1" sx={{ color: 'white', fontWeight: 'bold' }} gutterBottom>
+                Prévia da importação (nada foi gravado)
+              </Typography>
+              <Typography variant="body2" sx={{ color: '#ccc' }}>
+                {previa.modo === 'atualizacao'
+                  ? `${previa.totalRegistros} registros lidos: ${previa.inseridos ?? 0} novos, ${previa.atualizados ?? 0} atualizados, ${previa.duplicados ?? 0} sem alteração e ${previa.totalLinhasIgnoradas ?? previa.linhasIgnoradas.length} linhas ignoradas.`
+                  : `${previa.totalRegistros} registros lidos: ${previa.totalNovos ?? previa.registros.length} novos, ${previa.totalDuplicatas ?? previa.duplicatas.length} duplicatas e ${previa.totalLinhasIgnoradas ?? previa.linhasIgnoradas.length} linhas ignoradas.`}
+                {' '}A prévia pode ser confirmada até {new Date(previa.expiraEm).toLocaleTimeString('pt-BR')}.
+              </Typography>
+
+              {previa.avisos.map((aviso, i) => (
+                <Alert key={i} severity="warning" sx={{ mt: 1 }}>{aviso}</Alert>
+              ))}
+
+              {previa.linhasIgnoradas.length > 0 && (
+                <Typography variant="body2" component="div" sx={{ color: '#ccc', mt: 2 }}>
+                  <strong>Linhas ignoradas:</strong>
+                  {previa.linhasIgnoradas.slice(0, REGISTROS_EXIBIDOS_PREVIA).map((l, i) => (
+                    <div key={i}>• {l.planilha}, linha {l.linha}: {l.motivo}</div>
+                  ))}
+                  {(previa.totalLinhasIgnoradas ?? previa.linhasIgnoradas.length) > REGISTROS_EXIBIDOS_PREVIA && <div>…</div>}
+                </Typography>
+              )}
+
+              {previa.registros.length > 0 && (
+                <Typography variant="body2" component="div" sx={{ color: '#ccc', mt: 2 }}>
+                  <strong>Registros que serão inseridos:</strong>
+                  {previa.registros.slice(0, REGISTROS_EXIBIDOS_PREVIA).map((reg, i) => (
+                    <div key={i}>• BO {reg.numero_do_bo} — {reg.tipo_envolvido || 'sem envolvido'} — {reg.nomecompleto}</div>
+                  ))}
+                  {(previa.totalNovos ?? previa.registros.length) > REGISTROS_EXIBIDOS_PREVIA && <div>…</div>}
+                </Typography>
+              )}
+
+              {previa.alteracoes && previa.alteracoes.length > 0 && (
+                <Typography variant="body2" component="div" sx={{ color: '#ccc', mt: 2 }}>
+                  <strong>BOs que serão alterados:</strong>
+                  {previa.alteracoes.slice(0, REGISTROS_EXIBIDOS_PREVIA).map((bo, i) => (
+                    <div key={i}>
+                      • BO {bo.numero_do_bo}{bo.novo ? ' (novo)' : ''}
+                      {bo.ocorrencia && ` — campos: ${Object.keys(bo.ocorrencia).join(', ')}`}
+                      {bo.participantes_novos && ` — ${bo.participantes_novos.length} envolvidos novos`}
+                      {bo.participantes_alterados && ` — ${bo.participantes_alterados.length} envolvidos alterados`}
+                    </div>
+                  ))}
+                  {previa.alteracoes.length > REGISTROS_EXIBIDOS_PREVIA && <div>…</div>}
+                </Typography>
+              )}
+
+              <Box sx={{ display: 'flex', gap: 2, mt: 2 }}>
+                <PrimaryButton variant="contained" onClick={handleConfirmar} disabled={loading}>
+                  Confirmar importação
+                </PrimaryButton>
+                <Button variant="outlined" onClick={() => setPrevia(null)} disabled={loading} sx={{ color: '#ccc', borderColor: '#666' }}>
+                  Descartar
+                </Button>
+              </Box>
+            </Box>
+          )}
+
           <GoldDivider />
 
           <Box sx={{ mt: 3, mb: 2 }}>